@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/store"
+	"strings"
+)
+
+// backend is an opened store. save writes snapshot destinations back to
+// disk; close releases connections.
+type backend struct {
+	store.Store
+	desc  string
+	save  func() error
+	close func() error
+}
+
+func noop() error { return nil }
+
+// openBackend opens a store from a spec of the form kind[:arg]:
+//
+//	file[:dir]                     file store, DATA_DIR or ./data by default
+//	firestore[:project[/database]] Firestore, GCP_PROJECT_ID and FIRESTORE_DATABASE by default
+//	sqlite[:path]                  SQLite database, SQLITE_PATH by default
+//	postgres[:url]                 Postgres database, DATABASE_URL by default
+//	snapshot:path.json             JSON snapshot loaded into a memory store
+func openBackend(ctx context.Context, spec string) (*backend, error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "file":
+		dir := arg
+		if dir == "" {
+			dir = os.Getenv("DATA_DIR")
+		}
+		if dir == "" {
+			dir = "./data"
+		}
+		fs, err := store.NewFileStore(dir)
+		if err != nil {
+			return nil, fmt.Errorf("opening file store: %w", err)
+		}
+		return &backend{Store: fs, desc: "file store " + dir, save: noop, close: noop}, nil
+
+	case "firestore":
+		projectID, databaseID, _ := strings.Cut(arg, "/")
+		if projectID == "" {
+			projectID = os.Getenv("GCP_PROJECT_ID")
+			databaseID = os.Getenv("FIRESTORE_DATABASE")
+		}
+		if projectID == "" {
+			return nil, fmt.Errorf("firestore needs a project: firestore:<project>[/<database>] or GCP_PROJECT_ID")
+		}
+		fs, err := store.NewFirestoreStore(ctx, projectID, databaseID)
+		if err != nil {
+			return nil, fmt.Errorf("opening Firestore: %w", err)
+		}
+		if databaseID == "" {
+			databaseID = "(default)"
+		}
+		desc := fmt.Sprintf("Firestore (project: %s, database: %s)", projectID, databaseID)
+		return &backend{Store: fs, desc: desc, save: noop, close: fs.Close}, nil
+
+	case "sqlite":
+		path := arg
+		if path == "" {
+			path = os.Getenv("SQLITE_PATH")
+		}
+		if path == "" {
+			return nil, fmt.Errorf("sqlite needs a path: sqlite:<file.db> or SQLITE_PATH")
+		}
+		ss, err := store.NewSQLiteStore(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return &backend{Store: ss, desc: "SQLite " + path, save: noop, close: ss.Close}, nil
+
+	case "postgres", "postgresql":
+		url := arg
+		if strings.HasPrefix(arg, "//") {
+			// A bare postgres://... URL.
+			url = spec
+		}
+		if url == "" {
+			url = os.Getenv("DATABASE_URL")
+		}
+		if url == "" {
+			return nil, fmt.Errorf("postgres needs a URL: postgres:<url> or DATABASE_URL")
+		}
+		ps, err := store.NewPostgresStore(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		return &backend{Store: ps, desc: "Postgres", save: noop, close: ps.Close}, nil
+
+	case "snapshot":
+		if arg == "" {
+			return nil, fmt.Errorf("snapshot needs a path: snapshot:<file.json>")
+		}
+		ms := store.NewMemoryStore()
+		if err := readSnapshot(ctx, arg, ms); err != nil {
+			return nil, err
+		}
+		return &backend{
+			Store: ms,
+			desc:  "snapshot " + arg,
+			save:  func() error { return writeSnapshot(ctx, arg, ms) },
+			close: noop,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown store %q (want file, firestore, sqlite, postgres or snapshot)", spec)
+}
+
+// readSnapshot restores a snapshot file into s. A missing file is an empty
+// snapshot, so that a new one can be written as a destination.
+func readSnapshot(ctx context.Context, path string, s store.Store) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading snapshot: %w", err)
+	}
+	var d dataset
+	if err := json.Unmarshal(data, &d); err != nil {
+		return fmt.Errorf("parsing snapshot %s: %w", path, err)
+	}
+
+	for _, t := range d.Tournaments {
+		if err := s.ImportTournament(ctx, t); err != nil {
+			return err
+		}
+	}
+	for _, e := range d.Audit {
+		if err := s.AppendAuditEntry(ctx, e); err != nil {
+			return err
+		}
+	}
+	for _, u := range d.RegisteredUsers {
+		if err := s.RegisterUser(ctx, u); err != nil {
+			return err
+		}
+	}
+	for _, u := range d.LocalUsers {
+		if err := s.ImportLocalUser(ctx, u); err != nil {
+			return err
+		}
+	}
+	for _, wh := range d.Webhooks {
+		if err := s.CreateWebhook(ctx, wh); err != nil {
+			return err
+		}
+	}
+	for _, dl := range d.WebhookDeliveries {
+		if err := s.SaveWebhookDelivery(ctx, dl); err != nil {
+			return err
+		}
+	}
+	for _, sh := range d.ShareTokens {
+		if err := s.CreateShareToken(ctx, sh); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSnapshot(ctx context.Context, path string, s store.Store) error {
+	d, err := load(ctx, s)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("writing snapshot: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// dataset is everything a store holds. It is also the snapshot file format.
+type dataset struct {
+	Tournaments       []*models.Tournament      `json:"tournaments"`
+	Audit             []*models.AuditEntry      `json:"audit"`
+	RegisteredUsers   []*models.RegisteredUser  `json:"registeredUsers"`
+	LocalUsers        []*models.LocalUser       `json:"localUsers"`
+	Webhooks          []*models.Webhook         `json:"webhooks"`
+	WebhookDeliveries []*models.WebhookDelivery `json:"webhookDeliveries"`
+	ShareTokens       []*models.ShareToken      `json:"shareTokens"`
+}
+
+// load reads every entity from s. Audit entries, webhooks and share tokens
+// are only reachable through their tournament, so those of deleted
+// tournaments are left behind.
+func load(ctx context.Context, s store.Store) (*dataset, error) {
+	d := &dataset{}
+	var err error
+	if d.Tournaments, err = s.ListTournaments(ctx); err != nil {
+		return nil, fmt.Errorf("listing tournaments: %w", err)
+	}
+	for _, t := range d.Tournaments {
+		audit, err := s.ListAuditEntries(ctx, t.ID)
+		if err != nil {
+			return nil, fmt.Errorf("listing audit entries of %s: %w", t.ID, err)
+		}
+		d.Audit = append(d.Audit, audit...)
+
+		hooks, err := s.ListWebhooks(ctx, t.ID)
+		if err != nil {
+			return nil, fmt.Errorf("listing webhooks of %s: %w", t.ID, err)
+		}
+		d.Webhooks = append(d.Webhooks, hooks...)
+		for _, wh := range hooks {
+			deliveries, err := s.ListWebhookDeliveries(ctx, t.ID, wh.ID)
+			if err != nil {
+				return nil, fmt.Errorf("listing deliveries of webhook %s: %w", wh.ID, err)
+			}
+			d.WebhookDeliveries = append(d.WebhookDeliveries, deliveries...)
+		}
+
+		shares, err := s.ListShareTokens(ctx, t.ID)
+		if err != nil {
+			return nil, fmt.Errorf("listing share tokens of %s: %w", t.ID, err)
+		}
+		d.ShareTokens = append(d.ShareTokens, shares...)
+	}
+	if d.RegisteredUsers, err = s.ListRegisteredUsers(ctx); err != nil {
+		return nil, fmt.Errorf("listing registered users: %w", err)
+	}
+	if d.LocalUsers, err = s.ListLocalUsers(ctx); err != nil {
+		return nil, fmt.Errorf("listing local users: %w", err)
+	}
+	return d, nil
+}
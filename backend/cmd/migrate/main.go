@@ -1,109 +1,163 @@
+// Command migrate copies data from one store to another.
+//
+//	migrate -from file:./data -to firestore:my-project [-on-conflict skip|overwrite|newest-wins] [-dry-run]
+//	migrate -upgrade sqlite:./scoring.db [-dry-run]
+//
+// Every entity is compared with the destination first and listed with what
+// will happen to it. After writing, the destination is read back and
+// compared with the source.
+//
+// With -upgrade, every tournament in the one store is rewritten at the
+// current document schema version instead. Stop the server before running
+// it.
 package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
-	"scoring-backend/internal/store"
+	"strings"
 )
 
 func main() {
-	dataDir := os.Getenv("DATA_DIR")
-	if dataDir == "" {
-		dataDir = "./data"
-	}
+	from := flag.String("from", "file", "source store: file[:dir], firestore[:project[/database]], sqlite[:path], postgres[:url] or snapshot:path.json")
+	to := flag.String("to", "firestore", "destination store, in the same form as -from")
+	onConflict := flag.String("on-conflict", string(policySkip), "when an entity exists in both stores with different contents: skip, overwrite or newest-wins")
+	dryRun := flag.Bool("dry-run", false, "show what would change without writing")
+	verbose := flag.Bool("v", false, "also list entities that are already identical")
+	noVerify := flag.Bool("no-verify", false, "skip comparing the stores after migrating")
+	upgrade := flag.String("upgrade", "", "rewrite every tournament in this store at the current schema version, instead of migrating")
+	flag.Parse()
 
-	projectID := os.Getenv("GCP_PROJECT_ID")
-	if projectID == "" {
-		log.Fatal("GCP_PROJECT_ID is required")
-	}
+	ctx := context.Background()
 
-	databaseID := os.Getenv("FIRESTORE_DATABASE")
+	if *upgrade != "" {
+		b, err := openBackend(ctx, *upgrade)
+		if err != nil {
+			log.Fatalf("Failed to open store: %v", err)
+		}
+		defer b.close()
+		fmt.Printf("Upgrading %s\n\n", b.desc)
+		if err := upgradeAll(ctx, b, *dryRun); err != nil {
+			log.Fatalf("Upgrade failed: %v", err)
+		}
+		return
+	}
 
-	ctx := context.Background()
+	p := policy(*onConflict)
+	if p != policySkip && p != policyOverwrite && p != policyNewestWins {
+		log.Fatalf("Unknown conflict policy %q", *onConflict)
+	}
+	if *from == *to {
+		log.Fatal("Source and destination are the same store")
+	}
 
-	// Open source (file store)
-	src, err := store.NewFileStore(dataDir)
+	src, err := openBackend(ctx, *from)
 	if err != nil {
-		log.Fatalf("Failed to open file store: %v", err)
+		log.Fatalf("Failed to open source: %v", err)
 	}
-
-	// Open destination (firestore)
-	dst, err := store.NewFirestoreStore(ctx, projectID, databaseID)
+	defer src.close()
+	dst, err := openBackend(ctx, *to)
 	if err != nil {
-		log.Fatalf("Failed to open firestore store: %v", err)
+		log.Fatalf("Failed to open destination: %v", err)
 	}
-	defer dst.Close()
+	defer dst.close()
 
-	dbName := databaseID
-	if dbName == "" {
-		dbName = "(default)"
+	mode := ""
+	if *dryRun {
+		mode = " (dry run)"
 	}
-	fmt.Printf("Migrating from %s -> Firestore (project: %s, database: %s)\n\n", dataDir, projectID, dbName)
+	fmt.Printf("Migrating %s -> %s, on conflict: %s%s\n\n", src.desc, dst.desc, p, mode)
 
-	// Migrate tournaments — write directly to preserve original timestamps
-	tournaments, err := src.ListTournaments(ctx)
+	srcData, err := load(ctx, src)
+	if err != nil {
+		log.Fatalf("Failed to read source: %v", err)
+	}
+	dstData, err := load(ctx, dst)
 	if err != nil {
-		log.Fatalf("Failed to list tournaments: %v", err)
+		log.Fatalf("Failed to read destination: %v", err)
 	}
-	fmt.Printf("Tournaments: %d\n", len(tournaments))
-	for _, t := range tournaments {
-		totalMatches := 0
-		for _, r := range t.Rounds {
-			totalMatches += len(r.Matches)
+	steps := plan(records(srcData), records(dstData), p)
+
+	counts := map[action]int{}
+	failed := 0
+	kind := ""
+	for i, s := range steps {
+		if s.action == actionIdentical && !*verbose {
+			counts[s.action]++
+			continue
 		}
-		fmt.Printf("  %s (%s)\n", t.Name, t.ID)
-		fmt.Printf("    Created: %s\n", t.CreatedAt.Format("2006-01-02 15:04:05"))
-		fmt.Printf("    Teams: %s vs %s\n", t.Teams[0].Name, t.Teams[1].Name)
-		fmt.Printf("    Players: %d + %d\n", len(t.Teams[0].Players), len(t.Teams[1].Players))
-		fmt.Printf("    Rounds: %d, Matches: %d\n", len(t.Rounds), totalMatches)
-		for _, r := range t.Rounds {
-			fmt.Printf("      Round %d (%s): %d matches\n", r.Number, r.Name, len(r.Matches))
+		if s.src.kind != kind {
+			kind = s.src.kind
+			fmt.Printf("%s:\n", headings[kind])
 		}
-		if err := dst.ImportTournament(ctx, t); err != nil {
-			fmt.Printf("    SKIP: %v\n", err)
-			continue
+
+		line := fmt.Sprintf("  %-9s %s", s.action, s.src.key)
+		if s.src.label != "" {
+			line += " (" + s.src.label + ")"
 		}
-		fmt.Printf("    OK\n")
+		if s.reason != "" {
+			line += ": " + s.reason
+		}
+		fmt.Println(line)
+		for _, path := range s.diff {
+			fmt.Printf("              ~ %s\n", path)
+		}
+
+		if !*dryRun && (s.action == actionCreate || s.action == actionOverwrite) {
+			if err := apply(ctx, dst, s.src, s.action == actionOverwrite); err != nil {
+				fmt.Printf("    FAILED: %v\n", err)
+				steps[i].action = actionKeep
+				failed++
+				continue
+			}
+		}
+		counts[s.action]++
 	}
 
-	// Migrate registered users
-	users, err := src.ListRegisteredUsers(ctx)
-	if err != nil {
-		log.Fatalf("Failed to list registered users: %v", err)
+	fmt.Printf("\n%d created, %d overwritten, %d kept, %d identical, %d failed\n",
+		counts[actionCreate], counts[actionOverwrite], counts[actionKeep], counts[actionIdentical], failed)
+	if *dryRun {
+		fmt.Println("Dry run: nothing was written.")
+		return
 	}
-	fmt.Printf("\nRegistered users: %d\n", len(users))
-	for _, u := range users {
-		fmt.Printf("  %s <%s>\n", u.Name, u.Email)
-		if err := dst.RegisterUser(ctx, u); err != nil {
-			fmt.Printf("    SKIP: %v\n", err)
-			continue
-		}
-		fmt.Printf("    OK\n")
+
+	if err := dst.save(); err != nil {
+		log.Fatalf("Failed to save destination: %v", err)
+	}
+	if *noVerify {
+		exitIfFailed(failed)
+		return
 	}
 
-	// Migrate local users
-	localUsers, err := src.ListLocalUsers(ctx)
-	if err != nil {
-		log.Fatalf("Failed to list local users: %v", err)
-	}
-	fmt.Printf("\nLocal users: %d\n", len(localUsers))
-	for _, u := range localUsers {
-		status := "unverified"
-		if u.EmailVerified && u.Confirmed {
-			status = "active"
-		} else if u.EmailVerified {
-			status = "pending approval"
+	// Reopen snapshot destinations so verification reads what was saved.
+	check := dst
+	if strings.HasPrefix(*to, "snapshot:") {
+		if check, err = openBackend(ctx, *to); err != nil {
+			log.Fatalf("Failed to reopen destination: %v", err)
 		}
-		fmt.Printf("  %s <%s> [%s]\n", u.Name, u.Email, status)
-		if err := dst.CreateLocalUser(ctx, u); err != nil {
-			fmt.Printf("    SKIP: %v\n", err)
-			continue
+	}
+	problems, err := verify(ctx, check, steps)
+	if err != nil {
+		log.Fatalf("Failed to verify destination: %v", err)
+	}
+	if len(problems) > 0 {
+		fmt.Printf("\nVerification found %d mismatch(es):\n", len(problems))
+		for _, problem := range problems {
+			fmt.Printf("  %s\n", problem)
 		}
-		fmt.Printf("    OK\n")
+		os.Exit(1)
 	}
+	fmt.Println("\nVerification passed: every migrated entity matches the source.")
+	exitIfFailed(failed)
+}
 
-	fmt.Printf("\nDone. Migrated %d tournament(s), %d registered user(s), %d local user(s).\n",
-		len(tournaments), len(users), len(localUsers))
+// exitIfFailed exits with a failure status if any write failed. Deferred
+// closes are skipped, which is harmless for a process about to exit.
+func exitIfFailed(failed int) {
+	if failed > 0 {
+		os.Exit(1)
+	}
 }
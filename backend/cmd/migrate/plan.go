@@ -0,0 +1,271 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/store"
+	"sort"
+	"strings"
+	"time"
+)
+
+// policy decides what happens when an entity exists in both stores with
+// different contents.
+type policy string
+
+const (
+	policySkip       policy = "skip"        // keep the destination's copy
+	policyOverwrite  policy = "overwrite"   // replace it with the source's copy
+	policyNewestWins policy = "newest-wins" // keep whichever was changed last
+)
+
+type action string
+
+const (
+	actionCreate    action = "create"
+	actionOverwrite action = "overwrite"
+	actionKeep      action = "keep"
+	actionIdentical action = "identical"
+)
+
+// record is one entity to migrate, identified by kind and key.
+type record struct {
+	kind    string
+	key     string
+	label   string
+	value   any
+	changed time.Time // zero when the entity has no timestamp
+}
+
+// headings titles each kind of record in the output.
+var headings = map[string]string{
+	"tournament":       "Tournaments",
+	"audit entry":      "Audit entries",
+	"registered user":  "Registered users",
+	"local user":       "Local users",
+	"webhook":          "Webhooks",
+	"webhook delivery": "Webhook deliveries",
+	"share token":      "Share tokens",
+}
+
+func (r record) id() string { return r.kind + " " + r.key }
+
+// records lists the entities of d in the order they must be written:
+// tournaments before anything that refers to them.
+func records(d *dataset) []record {
+	var out []record
+	for _, t := range d.Tournaments {
+		out = append(out, record{"tournament", t.ID, t.Name, t, t.UpdatedAt})
+	}
+	for _, e := range d.Audit {
+		out = append(out, record{"audit entry", e.TournamentID + "/" + e.ID, string(e.Action), e, e.CreatedAt})
+	}
+	for _, u := range d.RegisteredUsers {
+		out = append(out, record{"registered user", strings.ToLower(u.Email), u.Name, u, time.Time{}})
+	}
+	for _, u := range d.LocalUsers {
+		out = append(out, record{"local user", strings.ToLower(u.Email), u.Name, u, u.CreatedAt})
+	}
+	for _, wh := range d.Webhooks {
+		out = append(out, record{"webhook", wh.TournamentID + "/" + wh.ID, wh.URL, wh, wh.CreatedAt})
+	}
+	for _, dl := range d.WebhookDeliveries {
+		out = append(out, record{"webhook delivery", dl.TournamentID + "/" + dl.ID, string(dl.Event), dl, dl.LastAttemptAt})
+	}
+	for _, sh := range d.ShareTokens {
+		out = append(out, record{"share token", sh.Token, sh.Label, sh, sh.CreatedAt})
+	}
+	return out
+}
+
+func index(recs []record) map[string]record {
+	m := make(map[string]record, len(recs))
+	for _, r := range recs {
+		m[r.id()] = r
+	}
+	return m
+}
+
+// step is the planned handling of one source record.
+type step struct {
+	src    record
+	action action
+	diff   []string // differing fields when the destination has the entity
+	reason string
+}
+
+// plan compares every source record with the destination.
+func plan(src, dst []record, p policy) []step {
+	existing := index(dst)
+	steps := make([]step, 0, len(src))
+	for _, r := range src {
+		d, ok := existing[r.id()]
+		if !ok {
+			steps = append(steps, step{src: r, action: actionCreate})
+			continue
+		}
+		diff := diffValues(r.value, d.value)
+		if len(diff) == 0 {
+			steps = append(steps, step{src: r, action: actionIdentical})
+			continue
+		}
+
+		s := step{src: r, diff: diff}
+		switch {
+		case r.kind == "audit entry":
+			// The audit log is append-only; entries are never rewritten.
+			s.action, s.reason = actionKeep, "audit entries are append-only"
+		case p == policyOverwrite:
+			s.action = actionOverwrite
+		case p == policyNewestWins && r.changed.After(d.changed):
+			s.action, s.reason = actionOverwrite, "source is newer"
+		case p == policyNewestWins:
+			s.action, s.reason = actionKeep, "destination is as new or newer"
+		default:
+			s.action, s.reason = actionKeep, "exists in destination"
+		}
+		steps = append(steps, s)
+	}
+	return steps
+}
+
+// apply writes one record to s. replace is set when s already has it.
+func apply(ctx context.Context, s store.Store, r record, replace bool) error {
+	switch v := r.value.(type) {
+	case *models.Tournament:
+		return s.ImportTournament(ctx, v)
+	case *models.AuditEntry:
+		return s.AppendAuditEntry(ctx, v)
+	case *models.RegisteredUser:
+		return s.RegisterUser(ctx, v)
+	case *models.LocalUser:
+		return s.ImportLocalUser(ctx, v)
+	case *models.Webhook:
+		if replace {
+			if err := s.DeleteWebhook(ctx, v.TournamentID, v.ID); err != nil {
+				return err
+			}
+		}
+		return s.CreateWebhook(ctx, v)
+	case *models.WebhookDelivery:
+		return s.SaveWebhookDelivery(ctx, v)
+	case *models.ShareToken:
+		if replace {
+			if err := s.DeleteShareToken(ctx, v.TournamentID, v.Token); err != nil {
+				return err
+			}
+		}
+		return s.CreateShareToken(ctx, v)
+	}
+	return fmt.Errorf("unsupported %s", r.kind)
+}
+
+// verify reloads the destination and reports every source record that was
+// written but does not match what the destination now holds.
+func verify(ctx context.Context, dst store.Store, steps []step) ([]string, error) {
+	d, err := load(ctx, dst)
+	if err != nil {
+		return nil, err
+	}
+	stored := index(records(d))
+
+	var problems []string
+	for _, s := range steps {
+		if s.action == actionKeep {
+			continue
+		}
+		got, ok := stored[s.src.id()]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s: missing from destination", s.src.id()))
+			continue
+		}
+		if diff := diffValues(s.src.value, got.value); len(diff) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: differs at %s", s.src.id(), strings.Join(diff, ", ")))
+		}
+	}
+	return problems, nil
+}
+
+// maxDiffPaths caps how many differing fields are listed for one entity.
+const maxDiffPaths = 8
+
+// diffValues compares the JSON forms of a and b and returns the paths that
+// differ, e.g. "rounds[4].matches[0].holeResults.3". Values are left out so
+// that password hashes and secrets never reach the output.
+func diffValues(a, b any) []string {
+	var paths []string
+	diffJSON(normalize(a), normalize(b), "", &paths)
+	if len(paths) > maxDiffPaths {
+		paths = append(paths[:maxDiffPaths], fmt.Sprintf("and %d more", len(paths)-maxDiffPaths))
+	}
+	return paths
+}
+
+func normalize(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out any
+	json.Unmarshal(data, &out)
+	return out
+}
+
+// sameInstant reports whether a and b are timestamps of the same moment to
+// the microsecond. Postgres keeps no finer precision, nor the original zone.
+func sameInstant(a, b any) bool {
+	as, ok1 := a.(string)
+	bs, ok2 := b.(string)
+	if !ok1 || !ok2 {
+		return false
+	}
+	at, err1 := time.Parse(time.RFC3339Nano, as)
+	bt, err2 := time.Parse(time.RFC3339Nano, bs)
+	return err1 == nil && err2 == nil && at.Truncate(time.Microsecond).Equal(bt.Truncate(time.Microsecond))
+}
+
+func diffJSON(a, b any, path string, paths *[]string) {
+	switch av := a.(type) {
+	case map[string]any:
+		bv, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sub := k
+			if path != "" {
+				sub = path + "." + k
+			}
+			diffJSON(av[k], bv[k], sub, paths)
+		}
+		return
+	case []any:
+		bv, ok := b.([]any)
+		if !ok || len(av) != len(bv) {
+			break
+		}
+		for i := range av {
+			diffJSON(av[i], bv[i], fmt.Sprintf("%s[%d]", path, i), paths)
+		}
+		return
+	}
+	if !reflect.DeepEqual(a, b) && !sameInstant(a, b) {
+		if path == "" {
+			path = "(value)"
+		}
+		*paths = append(*paths, path)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"scoring-backend/internal/models"
+	"testing"
+	"time"
+)
+
+func TestPlan(t *testing.T) {
+	older := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	newer := older.Add(time.Hour)
+	tournament := func(id, name string, updated time.Time) record {
+		return record{"tournament", id, name, &models.Tournament{ID: id, Name: name, UpdatedAt: updated}, updated}
+	}
+	user := func(email, name string) record {
+		return record{"registered user", email, name, &models.RegisteredUser{Email: email, Name: name}, time.Time{}}
+	}
+	entry := func(id string, action models.AuditAction) record {
+		return record{"audit entry", "t1/" + id, string(action), &models.AuditEntry{ID: id, TournamentID: "t1", Action: action, CreatedAt: older}, older}
+	}
+
+	src := []record{
+		tournament("new", "Only in source", older),
+		tournament("same", "Same", older),
+		tournament("src-newer", "Renamed", newer),
+		tournament("dst-newer", "Renamed", older),
+		tournament("tied", "Renamed", older),
+		user("ann@example.com", "Ann B"),
+		entry("a1", models.AuditHoleResult),
+	}
+	dst := []record{
+		tournament("same", "Same", older),
+		tournament("src-newer", "Original", older),
+		tournament("dst-newer", "Original", newer),
+		tournament("tied", "Original", older),
+		user("ann@example.com", "Ann"),
+		entry("a1", models.AuditMatchResult),
+	}
+
+	// want lists the action for each source record, in order.
+	tests := []struct {
+		policy policy
+		want   []action
+	}{
+		{policySkip, []action{actionCreate, actionIdentical, actionKeep, actionKeep, actionKeep, actionKeep, actionKeep}},
+		{policyOverwrite, []action{actionCreate, actionIdentical, actionOverwrite, actionOverwrite, actionOverwrite, actionOverwrite, actionKeep}},
+		{policyNewestWins, []action{actionCreate, actionIdentical, actionOverwrite, actionKeep, actionKeep, actionKeep, actionKeep}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			steps := plan(src, dst, tt.policy)
+			if len(steps) != len(tt.want) {
+				t.Fatalf("got %d steps, want %d", len(steps), len(tt.want))
+			}
+			for i, s := range steps {
+				if s.src.id() != src[i].id() {
+					t.Errorf("step %d is for %s, want %s", i, s.src.id(), src[i].id())
+				}
+				if s.action != tt.want[i] {
+					t.Errorf("%s: action %s (%s), want %s", s.src.id(), s.action, s.reason, tt.want[i])
+				}
+				switch s.action {
+				case actionCreate, actionIdentical:
+					if len(s.diff) != 0 {
+						t.Errorf("%s: diff %v, want none", s.src.id(), s.diff)
+					}
+				default:
+					if len(s.diff) == 0 {
+						t.Errorf("%s: no diff for a record that differs", s.src.id())
+					}
+				}
+			}
+			if s := steps[len(steps)-1]; s.reason != "audit entries are append-only" {
+				t.Errorf("audit entry reason = %q", s.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"scoring-backend/internal/schema"
+	"scoring-backend/internal/store"
+)
+
+// upgradeAll rewrites every tournament in the store, so that documents of an
+// older schema version are stored at schema.Current. Stores upgrade old
+// documents as they read them, so this only saves doing it on every read and
+// lets later builds drop old steps. Stop the server first: a write it makes
+// between the read and the rewrite of a tournament would be lost.
+//
+// Tournaments a store reports as already at schema.Current are skipped; stores
+// that don't record versions have every tournament rewritten.
+func upgradeAll(ctx context.Context, b *backend, dryRun bool) error {
+	tournaments, err := b.ListTournaments(ctx)
+	if err != nil {
+		return fmt.Errorf("listing tournaments: %w", err)
+	}
+	versioner, _ := b.Store.(store.SchemaVersioner)
+	written, current := 0, 0
+	for _, t := range tournaments {
+		if versioner != nil {
+			version, err := versioner.StoredSchemaVersion(ctx, t.ID)
+			if err != nil {
+				return fmt.Errorf("reading schema version of tournament %s: %w", t.ID, err)
+			}
+			if version >= schema.Current {
+				current++
+				continue
+			}
+			fmt.Printf("  %s (%s), schema version %d\n", t.ID, t.Name, version)
+		} else {
+			fmt.Printf("  %s (%s)\n", t.ID, t.Name)
+		}
+		if dryRun {
+			written++
+			continue
+		}
+		if err := b.ImportTournament(ctx, t); err != nil {
+			return fmt.Errorf("rewriting tournament %s: %w", t.ID, err)
+		}
+		written++
+	}
+	if dryRun {
+		fmt.Printf("\n%d tournament(s) would be rewritten at schema version %d, %d already at it. Dry run: nothing was written.\n", written, schema.Current, current)
+		return nil
+	}
+	if err := b.save(); err != nil {
+		return fmt.Errorf("saving: %w", err)
+	}
+	fmt.Printf("\n%d tournament(s) rewritten at schema version %d, %d already at it.\n", written, schema.Current, current)
+	return nil
+}
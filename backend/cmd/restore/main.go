@@ -0,0 +1,105 @@
+// Command restore rebuilds a file-store tournament as it was at a point in
+// time, from the journal the file store keeps of every write.
+//
+//	restore -tournament ID -list
+//	restore -tournament ID -at 2026-05-01T14:30:00Z [-out tournament.json] [-dry-run]
+//
+// Without -out the rebuilt tournament is written back as a new version of the
+// tournament, which is itself journaled and so can be undone the same way.
+// With -out it is written to a file instead, which can be imported as a
+// bundle. Stop the server before restoring into its data directory.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"scoring-backend/internal/store"
+	"time"
+)
+
+func main() {
+	dir := flag.String("dir", "", "file store data directory (default DATA_DIR or ./data)")
+	id := flag.String("tournament", "", "tournament ID")
+	list := flag.Bool("list", false, "list the tournament's journaled writes")
+	at := flag.String("at", "", "point in time to restore, RFC 3339 (e.g. 2026-05-01T14:30:00Z)")
+	out := flag.String("out", "", "write the restored tournament to this file instead of the store")
+	dryRun := flag.Bool("dry-run", false, "show what would be restored without writing")
+	flag.Parse()
+
+	if *id == "" {
+		log.Fatal("-tournament is required")
+	}
+	if *dir == "" {
+		*dir = os.Getenv("DATA_DIR")
+	}
+	if *dir == "" {
+		*dir = "./data"
+	}
+
+	ctx := context.Background()
+	fs, err := store.NewFileStore(*dir)
+	if err != nil {
+		log.Fatalf("Failed to open file store: %v", err)
+	}
+
+	if *list {
+		entries, err := fs.Journal(ctx, *id)
+		if err != nil {
+			log.Fatalf("Failed to read journal: %v", err)
+		}
+		for _, e := range entries {
+			line := fmt.Sprintf("%6d  %s  %-6s", e.Seq, e.At.Format(time.RFC3339Nano), e.Op)
+			if e.Version > 0 {
+				line += fmt.Sprintf("  v%d", e.Version)
+			}
+			if e.MatchID != "" {
+				line += "  match " + e.MatchID
+			}
+			fmt.Println(line)
+		}
+		return
+	}
+
+	if *at == "" {
+		log.Fatal("-at or -list is required")
+	}
+	when, err := time.Parse(time.RFC3339Nano, *at)
+	if err != nil {
+		log.Fatalf("Invalid -at: %v", err)
+	}
+
+	t, err := fs.TournamentAt(ctx, *id, when)
+	if err != nil {
+		log.Fatalf("Failed to rebuild tournament: %v", err)
+	}
+	matches := 0
+	for _, r := range t.Rounds {
+		matches += len(r.Matches)
+	}
+	fmt.Printf("%s (%s) as of %s: version %d, last updated %s, %d round(s), %d match(es)\n",
+		t.ID, t.Name, when.Format(time.RFC3339Nano), t.Version, t.UpdatedAt.Format(time.RFC3339), len(t.Rounds), matches)
+
+	switch {
+	case *dryRun:
+		fmt.Println("Dry run: nothing was written.")
+	case *out != "":
+		data, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			log.Fatalf("Failed to encode tournament: %v", err)
+		}
+		if err := os.WriteFile(*out, data, 0644); err != nil {
+			log.Fatalf("Failed to write %s: %v", *out, err)
+		}
+		fmt.Printf("Written to %s.\n", *out)
+	default:
+		restored, err := fs.RestoreTournament(ctx, *id, when)
+		if err != nil {
+			log.Fatalf("Failed to restore tournament: %v", err)
+		}
+		fmt.Printf("Restored as version %d.\n", restored.Version)
+	}
+}
@@ -6,12 +6,14 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
 	"scoring-backend/internal/auth"
 	"scoring-backend/internal/email"
 	"scoring-backend/internal/handlers"
 	"scoring-backend/internal/middleware"
 	"scoring-backend/internal/store"
 	"strings"
+	"time"
 )
 
 func main() {
@@ -56,11 +58,53 @@ func main() {
 			databaseID = "(default)"
 		}
 		log.Printf("Using Firestore store (project: %s, database: %s)", projectID, databaseID)
+	case "sqlite":
+		dbPath := os.Getenv("SQLITE_PATH")
+		if dbPath == "" {
+			dataDir := os.Getenv("DATA_DIR")
+			if dataDir == "" {
+				dataDir = "./data"
+			}
+			if err := os.MkdirAll(dataDir, 0755); err != nil {
+				log.Fatalf("Failed to create data directory: %v", err)
+			}
+			dbPath = filepath.Join(dataDir, "scoring.db")
+		}
+		ss, err := store.NewSQLiteStore(context.Background(), dbPath)
+		if err != nil {
+			log.Fatalf("Failed to initialize SQLite store: %v", err)
+		}
+		defer ss.Close()
+		s = ss
+		log.Printf("Using SQLite store (file: %s)", dbPath)
+	case "postgres":
+		dbURL := os.Getenv("DATABASE_URL")
+		if dbURL == "" {
+			log.Fatal("DATABASE_URL is required for the postgres store")
+		}
+		ps, err := store.NewPostgresStore(context.Background(), dbURL)
+		if err != nil {
+			log.Fatalf("Failed to initialize Postgres store: %v", err)
+		}
+		defer ps.Close()
+		s = ps
+		log.Println("Using Postgres store")
 	default:
 		s = store.NewMemoryStore()
 		log.Println("Using in-memory store")
 	}
 
+	// Optionally cache tournaments and users in front of the store, e.g.
+	// CACHE_TTL=10s. Writes made by other replicas are seen after the TTL.
+	if raw := os.Getenv("CACHE_TTL"); raw != "" {
+		ttl, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Fatalf("Invalid CACHE_TTL %q: %v", raw, err)
+		}
+		s = store.NewCachingStore(s, ttl)
+		log.Printf("Caching store reads (TTL: %s)", ttl)
+	}
+
 	devMode := os.Getenv("DEV_MODE") == "true"
 
 	// Parse admin emails from comma-separated env var
@@ -108,6 +152,7 @@ func main() {
 	}
 
 	h := handlers.New(s, emailCfg, jwtSecret, appURL, adminEmails)
+	h.ResumeWebhooks()
 
 	mux := http.NewServeMux()
 	h.RegisterRoutes(mux)
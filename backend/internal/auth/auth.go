@@ -97,6 +97,54 @@ func ValidateLocalToken(token, secret string) (*UserClaims, error) {
 	}, nil
 }
 
+// calendarTokenPrefix marks calendar feed tokens, and is mixed into their
+// signature so a local auth token can never pass as one or the reverse.
+const calendarTokenPrefix = "cal"
+
+// GenerateCalendarToken creates a token for a user's calendar feed URL.
+// Calendar apps can't send an Authorization header, so the token goes in the
+// URL; it only grants read access to the feed. It doesn't expire, but it is
+// signed with the user's calendar key as well as the secret, so replacing the
+// key revokes it.
+// Format: cal.<base64url(email)>.<base64url(hmac-sha256)>
+func GenerateCalendarToken(email, key, secret string) string {
+	emailB64 := base64.RawURLEncoding.EncodeToString([]byte(strings.ToLower(email)))
+	return calendarTokenPrefix + "." + emailB64 + "." + calendarSignature(emailB64, key, secret)
+}
+
+// CalendarTokenEmail returns the email a calendar token was issued for,
+// without verifying it, so the caller can look up the user's key.
+func CalendarTokenEmail(token string) (string, error) {
+	parts := strings.SplitN(token, ".", 3)
+	if len(parts) != 3 || parts[0] != calendarTokenPrefix {
+		return "", fmt.Errorf("invalid token format")
+	}
+	email, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid token payload")
+	}
+	return string(email), nil
+}
+
+// ValidateCalendarToken verifies a calendar token against the user's current
+// calendar key.
+func ValidateCalendarToken(token, key, secret string) error {
+	parts := strings.SplitN(token, ".", 3)
+	if len(parts) != 3 || parts[0] != calendarTokenPrefix {
+		return fmt.Errorf("invalid token format")
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(calendarSignature(parts[1], key, secret))) {
+		return fmt.Errorf("invalid token signature")
+	}
+	return nil
+}
+
+func calendarSignature(payloadB64, key, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(calendarTokenPrefix + ":" + payloadB64 + ":" + key))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
 // GenerateVerificationToken creates a random hex token for email verification.
 func GenerateVerificationToken() (string, error) {
 	bytes := make([]byte, 32)
@@ -107,13 +155,15 @@ func GenerateVerificationToken() (string, error) {
 }
 
 // Middleware returns an HTTP middleware that verifies the Authorization header.
-// Paths starting with /api/auth/ bypass authentication.
+// Paths starting with /api/auth/ bypass authentication, as do spectator paths
+// under /api/share/ and calendar feeds under /api/calendar/, which are
+// authorized by the token in their URL.
 // When devMode is true, any request is allowed through with a stub admin user identity.
 func Middleware(devMode bool, adminEmails map[string]bool, jwtSecret string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Skip auth for public auth endpoints
-			if strings.HasPrefix(r.URL.Path, "/api/auth/") {
+			// Skip auth for public endpoints
+			if isPublicPath(r.URL.Path) {
 				next.ServeHTTP(w, r)
 				return
 			}
@@ -132,13 +182,16 @@ func Middleware(devMode bool, adminEmails map[string]bool, jwtSecret string) fun
 			}
 
 			authHeader := r.Header.Get("Authorization")
-			if authHeader == "" {
-				http.Error(w, `{"error":"missing authorization header"}`, http.StatusUnauthorized)
-				return
-			}
-
 			token := strings.TrimPrefix(authHeader, "Bearer ")
-			if token == authHeader {
+			if authHeader == "" {
+				// Browsers can't set headers on a WebSocket handshake, so the
+				// token may come as a subprotocol instead.
+				token = WebSocketToken(r)
+				if token == "" {
+					http.Error(w, `{"error":"missing authorization header"}`, http.StatusUnauthorized)
+					return
+				}
+			} else if token == authHeader {
 				http.Error(w, `{"error":"invalid authorization format, use Bearer token"}`, http.StatusUnauthorized)
 				return
 			}
@@ -157,6 +210,39 @@ func Middleware(devMode bool, adminEmails map[string]bool, jwtSecret string) fun
 	}
 }
 
+// publicPaths are the path prefixes served without a bearer token.
+var publicPaths = []string{"/api/auth/", "/api/share/", "/api/calendar/"}
+
+func isPublicPath(path string) bool {
+	for _, prefix := range publicPaths {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// WebSocketTokenPrefix marks the Sec-WebSocket-Protocol entry that carries a
+// bearer token, e.g. "bearer.local.<payload>.<sig>".
+const WebSocketTokenPrefix = "bearer."
+
+// WebSocketToken returns the bearer token offered in a WebSocket handshake's
+// subprotocol list, or "" if there is none.
+func WebSocketToken(r *http.Request) string {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return ""
+	}
+	for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, proto := range strings.Split(header, ",") {
+			proto = strings.TrimSpace(proto)
+			if strings.HasPrefix(proto, WebSocketTokenPrefix) {
+				return strings.TrimPrefix(proto, WebSocketTokenPrefix)
+			}
+		}
+	}
+	return ""
+}
+
 // GetUser extracts the authenticated user claims from the request context.
 func GetUser(ctx context.Context) *UserClaims {
 	claims, _ := ctx.Value(UserKey).(*UserClaims)
@@ -0,0 +1,348 @@
+// Package bundle exports a tournament with its audit log and linked users as a
+// single versioned JSON document, and imports such documents into any store.
+package bundle
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/schema"
+	"scoring-backend/internal/store"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SchemaVersion is the bundle format written by Export. Decode upgrades older
+// bundles to this version.
+const SchemaVersion = 1
+
+// Bundle is a portable copy of one tournament.
+type Bundle struct {
+	SchemaVersion int `json:"schemaVersion"`
+	// DocumentSchema is the schema.Current of the exporting build, which the
+	// tournament is upgraded from on Decode. Bundles written before it was
+	// added leave it out (0).
+	DocumentSchema int                  `json:"documentSchema,omitempty"`
+	ExportedAt     time.Time            `json:"exportedAt"`
+	Tournament     *models.Tournament   `json:"tournament"`
+	Users          []UserStub           `json:"users"`
+	Audit          []*models.AuditEntry `json:"audit"`
+}
+
+// UserStub identifies an account linked to a player. Passwords and tokens are
+// never exported; the account must exist in the destination for the link to
+// be usable there.
+type UserStub struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+// upgrades maps a schema version to the function that rewrites a bundle of
+// that version into the next one.
+var upgrades = map[int]func(json.RawMessage) (json.RawMessage, error){
+	0: upgradeV0,
+}
+
+// upgradeV0 wraps a bare tournament document, as stored by the file store
+// before bundles existed, into a version 1 bundle with no users or audit log.
+func upgradeV0(data json.RawMessage) (json.RawMessage, error) {
+	return json.Marshal(map[string]any{
+		"schemaVersion": 1,
+		"tournament":    data,
+		"users":         []UserStub{},
+		"audit":         []*models.AuditEntry{},
+	})
+}
+
+// Export builds a bundle of the tournament with the given ID.
+func Export(ctx context.Context, s store.Store, id string) (*Bundle, error) {
+	t, err := s.GetTournament(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	audit, err := s.ListAuditEntries(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	if audit == nil {
+		audit = []*models.AuditEntry{}
+	}
+
+	names := map[string]string{}
+	if registered, err := s.ListRegisteredUsers(ctx); err == nil {
+		for _, u := range registered {
+			names[strings.ToLower(u.Email)] = u.Name
+		}
+	}
+	users := []UserStub{}
+	seen := map[string]bool{}
+	for _, team := range t.Teams {
+		for _, p := range team.Players {
+			email := strings.ToLower(p.UserEmail)
+			if email == "" || seen[email] {
+				continue
+			}
+			seen[email] = true
+			stub := UserStub{Email: p.UserEmail, Name: names[email]}
+			if u, err := s.GetLocalUser(ctx, p.UserEmail); err == nil {
+				stub.Name = u.Name
+			}
+			users = append(users, stub)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+
+	return &Bundle{
+		SchemaVersion:  SchemaVersion,
+		DocumentSchema: schema.Current,
+		ExportedAt:     time.Now(),
+		Tournament:     t,
+		Users:          users,
+		Audit:          audit,
+	}, nil
+}
+
+// Decode parses a bundle of any supported schema version, upgrading it to
+// SchemaVersion. A document without a schemaVersion field is treated as a
+// bare tournament (version 0).
+func Decode(data []byte) (*Bundle, error) {
+	var header struct {
+		SchemaVersion *int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	version := 0
+	if header.SchemaVersion != nil {
+		version = *header.SchemaVersion
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("bundle schema version %d is newer than supported version %d", version, SchemaVersion)
+	}
+	if version < 0 {
+		return nil, fmt.Errorf("invalid bundle schema version %d", version)
+	}
+
+	raw := json.RawMessage(data)
+	for ; version < SchemaVersion; version++ {
+		upgrade, ok := upgrades[version]
+		if !ok {
+			return nil, fmt.Errorf("no upgrade from bundle schema version %d", version)
+		}
+		var err error
+		if raw, err = upgrade(raw); err != nil {
+			return nil, fmt.Errorf("failed to upgrade bundle from schema version %d: %w", version, err)
+		}
+	}
+
+	raw, err := upgradeDocument(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var b Bundle
+	if err := json.Unmarshal(raw, &b); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	if b.Tournament == nil || b.Tournament.ID == "" {
+		return nil, fmt.Errorf("bundle has no tournament")
+	}
+	// IDs become file and document names in some stores.
+	if strings.ContainsAny(b.Tournament.ID, `/\`) || strings.HasPrefix(b.Tournament.ID, ".") {
+		return nil, fmt.Errorf("invalid tournament id %q", b.Tournament.ID)
+	}
+	return &b, nil
+}
+
+// upgradeDocument brings the tournament inside a current-format bundle from
+// its documentSchema to schema.Current, before it is decoded into the models.
+func upgradeDocument(raw json.RawMessage) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, fmt.Errorf("invalid bundle: %w", err)
+	}
+	var from int
+	if v, ok := fields["documentSchema"]; ok {
+		if err := json.Unmarshal(v, &from); err != nil {
+			return nil, fmt.Errorf("invalid bundle document schema: %w", err)
+		}
+	}
+	t, ok := fields["tournament"]
+	if !ok || from == schema.Current {
+		return raw, nil
+	}
+	t, err := schema.UpgradeJSON(t, from, schema.UpgradeTournament)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upgrade bundle tournament: %w", err)
+	}
+	fields["tournament"] = t
+	fields["documentSchema"] = json.RawMessage(fmt.Sprint(schema.Current))
+	return json.Marshal(fields)
+}
+
+// Remap gives the tournament, its teams, players and matches, and the audit
+// entries new IDs, so that the bundle can be imported alongside the
+// tournament it was exported from.
+func (b *Bundle) Remap() {
+	ids := map[string]string{}
+	newID := func(old string) string {
+		if old == "" {
+			return ""
+		}
+		if id, ok := ids[old]; ok {
+			return id
+		}
+		id := uuid.New().String()
+		ids[old] = id
+		return id
+	}
+	mapped := func(old string) string {
+		if id, ok := ids[old]; ok {
+			return id
+		}
+		return old
+	}
+
+	t := b.Tournament
+	t.ID = newID(t.ID)
+	for i := range t.Teams {
+		team := &t.Teams[i]
+		team.ID = newID(team.ID)
+		for j := range team.Players {
+			team.Players[j].ID = newID(team.Players[j].ID)
+			team.Players[j].TeamID = team.ID
+		}
+	}
+	for i := range t.Rounds {
+		for j := range t.Rounds[i].Matches {
+			m := &t.Rounds[i].Matches[j]
+			m.ID = newID(m.ID)
+			for k := range m.Team1Players {
+				m.Team1Players[k] = mapped(m.Team1Players[k])
+			}
+			for k := range m.Team2Players {
+				m.Team2Players[k] = mapped(m.Team2Players[k])
+			}
+		}
+	}
+	for i := range t.Rankings {
+		for k, pid := range t.Rankings[i].PlayerIDs {
+			t.Rankings[i].PlayerIDs[k] = mapped(pid)
+		}
+	}
+
+	// Entry IDs are remapped up front so that RevertOf can point at entries
+	// that appear later in the log.
+	for _, e := range b.Audit {
+		e.ID = newID(e.ID)
+	}
+
+	// Before and After are snapshots of the tournament, so the IDs inside them
+	// are replaced wherever they appear as whole JSON strings.
+	pairs := make([]string, 0, 2*len(ids))
+	for old, id := range ids {
+		pairs = append(pairs, `"`+old+`"`, `"`+id+`"`)
+	}
+	replacer := strings.NewReplacer(pairs...)
+	for _, e := range b.Audit {
+		e.TournamentID = t.ID
+		e.MatchID = mapped(e.MatchID)
+		e.PlayerID = mapped(e.PlayerID)
+		e.RevertOf = mapped(e.RevertOf)
+		if len(e.Before) > 0 {
+			e.Before = json.RawMessage(replacer.Replace(string(e.Before)))
+		}
+		if len(e.After) > 0 {
+			e.After = json.RawMessage(replacer.Replace(string(e.After)))
+		}
+	}
+}
+
+// Result reports what Import wrote.
+type Result struct {
+	Tournament   *models.Tournament `json:"tournament"`
+	AuditEntries int                `json:"auditEntries"`
+	// MissingUsers are linked emails with no account in the destination.
+	// The links are kept so they work once the accounts are created.
+	MissingUsers []string `json:"missingUsers"`
+}
+
+// ErrExists is returned by Import when a tournament with the bundle's
+// tournament ID already exists.
+var ErrExists = errors.New("tournament already exists")
+
+// Import writes the bundle's tournament and audit log to s. The tournament
+// keeps the IDs it has in the bundle; call Remap first to import a copy.
+// Accounts are never created.
+//
+// The tournament must not exist yet. Its ID is claimed by creating an empty
+// tournament, which the import then replaces, so two imports of the same
+// bundle can't both succeed. If the tournament or its audit log can't be
+// written, whatever was written is deleted again.
+func Import(ctx context.Context, s store.Store, b *Bundle) (*Result, error) {
+	t := b.Tournament
+	if t.Version < 1 {
+		t.Version = 1
+	}
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	if t.UpdatedAt.IsZero() {
+		t.UpdatedAt = t.CreatedAt
+	}
+
+	if err := s.CreateTournament(ctx, &models.Tournament{ID: t.ID, Name: t.Name}); err != nil {
+		if _, gerr := s.GetTournament(ctx, t.ID); gerr == nil {
+			return nil, fmt.Errorf("%w: %s", ErrExists, t.ID)
+		}
+		return nil, fmt.Errorf("failed to import tournament: %w", err)
+	}
+	rollBack := func(err error) (*Result, error) {
+		if derr := s.DeleteTournament(ctx, t.ID); derr != nil {
+			return nil, fmt.Errorf("%w (rolling back: %v)", err, derr)
+		}
+		return nil, err
+	}
+	if err := s.ImportTournament(ctx, t); err != nil {
+		return rollBack(fmt.Errorf("failed to import tournament: %w", err))
+	}
+
+	for _, e := range b.Audit {
+		e.TournamentID = t.ID
+		if err := s.AppendAuditEntry(ctx, e); err != nil {
+			return rollBack(fmt.Errorf("failed to import audit entry %s: %w", e.ID, err))
+		}
+	}
+
+	known := map[string]bool{}
+	if registered, err := s.ListRegisteredUsers(ctx); err == nil {
+		for _, u := range registered {
+			known[strings.ToLower(u.Email)] = true
+		}
+	}
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, team := range t.Teams {
+		for _, p := range team.Players {
+			email := strings.ToLower(p.UserEmail)
+			if email == "" || seen[email] {
+				continue
+			}
+			seen[email] = true
+			if known[email] {
+				continue
+			}
+			if _, err := s.GetLocalUser(ctx, p.UserEmail); err != nil {
+				missing = append(missing, p.UserEmail)
+			}
+		}
+	}
+	sort.Strings(missing)
+
+	return &Result{Tournament: t, AuditEntries: len(b.Audit), MissingUsers: missing}, nil
+}
@@ -0,0 +1,115 @@
+package bundle
+
+import (
+	"encoding/json"
+	"scoring-backend/internal/models"
+	"strings"
+	"testing"
+)
+
+func TestRemapAudit(t *testing.T) {
+	newBundle := func() *Bundle {
+		tour := &models.Tournament{ID: "t-old", Name: "Cup"}
+		tour.Teams[0] = models.Team{ID: "team-eu", Name: "Europe", Players: []models.Player{{ID: "p-rory", Name: "Rory", TeamID: "team-eu"}}}
+		tour.Teams[1] = models.Team{ID: "team-us", Name: "USA", Players: []models.Player{{ID: "p-scottie", Name: "Scottie", TeamID: "team-us"}}}
+		tour.Rounds = []models.Round{{Number: 1, Matches: []models.Match{{ID: "m-1", Team1Players: []string{"p-rory"}, Team2Players: []string{"p-scottie"}}}}}
+		return &Bundle{Tournament: tour}
+	}
+
+	// Each entry's Before and After are written with the bundle's IDs in
+	// <angle brackets>, and are expected back with the new IDs in their place.
+	var unmarked []string
+	for _, id := range []string{"t-old", "team-eu", "team-us", "p-rory", "p-scottie", "m-1"} {
+		unmarked = append(unmarked, "<"+id+">", id)
+	}
+	old := func(s string) json.RawMessage {
+		return json.RawMessage(strings.NewReplacer(unmarked...).Replace(s))
+	}
+	tests := []struct {
+		name          string
+		entry         models.AuditEntry
+		before, after string
+	}{
+		{
+			name:   "hole result",
+			entry:  models.AuditEntry{Action: models.AuditHoleResult, MatchID: "m-1", Hole: 3},
+			before: `""`, after: `"team1"`,
+		},
+		{
+			name:   "player link",
+			entry:  models.AuditEntry{Action: models.AuditPlayerLink, PlayerID: "p-rory"},
+			before: `""`, after: `"rory@example.com"`,
+		},
+		{
+			name:   "pairings",
+			entry:  models.AuditEntry{Action: models.AuditPairings, RoundNumber: 1},
+			before: `[]`,
+			after:  `[{"id":"<m-1>","team1Players":["<p-rory>"],"team2Players":["<p-scottie>"]}]`,
+		},
+		{
+			name:   "tournament edit",
+			entry:  models.AuditEntry{Action: models.AuditTournamentEdit},
+			before: `{"name":"Cup","teams":[{"id":"<team-eu>","players":[{"id":"<p-rory>","teamId":"<team-eu>"}]}]}`,
+			after:  `{"name":"Cup","teams":[{"id":"<team-eu>","players":[]}]}`,
+		},
+		{
+			name:   "ranking",
+			entry:  models.AuditEntry{Action: models.AuditRanking},
+			before: `null`, after: `["<p-scottie>","<p-rory>"]`,
+		},
+		{
+			name:   "IDs only replaced as whole strings",
+			entry:  models.AuditEntry{Action: models.AuditRoundSettings, RoundNumber: 1},
+			before: `{"name":"m-1 and p-rory"}`, after: `{"name":"<p-rory>"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBundle()
+			revert := &models.AuditEntry{ID: "a-revert", TournamentID: "t-old", Action: tt.entry.Action, RevertOf: "a-1"}
+			entry := tt.entry
+			entry.ID, entry.TournamentID = "a-1", "t-old"
+			entry.Before, entry.After = old(tt.before), old(tt.after)
+			// The revert comes first so that RevertOf points forward.
+			b.Audit = []*models.AuditEntry{revert, &entry}
+
+			b.Remap()
+
+			ids := map[string]string{
+				"t-old": b.Tournament.ID, "team-eu": b.Tournament.Teams[0].ID, "team-us": b.Tournament.Teams[1].ID,
+				"p-rory": b.Tournament.Teams[0].Players[0].ID, "p-scottie": b.Tournament.Teams[1].Players[0].ID,
+				"m-1": b.Tournament.Rounds[0].Matches[0].ID,
+			}
+			for old, id := range ids {
+				if id == old || id == "" {
+					t.Fatalf("%s was not given a new ID", old)
+				}
+			}
+			var pairs []string
+			for old, id := range ids {
+				pairs = append(pairs, "<"+old+">", id)
+			}
+			want := func(s string) string { return strings.NewReplacer(pairs...).Replace(s) }
+
+			got := b.Audit[1]
+			if got.ID == "a-1" || got.TournamentID != b.Tournament.ID {
+				t.Errorf("entry ID %s, tournament %s, want a new ID in %s", got.ID, got.TournamentID, b.Tournament.ID)
+			}
+			if b.Audit[0].RevertOf != got.ID {
+				t.Errorf("revert points at %s, want %s", b.Audit[0].RevertOf, got.ID)
+			}
+			if tt.entry.MatchID != "" && got.MatchID != ids[tt.entry.MatchID] {
+				t.Errorf("match ID = %s, want %s", got.MatchID, ids[tt.entry.MatchID])
+			}
+			if tt.entry.PlayerID != "" && got.PlayerID != ids[tt.entry.PlayerID] {
+				t.Errorf("player ID = %s, want %s", got.PlayerID, ids[tt.entry.PlayerID])
+			}
+			if string(got.Before) != want(tt.before) {
+				t.Errorf("before = %s, want %s", got.Before, want(tt.before))
+			}
+			if string(got.After) != want(tt.after) {
+				t.Errorf("after = %s, want %s", got.After, want(tt.after))
+			}
+		})
+	}
+}
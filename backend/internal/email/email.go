@@ -78,3 +78,35 @@ func (c *Config) SendNewUserNotification(adminEmails []string, userName, userEma
 
 	return smtp.SendMail(addr, auth, c.From, adminEmails, []byte(msg))
 }
+
+func (c *Config) SendDisputeNotification(adminEmails []string, tournamentName, matchLabel, disputedBy, comment, appURL string) error {
+	if !c.IsConfigured() || len(adminEmails) == 0 {
+		return fmt.Errorf("email not configured or no admin emails")
+	}
+
+	if comment == "" {
+		comment = "(no comment)"
+	}
+
+	subject := "Scorecard disputed - PUC Redyr Golf Scoring"
+	body := fmt.Sprintf(
+		"A match scorecard has been disputed and needs an admin to resolve it:\r\n\r\n"+
+			"Tournament: %s\r\nMatch: %s\r\nDisputed by: %s\r\nComment: %s\r\n\r\n"+
+			"Review at:\r\n%s",
+		tournamentName, matchLabel, disputedBy, comment, strings.TrimRight(appURL, "/"),
+	)
+
+	msg := fmt.Sprintf(
+		"From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		c.From, strings.Join(adminEmails, ", "), subject, body,
+	)
+
+	addr := c.Host + ":" + c.Port
+
+	var auth smtp.Auth
+	if c.User != "" {
+		auth = smtp.PlainAuth("", c.User, c.Pass, c.Host)
+	}
+
+	return smtp.SendMail(addr, auth, c.From, adminEmails, []byte(msg))
+}
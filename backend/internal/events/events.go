@@ -0,0 +1,192 @@
+// Package events is an in-process bus for domain events. Handlers publish an
+// event once a store mutation has succeeded, and side effects such as live
+// streams, webhooks and notifications subscribe to the events they care about.
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"scoring-backend/internal/models"
+	"sync"
+)
+
+// Event is any of the domain event types below.
+type Event interface {
+	eventName() string
+}
+
+// Change describes the audited part of an event: what kind of change it was,
+// where in the tournament it happened and the value on either side. Events
+// with no Change, such as scorecard attestations, are not audited.
+type Change struct {
+	Action      models.AuditAction
+	RoundNumber int
+	MatchID     string
+	Hole        int
+	PlayerID    string
+	Before      any
+	After       any
+	RevertOf    string // ID of the audit entry this change reverted
+	Version     int64  // match version a hole or match result change produced
+}
+
+// HoleRecorded is published after a hole result is saved. Before and
+// Tournament are the tournament on either side of the change.
+type HoleRecorded struct {
+	Before      *models.Tournament
+	Tournament  *models.Tournament
+	RoundNumber int
+	MatchID     string
+	Hole        int
+	Result      string
+	Actor       string
+	Change      *Change
+}
+
+// MatchUpdated is published after a match's result or attestation is changed
+// directly rather than through a hole result.
+type MatchUpdated struct {
+	Before      *models.Tournament
+	Tournament  *models.Tournament
+	RoundNumber int
+	MatchID     string
+	Actor       string
+	Change      *Change
+}
+
+// MatchDecided is published when a match's result becomes official (see
+// models.Match.IsOfficial): set by an admin, or scored hole by hole and then
+// attested by both sides.
+type MatchDecided struct {
+	Before      *models.Tournament
+	Tournament  *models.Tournament
+	RoundNumber int
+	MatchID     string
+}
+
+// PairingsSet is published after a round's matches are replaced.
+type PairingsSet struct {
+	Tournament  *models.Tournament
+	RoundNumber int
+	Actor       string
+	Change      *Change
+}
+
+// RoundLocked is published when a round goes from unlocked to locked.
+type RoundLocked struct {
+	Tournament  *models.Tournament
+	RoundNumber int
+}
+
+// TournamentUpdated is published after any change to a tournament that isn't
+// confined to one match or round's pairings: settings, locks, rosters and
+// player links. Reverting an audit entry publishes the same event as the
+// change it undid.
+type TournamentUpdated struct {
+	Tournament *models.Tournament
+	Actor      string
+	Change     *Change
+}
+
+// TournamentDeleted is published after a tournament is deleted.
+type TournamentDeleted struct {
+	TournamentID string
+}
+
+// ScorecardDisputed is published when a player disputes a match result.
+type ScorecardDisputed struct {
+	Tournament  *models.Tournament
+	RoundNumber int
+	MatchID     string
+	Email       string
+	Comment     string
+}
+
+// UserRegistered is published after a new local account is created.
+type UserRegistered struct {
+	User *models.LocalUser
+}
+
+func (HoleRecorded) eventName() string      { return "HoleRecorded" }
+func (MatchUpdated) eventName() string      { return "MatchUpdated" }
+func (MatchDecided) eventName() string      { return "MatchDecided" }
+func (PairingsSet) eventName() string       { return "PairingsSet" }
+func (RoundLocked) eventName() string       { return "RoundLocked" }
+func (TournamentUpdated) eventName() string { return "TournamentUpdated" }
+func (TournamentDeleted) eventName() string { return "TournamentDeleted" }
+func (ScorecardDisputed) eventName() string { return "ScorecardDisputed" }
+func (UserRegistered) eventName() string    { return "UserRegistered" }
+
+// Bus delivers published events to subscribers synchronously and in the
+// order they subscribed. Subscribers that do slow work should hand it off to
+// a goroutine.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []subscriber
+}
+
+type subscriber struct {
+	handle   func(context.Context, Event) error
+	required bool
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers fn for every event of type E.
+func Subscribe[E Event](b *Bus, fn func(ctx context.Context, event E)) {
+	b.add(subscriber{handle: func(ctx context.Context, ev Event) error {
+		if e, ok := ev.(E); ok {
+			fn(ctx, e)
+		}
+		return nil
+	}})
+}
+
+// SubscribeRequired registers fn for every event of type E as a side effect
+// the publisher has to know about: if fn fails or panics, Publish returns the
+// error. The audit log is one.
+func SubscribeRequired[E Event](b *Bus, fn func(ctx context.Context, event E) error) {
+	b.add(subscriber{required: true, handle: func(ctx context.Context, ev Event) error {
+		if e, ok := ev.(E); ok {
+			return fn(ctx, e)
+		}
+		return nil
+	}})
+}
+
+func (b *Bus) add(s subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, s)
+}
+
+// Publish delivers ev to its subscribers. A failing subscriber does not stop
+// the others; the mutation that caused the event has already been saved.
+// Panics are logged, and the errors of required subscribers are returned.
+func (b *Bus) Publish(ctx context.Context, ev Event) error {
+	b.mu.RLock()
+	subscribers := b.subscribers
+	b.mu.RUnlock()
+
+	var errs []error
+	for _, s := range subscribers {
+		err := func() (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					log.Printf("Subscriber to %s panicked: %v\n%s", ev.eventName(), p, debug.Stack())
+					err = fmt.Errorf("subscriber to %s panicked: %v", ev.eventName(), p)
+				}
+			}()
+			return s.handle(ctx, ev)
+		}()
+		if err != nil && s.required {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
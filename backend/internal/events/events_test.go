@@ -0,0 +1,84 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPublish(t *testing.T) {
+	// Each subscriber records its name when called and then does what its
+	// kind says.
+	type sub struct {
+		name     string
+		required bool
+		kind     string // "ok", "fail" or "panic"
+	}
+	tests := []struct {
+		name    string
+		subs    []sub
+		wantErr []string // substrings of the returned error; none for nil
+	}{
+		{"no subscribers", nil, nil},
+		{"all succeed", []sub{{"a", false, "ok"}, {"b", true, "ok"}}, nil},
+		{"optional subscriber fails", []sub{{"a", false, "fail"}, {"b", false, "ok"}}, nil},
+		{"optional subscriber panics", []sub{{"a", false, "panic"}, {"b", true, "ok"}}, nil},
+		{"required subscriber fails", []sub{{"a", true, "fail"}, {"b", false, "ok"}}, []string{"a failed"}},
+		{"required subscriber panics", []sub{{"a", false, "ok"}, {"b", true, "panic"}, {"c", false, "ok"}}, []string{"subscriber to RoundLocked panicked: b"}},
+		{"several required fail", []sub{{"a", true, "fail"}, {"b", true, "panic"}, {"c", true, "fail"}}, []string{"a failed", "panicked: b", "c failed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBus()
+			var called []string
+			for _, s := range tt.subs {
+				fn := func(context.Context, RoundLocked) error {
+					called = append(called, s.name)
+					switch s.kind {
+					case "fail":
+						return errors.New(s.name + " failed")
+					case "panic":
+						panic(s.name)
+					}
+					return nil
+				}
+				if s.required {
+					SubscribeRequired(b, fn)
+				} else {
+					Subscribe(b, func(ctx context.Context, e RoundLocked) { fn(ctx, e) })
+				}
+			}
+			// A subscriber to another event is never called.
+			SubscribeRequired(b, func(context.Context, TournamentDeleted) error {
+				called = append(called, "other")
+				return errors.New("other event")
+			})
+
+			err := b.Publish(context.Background(), RoundLocked{RoundNumber: 1})
+
+			var want []string
+			for _, s := range tt.subs {
+				want = append(want, s.name)
+			}
+			if !slices.Equal(called, want) {
+				t.Errorf("called %v, want %v in order", called, want)
+			}
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Errorf("Publish = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Publish = nil, want an error with %q", tt.wantErr)
+			}
+			for _, s := range tt.wantErr {
+				if !strings.Contains(err.Error(), s) {
+					t.Errorf("Publish = %q, want it to contain %q", err, s)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+// Package export turns a tournament into tables of match results for
+// spreadsheets, written as CSV or XLSX.
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"scoring-backend/internal/models"
+	"strconv"
+	"strings"
+)
+
+// Sheet is a named table. Cells are strings, ints or float64s; numbers are
+// kept as numbers in XLSX so they can be summed.
+type Sheet struct {
+	Name string
+	Rows [][]any
+}
+
+// Workbook returns the matches, holes and scoreboard sheets of t.
+func Workbook(t *models.Tournament) []Sheet {
+	return []Sheet{Matches(t), Holes(t), Scoreboard(t)}
+}
+
+// Matches has one row per match with its players, result and the points each
+// side earned. Like the scoreboard's official totals, a match earns points
+// only once its result is official.
+func Matches(t *models.Tournament) Sheet {
+	team1, team2 := t.Teams[0].Name, t.Teams[1].Name
+	rows := [][]any{{
+		"Round", "Round Name", "Format", "Match",
+		team1 + " Players", team2 + " Players",
+		"Result", "Score", team1 + " Points", team2 + " Points", "Status",
+	}}
+
+	names := playerNames(t)
+	for _, round := range t.Rounds {
+		for i, m := range round.Matches {
+			var p1, p2 float64
+			switch {
+			case !m.IsOfficial():
+			case m.Result == models.ResultTeam1:
+				p1 = round.PointsPerMatch
+			case m.Result == models.ResultTeam2:
+				p2 = round.PointsPerMatch
+			case m.Result == models.ResultTie:
+				p1, p2 = round.PointsPerMatch/2, round.PointsPerMatch/2
+			}
+			rows = append(rows, []any{
+				round.Number, round.Name, string(round.Type), i + 1,
+				names.join(m.Team1Players), names.join(m.Team2Players),
+				resultLabel(t, m.Result), m.Score, p1, p2, matchStatus(&m),
+			})
+		}
+	}
+	return Sheet{Name: "Matches", Rows: rows}
+}
+
+// Holes has one row per match and a column per hole, naming the team that
+// won each hole.
+func Holes(t *models.Tournament) Sheet {
+	maxHoles := 0
+	for i := range t.Rounds {
+		maxHoles = max(maxHoles, t.Rounds[i].HoleCount())
+	}
+
+	header := []any{"Round", "Match", t.Teams[0].Name + " Players", t.Teams[1].Name + " Players"}
+	for h := 1; h <= maxHoles; h++ {
+		header = append(header, strconv.Itoa(h))
+	}
+	rows := [][]any{header}
+
+	names := playerNames(t)
+	for ri := range t.Rounds {
+		round := &t.Rounds[ri]
+		for i, m := range round.Matches {
+			row := []any{round.Number, i + 1, names.join(m.Team1Players), names.join(m.Team2Players)}
+			for h := 1; h <= round.HoleCount(); h++ {
+				row = append(row, holeLabel(t, m.HoleResults[strconv.Itoa(h)]))
+			}
+			rows = append(rows, row)
+		}
+	}
+	return Sheet{Name: "Holes", Rows: rows}
+}
+
+// Scoreboard has the official and provisional points per round and in total,
+// as calculated by the scoreboard.
+func Scoreboard(t *models.Tournament) Sheet {
+	sb := t.CalculateScoreboard()
+	rows := [][]any{{
+		"Round", "Round Name", sb.Team1Name, sb.Team2Name,
+		sb.Team1Name + " Provisional", sb.Team2Name + " Provisional",
+		"Matches Played", "Matches Awaiting", "Total Matches",
+	}}
+	for _, rs := range sb.RoundScores {
+		rows = append(rows, []any{
+			rs.RoundNumber, rs.RoundName, rs.Team1Points, rs.Team2Points,
+			rs.Team1Provisional, rs.Team2Provisional,
+			rs.MatchesPlayed, rs.MatchesAwaiting, rs.TotalMatches,
+		})
+	}
+	rows = append(rows, []any{"Total", "", sb.Team1Total, sb.Team2Total, sb.Team1Provisional, sb.Team2Provisional})
+	return Sheet{Name: "Scoreboard", Rows: rows}
+}
+
+// WriteCSV writes a sheet as CSV. Text that a spreadsheet would take for a
+// formula, such as a player named "=HYPERLINK(...)", is prefixed with a quote
+// so it opens as text.
+func WriteCSV(w io.Writer, s Sheet) error {
+	cw := csv.NewWriter(w)
+	for _, row := range s.Rows {
+		record := make([]string, len(row))
+		for i, cell := range row {
+			record[i] = csvCell(cell)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// formulaPrefixes are the characters that make a spreadsheet read a CSV cell
+// as a formula.
+const formulaPrefixes = "=+-@\t\r"
+
+// csvCell formats a cell for CSV, quoting text that would read as a formula.
+// Numbers are left alone so negative ones stay numbers.
+func csvCell(cell any) string {
+	text := formatCell(cell)
+	if _, ok := cell.(string); ok && text != "" && strings.ContainsRune(formulaPrefixes, rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func formatCell(cell any) string {
+	switch v := cell.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type nameIndex map[string]string
+
+func playerNames(t *models.Tournament) nameIndex {
+	names := make(nameIndex)
+	for _, team := range t.Teams {
+		for _, p := range team.Players {
+			names[p.ID] = p.Name
+		}
+	}
+	return names
+}
+
+func (n nameIndex) join(ids []string) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, n[id])
+	}
+	return strings.Join(parts, " & ")
+}
+
+func resultLabel(t *models.Tournament, r models.MatchResult) string {
+	switch r {
+	case models.ResultTeam1:
+		return t.Teams[0].Name
+	case models.ResultTeam2:
+		return t.Teams[1].Name
+	case models.ResultTie:
+		return "Halved"
+	default:
+		return "Pending"
+	}
+}
+
+func holeLabel(t *models.Tournament, result string) string {
+	switch result {
+	case "team1":
+		return t.Teams[0].Name
+	case "team2":
+		return t.Teams[1].Name
+	case "halved":
+		return "Halved"
+	default:
+		return ""
+	}
+}
+
+func matchStatus(m *models.Match) string {
+	switch {
+	case (m.Result == models.ResultPending || m.Result == "") && len(m.HoleResults) == 0:
+		return "Not started"
+	case m.Result == models.ResultPending || m.Result == "":
+		return "In progress"
+	case m.AttestationStatus == models.AttestationAwaiting:
+		return "Awaiting attestation"
+	case m.AttestationStatus == models.AttestationDisputed:
+		return "Disputed"
+	default:
+		return "Official"
+	}
+}
@@ -0,0 +1,453 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"scoring-backend/internal/events"
+	"scoring-backend/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// matchResultValue is the audited value of a manually set match result.
+type matchResultValue struct {
+	Result models.MatchResult `json:"result"`
+	Score  string             `json:"score"`
+}
+
+// attestationValue is the audited value of a match's attestation state.
+type attestationValue struct {
+	Status       models.AttestationStatus `json:"status"`
+	Attestations []models.Attestation     `json:"attestations"`
+}
+
+func attestationOf(m *models.Match) attestationValue {
+	return attestationValue{Status: m.AttestationStatus, Attestations: m.Attestations}
+}
+
+// tournamentSettings is the audited value of a tournament edit.
+type tournamentSettings struct {
+	Name            string         `json:"name"`
+	HeaderColor     string         `json:"headerColor,omitempty"`
+	BgColor         string         `json:"bgColor,omitempty"`
+	CombineRounds23 bool           `json:"combineRounds23,omitempty"`
+	Teams           [2]models.Team `json:"teams"`
+}
+
+// roundSettings is the audited value of a round settings edit.
+type roundSettings struct {
+	Name           string  `json:"name"`
+	Holes          int     `json:"holes,omitempty"`
+	PointsPerMatch float64 `json:"pointsPerMatch"`
+}
+
+func settingsOf(t *models.Tournament) tournamentSettings {
+	s := tournamentSettings{
+		Name:            t.Name,
+		HeaderColor:     t.HeaderColor,
+		BgColor:         t.BgColor,
+		CombineRounds23: t.CombineRounds23,
+		Teams:           t.Teams,
+	}
+	// Copy player slices so later edits to t don't leak into the snapshot.
+	for i := range s.Teams {
+		s.Teams[i].Players = append([]models.Player(nil), t.Teams[i].Players...)
+	}
+	return s
+}
+
+func roundSettingsOf(r *models.Round) roundSettings {
+	return roundSettings{Name: r.Name, Holes: r.Holes, PointsPerMatch: r.PointsPerMatch}
+}
+
+func findRound(t *models.Tournament, roundNumber int) *models.Round {
+	for i := range t.Rounds {
+		if t.Rounds[i].Number == roundNumber {
+			return &t.Rounds[i]
+		}
+	}
+	return nil
+}
+
+func findMatch(t *models.Tournament, roundNumber int, matchID string) *models.Match {
+	round := findRound(t, roundNumber)
+	if round == nil {
+		return nil
+	}
+	for i := range round.Matches {
+		if round.Matches[i].ID == matchID {
+			return &round.Matches[i]
+		}
+	}
+	return nil
+}
+
+func findPlayer(t *models.Tournament, playerID string) *models.Player {
+	for ti := range t.Teams {
+		for pi := range t.Teams[ti].Players {
+			if t.Teams[ti].Players[pi].ID == playerID {
+				return &t.Teams[ti].Players[pi]
+			}
+		}
+	}
+	return nil
+}
+
+// recordAudit appends an entry for an audited change to the tournament's audit
+// log. It runs as a required event subscriber: the mutation has already been
+// saved by the time it does, and a failure is returned to the handler that
+// published the change so the client hears about it.
+func (h *Handler) recordAudit(ctx context.Context, tournamentID, actor string, c *events.Change) error {
+	if c == nil {
+		return nil
+	}
+	entry := models.AuditEntry{
+		ID:           uuid.New().String(),
+		TournamentID: tournamentID,
+		Action:       c.Action,
+		RoundNumber:  c.RoundNumber,
+		MatchID:      c.MatchID,
+		Hole:         c.Hole,
+		PlayerID:     c.PlayerID,
+		UserEmail:    actor,
+		RevertOf:     c.RevertOf,
+		Version:      c.Version,
+		CreatedAt:    time.Now(),
+	}
+
+	var err error
+	if entry.Before, err = json.Marshal(c.Before); err != nil {
+		return fmt.Errorf("encoding audit value: %w", err)
+	}
+	if entry.After, err = json.Marshal(c.After); err != nil {
+		return fmt.Errorf("encoding audit value: %w", err)
+	}
+
+	if err := h.store.AppendAuditEntry(ctx, &entry); err != nil {
+		log.Printf("Failed to record audit entry for tournament %s: %v", entry.TournamentID, err)
+		return fmt.Errorf("recording audit entry: %w", err)
+	}
+	return nil
+}
+
+func (h *Handler) ListAudit(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.store.GetTournament(r.Context(), id); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	entries, err := h.store.ListAuditEntries(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (h *Handler) ListMatchAudit(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	matchID := r.PathValue("matchId")
+	roundNum, err := strconv.Atoi(r.PathValue("round"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid round number")
+		return
+	}
+
+	t, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if findMatch(t, roundNum, matchID) == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("match %s not found in round %d", matchID, roundNum))
+		return
+	}
+
+	entries, err := h.store.ListAuditEntries(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	result := make([]*models.AuditEntry, 0)
+	for _, e := range entries {
+		if e.RoundNumber == roundNum && e.MatchID == matchID {
+			result = append(result, e)
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// RevertAudit restores the Before value of an audit entry. The revert is itself
+// recorded as a new entry pointing back at the one it undid.
+func (h *Handler) RevertAudit(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	entryID := r.PathValue("entryId")
+
+	entries, err := h.store.ListAuditEntries(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var entry *models.AuditEntry
+	for _, e := range entries {
+		if e.ID == entryID {
+			entry = e
+			break
+		}
+	}
+	if entry == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("audit entry %s not found", entryID))
+		return
+	}
+
+	t, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if !checkIfMatch(w, r, t, true) {
+		return
+	}
+
+	current, status, err := h.revertEntry(r, t, entry)
+	if err != nil {
+		writeStoreError(w, status, err)
+		return
+	}
+
+	updated, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = h.publishRevert(r, t, updated, entry, current)
+	writeSaved(w, http.StatusOK, updated, err)
+}
+
+// publishRevert publishes the same typed event the reverted change published
+// when it was first made, so live clients, webhooks and the audit log see a
+// revert exactly as they would the equivalent forward edit. current is the
+// value the revert replaced. It returns the error from a required subscriber.
+func (h *Handler) publishRevert(r *http.Request, before, updated *models.Tournament, entry *models.AuditEntry, current any) error {
+	change := &events.Change{
+		Action:      entry.Action,
+		RoundNumber: entry.RoundNumber,
+		MatchID:     entry.MatchID,
+		Hole:        entry.Hole,
+		PlayerID:    entry.PlayerID,
+		Before:      current,
+		After:       entry.Before,
+		RevertOf:    entry.ID,
+	}
+	if entry.Action == models.AuditHoleResult || entry.Action == models.AuditMatchResult {
+		// revertEntry made the write conditional on the match's version in before.
+		if match := findMatch(before, entry.RoundNumber, entry.MatchID); match != nil {
+			change.Version = match.Version + 1
+		}
+	}
+
+	switch entry.Action {
+	case models.AuditHoleResult:
+		var result string
+		if match := findMatch(updated, entry.RoundNumber, entry.MatchID); match != nil {
+			result = match.HoleResults[strconv.Itoa(entry.Hole)]
+		}
+		return h.publishMatchChange(r, events.HoleRecorded{
+			Before:      before,
+			Tournament:  updated,
+			RoundNumber: entry.RoundNumber,
+			MatchID:     entry.MatchID,
+			Hole:        entry.Hole,
+			Result:      result,
+			Actor:       actor(r),
+			Change:      change,
+		})
+
+	case models.AuditMatchResult, models.AuditTeeTime:
+		return h.publishMatchChange(r, events.MatchUpdated{
+			Before:      before,
+			Tournament:  updated,
+			RoundNumber: entry.RoundNumber,
+			MatchID:     entry.MatchID,
+			Actor:       actor(r),
+			Change:      change,
+		})
+
+	case models.AuditPairings:
+		return h.events.Publish(r.Context(), events.PairingsSet{Tournament: updated, RoundNumber: entry.RoundNumber, Actor: actor(r), Change: change})
+
+	default:
+		err := h.events.Publish(r.Context(), events.TournamentUpdated{Tournament: updated, Actor: actor(r), Change: change})
+		if entry.Action == models.AuditRoundLock {
+			if wasLocked, _ := current.(bool); !wasLocked {
+				if round := findRound(updated, entry.RoundNumber); round != nil && round.Locked {
+					err = errors.Join(err, h.events.Publish(r.Context(), events.RoundLocked{Tournament: updated, RoundNumber: entry.RoundNumber}))
+				}
+			}
+		}
+		return err
+	}
+}
+
+// revertEntry applies entry.Before to the tournament, provided it is still at
+// the version the request's If-Match named and, for a hole or match result,
+// the match hasn't changed since t was read. It returns the value it
+// replaced, along with an HTTP status to use if it fails.
+func (h *Handler) revertEntry(r *http.Request, t *models.Tournament, entry *models.AuditEntry) (any, int, error) {
+	ctx := r.Context()
+	want := precondition(r, t)
+	badValue := func(err error) (any, int, error) {
+		return nil, http.StatusInternalServerError, fmt.Errorf("decoding audit entry %s: %w", entry.ID, err)
+	}
+
+	switch entry.Action {
+	case models.AuditHoleResult:
+		match := findMatch(t, entry.RoundNumber, entry.MatchID)
+		if match == nil {
+			return nil, http.StatusConflict, fmt.Errorf("match %s no longer exists", entry.MatchID)
+		}
+		var before string
+		if err := json.Unmarshal(entry.Before, &before); err != nil {
+			return badValue(err)
+		}
+		current := match.HoleResults[strconv.Itoa(entry.Hole)]
+		want.MatchVersion = match.Version
+		if err := h.store.UpdateHoleResult(ctx, t.ID, entry.RoundNumber, entry.MatchID, entry.Hole, before, want); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return current, 0, nil
+
+	case models.AuditMatchResult:
+		match := findMatch(t, entry.RoundNumber, entry.MatchID)
+		if match == nil {
+			return nil, http.StatusConflict, fmt.Errorf("match %s no longer exists", entry.MatchID)
+		}
+		var before matchResultValue
+		if err := json.Unmarshal(entry.Before, &before); err != nil {
+			return badValue(err)
+		}
+		current := matchResultValue{Result: match.Result, Score: match.Score}
+		want.MatchVersion = match.Version
+		if err := h.store.UpdateMatchResult(ctx, t.ID, entry.RoundNumber, entry.MatchID, before.Result, before.Score, want); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return current, 0, nil
+
+	case models.AuditPairings:
+		round := findRound(t, entry.RoundNumber)
+		if round == nil {
+			return nil, http.StatusConflict, fmt.Errorf("round %d no longer exists", entry.RoundNumber)
+		}
+		var before []models.Match
+		if err := json.Unmarshal(entry.Before, &before); err != nil {
+			return badValue(err)
+		}
+		if before == nil {
+			before = []models.Match{}
+		}
+		// Put back, the old matches move on a version, so a device that last
+		// saw them before they were replaced counts the revert as a change.
+		for i := range before {
+			before[i].Version = max(before[i].Version, 1) + 1
+		}
+		current := round.Matches
+		if err := h.store.SetRoundPairings(ctx, t.ID, entry.RoundNumber, before, want); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return current, 0, nil
+
+	case models.AuditPlayerLink:
+		player := findPlayer(t, entry.PlayerID)
+		if player == nil {
+			return nil, http.StatusConflict, fmt.Errorf("player %s no longer exists", entry.PlayerID)
+		}
+		var before string
+		if err := json.Unmarshal(entry.Before, &before); err != nil {
+			return badValue(err)
+		}
+		current := player.UserEmail
+		if err := h.store.LinkPlayer(ctx, t.ID, entry.PlayerID, before, want); err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		return current, 0, nil
+	}
+
+	// The remaining actions edit the tournament document as a whole.
+	var current any
+	switch entry.Action {
+	case models.AuditTournamentLock, models.AuditRankingsLock:
+		var before bool
+		if err := json.Unmarshal(entry.Before, &before); err != nil {
+			return badValue(err)
+		}
+		if entry.Action == models.AuditTournamentLock {
+			current, t.Locked = t.Locked, before
+		} else {
+			current, t.RankingsLocked = t.RankingsLocked, before
+		}
+
+	case models.AuditRoundLock:
+		round := findRound(t, entry.RoundNumber)
+		if round == nil {
+			return nil, http.StatusConflict, fmt.Errorf("round %d no longer exists", entry.RoundNumber)
+		}
+		var before bool
+		if err := json.Unmarshal(entry.Before, &before); err != nil {
+			return badValue(err)
+		}
+		current, round.Locked = round.Locked, before
+
+	case models.AuditRoundSettings:
+		round := findRound(t, entry.RoundNumber)
+		if round == nil {
+			return nil, http.StatusConflict, fmt.Errorf("round %d no longer exists", entry.RoundNumber)
+		}
+		var before roundSettings
+		if err := json.Unmarshal(entry.Before, &before); err != nil {
+			return badValue(err)
+		}
+		current = roundSettingsOf(round)
+		round.Name = before.Name
+		round.Holes = before.Holes
+		round.PointsPerMatch = before.PointsPerMatch
+
+	case models.AuditTeeTime:
+		match := findMatch(t, entry.RoundNumber, entry.MatchID)
+		if match == nil {
+			return nil, http.StatusConflict, fmt.Errorf("match %s no longer exists", entry.MatchID)
+		}
+		var before *time.Time
+		if err := json.Unmarshal(entry.Before, &before); err != nil {
+			return badValue(err)
+		}
+		current, match.TeeTime = match.TeeTime, before
+
+	case models.AuditTournamentEdit:
+		var before tournamentSettings
+		if err := json.Unmarshal(entry.Before, &before); err != nil {
+			return badValue(err)
+		}
+		current = settingsOf(t)
+		t.Name = before.Name
+		t.HeaderColor = before.HeaderColor
+		t.BgColor = before.BgColor
+		t.CombineRounds23 = before.CombineRounds23
+		t.Teams = before.Teams
+
+	default:
+		return nil, http.StatusBadRequest, fmt.Errorf("audit entries of type %s cannot be reverted", entry.Action)
+	}
+
+	if err := h.store.UpdateTournament(ctx, t); err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	return current, 0, nil
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"scoring-backend/internal/auth"
+	"scoring-backend/internal/bundle"
+	"scoring-backend/internal/events"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/store"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// failingAuditStore refuses every audit entry.
+type failingAuditStore struct {
+	store.Store
+}
+
+func (failingAuditStore) AppendAuditEntry(context.Context, *models.AuditEntry) error {
+	return errors.New("disk full")
+}
+
+func TestAuditFailureIsAWarning(t *testing.T) {
+	s := failingAuditStore{store.NewMemoryStore()}
+	h := New(s, nil, "secret", "http://localhost", nil)
+	tour := newSyncTournament(t, s)
+	match := tour.Rounds[0].Matches[0]
+
+	body, _ := json.Marshal(UpdateHoleResultRequest{Result: "team1"})
+	r := httptest.NewRequest(http.MethodPut, "/", bytes.NewReader(body))
+	r.SetPathValue("id", tour.ID)
+	r.SetPathValue("round", "1")
+	r.SetPathValue("matchId", match.ID)
+	r.SetPathValue("hole", "1")
+	r = r.WithContext(context.WithValue(r.Context(), auth.UserKey, &auth.UserClaims{Email: syncPlayer1}))
+	w := httptest.NewRecorder()
+	h.UpdateHoleResult(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		ID      string `json:"id"`
+		Version int64  `json:"version"`
+		Warning string `json:"warning"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.ID != tour.ID || resp.Version != tour.Version+1 || resp.Warning == "" {
+		t.Errorf("response = %+v, want the updated tournament with a warning", resp)
+	}
+
+	got, err := s.GetTournament(context.Background(), tour.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := got.Rounds[0].Matches[0].HoleResults["1"]; result != "team1" {
+		t.Errorf("stored hole 1 = %q, want team1", result)
+	}
+}
+
+func TestAttestMatchIsAudited(t *testing.T) {
+	s := store.NewMemoryStore()
+	h := New(s, nil, "secret", "http://localhost", nil)
+	tour := newSyncTournament(t, s)
+	match := tour.Rounds[0].Matches[0]
+	for hole := 1; hole <= 10; hole++ {
+		if err := s.UpdateHoleResult(context.Background(), tour.ID, 1, match.ID, hole, "team1", store.Precondition{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var disputed *models.Tournament
+	events.Subscribe(h.events, func(_ context.Context, e events.ScorecardDisputed) { disputed = e.Tournament })
+
+	body, _ := json.Marshal(AttestMatchRequest{Confirmed: false, Comment: "hole 7 was halved"})
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.SetPathValue("id", tour.ID)
+	r.SetPathValue("round", "1")
+	r.SetPathValue("matchId", match.ID)
+	r = r.WithContext(context.WithValue(r.Context(), auth.UserKey, &auth.UserClaims{Email: syncPlayer2}))
+	w := httptest.NewRecorder()
+	h.AttestMatch(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	entries, err := s.ListAuditEntries(context.Background(), tour.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Action != models.AuditAttestation || entries[0].UserEmail != syncPlayer2 {
+		t.Fatalf("audit = %+v, want one attestation by %s", entries, syncPlayer2)
+	}
+	var after attestationValue
+	if err := json.Unmarshal(entries[0].After, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.Status != models.AttestationDisputed || len(after.Attestations) != 1 {
+		t.Errorf("audited attestation = %+v, want the dispute", after)
+	}
+
+	// The dispute is published with the match as it now stands.
+	if disputed == nil || findMatch(disputed, 1, match.ID).AttestationStatus != models.AttestationDisputed {
+		t.Error("ScorecardDisputed was not published with the disputed match")
+	}
+}
+
+func TestSubmitRankingIsAudited(t *testing.T) {
+	s := store.NewMemoryStore()
+	h := New(s, nil, "secret", "http://localhost", nil)
+	tour := newSyncTournament(t, s)
+	team := &tour.Teams[0]
+	team.Players = append(team.Players, models.Player{ID: uuid.NewString(), Name: "Partner", TeamID: team.ID})
+	if err := s.UpdateTournament(context.Background(), tour); err != nil {
+		t.Fatal(err)
+	}
+	first := []string{team.Players[0].ID, team.Players[1].ID}
+	second := []string{team.Players[1].ID, team.Players[0].ID}
+
+	submit := func(ids []string) {
+		t.Helper()
+		body, _ := json.Marshal(map[string][]string{"playerIds": ids})
+		r := httptest.NewRequest(http.MethodPut, "/", bytes.NewReader(body))
+		r.SetPathValue("id", tour.ID)
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserKey, &auth.UserClaims{Email: syncPlayer1}))
+		w := httptest.NewRecorder()
+		h.SubmitRanking(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", w.Code, w.Body)
+		}
+	}
+	submit(first)
+	// A hole scored in between doesn't get in the way of the resubmission.
+	changeHole(t, s, tour, syncPlayer2, "team2", time.Now())
+	submit(second)
+
+	got, err := s.GetTournament(context.Background(), tour.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Rankings) != 1 || !slices.Equal(got.Rankings[0].PlayerIDs, second) {
+		t.Errorf("rankings = %+v, want only the resubmitted one", got.Rankings)
+	}
+	if result := got.Rounds[0].Matches[0].HoleResults["1"]; result != "team2" {
+		t.Errorf("hole 1 = %q, want team2 kept", result)
+	}
+
+	entries, err := s.ListAuditEntries(context.Background(), tour.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rankings []*models.AuditEntry
+	for _, e := range entries {
+		if e.Action == models.AuditRanking {
+			rankings = append(rankings, e)
+		}
+	}
+	if len(rankings) != 2 || rankings[1].UserEmail != syncPlayer1 {
+		t.Fatalf("audit = %+v, want two rankings by %s", rankings, syncPlayer1)
+	}
+	var before, after []string
+	if err := json.Unmarshal(rankings[1].Before, &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(rankings[1].After, &after); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(before, first) || !slices.Equal(after, second) {
+		t.Errorf("audited ranking %v -> %v, want %v -> %v", before, after, first, second)
+	}
+}
+
+func TestImportBundle(t *testing.T) {
+	src := store.NewMemoryStore()
+	tour := newSyncTournament(t, src)
+	entry := &models.AuditEntry{ID: uuid.NewString(), TournamentID: tour.ID, Action: models.AuditTournamentLock, UserEmail: syncAdmin, Before: json.RawMessage(`false`), After: json.RawMessage(`true`), CreatedAt: time.Now()}
+	if err := src.AppendAuditEntry(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+	b, err := bundle.Export(context.Background(), src, tour.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	importBundle := func(h *Handler) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/?keepIds=true", bytes.NewReader(data))
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserKey, &auth.UserClaims{Email: syncAdmin, IsAdmin: true}))
+		w := httptest.NewRecorder()
+		h.ImportBundle(w, r)
+		return w
+	}
+
+	// An audit log that can't be written takes the tournament with it.
+	failing := failingAuditStore{store.NewMemoryStore()}
+	if w := importBundle(New(failing, nil, "secret", "http://localhost", nil)); w.Code != http.StatusInternalServerError {
+		t.Errorf("import with a failing audit log: status %d, want 500", w.Code)
+	}
+	if _, err := failing.GetTournament(context.Background(), tour.ID); err == nil {
+		t.Error("tournament kept after its audit log failed to import")
+	}
+
+	s := store.NewMemoryStore()
+	h := New(s, nil, "secret", "http://localhost", nil)
+	if w := importBundle(h); w.Code != http.StatusCreated {
+		t.Fatalf("import: status %d: %s", w.Code, w.Body)
+	}
+	if w := importBundle(h); w.Code != http.StatusConflict {
+		t.Errorf("second import: status %d, want 409", w.Code)
+	}
+
+	entries, err := s.ListAuditEntries(context.Background(), tour.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].ID != entry.ID || entries[1].Action != models.AuditImport {
+		t.Fatalf("audit = %+v, want the imported entry then the import", entries)
+	}
+	var after importValue
+	if err := json.Unmarshal(entries[1].After, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after != (importValue{From: tour.ID, AuditEntries: 1}) {
+		t.Errorf("audited import = %+v", after)
+	}
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"scoring-backend/internal/bundle"
+	"scoring-backend/internal/events"
+	"scoring-backend/internal/models"
+	"strconv"
+)
+
+// maxBundleSize limits an uploaded bundle. Audit logs of long tournaments make
+// bundles much larger than the tournament itself.
+const maxBundleSize = 32 << 20
+
+// ExportBundle returns the tournament, its audit log and the users linked to
+// its players as a versioned JSON bundle.
+func (h *Handler) ExportBundle(w http.ResponseWriter, r *http.Request) {
+	b, err := bundle.Export(r.Context(), h.store, r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, exportFilename(b.Tournament, "bundle.json")))
+	writeJSON(w, http.StatusOK, b)
+}
+
+// importValue is what the audit entry of an import records: the ID the
+// tournament had in the bundle and how many audit entries came with it.
+type importValue struct {
+	From         string `json:"from"`
+	AuditEntries int    `json:"auditEntries"`
+}
+
+// ImportBundle creates a tournament from a bundle of any supported schema
+// version. The tournament, teams, players, matches and audit entries get new
+// IDs unless keepIds is set, in which case the tournament must not already
+// exist.
+func (h *Handler) ImportBundle(w http.ResponseWriter, r *http.Request) {
+	keepIDs, _ := strconv.ParseBool(r.URL.Query().Get("keepIds"))
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBundleSize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "bundle is too large")
+		return
+	}
+	b, err := bundle.Decode(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	from := b.Tournament.ID
+	if !keepIDs {
+		b.Remap()
+	}
+
+	result, err := bundle.Import(r.Context(), h.store, b)
+	if errors.Is(err, bundle.ErrExists) {
+		writeError(w, http.StatusConflict, fmt.Sprintf("tournament %s already exists", b.Tournament.ID))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: result.Tournament,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditImport, After: importValue{From: from, AuditEntries: result.AuditEntries}},
+	})
+	writeSaved(w, http.StatusCreated, result, err)
+}
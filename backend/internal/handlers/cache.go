@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"net/http"
+	"scoring-backend/internal/store"
+)
+
+// CacheStats reports the hit rate of the read cache in front of the store.
+func (h *Handler) CacheStats(w http.ResponseWriter, r *http.Request) {
+	cache, ok := h.store.(*store.CachingStore)
+	if !ok {
+		writeError(w, http.StatusNotFound, "caching is not enabled (set CACHE_TTL)")
+		return
+	}
+	writeJSON(w, http.StatusOK, cache.Stats())
+}
+
+// FlushCache drops everything the read cache holds, e.g. after changing the
+// backend's data outside the server.
+func (h *Handler) FlushCache(w http.ResponseWriter, r *http.Request) {
+	cache, ok := h.store.(*store.CachingStore)
+	if !ok {
+		writeError(w, http.StatusNotFound, "caching is not enabled (set CACHE_TTL)")
+		return
+	}
+	cache.Flush()
+	writeJSON(w, http.StatusOK, cache.Stats())
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"scoring-backend/internal/auth"
+	"scoring-backend/internal/ical"
+	"scoring-backend/internal/models"
+	"strings"
+	"time"
+)
+
+// minutesPerHole sets how long a calendar entry blocks out for a match.
+const minutesPerHole = 15
+
+// GetCalendarLink returns the signed-in user's personal calendar feed URL.
+func (h *Handler) GetCalendarLink(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUser(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	local, err := h.store.GetLocalUser(r.Context(), user.Email)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"url": h.calendarURL(r, local)})
+}
+
+// ResetCalendarLink gives the signed-in user a new calendar feed URL. Any
+// calendar still subscribed to the old one stops receiving updates.
+func (h *Handler) ResetCalendarLink(w http.ResponseWriter, r *http.Request) {
+	user := auth.GetUser(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+	key, err := auth.GenerateVerificationToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to generate calendar key")
+		return
+	}
+	if err := h.store.SetCalendarKey(r.Context(), user.Email, key); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	local, err := h.store.GetLocalUser(r.Context(), user.Email)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"url": h.calendarURL(r, local)})
+}
+
+// calendarURL is the feed URL for user, on the host the request came in on.
+func (h *Handler) calendarURL(r *http.Request, user *models.LocalUser) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	token := auth.GenerateCalendarToken(user.Email, user.CalendarKey, h.jwtSecret)
+	return fmt.Sprintf("%s://%s/api/calendar/%s/tee-times.ics", scheme, r.Host, token)
+}
+
+// CalendarFeed serves an iCalendar feed of the matches, across all
+// tournaments, that the token's user is linked to as a player. Matches
+// without a tee time are left out.
+func (h *Handler) CalendarFeed(w http.ResponseWriter, r *http.Request) {
+	token := r.PathValue("token")
+	email, err := auth.CalendarTokenEmail(token)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "calendar not found")
+		return
+	}
+	// A disabled or deleted account loses its feed, and a reset link
+	// replaces the key the old one was signed with.
+	user, err := h.store.GetLocalUser(r.Context(), email)
+	if err != nil || user.Disabled || auth.ValidateCalendarToken(token, user.CalendarKey, h.jwtSecret) != nil {
+		writeError(w, http.StatusNotFound, "calendar not found")
+		return
+	}
+
+	tournaments, err := h.store.ListTournaments(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cal := ical.Calendar{Name: "Tee times"}
+	for _, t := range tournaments {
+		cal.Events = append(cal.Events, teeTimeEvents(t, email)...)
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Content-Disposition", `inline; filename="tee-times.ics"`)
+	if err := cal.Write(w); err != nil {
+		log.Printf("Failed to write calendar feed: %v", err)
+	}
+}
+
+// teeTimeEvents returns a calendar event for each of the player's matches in
+// t that has a tee time, e.g. "Four-Ball vs Smith/Jones".
+func teeTimeEvents(t *models.Tournament, email string) []ical.Event {
+	names := make(map[string]string)
+	emails := make(map[string]string)
+	for _, team := range t.Teams {
+		for _, p := range team.Players {
+			names[p.ID] = p.Name
+			emails[p.ID] = strings.ToLower(p.UserEmail)
+		}
+	}
+	others := func(ids []string, self string) []string {
+		out := make([]string, 0, len(ids))
+		for _, id := range ids {
+			if id != self {
+				out = append(out, names[id])
+			}
+		}
+		return out
+	}
+
+	var result []ical.Event
+	for ri := range t.Rounds {
+		round := &t.Rounds[ri]
+		for mi := range round.Matches {
+			m := &round.Matches[mi]
+			if m.TeeTime == nil {
+				continue
+			}
+			side := playerSideInMatch(t, round.Number, m.ID, email)
+			if side == "" {
+				continue
+			}
+
+			ours, theirs := m.Team1Players, m.Team2Players
+			ourTeam, theirTeam := t.Teams[0].Name, t.Teams[1].Name
+			if side == "team2" {
+				ours, theirs = theirs, ours
+				ourTeam, theirTeam = theirTeam, ourTeam
+			}
+			self := ""
+			for _, id := range ours {
+				if emails[id] == email {
+					self = id
+				}
+			}
+			opponents := others(theirs, "")
+			partners := others(ours, self)
+
+			desc := []string{
+				t.Name,
+				fmt.Sprintf("%s, match %d", round.Name, mi+1),
+				fmt.Sprintf("%s vs %s", ourTeam, theirTeam),
+				"Opponents: " + strings.Join(opponents, ", "),
+			}
+			if len(partners) > 0 {
+				desc = append(desc, "Partner: "+strings.Join(partners, ", "))
+			}
+			if m.Result != models.ResultPending && m.Score != "" {
+				desc = append(desc, "Result: "+m.Score)
+			}
+
+			result = append(result, ical.Event{
+				UID:         m.ID + "@golf-matchplay",
+				Start:       *m.TeeTime,
+				End:         m.TeeTime.Add(time.Duration(round.HoleCount()*minutesPerHole) * time.Minute),
+				Summary:     fmt.Sprintf("%s vs %s", round.Name, strings.Join(opponents, "/")),
+				Description: strings.Join(desc, "\n"),
+				Updated:     t.UpdatedAt,
+			})
+		}
+	}
+	return result
+}
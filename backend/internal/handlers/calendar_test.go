@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"scoring-backend/internal/auth"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/store"
+	"testing"
+	"time"
+)
+
+func TestResetCalendarLink(t *testing.T) {
+	s := store.NewMemoryStore()
+	h := New(s, nil, "secret", "http://localhost", nil)
+	mux := http.NewServeMux()
+	h.RegisterRoutes(mux)
+	const email = "golfer@example.com"
+	if err := s.CreateLocalUser(context.Background(), &models.LocalUser{Email: email, Name: "Golfer", CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	link := func(method, path string) string {
+		t.Helper()
+		r := httptest.NewRequest(method, path, nil)
+		r = r.WithContext(context.WithValue(r.Context(), auth.UserKey, &auth.UserClaims{Email: email}))
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s %s: status %d: %s", method, path, w.Code, w.Body)
+		}
+		var resp struct{ URL string }
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatal(err)
+		}
+		u, err := url.Parse(resp.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u.Path
+	}
+	feed := func(path string) int {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w.Code
+	}
+
+	old := link(http.MethodGet, "/api/me/calendar")
+	if code := feed(old); code != http.StatusOK {
+		t.Fatalf("feed before reset: status %d", code)
+	}
+
+	reset := link(http.MethodPost, "/api/me/calendar/reset")
+	if reset == old {
+		t.Fatal("reset returned the same link")
+	}
+	if code := feed(old); code != http.StatusNotFound {
+		t.Errorf("old feed after reset: status %d, want 404", code)
+	}
+	if code := feed(reset); code != http.StatusOK {
+		t.Errorf("new feed: status %d, want 200", code)
+	}
+	if current := link(http.MethodGet, "/api/me/calendar"); current != reset {
+		t.Errorf("link after reset = %s, want %s", current, reset)
+	}
+}
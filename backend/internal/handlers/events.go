@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"scoring-backend/internal/live"
+	"scoring-backend/internal/models"
+	"time"
+)
+
+// heartbeatInterval keeps idle event streams from being closed by proxies.
+const heartbeatInterval = 25 * time.Second
+
+// holeEvent is the payload of a "hole" event.
+type holeEvent struct {
+	Version     int64         `json:"version"`
+	RoundNumber int           `json:"roundNumber"`
+	Hole        int           `json:"hole"`
+	Match       *models.Match `json:"match"`
+}
+
+// matchEvent is the payload of a "match" event.
+type matchEvent struct {
+	Version     int64         `json:"version"`
+	RoundNumber int           `json:"roundNumber"`
+	Match       *models.Match `json:"match"`
+}
+
+// pairingsEvent is the payload of a "pairings" event.
+type pairingsEvent struct {
+	Version     int64          `json:"version"`
+	RoundNumber int            `json:"roundNumber"`
+	Matches     []models.Match `json:"matches"`
+}
+
+// snapshotEvent is sent when a client connects without a usable Last-Event-ID.
+type snapshotEvent struct {
+	Tournament *models.Tournament `json:"tournament"`
+	Scoreboard models.Scoreboard  `json:"scoreboard"`
+}
+
+// streamTournament returns the copy of t sent to every subscriber. Rankings are
+// private to their submitter and the admins, so they are left out.
+func streamTournament(t *models.Tournament) *models.Tournament {
+	c := *t
+	c.Rankings = nil
+	return &c
+}
+
+// publishHole publishes a hole result along with the match state it produced.
+func (h *Handler) publishHole(t *models.Tournament, roundNumber int, matchID string, hole int) {
+	if t == nil {
+		return
+	}
+	if match := findMatch(t, roundNumber, matchID); match != nil {
+		h.live.Publish(t.ID, "hole", holeEvent{Version: t.Version, RoundNumber: roundNumber, Hole: hole, Match: match})
+	}
+	h.live.PublishScoreboard(t.ID, t.CalculateScoreboard())
+}
+
+func (h *Handler) publishMatch(t *models.Tournament, roundNumber int, matchID string) {
+	if t == nil {
+		return
+	}
+	if match := findMatch(t, roundNumber, matchID); match != nil {
+		h.live.Publish(t.ID, "match", matchEvent{Version: t.Version, RoundNumber: roundNumber, Match: match})
+	}
+	h.live.PublishScoreboard(t.ID, t.CalculateScoreboard())
+}
+
+func (h *Handler) publishPairings(t *models.Tournament, roundNumber int) {
+	if t == nil {
+		return
+	}
+	if round := findRound(t, roundNumber); round != nil {
+		h.live.Publish(t.ID, "pairings", pairingsEvent{Version: t.Version, RoundNumber: roundNumber, Matches: round.Matches})
+	}
+	h.live.PublishScoreboard(t.ID, t.CalculateScoreboard())
+}
+
+// publishTournament publishes the whole tournament after edits that aren't
+// tied to a single match, such as locks, settings and team changes.
+func (h *Handler) publishTournament(t *models.Tournament) {
+	if t == nil {
+		return
+	}
+	h.live.Publish(t.ID, "tournament", streamTournament(t))
+	h.live.PublishScoreboard(t.ID, t.CalculateScoreboard())
+}
+
+// TournamentEvents streams live changes to a tournament as Server-Sent Events.
+//
+// Event types are "hole", "match", "pairings", "tournament", "scoreboard" and
+// "deleted". A client that connects without a Last-Event-ID, or with one that
+// is too old to resume from, first receives a "snapshot" of the tournament and
+// scoreboard.
+func (h *Handler) TournamentEvents(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.store.GetTournament(r.Context(), id); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	rc := http.NewResponseController(w)
+
+	// Subscribe before taking the snapshot so no change can fall between them.
+	sub := h.live.Subscribe(id, r.Header.Get("Last-Event-ID"))
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, "retry: 3000\n\n")
+
+	if sub.Resumed {
+		for _, ev := range sub.Replay {
+			writeEvent(w, ev)
+		}
+	} else {
+		t, err := h.store.GetTournament(r.Context(), id)
+		if err != nil {
+			return
+		}
+		snapshot := snapshotEvent{Tournament: streamTournament(t), Scoreboard: t.CalculateScoreboard()}
+		data, err := json.Marshal(snapshot)
+		if err != nil {
+			return
+		}
+		writeEvent(w, live.Event{ID: sub.LastID, Type: "snapshot", Data: data})
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-sub.Events:
+			if !ok {
+				// Too slow to keep up, and the client will reconnect and
+				// replay, or the tournament was deleted.
+				return
+			}
+			writeEvent(w, ev)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, ev live.Event) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, ev.Data)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"scoring-backend/internal/export"
+	"scoring-backend/internal/models"
+	"strings"
+)
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// exportFilename builds a download name from the tournament name, e.g.
+// "Ryder-Cup-2026-matches.csv".
+func exportFilename(t *models.Tournament, suffix string) string {
+	base := strings.Trim(unsafeFilename.ReplaceAllString(t.Name, "-"), "-")
+	if base == "" {
+		base = "tournament"
+	}
+	return base + "-" + suffix
+}
+
+func (h *Handler) ExportMatchesCSV(w http.ResponseWriter, r *http.Request) {
+	h.exportCSV(w, r, "matches.csv", export.Matches)
+}
+
+func (h *Handler) ExportHolesCSV(w http.ResponseWriter, r *http.Request) {
+	h.exportCSV(w, r, "holes.csv", export.Holes)
+}
+
+func (h *Handler) exportCSV(w http.ResponseWriter, r *http.Request, suffix string, sheet func(*models.Tournament) export.Sheet) {
+	t, err := h.store.GetTournament(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, exportFilename(t, suffix)))
+	if err := export.WriteCSV(w, sheet(t)); err != nil {
+		log.Printf("Failed to write %s export for tournament %s: %v", suffix, t.ID, err)
+	}
+}
+
+// ExportXLSX returns a workbook with the matches, hole-by-hole and scoreboard
+// sheets.
+func (h *Handler) ExportXLSX(w http.ResponseWriter, r *http.Request) {
+	t, err := h.store.GetTournament(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, exportFilename(t, "results.xlsx")))
+	if err := export.WriteXLSX(w, export.Workbook(t)); err != nil {
+		log.Printf("Failed to write XLSX export for tournament %s: %v", t.ID, err)
+	}
+}
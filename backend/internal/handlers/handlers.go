@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"scoring-backend/internal/auth"
 	"scoring-backend/internal/email"
+	"scoring-backend/internal/events"
+	"scoring-backend/internal/live"
 	"scoring-backend/internal/models"
 	"scoring-backend/internal/store"
+	"scoring-backend/internal/webhook"
 	"strconv"
 	"strings"
 	"time"
@@ -23,16 +27,36 @@ type Handler struct {
 	jwtSecret   string
 	appURL      string
 	adminEmails map[string]bool
+	live        *live.Hub
+	webhooks    *webhook.Dispatcher
+	events      *events.Bus
 }
 
 func New(s store.Store, emailCfg *email.Config, jwtSecret, appURL string, adminEmails map[string]bool) *Handler {
-	return &Handler{
+	h := &Handler{
 		store:       s,
 		emailCfg:    emailCfg,
 		jwtSecret:   jwtSecret,
 		appURL:      appURL,
 		adminEmails: adminEmails,
+		live:        live.NewHub(),
+		webhooks:    webhook.NewDispatcher(s),
+		events:      events.NewBus(),
 	}
+	h.subscribe()
+	return h
+}
+
+// ResumeWebhooks carries on with the webhook deliveries that were waiting to
+// be retried when the server last stopped. Call it once at startup.
+func (h *Handler) ResumeWebhooks() {
+	h.webhooks.Resume()
+}
+
+// Events returns the bus that domain events are published on, so other parts
+// of the server can subscribe to them.
+func (h *Handler) Events() *events.Bus {
+	return h.events
 }
 
 func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
@@ -41,14 +65,31 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /api/auth/login", h.Login)
 	mux.HandleFunc("POST /api/auth/verify", h.VerifyEmail)
 
+	// Spectator routes, authorized by the share token in the path
+	mux.HandleFunc("GET /api/share/{token}", h.GetSharedTournament)
+	mux.HandleFunc("GET /api/share/{token}/scoreboard", h.GetSharedScoreboard)
+	mux.HandleFunc("GET /api/share/{token}/tv", h.TVLeaderboard)
+
+	// Calendar feeds, authorized by the calendar token in the path
+	mux.HandleFunc("GET /api/calendar/{token}/tee-times.ics", h.CalendarFeed)
+
 	// Authenticated routes
 	mux.HandleFunc("GET /api/me", h.GetMe)
+	mux.HandleFunc("GET /api/me/calendar", h.GetCalendarLink)
+	mux.HandleFunc("POST /api/me/calendar/reset", h.ResetCalendarLink)
 	mux.HandleFunc("GET /api/tournaments", h.ListTournaments)
 	mux.HandleFunc("POST /api/tournaments", auth.RequireAdmin(h.CreateTournament))
+	mux.HandleFunc("POST /api/tournaments/import", auth.RequireAdmin(h.ImportBundle))
 	mux.HandleFunc("GET /api/tournaments/{id}", h.GetTournament)
 	mux.HandleFunc("PUT /api/tournaments/{id}", auth.RequireAdmin(h.UpdateTournament))
 	mux.HandleFunc("DELETE /api/tournaments/{id}", auth.RequireAdmin(h.DeleteTournament))
 	mux.HandleFunc("GET /api/tournaments/{id}/scoreboard", h.GetScoreboard)
+	mux.HandleFunc("GET /api/tournaments/{id}/events", h.TournamentEvents)
+	mux.HandleFunc("GET /api/tournaments/{id}/export/matches.csv", h.ExportMatchesCSV)
+	mux.HandleFunc("GET /api/tournaments/{id}/export/holes.csv", h.ExportHolesCSV)
+	mux.HandleFunc("GET /api/tournaments/{id}/export/results.xlsx", h.ExportXLSX)
+	mux.HandleFunc("GET /api/tournaments/{id}/bundle", auth.RequireAdmin(h.ExportBundle))
+	mux.HandleFunc("GET /api/tournaments/{id}/scoring", h.ScoringSocket)
 	mux.HandleFunc("PUT /api/tournaments/{id}/lock", auth.RequireAdmin(h.LockTournament))
 	mux.HandleFunc("PUT /api/tournaments/{id}/combine-rounds", auth.RequireAdmin(h.CombineRounds))
 	mux.HandleFunc("PUT /api/tournaments/{id}/rounds/{round}/name", auth.RequireAdmin(h.UpdateRoundName))
@@ -56,19 +97,37 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("PUT /api/tournaments/{id}/rounds/{round}/points", auth.RequireAdmin(h.UpdateRoundPoints))
 	mux.HandleFunc("PUT /api/tournaments/{id}/rounds/{round}/lock", auth.RequireAdmin(h.LockRound))
 	mux.HandleFunc("PUT /api/tournaments/{id}/rounds/{round}/pairings", auth.RequireAdmin(h.SetPairings))
+	mux.HandleFunc("GET /api/tournaments/{id}/rounds/{round}/scorecards.pdf", h.RoundScorecardsPDF)
+	mux.HandleFunc("GET /api/tournaments/{id}/rounds/{round}/draw.pdf", h.RoundDrawSheetPDF)
 	mux.HandleFunc("PUT /api/tournaments/{id}/rounds/{round}/matches/{matchId}", auth.RequireAdmin(h.UpdateMatchResult))
+	mux.HandleFunc("PUT /api/tournaments/{id}/rounds/{round}/matches/{matchId}/tee-time", auth.RequireAdmin(h.SetTeeTime))
 	mux.HandleFunc("PUT /api/tournaments/{id}/rounds/{round}/matches/{matchId}/holes/{hole}", h.UpdateHoleResult)
+	mux.HandleFunc("POST /api/tournaments/{id}/sync", h.SyncHoles)
+	mux.HandleFunc("POST /api/tournaments/{id}/rounds/{round}/matches/{matchId}/attest", h.AttestMatch)
 	mux.HandleFunc("GET /api/tournaments/{id}/rankings", h.GetRankings)
 	mux.HandleFunc("PUT /api/tournaments/{id}/rankings", h.SubmitRanking)
 	mux.HandleFunc("PUT /api/tournaments/{id}/rankings/lock", auth.RequireAdmin(h.LockRankings))
 	mux.HandleFunc("GET /api/users", auth.RequireAdmin(h.ListUsers))
 	mux.HandleFunc("PUT /api/tournaments/{id}/players/{playerId}/link", auth.RequireAdmin(h.LinkPlayer))
+	mux.HandleFunc("POST /api/tournaments/{id}/roster/import", auth.RequireAdmin(h.ImportRoster))
+	mux.HandleFunc("GET /api/tournaments/{id}/webhooks", auth.RequireAdmin(h.ListWebhooks))
+	mux.HandleFunc("POST /api/tournaments/{id}/webhooks", auth.RequireAdmin(h.CreateWebhook))
+	mux.HandleFunc("DELETE /api/tournaments/{id}/webhooks/{webhookId}", auth.RequireAdmin(h.DeleteWebhook))
+	mux.HandleFunc("GET /api/tournaments/{id}/webhooks/{webhookId}/deliveries", auth.RequireAdmin(h.ListWebhookDeliveries))
+	mux.HandleFunc("GET /api/tournaments/{id}/shares", auth.RequireAdmin(h.ListShareTokens))
+	mux.HandleFunc("POST /api/tournaments/{id}/shares", auth.RequireAdmin(h.CreateShareToken))
+	mux.HandleFunc("DELETE /api/tournaments/{id}/shares/{token}", auth.RequireAdmin(h.RevokeShareToken))
+	mux.HandleFunc("GET /api/tournaments/{id}/audit", auth.RequireAdmin(h.ListAudit))
+	mux.HandleFunc("POST /api/tournaments/{id}/audit/{entryId}/revert", auth.RequireAdmin(h.RevertAudit))
+	mux.HandleFunc("GET /api/tournaments/{id}/rounds/{round}/matches/{matchId}/audit", h.ListMatchAudit)
 
 	// Admin user management
 	mux.HandleFunc("GET /api/admin/users", auth.RequireAdmin(h.ListLocalUsersAdmin))
 	mux.HandleFunc("POST /api/admin/users/confirm", auth.RequireAdmin(h.ConfirmUser))
 	mux.HandleFunc("POST /api/admin/users/reject", auth.RequireAdmin(h.RejectUser))
 	mux.HandleFunc("POST /api/admin/users/enable", auth.RequireAdmin(h.EnableUser))
+	mux.HandleFunc("GET /api/admin/cache", auth.RequireAdmin(h.CacheStats))
+	mux.HandleFunc("DELETE /api/admin/cache", auth.RequireAdmin(h.FlushCache))
 }
 
 // --- Public auth handlers ---
@@ -128,20 +187,12 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		if err := h.emailCfg.SendVerification(req.Email, verToken, h.appURL); err != nil {
 			log.Printf("Failed to send verification email to %s: %v", req.Email, err)
 		}
-		// Notify admins about the new registration
-		if !user.Confirmed {
-			admins := make([]string, 0, len(h.adminEmails))
-			for em := range h.adminEmails {
-				admins = append(admins, em)
-			}
-			if err := h.emailCfg.SendNewUserNotification(admins, req.Name, req.Email, h.appURL); err != nil {
-				log.Printf("Failed to send admin notification: %v", err)
-			}
-		}
 	} else {
 		log.Printf("Email not configured. Verification token for %s: %s", req.Email, verToken)
 	}
 
+	h.events.Publish(r.Context(), events.UserRegistered{User: user})
+
 	writeJSON(w, http.StatusCreated, map[string]string{
 		"message": "Registration successful. Please check your email to verify your account. An admin will need to confirm your access.",
 	})
@@ -390,6 +441,7 @@ func (h *Handler) GetTournament(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	w.Header().Set("ETag", etag(t.Version))
 	writeJSON(w, http.StatusOK, t)
 }
 
@@ -418,6 +470,9 @@ func (h *Handler) UpdateTournament(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	if !checkIfMatch(w, r, t, true) {
+		return
+	}
 
 	var req UpdateTournamentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -425,6 +480,8 @@ func (h *Handler) UpdateTournament(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := settingsOf(t)
+
 	if req.Name != "" {
 		t.Name = req.Name
 	}
@@ -447,15 +504,18 @@ func (h *Handler) UpdateTournament(w http.ResponseWriter, r *http.Request) {
 			for j, p := range req.Teams[i].Players {
 				playerID := uuid.New().String()
 				userEmail := ""
+				var handicap *float64
 				if j < len(t.Teams[i].Players) {
 					playerID = t.Teams[i].Players[j].ID
 					userEmail = t.Teams[i].Players[j].UserEmail
+					handicap = t.Teams[i].Players[j].Handicap
 				}
 				players[j] = models.Player{
 					ID:        playerID,
 					Name:      p.Name,
 					TeamID:    t.Teams[i].ID,
 					UserEmail: userEmail,
+					Handicap:  handicap,
 				}
 			}
 			t.Teams[i].Players = players
@@ -463,11 +523,16 @@ func (h *Handler) UpdateTournament(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := h.store.UpdateTournament(r.Context(), t); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeStoreError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, t)
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: t,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditTournamentEdit, Before: before, After: settingsOf(t)},
+	})
+	writeSaved(w, http.StatusOK, t, err)
 }
 
 func (h *Handler) DeleteTournament(w http.ResponseWriter, r *http.Request) {
@@ -476,6 +541,7 @@ func (h *Handler) DeleteTournament(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	h.events.Publish(r.Context(), events.TournamentDeleted{TournamentID: id})
 	w.WriteHeader(http.StatusNoContent)
 }
 
@@ -496,8 +562,9 @@ type SetPairingsRequest struct {
 }
 
 type MatchInput struct {
-	Team1Players []string `json:"team1Players"`
-	Team2Players []string `json:"team2Players"`
+	Team1Players []string   `json:"team1Players"`
+	Team2Players []string   `json:"team2Players"`
+	TeeTime      *time.Time `json:"teeTime,omitempty"`
 }
 
 func (h *Handler) LockTournament(w http.ResponseWriter, r *http.Request) {
@@ -507,6 +574,9 @@ func (h *Handler) LockTournament(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	if !checkIfMatch(w, r, t, true) {
+		return
+	}
 
 	var req struct {
 		Locked bool `json:"locked"`
@@ -516,13 +586,19 @@ func (h *Handler) LockTournament(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := t.Locked
 	t.Locked = req.Locked
 	if err := h.store.UpdateTournament(r.Context(), t); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeStoreError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, t)
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: t,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditTournamentLock, Before: before, After: t.Locked},
+	})
+	writeSaved(w, http.StatusOK, t, err)
 }
 
 func (h *Handler) CombineRounds(w http.ResponseWriter, r *http.Request) {
@@ -532,6 +608,9 @@ func (h *Handler) CombineRounds(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	if !checkIfMatch(w, r, t, true) {
+		return
+	}
 
 	var req struct {
 		Combine bool `json:"combine"`
@@ -541,13 +620,19 @@ func (h *Handler) CombineRounds(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := settingsOf(t)
 	t.CombineRounds23 = req.Combine
 	if err := h.store.UpdateTournament(r.Context(), t); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeStoreError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, t)
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: t,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditTournamentEdit, Before: before, After: settingsOf(t)},
+	})
+	writeSaved(w, http.StatusOK, t, err)
 }
 
 func (h *Handler) LockRound(w http.ResponseWriter, r *http.Request) {
@@ -571,26 +656,32 @@ func (h *Handler) LockRound(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
-
-	found := false
-	for i := range t.Rounds {
-		if t.Rounds[i].Number == roundNum {
-			t.Rounds[i].Locked = req.Locked
-			found = true
-			break
-		}
+	if !checkIfMatch(w, r, t, true) {
+		return
 	}
-	if !found {
+
+	round := findRound(t, roundNum)
+	if round == nil {
 		writeError(w, http.StatusNotFound, fmt.Sprintf("round %d not found", roundNum))
 		return
 	}
+	before := round.Locked
+	round.Locked = req.Locked
 
 	if err := h.store.UpdateTournament(r.Context(), t); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeStoreError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, t)
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: t,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditRoundLock, RoundNumber: roundNum, Before: before, After: round.Locked},
+	})
+	if round.Locked && !before {
+		err = errors.Join(err, h.events.Publish(r.Context(), events.RoundLocked{Tournament: t, RoundNumber: roundNum}))
+	}
+	writeSaved(w, http.StatusOK, t, err)
 }
 
 func (h *Handler) UpdateRoundPoints(w http.ResponseWriter, r *http.Request) {
@@ -618,26 +709,29 @@ func (h *Handler) UpdateRoundPoints(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
-
-	found := false
-	for i := range t.Rounds {
-		if t.Rounds[i].Number == roundNum {
-			t.Rounds[i].PointsPerMatch = req.PointsPerMatch
-			found = true
-			break
-		}
+	if !checkIfMatch(w, r, t, true) {
+		return
 	}
-	if !found {
+
+	round := findRound(t, roundNum)
+	if round == nil {
 		writeError(w, http.StatusNotFound, fmt.Sprintf("round %d not found", roundNum))
 		return
 	}
+	before := roundSettingsOf(round)
+	round.PointsPerMatch = req.PointsPerMatch
 
 	if err := h.store.UpdateTournament(r.Context(), t); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeStoreError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, t)
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: t,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditRoundSettings, RoundNumber: roundNum, Before: before, After: roundSettingsOf(round)},
+	})
+	writeSaved(w, http.StatusOK, t, err)
 }
 
 func (h *Handler) UpdateRoundHoles(w http.ResponseWriter, r *http.Request) {
@@ -665,26 +759,29 @@ func (h *Handler) UpdateRoundHoles(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
-
-	found := false
-	for i := range t.Rounds {
-		if t.Rounds[i].Number == roundNum {
-			t.Rounds[i].Holes = req.Holes
-			found = true
-			break
-		}
+	if !checkIfMatch(w, r, t, true) {
+		return
 	}
-	if !found {
+
+	round := findRound(t, roundNum)
+	if round == nil {
 		writeError(w, http.StatusNotFound, fmt.Sprintf("round %d not found", roundNum))
 		return
 	}
+	before := roundSettingsOf(round)
+	round.Holes = req.Holes
 
 	if err := h.store.UpdateTournament(r.Context(), t); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeStoreError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, t)
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: t,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditRoundSettings, RoundNumber: roundNum, Before: before, After: roundSettingsOf(round)},
+	})
+	writeSaved(w, http.StatusOK, t, err)
 }
 
 func (h *Handler) UpdateRoundName(w http.ResponseWriter, r *http.Request) {
@@ -714,26 +811,29 @@ func (h *Handler) UpdateRoundName(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
-
-	found := false
-	for i := range t.Rounds {
-		if t.Rounds[i].Number == roundNum {
-			t.Rounds[i].Name = req.Name
-			found = true
-			break
-		}
+	if !checkIfMatch(w, r, t, true) {
+		return
 	}
-	if !found {
+
+	round := findRound(t, roundNum)
+	if round == nil {
 		writeError(w, http.StatusNotFound, fmt.Sprintf("round %d not found", roundNum))
 		return
 	}
+	before := roundSettingsOf(round)
+	round.Name = req.Name
 
 	if err := h.store.UpdateTournament(r.Context(), t); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeStoreError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, t)
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: t,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditRoundSettings, RoundNumber: roundNum, Before: before, After: roundSettingsOf(round)},
+	})
+	writeSaved(w, http.StatusOK, t, err)
 }
 
 func (h *Handler) SetPairings(w http.ResponseWriter, r *http.Request) {
@@ -751,6 +851,21 @@ func (h *Handler) SetPairings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	t, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if !checkIfMatch(w, r, t, true) {
+		return
+	}
+	round := findRound(t, roundNum)
+	if round == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("round %d not found", roundNum))
+		return
+	}
+	before := round.Matches
+
 	matches := make([]models.Match, len(req.Matches))
 	for i, m := range req.Matches {
 		matches[i] = models.Match{
@@ -760,16 +875,27 @@ func (h *Handler) SetPairings(w http.ResponseWriter, r *http.Request) {
 			Team2Players: m.Team2Players,
 			Result:       models.ResultPending,
 			HoleResults:  make(map[string]string),
+			TeeTime:      m.TeeTime,
 		}
 	}
 
-	if err := h.store.SetRoundPairings(r.Context(), id, roundNum, matches); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+	if err := h.store.SetRoundPairings(r.Context(), id, roundNum, matches, precondition(r, t)); err != nil {
+		writeStoreError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	t, _ := h.store.GetTournament(r.Context(), id)
-	writeJSON(w, http.StatusOK, t)
+	t, err = h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = h.events.Publish(r.Context(), events.PairingsSet{
+		Tournament:  t,
+		RoundNumber: roundNum,
+		Actor:       actor(r),
+		Change:      &events.Change{Action: models.AuditPairings, RoundNumber: roundNum, Before: before, After: matches},
+	})
+	writeSaved(w, http.StatusOK, t, err)
 }
 
 type UpdateMatchResultRequest struct {
@@ -805,13 +931,99 @@ func (h *Handler) UpdateMatchResult(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.UpdateMatchResult(r.Context(), id, roundNum, matchID, req.Result, req.Score); err != nil {
+	t, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if !checkIfMatch(w, r, t, true) {
+		return
+	}
+	match := findMatch(t, roundNum, matchID)
+	if match == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("match %s not found in round %d", matchID, roundNum))
+		return
+	}
+	before := matchResultValue{Result: match.Result, Score: match.Score}
+	want := precondition(r, t)
+	want.MatchVersion = match.Version
+
+	if err := h.store.UpdateMatchResult(r.Context(), id, roundNum, matchID, req.Result, req.Score, want); err != nil {
+		writeStoreError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	updated, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	err = h.publishMatchChange(r, events.MatchUpdated{
+		Before:      t,
+		Tournament:  updated,
+		RoundNumber: roundNum,
+		MatchID:     matchID,
+		Actor:       actor(r),
+		Change: &events.Change{
+			Action:      models.AuditMatchResult,
+			RoundNumber: roundNum,
+			MatchID:     matchID,
+			Before:      before,
+			After:       matchResultValue{Result: req.Result, Score: req.Score},
+			Version:     want.MatchVersion + 1,
+		},
+	})
+	writeSaved(w, http.StatusOK, updated, err)
+}
 
-	t, _ := h.store.GetTournament(r.Context(), id)
-	writeJSON(w, http.StatusOK, t)
+type SetTeeTimeRequest struct {
+	TeeTime *time.Time `json:"teeTime"` // null clears the tee time
+}
+
+func (h *Handler) SetTeeTime(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	matchID := r.PathValue("matchId")
+	roundNum, err := strconv.Atoi(r.PathValue("round"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid round number")
+		return
+	}
+
+	var req SetTeeTimeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	t, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if !checkIfMatch(w, r, t, true) {
+		return
+	}
+	match := findMatch(t, roundNum, matchID)
+	if match == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("match %s not found in round %d", matchID, roundNum))
+		return
+	}
+	before := match.TeeTime
+	match.TeeTime = req.TeeTime
+
+	if err := h.store.UpdateTournament(r.Context(), t); err != nil {
+		writeStoreError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = h.events.Publish(r.Context(), events.MatchUpdated{
+		Tournament:  t,
+		RoundNumber: roundNum,
+		MatchID:     matchID,
+		Actor:       actor(r),
+		Change:      &events.Change{Action: models.AuditTeeTime, RoundNumber: roundNum, MatchID: matchID, Before: before, After: req.TeeTime},
+	})
+	writeSaved(w, http.StatusOK, t, err)
 }
 
 type UpdateHoleResultRequest struct {
@@ -825,79 +1037,280 @@ func (h *Handler) UpdateHoleResult(w http.ResponseWriter, r *http.Request) {
 	holeStr := r.PathValue("hole")
 
 	roundNum, err := strconv.Atoi(roundStr)
-	if err != nil || roundNum < 1 || roundNum > 5 {
+	if err != nil {
 		writeError(w, http.StatusBadRequest, "invalid round number")
 		return
 	}
 
 	holeNum, err := strconv.Atoi(holeStr)
-	if err != nil || holeNum < 1 || holeNum > 18 {
+	if err != nil {
 		writeError(w, http.StatusBadRequest, "invalid hole number (1-18)")
 		return
 	}
 
-	// Validate hole number against round's configured hole count
-	{
-		t, err := h.store.GetTournament(r.Context(), id)
-		if err == nil {
-			for _, round := range t.Rounds {
-				if round.Number == roundNum && holeNum > round.HoleCount() {
-					writeError(w, http.StatusBadRequest, fmt.Sprintf("hole %d exceeds this round's %d holes", holeNum, round.HoleCount()))
-					return
-				}
-			}
-		}
-	}
-
 	var req UpdateHoleResultRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	validResults := map[string]bool{"team1": true, "team2": true, "halved": true, "": true}
-	if !validResults[req.Result] {
-		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid hole result: %s", req.Result))
+	t, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	if !checkIfMatch(w, r, t, false) {
+		return
+	}
+
+	t, warning, status, err := h.recordHoleResult(r, t, roundNum, matchID, holeNum, req.Result, precondition(r, t))
+	if err != nil {
+		writeStoreError(w, status, err)
+		return
+	}
+	writeSaved(w, http.StatusOK, t, warning)
+}
+
+// maxHoleAttempts bounds how often a hole result is re-applied after other
+// writes to the same match keep getting in first.
+const maxHoleAttempts = 5
+
+// recordHoleResult is applyHoleResult for callers with no view of the match
+// beyond t: if another write to the match lands between reading t and
+// writing, it re-reads the tournament and tries again. A conflict on the
+// tournament version the client named is returned as it is.
+func (h *Handler) recordHoleResult(r *http.Request, t *models.Tournament, roundNum int, matchID string, holeNum int, result string, want store.Precondition) (*models.Tournament, error, int, error) {
+	for attempt := 1; ; attempt++ {
+		updated, warning, status, err := h.applyHoleResult(r, t, roundNum, matchID, holeNum, result, want)
+		var conflict *store.VersionConflictError
+		if attempt == maxHoleAttempts || !errors.As(err, &conflict) || conflict.MatchID == "" {
+			return updated, warning, status, err
+		}
+		if t, err = h.store.GetTournament(r.Context(), t.ID); err != nil {
+			return nil, nil, http.StatusInternalServerError, err
+		}
+	}
+}
+
+// applyHoleResult validates a hole result against t and the request's user,
+// records it, and returns the updated tournament. On failure it returns the
+// HTTP status to report. If the result was saved but publishing it failed,
+// that isn't a failure: the error comes back as warning, for the client to be
+// told about without retrying the write. The write is made only
+// if want holds and the match is still at its version in t, so the audited
+// before value and version are exact; a *store.VersionConflictError with a
+// MatchID means the match moved on. Both the REST endpoint and the scoring
+// socket go through here so they enforce the same rules.
+func (h *Handler) applyHoleResult(r *http.Request, t *models.Tournament, roundNum int, matchID string, holeNum int, result string, want store.Precondition) (updated *models.Tournament, warning error, status int, err error) {
+	if status, err := checkHoleResult(r, t, roundNum, matchID, holeNum, result); err != nil {
+		return nil, nil, status, err
+	}
+	user := auth.GetUser(r.Context())
+
+	var before string
+	if match := findMatch(t, roundNum, matchID); match != nil {
+		before = match.HoleResults[strconv.Itoa(holeNum)]
+		want.MatchVersion = match.Version
+	}
+	want.Writer = writer(r)
+
+	if err := h.store.UpdateHoleResult(r.Context(), t.ID, roundNum, matchID, holeNum, result, want); err != nil {
+		var conflict *store.VersionConflictError
+		var superseded *store.SupersededError
+		if errors.As(err, &conflict) || errors.As(err, &superseded) {
+			return nil, nil, http.StatusConflict, err
+		}
+		return nil, nil, http.StatusInternalServerError, err
+	}
+
+	updated, err = h.store.GetTournament(r.Context(), t.ID)
+	if err != nil {
+		return nil, nil, http.StatusInternalServerError, err
+	}
+	warning = h.publishMatchChange(r, events.HoleRecorded{
+		Before:      t,
+		Tournament:  updated,
+		RoundNumber: roundNum,
+		MatchID:     matchID,
+		Hole:        holeNum,
+		Result:      result,
+		Actor:       user.Email,
+		Change: &events.Change{
+			Action:      models.AuditHoleResult,
+			RoundNumber: roundNum,
+			MatchID:     matchID,
+			Hole:        holeNum,
+			Before:      before,
+			After:       result,
+			Version:     want.MatchVersion + 1,
+		},
+	})
+	return updated, warning, 0, nil
+}
+
+// checkHoleResult validates a hole result and checks that the current user may
+// record it, returning an HTTP status to use if not.
+func checkHoleResult(r *http.Request, t *models.Tournament, roundNum int, matchID string, holeNum int, result string) (int, error) {
+	if roundNum < 1 || roundNum > 5 {
+		return http.StatusBadRequest, fmt.Errorf("invalid round number")
+	}
+	if holeNum < 1 || holeNum > 18 {
+		return http.StatusBadRequest, fmt.Errorf("invalid hole number (1-18)")
+	}
+
+	// Validate hole number against round's configured hole count
+	for _, round := range t.Rounds {
+		if round.Number == roundNum && holeNum > round.HoleCount() {
+			return http.StatusBadRequest, fmt.Errorf("hole %d exceeds this round's %d holes", holeNum, round.HoleCount())
+		}
+	}
+
+	validResults := map[string]bool{"team1": true, "team2": true, "halved": true, "": true}
+	if !validResults[result] {
+		return http.StatusBadRequest, fmt.Errorf("invalid hole result: %s", result)
+	}
 
 	user := auth.GetUser(r.Context())
 	if user == nil {
-		writeError(w, http.StatusUnauthorized, "not authenticated")
-		return
+		return http.StatusUnauthorized, fmt.Errorf("not authenticated")
 	}
 	if !user.IsAdmin {
-		t, err := h.store.GetTournament(r.Context(), id)
-		if err != nil {
-			writeError(w, http.StatusNotFound, err.Error())
-			return
-		}
 		if t.Locked {
-			writeError(w, http.StatusForbidden, "this tournament is locked")
-			return
+			return http.StatusForbidden, fmt.Errorf("this tournament is locked")
 		}
 		for _, round := range t.Rounds {
 			if round.Number == roundNum && round.Locked {
-				writeError(w, http.StatusForbidden, "this round is locked")
-				return
+				return http.StatusForbidden, fmt.Errorf("this round is locked")
 			}
 		}
 		if !isPlayerInMatch(t, roundNum, matchID, strings.ToLower(user.Email)) {
-			writeError(w, http.StatusForbidden, "you are not a player in this match")
+			return http.StatusForbidden, fmt.Errorf("you are not a player in this match")
+		}
+	}
+	return 0, nil
+}
+
+type AttestMatchRequest struct {
+	Confirmed bool   `json:"confirmed"`
+	Comment   string `json:"comment"`
+}
+
+// AttestMatch lets a linked player confirm or dispute a decided match on behalf
+// of their side. Disputes are emailed to the admins.
+func (h *Handler) AttestMatch(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	matchID := r.PathValue("matchId")
+
+	roundNum, err := strconv.Atoi(r.PathValue("round"))
+	if err != nil || roundNum < 1 || roundNum > 5 {
+		writeError(w, http.StatusBadRequest, "invalid round number")
+		return
+	}
+
+	var req AttestMatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	req.Comment = strings.TrimSpace(req.Comment)
+	if !req.Confirmed && req.Comment == "" {
+		writeError(w, http.StatusBadRequest, "a comment is required to dispute a scorecard")
+		return
+	}
+
+	user := auth.GetUser(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	t, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if !checkIfMatch(w, r, t, false) {
+		return
+	}
+	if t.Locked {
+		writeError(w, http.StatusForbidden, "this tournament is locked")
+		return
+	}
+	for _, round := range t.Rounds {
+		if round.Number == roundNum && round.Locked {
+			writeError(w, http.StatusForbidden, "this round is locked")
 			return
 		}
 	}
 
-	if err := h.store.UpdateHoleResult(r.Context(), id, roundNum, matchID, holeNum, req.Result); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+	userEmail := strings.ToLower(user.Email)
+	side := playerSideInMatch(t, roundNum, matchID, userEmail)
+	if side == "" {
+		writeError(w, http.StatusForbidden, "you are not a player in this match")
 		return
 	}
+	match := findMatch(t, roundNum, matchID)
+	before := attestationOf(match)
+	// Attest the scorecard the player saw: if the match changes first, the
+	// attestation fails rather than vouching for a different card.
+	want := precondition(r, t)
+	want.MatchVersion = match.Version
 
-	t, _ := h.store.GetTournament(r.Context(), id)
-	writeJSON(w, http.StatusOK, t)
+	attestation := models.Attestation{
+		Side:      side,
+		Email:     userEmail,
+		Confirmed: req.Confirmed,
+		Comment:   req.Comment,
+		CreatedAt: time.Now(),
+	}
+	if err := h.store.AttestMatch(r.Context(), id, roundNum, matchID, attestation, want); err != nil {
+		writeStoreError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	var after attestationValue
+	if m := findMatch(updated, roundNum, matchID); m != nil {
+		after = attestationOf(m)
+	}
+	err = h.publishMatchChange(r, events.MatchUpdated{
+		Before:      t,
+		Tournament:  updated,
+		RoundNumber: roundNum,
+		MatchID:     matchID,
+		Actor:       userEmail,
+		Change: &events.Change{
+			Action:      models.AuditAttestation,
+			RoundNumber: roundNum,
+			MatchID:     matchID,
+			Before:      before,
+			After:       after,
+			Version:     match.Version + 1,
+		},
+	})
+	if !req.Confirmed {
+		err = errors.Join(err, h.events.Publish(r.Context(), events.ScorecardDisputed{
+			Tournament:  updated,
+			RoundNumber: roundNum,
+			MatchID:     matchID,
+			Email:       userEmail,
+			Comment:     req.Comment,
+		}))
+	}
+	writeSaved(w, http.StatusOK, updated, err)
 }
 
 func isPlayerInMatch(t *models.Tournament, roundNumber int, matchID string, email string) bool {
+	return playerSideInMatch(t, roundNumber, matchID, email) != ""
+}
+
+// playerSideInMatch returns "team1" or "team2" for the side of the match the
+// user with the given email is linked to, or "" if they are not in the match.
+func playerSideInMatch(t *models.Tournament, roundNumber int, matchID string, email string) string {
 	playerEmails := make(map[string]string)
 	for _, team := range t.Teams {
 		for _, p := range team.Players {
@@ -917,18 +1330,48 @@ func isPlayerInMatch(t *models.Tournament, roundNumber int, matchID string, emai
 			}
 			for _, pid := range match.Team1Players {
 				if playerEmails[pid] == email {
-					return true
+					return "team1"
 				}
 			}
 			for _, pid := range match.Team2Players {
 				if playerEmails[pid] == email {
-					return true
+					return "team2"
 				}
 			}
-			return false
+			return ""
+		}
+	}
+	return ""
+}
+
+// matchLabel describes a match for notifications, e.g.
+// "Four-Ball: Smith & Jones vs Brown & Green".
+func matchLabel(t *models.Tournament, roundNumber int, matchID string) string {
+	names := make(map[string]string)
+	for _, team := range t.Teams {
+		for _, p := range team.Players {
+			names[p.ID] = p.Name
+		}
+	}
+	side := func(ids []string) string {
+		parts := make([]string, 0, len(ids))
+		for _, pid := range ids {
+			parts = append(parts, names[pid])
+		}
+		return strings.Join(parts, " & ")
+	}
+
+	for _, round := range t.Rounds {
+		if round.Number != roundNumber {
+			continue
+		}
+		for _, match := range round.Matches {
+			if match.ID == matchID {
+				return fmt.Sprintf("%s: %s vs %s", round.Name, side(match.Team1Players), side(match.Team2Players))
+			}
 		}
 	}
-	return false
+	return fmt.Sprintf("round %d match %s", roundNumber, matchID)
 }
 
 func (h *Handler) ListUsers(w http.ResponseWriter, r *http.Request) {
@@ -963,13 +1406,37 @@ func (h *Handler) LinkPlayer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := h.store.LinkPlayer(r.Context(), id, playerID, req.Email); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+	t, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if !checkIfMatch(w, r, t, true) {
 		return
 	}
+	player := findPlayer(t, playerID)
+	if player == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("player %s not found", playerID))
+		return
+	}
+	before := player.UserEmail
 
-	t, _ := h.store.GetTournament(r.Context(), id)
-	writeJSON(w, http.StatusOK, t)
+	if err := h.store.LinkPlayer(r.Context(), id, playerID, req.Email, precondition(r, t)); err != nil {
+		writeStoreError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	t, err = h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: t,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditPlayerLink, PlayerID: playerID, Before: before, After: req.Email},
+	})
+	writeSaved(w, http.StatusOK, t, err)
 }
 
 func (h *Handler) LockRankings(w http.ResponseWriter, r *http.Request) {
@@ -979,6 +1446,9 @@ func (h *Handler) LockRankings(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	if !checkIfMatch(w, r, t, true) {
+		return
+	}
 
 	var req struct {
 		Locked bool `json:"locked"`
@@ -988,13 +1458,19 @@ func (h *Handler) LockRankings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	before := t.RankingsLocked
 	t.RankingsLocked = req.Locked
 	if err := h.store.UpdateTournament(r.Context(), t); err != nil {
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeStoreError(w, http.StatusInternalServerError, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, t)
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: t,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditRankingsLock, Before: before, After: t.RankingsLocked},
+	})
+	writeSaved(w, http.StatusOK, t, err)
 }
 
 func (h *Handler) GetRankings(w http.ResponseWriter, r *http.Request) {
@@ -1043,6 +1519,9 @@ func (h *Handler) SubmitRanking(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusNotFound, err.Error())
 		return
 	}
+	if !checkIfMatch(w, r, t, false) {
+		return
+	}
 
 	if t.RankingsLocked && !user.IsAdmin {
 		writeError(w, http.StatusForbidden, "rankings are locked")
@@ -1100,30 +1579,35 @@ func (h *Handler) SubmitRanking(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Upsert the ranking
-	found := false
-	for i, rk := range t.Rankings {
+	var before []string
+	for _, rk := range t.Rankings {
 		if strings.EqualFold(rk.SubmittedBy, submitterEmail) {
-			t.Rankings[i].PlayerIDs = req.PlayerIDs
-			t.Rankings[i].UpdatedAt = time.Now()
-			found = true
+			before = rk.PlayerIDs
 			break
 		}
 	}
-	if !found {
-		t.Rankings = append(t.Rankings, models.PlayerRanking{
-			SubmittedBy: submitterEmail,
-			PlayerIDs:   req.PlayerIDs,
-			UpdatedAt:   time.Now(),
-		})
+
+	ranking := models.PlayerRanking{
+		SubmittedBy: submitterEmail,
+		PlayerIDs:   req.PlayerIDs,
+		UpdatedAt:   time.Now(),
+	}
+	if err := h.store.SaveRanking(r.Context(), id, ranking, precondition(r, t)); err != nil {
+		writeStoreError(w, http.StatusInternalServerError, err)
+		return
 	}
 
-	if err := h.store.UpdateTournament(r.Context(), t); err != nil {
+	t, err = h.store.GetTournament(r.Context(), id)
+	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
-
-	writeJSON(w, http.StatusOK, map[string]string{"message": "ranking saved"})
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: t,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditRanking, Before: before, After: req.PlayerIDs},
+	})
+	writeSaved(w, http.StatusOK, map[string]string{"message": "ranking saved"}, err)
 }
 
 func writeJSON(w http.ResponseWriter, status int, data any) {
@@ -1137,3 +1621,110 @@ func writeError(w http.ResponseWriter, status int, message string) {
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(map[string]string{"error": message})
 }
+
+// publishWarning describes the error returned when a required subscriber,
+// such as the audit log, fails after a change has been saved.
+func publishWarning(err error) string {
+	if err == nil {
+		return ""
+	}
+	return "change saved, but recording it failed: " + err.Error()
+}
+
+// writeSaved writes the response to a change that has been saved. If
+// publishing it then failed (err), the change still stands, so rather than an
+// error the client would retry, the response carries a "warning" field
+// alongside the fields of data.
+func writeSaved(w http.ResponseWriter, status int, data any, err error) {
+	warning := publishWarning(err)
+	if warning == "" {
+		writeJSON(w, status, data)
+		return
+	}
+	var fields map[string]json.RawMessage
+	if raw, merr := json.Marshal(data); merr == nil && json.Unmarshal(raw, &fields) == nil {
+		fields["warning"], _ = json.Marshal(warning)
+		writeJSON(w, status, fields)
+		return
+	}
+	writeJSON(w, status, data)
+}
+
+// etag formats a tournament version as an HTTP entity tag.
+func etag(version int64) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// checkIfMatch enforces the If-Match precondition against the version of the
+// tournament the handler read, and writes the error response if it fails.
+// Admin writes require the header. Players' hole results, attestations and
+// ranking submissions only check it when present: the version covers the
+// whole tournament, so requiring it would have scorers in different matches
+// refusing each other's writes all round.
+//
+// This is only the early check. The store checks the version again as part of
+// the write, UpdateTournament against t.Version and the fine-grained writes
+// against precondition(r, t), so a change landing in between is still a 409.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, t *models.Tournament, required bool) bool {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" {
+		if required {
+			writeError(w, http.StatusPreconditionRequired, "If-Match header with the tournament version is required")
+			return false
+		}
+		return true
+	}
+	if raw == "*" {
+		return true
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(raw, "W/"), `"`), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid If-Match header")
+		return false
+	}
+	if version != t.Version {
+		writeConflict(w, &store.VersionConflictError{TournamentID: t.ID, Current: t.Version})
+		return false
+	}
+	return true
+}
+
+// precondition is the store precondition for a fine-grained write made after
+// checkIfMatch passed: the version the client sent, if it sent one.
+func precondition(r *http.Request, t *models.Tournament) store.Precondition {
+	raw := strings.TrimSpace(r.Header.Get("If-Match"))
+	if raw == "" || raw == "*" {
+		return store.Precondition{Writer: writer(r)}
+	}
+	return store.Precondition{Version: t.Version, Writer: writer(r)}
+}
+
+// writeConflict reports a version conflict. The ETag is the tournament's
+// version, so a conflict on a single match's version reports that under
+// matchId and leaves the header unset.
+func writeConflict(w http.ResponseWriter, conflict *store.VersionConflictError) {
+	body := map[string]any{
+		"error":          conflict.Error(),
+		"currentVersion": conflict.Current,
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if conflict.MatchID != "" {
+		body["matchId"] = conflict.MatchID
+	} else {
+		w.Header().Set("ETag", etag(conflict.Current))
+	}
+	w.WriteHeader(http.StatusConflict)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeStoreError reports a version conflict from the store as 409 and any
+// other error with the given status.
+func writeStoreError(w http.ResponseWriter, status int, err error) {
+	var conflict *store.VersionConflictError
+	if errors.As(err, &conflict) {
+		writeConflict(w, conflict)
+		return
+	}
+	writeError(w, status, err.Error())
+}
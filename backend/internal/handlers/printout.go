@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/printout"
+	"strconv"
+)
+
+func (h *Handler) RoundScorecardsPDF(w http.ResponseWriter, r *http.Request) {
+	h.writePDF(w, r, "scorecards", printout.Scorecards)
+}
+
+func (h *Handler) RoundDrawSheetPDF(w http.ResponseWriter, r *http.Request) {
+	h.writePDF(w, r, "draw", printout.DrawSheet)
+}
+
+// writePDF renders into a buffer first so a failure can still be reported as
+// a JSON error instead of a truncated download.
+func (h *Handler) writePDF(w http.ResponseWriter, r *http.Request, kind string, render func(io.Writer, *models.Tournament, int) error) {
+	roundNum, err := strconv.Atoi(r.PathValue("round"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid round number")
+		return
+	}
+	t, err := h.store.GetTournament(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := render(&buf, t, roundNum); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, exportFilename(t, fmt.Sprintf("round-%d-%s.pdf", roundNum, kind))))
+	w.Write(buf.Bytes())
+}
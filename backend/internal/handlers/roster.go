@@ -0,0 +1,306 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"scoring-backend/internal/events"
+	"scoring-backend/internal/models"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// maxRosterSize bounds the CSV body of a roster import.
+const maxRosterSize = 1 << 20
+
+// rosterRow is one player line of an imported roster CSV.
+type rosterRow struct {
+	Line     int      `json:"line"`
+	Team     string   `json:"team"`
+	Name     string   `json:"name"`
+	Email    string   `json:"email,omitempty"`
+	Handicap *float64 `json:"handicap,omitempty"`
+}
+
+type rosterUpdate struct {
+	rosterRow
+	Changes []string `json:"changes"`
+}
+
+type rosterError struct {
+	Line  int    `json:"line"`
+	Error string `json:"error"`
+}
+
+// RosterImportReport describes what an import did, or would do on a dry run.
+// Players already on a team but missing from the CSV are left as they are.
+type RosterImportReport struct {
+	DryRun          bool               `json:"dryRun"`
+	Added           []rosterRow        `json:"added"`
+	Updated         []rosterUpdate     `json:"updated"`
+	Unchanged       int                `json:"unchanged"`
+	UnmatchedEmails []string           `json:"unmatchedEmails"`
+	Errors          []rosterError      `json:"errors"`
+	Tournament      *models.Tournament `json:"tournament,omitempty"`
+}
+
+// ImportRoster fills both teams from a CSV with a header row naming the
+// columns name, team, email and handicap; only name and team are required.
+// Team is a team name or 1 or 2. Players are matched to existing ones on the
+// same team by name. Emails of confirmed local users are linked to their
+// player; other emails are reported and not linked.
+//
+// With ?dryRun=true nothing is saved. Otherwise If-Match is required, and a
+// CSV with any invalid row is rejected as a whole.
+func (h *Handler) ImportRoster(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+
+	t, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if !dryRun && !checkIfMatch(w, r, t, true) {
+		return
+	}
+
+	rows, rowErrors, err := parseRoster(http.MaxBytesReader(w, r.Body, maxRosterSize), t)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, err := h.store.ListLocalUsers(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	linkable := make(map[string]bool)
+	for _, u := range users {
+		if u.Confirmed && !u.Disabled {
+			linkable[strings.ToLower(u.Email)] = true
+		}
+	}
+
+	before := settingsOf(t)
+	report := applyRoster(t, rows, linkable)
+	report.DryRun = dryRun
+	report.Errors = append(rowErrors, report.Errors...)
+
+	if len(report.Errors) > 0 {
+		if dryRun {
+			writeJSON(w, http.StatusOK, report)
+		} else {
+			writeJSON(w, http.StatusBadRequest, report)
+		}
+		return
+	}
+	if dryRun || (len(report.Added) == 0 && len(report.Updated) == 0) {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	if err := h.store.UpdateTournament(r.Context(), t); err != nil {
+		writeStoreError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	err = h.events.Publish(r.Context(), events.TournamentUpdated{
+		Tournament: t,
+		Actor:      actor(r),
+		Change:     &events.Change{Action: models.AuditTournamentEdit, Before: before, After: settingsOf(t)},
+	})
+	report.Tournament = t
+	writeSaved(w, http.StatusOK, report, err)
+}
+
+// parseRoster reads the CSV into rows, resolving each row's team to "0" or
+// "1". Problems with individual rows are returned separately from an error
+// that makes the whole file unreadable.
+func parseRoster(body io.Reader, t *models.Tournament) ([]rosterRow, []rosterError, error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, nil, fmt.Errorf("the CSV is empty")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	cols := map[string]int{"name": -1, "team": -1, "email": -1, "handicap": -1}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if _, known := cols[name]; known {
+			cols[name] = i
+		}
+	}
+	if cols["name"] < 0 || cols["team"] < 0 {
+		return nil, nil, fmt.Errorf("the CSV header must include name and team columns")
+	}
+
+	rows := make([]rosterRow, 0)
+	rowErrors := make([]rosterError, 0)
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := cr.FieldPos(0)
+		if err != nil {
+			var maxBytes *http.MaxBytesError
+			if errors.As(err, &maxBytes) {
+				return nil, nil, fmt.Errorf("the CSV is larger than %d bytes", maxRosterSize)
+			}
+			rowErrors = append(rowErrors, rosterError{Line: line, Error: err.Error()})
+			continue
+		}
+		field := func(col string) string {
+			if i := cols[col]; i >= 0 && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		if strings.Join(record, "") == "" {
+			continue
+		}
+
+		row := rosterRow{Line: line, Name: field("name"), Email: strings.ToLower(field("email"))}
+		if row.Name == "" {
+			rowErrors = append(rowErrors, rosterError{Line: line, Error: "name is required"})
+			continue
+		}
+		team := rosterTeam(t, field("team"))
+		if team < 0 {
+			rowErrors = append(rowErrors, rosterError{Line: line, Error: fmt.Sprintf("unknown team %q", field("team"))})
+			continue
+		}
+		row.Team = t.Teams[team].Name
+		if raw := field("handicap"); raw != "" {
+			hcp, err := strconv.ParseFloat(strings.TrimPrefix(raw, "+"), 64)
+			if err != nil {
+				rowErrors = append(rowErrors, rosterError{Line: line, Error: fmt.Sprintf("invalid handicap %q", raw)})
+				continue
+			}
+			// A plus handicap is better than scratch.
+			if strings.HasPrefix(raw, "+") {
+				hcp = -hcp
+			}
+			row.Handicap = &hcp
+		}
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+// rosterTeam returns the index of the team named by s, which may also be "1"
+// or "2", or -1 if there is none.
+func rosterTeam(t *models.Tournament, s string) int {
+	switch s {
+	case "1":
+		return 0
+	case "2":
+		return 1
+	}
+	for i := range t.Teams {
+		if strings.EqualFold(t.Teams[i].Name, s) {
+			return i
+		}
+	}
+	return -1
+}
+
+// applyRoster adds and updates players in t from rows and reports the changes.
+func applyRoster(t *models.Tournament, rows []rosterRow, linkable map[string]bool) *RosterImportReport {
+	report := &RosterImportReport{
+		Added:           make([]rosterRow, 0),
+		Updated:         make([]rosterUpdate, 0),
+		UnmatchedEmails: make([]string, 0),
+		Errors:          make([]rosterError, 0),
+	}
+
+	for _, row := range rows {
+		team := &t.Teams[rosterTeam(t, row.Team)]
+
+		link := ""
+		if row.Email != "" {
+			if linkable[row.Email] {
+				link = row.Email
+			} else if !slices.Contains(report.UnmatchedEmails, row.Email) {
+				report.UnmatchedEmails = append(report.UnmatchedEmails, row.Email)
+			}
+		}
+
+		var player *models.Player
+		for i := range team.Players {
+			if strings.EqualFold(team.Players[i].Name, row.Name) {
+				player = &team.Players[i]
+				break
+			}
+		}
+
+		if link != "" {
+			if other := linkedPlayer(t, link); other != nil && other != player {
+				report.Errors = append(report.Errors, rosterError{
+					Line:  row.Line,
+					Error: fmt.Sprintf("%s is already linked to %s", link, other.Name),
+				})
+				continue
+			}
+		}
+
+		if player == nil {
+			team.Players = append(team.Players, models.Player{
+				ID:        uuid.New().String(),
+				Name:      row.Name,
+				TeamID:    team.ID,
+				UserEmail: link,
+				Handicap:  row.Handicap,
+			})
+			row.Email = link
+			report.Added = append(report.Added, row)
+			continue
+		}
+
+		update := rosterUpdate{rosterRow: row, Changes: make([]string, 0)}
+		if link != "" && !strings.EqualFold(link, player.UserEmail) {
+			update.Changes = append(update.Changes, fmt.Sprintf("email: %q -> %q", player.UserEmail, link))
+			player.UserEmail = link
+		}
+		if row.Handicap != nil && (player.Handicap == nil || *player.Handicap != *row.Handicap) {
+			old := ""
+			if player.Handicap != nil {
+				old = strconv.FormatFloat(*player.Handicap, 'f', -1, 64)
+			}
+			update.Changes = append(update.Changes, fmt.Sprintf("handicap: %q -> %q", old, strconv.FormatFloat(*row.Handicap, 'f', -1, 64)))
+			player.Handicap = row.Handicap
+		}
+		if len(update.Changes) == 0 {
+			report.Unchanged++
+			continue
+		}
+		update.Email = player.UserEmail
+		report.Updated = append(report.Updated, update)
+	}
+	return report
+}
+
+// linkedPlayer returns the player in t linked to email, if any.
+func linkedPlayer(t *models.Tournament, email string) *models.Player {
+	for i := range t.Teams {
+		for j := range t.Teams[i].Players {
+			if strings.EqualFold(t.Teams[i].Players[j].UserEmail, email) {
+				return &t.Teams[i].Players[j]
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"reflect"
+	"scoring-backend/internal/models"
+	"strings"
+	"testing"
+)
+
+func rosterTournament() *models.Tournament {
+	hcp := 12.0
+	t := &models.Tournament{}
+	t.Teams[0] = models.Team{ID: "eu", Name: "Europe", Players: []models.Player{
+		{ID: "p1", Name: "Rory", TeamID: "eu", UserEmail: "rory@example.com", Handicap: &hcp},
+	}}
+	t.Teams[1] = models.Team{ID: "us", Name: "USA", Players: []models.Player{
+		{ID: "p2", Name: "Scottie", TeamID: "us"},
+	}}
+	return t
+}
+
+func hcp(v float64) *float64 { return &v }
+
+func TestParseRoster(t *testing.T) {
+	tests := []struct {
+		name       string
+		csv        string
+		wantRows   []rosterRow
+		wantErrors []rosterError
+		wantErr    string
+	}{
+		{
+			name: "teams by name and number",
+			csv:  "name,team\nRory,europe\nJon,1\nScottie,2\nXander,USA\n",
+			wantRows: []rosterRow{
+				{Line: 2, Name: "Rory", Team: "Europe"},
+				{Line: 3, Name: "Jon", Team: "Europe"},
+				{Line: 4, Name: "Scottie", Team: "USA"},
+				{Line: 5, Name: "Xander", Team: "USA"},
+			},
+		},
+		{
+			name: "handicaps",
+			csv:  "Name,Team,Handicap\nRory,1,4.2\nJon,1,+1.5\nScottie,2,0\nXander,2,\nTommy,1,scratch\n",
+			wantRows: []rosterRow{
+				{Line: 2, Name: "Rory", Team: "Europe", Handicap: hcp(4.2)},
+				{Line: 3, Name: "Jon", Team: "Europe", Handicap: hcp(-1.5)},
+				{Line: 4, Name: "Scottie", Team: "USA", Handicap: hcp(0)},
+				{Line: 5, Name: "Xander", Team: "USA"},
+			},
+			wantErrors: []rosterError{{Line: 6, Error: `invalid handicap "scratch"`}},
+		},
+		{
+			name: "emails lowercased, blank lines skipped, bad rows reported",
+			csv:  "\ufeffemail,name,team\nRory@Example.com,Rory,1\n,,\nnobody@example.com,,1\nsomeone@example.com,Someone,3\n",
+			wantRows: []rosterRow{
+				{Line: 2, Name: "Rory", Team: "Europe", Email: "rory@example.com"},
+			},
+			wantErrors: []rosterError{
+				{Line: 4, Error: "name is required"},
+				{Line: 5, Error: `unknown team "3"`},
+			},
+		},
+		{name: "empty", csv: "", wantErr: "the CSV is empty"},
+		{name: "missing team column", csv: "name,email\nRory,rory@example.com\n", wantErr: "the CSV header must include name and team columns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, rowErrors, err := parseRoster(strings.NewReader(tt.csv), rosterTournament())
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantRows == nil {
+				tt.wantRows = []rosterRow{}
+			}
+			if tt.wantErrors == nil {
+				tt.wantErrors = []rosterError{}
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("rows = %+v, want %+v", rows, tt.wantRows)
+			}
+			if !reflect.DeepEqual(rowErrors, tt.wantErrors) {
+				t.Errorf("errors = %+v, want %+v", rowErrors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestApplyRoster(t *testing.T) {
+	linkable := map[string]bool{"rory@example.com": true, "jon@example.com": true, "scottie@example.com": true}
+	tests := []struct {
+		name          string
+		rows          []rosterRow
+		wantAdded     []string // names
+		wantUpdated   map[string][]string
+		wantUnchanged int
+		wantUnmatched []string
+		wantErrors    []rosterError
+		check         func(t *testing.T, tour *models.Tournament)
+	}{
+		{
+			name:      "new player added to the right team",
+			rows:      []rosterRow{{Line: 2, Name: "Jon", Team: "Europe", Email: "jon@example.com", Handicap: hcp(3)}},
+			wantAdded: []string{"Jon"},
+			check: func(t *testing.T, tour *models.Tournament) {
+				p := tour.Teams[0].Players[1]
+				if p.Name != "Jon" || p.TeamID != "eu" || p.UserEmail != "jon@example.com" || p.ID == "" || p.Handicap == nil || *p.Handicap != 3 {
+					t.Errorf("added player = %+v", p)
+				}
+			},
+		},
+		{
+			name:          "same handicap and email is unchanged",
+			rows:          []rosterRow{{Line: 2, Name: "rory", Team: "Europe", Email: "rory@example.com", Handicap: hcp(12)}},
+			wantUnchanged: 1,
+		},
+		{
+			name:        "handicap changed, including to a plus handicap",
+			rows:        []rosterRow{{Line: 2, Name: "Rory", Team: "Europe", Handicap: hcp(-1)}, {Line: 3, Name: "Scottie", Team: "USA", Handicap: hcp(0)}},
+			wantUpdated: map[string][]string{"Rory": {`handicap: "12" -> "-1"`}, "Scottie": {`handicap: "" -> "0"`}},
+		},
+		{
+			name:        "blank handicap leaves it alone",
+			rows:        []rosterRow{{Line: 2, Name: "Scottie", Team: "USA", Email: "scottie@example.com"}},
+			wantUpdated: map[string][]string{"Scottie": {`email: "" -> "scottie@example.com"`}},
+			check: func(t *testing.T, tour *models.Tournament) {
+				if h := tour.Teams[0].Players[0].Handicap; h == nil || *h != 12 {
+					t.Errorf("Rory's handicap = %v, want 12 kept", h)
+				}
+			},
+		},
+		{
+			name:          "email without an account is reported and not linked",
+			rows:          []rosterRow{{Line: 2, Name: "Xander", Team: "USA", Email: "xander@example.com"}, {Line: 3, Name: "Wyndham", Team: "USA", Email: "xander@example.com"}},
+			wantAdded:     []string{"Xander", "Wyndham"},
+			wantUnmatched: []string{"xander@example.com"},
+			check: func(t *testing.T, tour *models.Tournament) {
+				for _, p := range tour.Teams[1].Players[1:] {
+					if p.UserEmail != "" {
+						t.Errorf("%s linked to %q", p.Name, p.UserEmail)
+					}
+				}
+			},
+		},
+		{
+			name:       "email already linked to another player",
+			rows:       []rosterRow{{Line: 2, Name: "Scottie", Team: "USA", Email: "rory@example.com"}},
+			wantErrors: []rosterError{{Line: 2, Error: "rory@example.com is already linked to Rory"}},
+		},
+		{
+			name:       "duplicate email within the CSV",
+			rows:       []rosterRow{{Line: 2, Name: "Jon", Team: "Europe", Email: "jon@example.com"}, {Line: 3, Name: "Justin", Team: "USA", Email: "jon@example.com"}},
+			wantAdded:  []string{"Jon"},
+			wantErrors: []rosterError{{Line: 3, Error: "jon@example.com is already linked to Jon"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tour := rosterTournament()
+			report := applyRoster(tour, tt.rows, linkable)
+
+			var added []string
+			for _, row := range report.Added {
+				added = append(added, row.Name)
+			}
+			if !reflect.DeepEqual(added, tt.wantAdded) {
+				t.Errorf("added %v, want %v", added, tt.wantAdded)
+			}
+			updated := map[string][]string{}
+			for _, u := range report.Updated {
+				updated[u.Name] = u.Changes
+			}
+			if tt.wantUpdated == nil {
+				tt.wantUpdated = map[string][]string{}
+			}
+			if !reflect.DeepEqual(updated, tt.wantUpdated) {
+				t.Errorf("updated %v, want %v", updated, tt.wantUpdated)
+			}
+			if report.Unchanged != tt.wantUnchanged {
+				t.Errorf("unchanged = %d, want %d", report.Unchanged, tt.wantUnchanged)
+			}
+			if tt.wantUnmatched == nil {
+				tt.wantUnmatched = []string{}
+			}
+			if !reflect.DeepEqual(report.UnmatchedEmails, tt.wantUnmatched) {
+				t.Errorf("unmatched emails = %v, want %v", report.UnmatchedEmails, tt.wantUnmatched)
+			}
+			if tt.wantErrors == nil {
+				tt.wantErrors = []rosterError{}
+			}
+			if !reflect.DeepEqual(report.Errors, tt.wantErrors) {
+				t.Errorf("errors = %+v, want %+v", report.Errors, tt.wantErrors)
+			}
+			if tt.check != nil {
+				tt.check(t, tour)
+			}
+		})
+	}
+}
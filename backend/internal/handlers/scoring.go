@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/store"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// scoringProtocol is the WebSocket subprotocol spoken on the scoring socket.
+const scoringProtocol = "scoring.v1"
+
+// socketPingInterval detects dead connections from phones that dropped off
+// the network without closing the socket.
+const socketPingInterval = 30 * time.Second
+
+// socketRequest is a message sent by a scoring client. Type is "hole" to
+// record a hole result or "ping". ID is chosen by the client and echoed in the
+// reply so it can match acknowledgements to the entries it queued.
+type socketRequest struct {
+	Type        string `json:"type"`
+	ID          string `json:"id"`
+	RoundNumber int    `json:"roundNumber"`
+	MatchID     string `json:"matchId"`
+	Hole        int    `json:"hole"`
+	Result      string `json:"result"`
+}
+
+// socketReply is a message sent to a scoring client. Type is "ack" or "error"
+// in reply to a request, "pong", or "event" for a change to the tournament
+// published by anyone (the same events as the SSE stream). An ack carries a
+// Warning if the result was saved but not fully recorded.
+type socketReply struct {
+	Type    string        `json:"type"`
+	ID      string        `json:"id,omitempty"`
+	Version int64         `json:"version,omitempty"`
+	Match   *models.Match `json:"match,omitempty"`
+	Status  int           `json:"status,omitempty"`
+	Error   string        `json:"error,omitempty"`
+	Warning string        `json:"warning,omitempty"`
+	Event   string        `json:"event,omitempty"`
+	EventID string        `json:"eventId,omitempty"`
+	Data    any           `json:"data,omitempty"`
+}
+
+// ScoringSocket is a WebSocket for on-course scorers. Hole results sent on it
+// go through the same checks as UpdateHoleResult and are acknowledged with the
+// recalculated match. Changes to other matches are pushed on the same
+// connection. Browsers pass the bearer token as a "bearer.<token>" subprotocol
+// alongside scoring.v1; a lastEventId query parameter resumes the event feed
+// after a reconnect.
+func (h *Handler) ScoringSocket(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := h.store.GetTournament(r.Context(), id); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{
+		Subprotocols: []string{scoringProtocol},
+		// Clients authenticate with an explicit token rather than cookies, so
+		// a cross-origin page can't ride on a user's session.
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return
+	}
+	defer conn.CloseNow()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	sub := h.live.Subscribe(id, r.URL.Query().Get("lastEventId"))
+	defer sub.Close()
+
+	go func() {
+		defer cancel()
+		ping := time.NewTicker(socketPingInterval)
+		defer ping.Stop()
+
+		for _, ev := range sub.Replay {
+			if wsjson.Write(ctx, conn, socketReply{Type: "event", Event: ev.Type, EventID: ev.ID, Data: ev.Data}) != nil {
+				return
+			}
+		}
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-sub.Events:
+				if !ok {
+					conn.Close(websocket.StatusTryAgainLater, "too far behind, reconnect to catch up")
+					return
+				}
+				if wsjson.Write(ctx, conn, socketReply{Type: "event", Event: ev.Type, EventID: ev.ID, Data: ev.Data}) != nil {
+					return
+				}
+			case <-ping.C:
+				pingCtx, stop := context.WithTimeout(ctx, 10*time.Second)
+				err := conn.Ping(pingCtx)
+				stop()
+				if err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		var req socketRequest
+		if err := wsjson.Read(ctx, conn, &req); err != nil {
+			return
+		}
+		if err := wsjson.Write(ctx, conn, h.handleSocketRequest(r, id, req)); err != nil {
+			return
+		}
+	}
+}
+
+func (h *Handler) handleSocketRequest(r *http.Request, tournamentID string, req socketRequest) socketReply {
+	fail := func(status int, message string) socketReply {
+		return socketReply{Type: "error", ID: req.ID, Status: status, Error: message}
+	}
+
+	switch req.Type {
+	case "ping":
+		return socketReply{Type: "pong", ID: req.ID}
+	case "hole":
+	default:
+		return fail(http.StatusBadRequest, "unknown message type: "+req.Type)
+	}
+
+	t, err := h.store.GetTournament(r.Context(), tournamentID)
+	if err != nil {
+		return fail(http.StatusNotFound, err.Error())
+	}
+	t, warning, status, err := h.recordHoleResult(r, t, req.RoundNumber, req.MatchID, req.Hole, req.Result, store.Precondition{})
+	if err != nil {
+		return fail(status, err.Error())
+	}
+	return socketReply{Type: "ack", ID: req.ID, Version: t.Version, Match: findMatch(t, req.RoundNumber, req.MatchID), Warning: publishWarning(warning)}
+}
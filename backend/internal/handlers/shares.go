@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"scoring-backend/internal/auth"
+	"scoring-backend/internal/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+type CreateShareTokenRequest struct {
+	Label string `json:"label"`
+}
+
+func (h *Handler) CreateShareToken(w http.ResponseWriter, r *http.Request) {
+	var req CreateShareTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	token, err := auth.GenerateVerificationToken()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to generate share token")
+		return
+	}
+	share := &models.ShareToken{
+		Token:        token,
+		TournamentID: r.PathValue("id"),
+		Label:        strings.TrimSpace(req.Label),
+		CreatedBy:    actor(r),
+		CreatedAt:    time.Now(),
+	}
+	if err := h.store.CreateShareToken(r.Context(), share); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, share)
+}
+
+func (h *Handler) ListShareTokens(w http.ResponseWriter, r *http.Request) {
+	shares, err := h.store.ListShareTokens(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, shares)
+}
+
+func (h *Handler) RevokeShareToken(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.DeleteShareToken(r.Context(), r.PathValue("id"), r.PathValue("token")); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sharedTournament resolves the share token in the request path to the
+// tournament it grants access to, writing a 404 if either is gone.
+func (h *Handler) sharedTournament(w http.ResponseWriter, r *http.Request) (*models.Tournament, bool) {
+	share, err := h.store.GetShareToken(r.Context(), r.PathValue("token"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, "this link is invalid or has been revoked")
+		return nil, false
+	}
+	t, err := h.store.GetTournament(r.Context(), share.TournamentID)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "this link is invalid or has been revoked")
+		return nil, false
+	}
+	return t, true
+}
+
+// GetSharedTournament returns the tournament behind a share token: teams,
+// pairings and hole-by-hole results, without anything tied to a user account.
+func (h *Handler) GetSharedTournament(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.sharedTournament(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, spectatorTournament(t))
+}
+
+func (h *Handler) GetSharedScoreboard(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.sharedTournament(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, t.CalculateScoreboard())
+}
+
+// spectatorTournament returns a copy of t with private data removed: the
+// emails linked to players, rankings, and who attested or disputed a match and
+// why. Attestation status is kept since it decides which points are official.
+func spectatorTournament(t *models.Tournament) *models.Tournament {
+	c := *t
+	c.Rankings = nil
+	c.RankingsLocked = false
+	for i := range c.Teams {
+		c.Teams[i].Players = slices.Clone(t.Teams[i].Players)
+		for j := range c.Teams[i].Players {
+			c.Teams[i].Players[j].UserEmail = ""
+		}
+	}
+	c.Rounds = slices.Clone(t.Rounds)
+	for i := range c.Rounds {
+		c.Rounds[i].Matches = slices.Clone(t.Rounds[i].Matches)
+		for j := range c.Rounds[i].Matches {
+			c.Rounds[i].Matches[j].Attestations = nil
+		}
+	}
+	return &c
+}
@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"scoring-backend/internal/auth"
+	"scoring-backend/internal/events"
+	"scoring-backend/internal/models"
+)
+
+// subscribe registers the handler's own side effects on its event bus: the
+// audit log, the live event stream, webhooks and email notifications. The
+// audit log subscribes first so an entry exists before anything is told about
+// the change, and as a required subscriber so a failure to record it reaches
+// the client.
+func (h *Handler) subscribe() {
+	b := h.events
+
+	events.SubscribeRequired(b, func(ctx context.Context, e events.HoleRecorded) error {
+		return h.recordAudit(ctx, e.Tournament.ID, e.Actor, e.Change)
+	})
+	events.SubscribeRequired(b, func(ctx context.Context, e events.MatchUpdated) error {
+		return h.recordAudit(ctx, e.Tournament.ID, e.Actor, e.Change)
+	})
+	events.SubscribeRequired(b, func(ctx context.Context, e events.PairingsSet) error {
+		return h.recordAudit(ctx, e.Tournament.ID, e.Actor, e.Change)
+	})
+	events.SubscribeRequired(b, func(ctx context.Context, e events.TournamentUpdated) error {
+		return h.recordAudit(ctx, e.Tournament.ID, e.Actor, e.Change)
+	})
+
+	events.Subscribe(b, func(ctx context.Context, e events.HoleRecorded) {
+		h.publishHole(e.Tournament, e.RoundNumber, e.MatchID, e.Hole)
+		h.sendCupClinched(e.Before, e.Tournament)
+	})
+	events.Subscribe(b, func(ctx context.Context, e events.MatchUpdated) {
+		h.publishMatch(e.Tournament, e.RoundNumber, e.MatchID)
+		h.sendCupClinched(e.Before, e.Tournament)
+	})
+	events.Subscribe(b, func(ctx context.Context, e events.PairingsSet) {
+		h.publishPairings(e.Tournament, e.RoundNumber)
+	})
+	events.Subscribe(b, func(ctx context.Context, e events.TournamentUpdated) {
+		h.publishTournament(e.Tournament)
+	})
+	events.Subscribe(b, func(ctx context.Context, e events.TournamentDeleted) {
+		h.live.End(e.TournamentID, "deleted", map[string]string{"id": e.TournamentID})
+	})
+
+	events.Subscribe(b, h.sendMatchCompleted)
+	events.Subscribe(b, h.sendRoundLocked)
+	events.Subscribe(b, h.sendUserRegistered)
+
+	events.Subscribe(b, h.notifyAdminsOfRegistration)
+	events.Subscribe(b, h.notifyAdminsOfDispute)
+}
+
+// publishMatchChange publishes a HoleRecorded or MatchUpdated event, followed
+// by MatchDecided if the change made the match's result official: decided,
+// and attested by both sides where attestation is needed. It returns the
+// error from publishing ev.
+func (h *Handler) publishMatchChange(r *http.Request, ev events.Event) error {
+	err := h.events.Publish(r.Context(), ev)
+
+	var before, after *models.Tournament
+	var roundNumber int
+	var matchID string
+	switch e := ev.(type) {
+	case events.HoleRecorded:
+		before, after, roundNumber, matchID = e.Before, e.Tournament, e.RoundNumber, e.MatchID
+	case events.MatchUpdated:
+		before, after, roundNumber, matchID = e.Before, e.Tournament, e.RoundNumber, e.MatchID
+	default:
+		return err
+	}
+	if before == nil || after == nil {
+		return err
+	}
+
+	match := findMatch(after, roundNumber, matchID)
+	if match == nil || !match.IsOfficial() {
+		return err
+	}
+	if prev := findMatch(before, roundNumber, matchID); prev != nil && prev.IsOfficial() {
+		return err
+	}
+	return errors.Join(err, h.events.Publish(r.Context(), events.MatchDecided{Before: before, Tournament: after, RoundNumber: roundNumber, MatchID: matchID}))
+}
+
+// actor returns the email of the user making the request, for events.
+func actor(r *http.Request) string {
+	if user := auth.GetUser(r.Context()); user != nil {
+		return user.Email
+	}
+	return ""
+}
+
+// writer is the request's user as the writer of a change to a match, for the
+// store to record.
+func writer(r *http.Request) models.Write {
+	if user := auth.GetUser(r.Context()); user != nil {
+		return models.Write{Email: user.Email, Admin: user.IsAdmin}
+	}
+	return models.Write{}
+}
+
+func (h *Handler) adminEmailList() []string {
+	admins := make([]string, 0, len(h.adminEmails))
+	for em := range h.adminEmails {
+		admins = append(admins, em)
+	}
+	return admins
+}
+
+// notifyAdminsOfRegistration emails the admins about a new account that needs
+// their confirmation.
+func (h *Handler) notifyAdminsOfRegistration(ctx context.Context, e events.UserRegistered) {
+	if e.User.Confirmed || !h.emailCfg.IsConfigured() {
+		return
+	}
+	if err := h.emailCfg.SendNewUserNotification(h.adminEmailList(), e.User.Name, e.User.Email, h.appURL); err != nil {
+		log.Printf("Failed to send admin notification: %v", err)
+	}
+}
+
+func (h *Handler) notifyAdminsOfDispute(ctx context.Context, e events.ScorecardDisputed) {
+	label := matchLabel(e.Tournament, e.RoundNumber, e.MatchID)
+	if !h.emailCfg.IsConfigured() {
+		log.Printf("Email not configured. Scorecard for %s disputed by %s: %s", label, e.Email, e.Comment)
+		return
+	}
+	if err := h.emailCfg.SendDisputeNotification(h.adminEmailList(), e.Tournament.Name, label, e.Email, e.Comment, h.appURL); err != nil {
+		log.Printf("Failed to send dispute notification: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"scoring-backend/internal/auth"
+	"scoring-backend/internal/events"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/store"
+	"strings"
+	"testing"
+)
+
+func TestMatchDecidedWhenOfficial(t *testing.T) {
+	decided := models.Match{ID: "m", Result: models.ResultTeam1, Score: "3 & 2"}
+	pending := models.Match{ID: "m", Result: models.ResultPending}
+	with := func(m models.Match, status models.AttestationStatus) models.Match {
+		m.AttestationStatus = status
+		return m
+	}
+
+	tests := []struct {
+		name          string
+		before, after models.Match
+		want          bool
+	}{
+		{"scored, awaiting attestation", pending, with(decided, models.AttestationAwaiting), false},
+		{"attested by both sides", with(decided, models.AttestationAwaiting), with(decided, models.AttestationAttested), true},
+		{"disputed", with(decided, models.AttestationAwaiting), with(decided, models.AttestationDisputed), false},
+		{"set directly by an admin", pending, decided, true},
+		{"dispute settled by an admin", with(decided, models.AttestationDisputed), decided, true},
+		{"already official", decided, models.Match{ID: "m", Result: models.ResultTie, Score: "A/S"}, false},
+		{"back to pending", decided, pending, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			h := New(store.NewMemoryStore(), nil, "secret", "http://localhost", nil)
+			fired := false
+			events.Subscribe(h.events, func(context.Context, events.MatchDecided) { fired = true })
+
+			tournament := func(m models.Match) *models.Tournament {
+				return &models.Tournament{ID: "t", Rounds: []models.Round{{Number: 1, Matches: []models.Match{m}}}}
+			}
+			r := httptest.NewRequest("PUT", "/", nil)
+			err := h.publishMatchChange(r, events.MatchUpdated{Before: tournament(tc.before), Tournament: tournament(tc.after), RoundNumber: 1, MatchID: "m"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if fired != tc.want {
+				t.Errorf("MatchDecided published = %v, want %v", fired, tc.want)
+			}
+		})
+	}
+}
+
+func TestLockRoundReportsRoundLockedFailure(t *testing.T) {
+	s := store.NewMemoryStore()
+	h := New(s, nil, "secret", "http://localhost", nil)
+	tour := newSyncTournament(t, s)
+	events.SubscribeRequired(h.events, func(context.Context, events.RoundLocked) error {
+		return errors.New("notifier down")
+	})
+
+	r := httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"locked":true}`))
+	r.SetPathValue("id", tour.ID)
+	r.SetPathValue("round", "1")
+	r.Header.Set("If-Match", etag(tour.Version))
+	r = r.WithContext(context.WithValue(r.Context(), auth.UserKey, &auth.UserClaims{Email: syncAdmin, IsAdmin: true}))
+	w := httptest.NewRecorder()
+	h.LockRound(w, r)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp struct {
+		Warning string `json:"warning"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(resp.Warning, "notifier down") {
+		t.Errorf("warning = %q, want the RoundLocked failure", resp.Warning)
+	}
+}
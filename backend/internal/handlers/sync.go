@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"scoring-backend/internal/auth"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/store"
+	"strconv"
+	"time"
+)
+
+// maxSyncEntries bounds one sync upload. A device that scored a whole
+// tournament offline stays well under it.
+const maxSyncEntries = 500
+
+// Outcomes of a synced hole entry.
+const (
+	syncApplied    = "applied"    // recorded
+	syncUnchanged  = "unchanged"  // the server already had this result
+	syncSuperseded = "superseded" // lost a conflict; the server's result stands
+	syncRejected   = "rejected"   // invalid or not allowed
+)
+
+// SyncHoleEntry is a hole result queued on a device, usually while it was
+// offline. BaseVersion is the version of the match the device last saw when
+// the result was entered, and ClientTime when it was entered by the device's
+// clock.
+type SyncHoleEntry struct {
+	ID          string    `json:"id"`
+	RoundNumber int       `json:"roundNumber"`
+	MatchID     string    `json:"matchId"`
+	Hole        int       `json:"hole"`
+	Result      string    `json:"result"`
+	ClientTime  time.Time `json:"clientTime"`
+	BaseVersion int64     `json:"baseVersion"`
+}
+
+type SyncHolesRequest struct {
+	Entries []SyncHoleEntry `json:"entries"`
+}
+
+// syncConflict is the change made on the server since an entry's base version
+// that the entry was weighed against.
+type syncConflict struct {
+	Result    string    `json:"result"`
+	UserEmail string    `json:"userEmail"`
+	At        time.Time `json:"at"`
+}
+
+// syncOutcome reports what happened to one entry. Result is the hole's result
+// on the server once the entry has been dealt with.
+type syncOutcome struct {
+	ID       string        `json:"id"`
+	Status   string        `json:"status"`
+	Result   string        `json:"result"`
+	Reason   string        `json:"reason,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Warning  string        `json:"warning,omitempty"`
+	Code     int           `json:"code,omitempty"`
+	Conflict *syncConflict `json:"conflict,omitempty"`
+}
+
+// SyncHolesResponse carries an outcome for every entry, in upload order, and
+// the resolved state of each match the batch touched.
+type SyncHolesResponse struct {
+	Version int64          `json:"version"`
+	Results []syncOutcome  `json:"results"`
+	Matches []models.Match `json:"matches"`
+}
+
+// holeKey identifies a hole of a match.
+type holeKey struct {
+	round int
+	match string
+	hole  int
+}
+
+// SyncHoles records a batch of hole results queued on a device. Entries are
+// applied in upload order, each with the same checks as UpdateHoleResult, so
+// one bad entry doesn't hold up the rest.
+//
+// An entry conflicts when its hole, or the match's result directly, was
+// changed after the entry's base match version by anyone but earlier entries
+// of the same sync; a user's own change from another device counts. The store
+// decides the conflict as part of the write, against the last write it
+// recorded on the match (see store.HoleSync): an admin's entry beats a
+// non-admin's change and the reverse, and otherwise the later of the two wins,
+// taking the entry's client time (never later than now, so a fast clock can't
+// win every conflict) against the server time of the change.
+func (h *Handler) SyncHoles(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var req SyncHolesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if len(req.Entries) == 0 {
+		writeError(w, http.StatusBadRequest, "no entries to sync")
+		return
+	}
+	if len(req.Entries) > maxSyncEntries {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("too many entries (at most %d per sync)", maxSyncEntries))
+		return
+	}
+
+	user := auth.GetUser(r.Context())
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "not authenticated")
+		return
+	}
+
+	t, err := h.store.GetTournament(r.Context(), id)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	now := time.Now()
+	own := make(map[string][]int64)
+	resp := SyncHolesResponse{Results: make([]syncOutcome, 0, len(req.Entries))}
+	var touched []holeKey
+	for _, entry := range req.Entries {
+		var out syncOutcome
+		t, out = h.syncHole(r, t, entry, own, now)
+		resp.Results = append(resp.Results, out)
+		touched = append(touched, holeKey{round: entry.RoundNumber, match: entry.MatchID})
+	}
+
+	resp.Version = t.Version
+	seen := make(map[holeKey]bool)
+	for _, k := range touched {
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		if m := findMatch(t, k.round, k.match); m != nil {
+			resp.Matches = append(resp.Matches, *m)
+		}
+	}
+	if resp.Matches == nil {
+		resp.Matches = []models.Match{}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// syncHole applies one synced entry to the tournament last read as t, trying
+// again against a fresh read whenever another write to the match gets in
+// first. own holds the match versions each match was moved to by earlier
+// entries of the sync, and gains the one this entry produces. It returns the
+// tournament as it stands afterwards.
+func (h *Handler) syncHole(r *http.Request, t *models.Tournament, entry SyncHoleEntry, own map[string][]int64, now time.Time) (*models.Tournament, syncOutcome) {
+	out := syncOutcome{ID: entry.ID}
+	reject := func(code int, err error) (*models.Tournament, syncOutcome) {
+		out.Status, out.Code, out.Error = syncRejected, code, err.Error()
+		return t, out
+	}
+	user := auth.GetUser(r.Context())
+
+	for attempt := 1; ; attempt++ {
+		out = syncOutcome{ID: entry.ID}
+		match := findMatch(t, entry.RoundNumber, entry.MatchID)
+		if match == nil {
+			return reject(http.StatusNotFound, fmt.Errorf("match %s not found in round %d", entry.MatchID, entry.RoundNumber))
+		}
+		if code, err := checkHoleResult(r, t, entry.RoundNumber, entry.MatchID, entry.Hole, entry.Result); err != nil {
+			return reject(code, err)
+		}
+
+		out.Result = match.HoleResults[strconv.Itoa(entry.Hole)]
+		if out.Result == entry.Result {
+			out.Status = syncUnchanged
+			return t, out
+		}
+
+		hs := &store.HoleSync{BaseVersion: entry.BaseVersion, At: clientTime(entry, now), Own: own[entry.MatchID]}
+		updated, warning, code, err := h.applyHoleResult(r, t, entry.RoundNumber, entry.MatchID, entry.Hole, entry.Result, store.Precondition{Sync: hs})
+		var conflict *store.VersionConflictError
+		if errors.As(err, &conflict) && conflict.MatchID != "" && attempt < maxHoleAttempts {
+			if t, err = h.store.GetTournament(r.Context(), t.ID); err != nil {
+				return reject(http.StatusInternalServerError, err)
+			}
+			continue
+		}
+		var superseded *store.SupersededError
+		if errors.As(err, &superseded) {
+			c := superseded.Change
+			out.Result, out.Conflict = superseded.Result, &syncConflict{Result: superseded.Result, UserEmail: c.Email, At: c.At}
+			out.Status, out.Reason = syncSuperseded, "a later result was recorded by "+c.Email
+			if c.Admin && !user.IsAdmin {
+				out.Reason = "an admin changed this hole since"
+			}
+			return t, out
+		}
+		if err != nil {
+			return reject(code, err)
+		}
+
+		if c := hs.Overrode; c != nil {
+			out.Conflict = &syncConflict{Result: out.Result, UserEmail: c.Email, At: c.At}
+			out.Reason = "entered after a change by " + c.Email
+			if user.IsAdmin && !c.Admin {
+				out.Reason = "admin entry overrides a change by " + c.Email
+			}
+		}
+		out.Warning = publishWarning(warning)
+		if m := findMatch(updated, entry.RoundNumber, entry.MatchID); m != nil {
+			own[entry.MatchID] = append(own[entry.MatchID], m.Version)
+		}
+		out.Status, out.Result = syncApplied, entry.Result
+		return updated, out
+	}
+}
+
+// clientTime is when the device says an entry was made, clamped to now. An
+// entry without a time counts as made now.
+func clientTime(entry SyncHoleEntry, now time.Time) time.Time {
+	if entry.ClientTime.IsZero() || entry.ClientTime.After(now) {
+		return now
+	}
+	return entry.ClientTime
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"scoring-backend/internal/auth"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/store"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	syncAdmin   = "admin@example.com"
+	syncPlayer1 = "one@example.com"
+	syncPlayer2 = "two@example.com"
+)
+
+// newSyncTournament stores a tournament with one singles match between two
+// linked players and returns it.
+func newSyncTournament(t *testing.T, s store.Store) *models.Tournament {
+	t.Helper()
+	tour := &models.Tournament{ID: uuid.NewString(), Name: "Sync"}
+	for i, email := range []string{syncPlayer1, syncPlayer2} {
+		teamID := uuid.NewString()
+		tour.Teams[i] = models.Team{ID: teamID, Name: email, Players: []models.Player{{ID: uuid.NewString(), Name: email, TeamID: teamID, UserEmail: email}}}
+	}
+	tour.Rounds = []models.Round{{Number: 1, Name: "Singles", Type: models.RoundSingles, PointsPerMatch: 1, Matches: []models.Match{{
+		ID:           uuid.NewString(),
+		RoundNumber:  1,
+		Team1Players: []string{tour.Teams[0].Players[0].ID},
+		Team2Players: []string{tour.Teams[1].Players[0].ID},
+		Result:       models.ResultPending,
+		HoleResults:  map[string]string{},
+	}}}}
+	if err := s.CreateTournament(context.Background(), tour); err != nil {
+		t.Fatalf("CreateTournament: %v", err)
+	}
+	return tour
+}
+
+// changeHole records hole 1 of the match as changed by email at the given
+// server time, as a live edit would have.
+func changeHole(t *testing.T, s store.Store, tour *models.Tournament, email, result string, at time.Time) {
+	t.Helper()
+	m := tour.Rounds[0].Matches[0]
+	writer := models.Write{Email: email, Admin: email == syncAdmin, At: at}
+	if err := s.UpdateHoleResult(context.Background(), tour.ID, 1, m.ID, 1, result, store.Precondition{Writer: writer}); err != nil {
+		t.Fatalf("UpdateHoleResult: %v", err)
+	}
+}
+
+// syncHoleOne syncs a single entry for hole 1, made against the match's first
+// version, as user and returns its outcome.
+func syncHoleOne(t *testing.T, h *Handler, tour *models.Tournament, user *auth.UserClaims, result string, clientTime time.Time) syncOutcome {
+	t.Helper()
+	body, _ := json.Marshal(SyncHolesRequest{Entries: []SyncHoleEntry{{
+		ID:          uuid.NewString(),
+		RoundNumber: 1,
+		MatchID:     tour.Rounds[0].Matches[0].ID,
+		Hole:        1,
+		Result:      result,
+		ClientTime:  clientTime,
+		BaseVersion: 1,
+	}}})
+	r := httptest.NewRequest(http.MethodPost, "/api/tournaments/"+tour.ID+"/sync", bytes.NewReader(body))
+	r.SetPathValue("id", tour.ID)
+	r = r.WithContext(context.WithValue(r.Context(), auth.UserKey, user))
+	w := httptest.NewRecorder()
+	h.SyncHoles(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("SyncHoles: status %d: %s", w.Code, w.Body)
+	}
+
+	var resp SyncHolesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	if len(resp.Results) != 1 {
+		t.Fatalf("got %d results, want 1", len(resp.Results))
+	}
+	return resp.Results[0]
+}
+
+func TestSyncHolesConflicts(t *testing.T) {
+	now := time.Now()
+	admin := &auth.UserClaims{Email: syncAdmin, IsAdmin: true}
+	player := &auth.UserClaims{Email: syncPlayer2}
+
+	tests := []struct {
+		name       string
+		changedBy  string
+		changedAt  time.Time
+		user       *auth.UserClaims
+		clientTime time.Time
+		wantStatus string
+		wantResult string
+	}{
+		{"admin wins over an earlier player change", syncPlayer1, now.Add(-time.Minute), admin, now.Add(-time.Hour), syncApplied, "team2"},
+		{"player loses to an admin change", syncAdmin, now.Add(-time.Hour), player, now.Add(-time.Minute), syncSuperseded, "team1"},
+		{"later client time wins", syncPlayer1, now.Add(-time.Hour), player, now.Add(-time.Minute), syncApplied, "team2"},
+		{"earlier client time loses", syncPlayer1, now.Add(-time.Minute), player, now.Add(-time.Hour), syncSuperseded, "team1"},
+		// The change is stamped ahead of the sync; a device clock further
+		// ahead still counts as now and loses.
+		{"future client time is clamped", syncPlayer1, now.Add(time.Hour), player, now.Add(24 * time.Hour), syncSuperseded, "team1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := store.NewMemoryStore()
+			h := New(s, nil, "secret", "http://localhost", map[string]bool{syncAdmin: true})
+			tour := newSyncTournament(t, s)
+			changeHole(t, s, tour, tc.changedBy, "team1", tc.changedAt)
+
+			out := syncHoleOne(t, h, tour, tc.user, "team2", tc.clientTime)
+			if out.Status != tc.wantStatus || out.Result != tc.wantResult {
+				t.Errorf("outcome = %s with result %q (%s), want %s with %q", out.Status, out.Result, out.Reason, tc.wantStatus, tc.wantResult)
+			}
+			if out.Conflict == nil || out.Conflict.UserEmail != tc.changedBy {
+				t.Errorf("conflict = %+v, want the change by %s", out.Conflict, tc.changedBy)
+			}
+
+			got, err := s.GetTournament(context.Background(), tour.ID)
+			if err != nil {
+				t.Fatalf("GetTournament: %v", err)
+			}
+			if result := got.Rounds[0].Matches[0].HoleResults["1"]; result != tc.wantResult {
+				t.Errorf("stored hole 1 = %q, want %q", result, tc.wantResult)
+			}
+		})
+	}
+}
+
+func TestSyncHolesWithoutConflict(t *testing.T) {
+	s := store.NewMemoryStore()
+	h := New(s, nil, "secret", "http://localhost", nil)
+	tour := newSyncTournament(t, s)
+
+	out := syncHoleOne(t, h, tour, &auth.UserClaims{Email: syncPlayer1}, "team1", time.Now())
+	if out.Status != syncApplied || out.Conflict != nil {
+		t.Errorf("outcome = %+v, want applied with no conflict", out)
+	}
+
+	entries, err := s.ListAuditEntries(context.Background(), tour.ID)
+	if err != nil {
+		t.Fatalf("ListAuditEntries: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Version != 2 {
+		t.Errorf("audit = %+v, want one entry at match version 2", entries)
+	}
+}
+
+// A result an admin set directly since the device last saw the match, which
+// the audit log alone never showed as a change to the hole, beats a player's
+// entry.
+func TestSyncHolesAfterDirectResult(t *testing.T) {
+	s := store.NewMemoryStore()
+	h := New(s, nil, "secret", "http://localhost", map[string]bool{syncAdmin: true})
+	tour := newSyncTournament(t, s)
+	m := tour.Rounds[0].Matches[0]
+	admin := models.Write{Email: syncAdmin, Admin: true}
+	if err := s.UpdateMatchResult(context.Background(), tour.ID, 1, m.ID, models.ResultTie, "A/S", store.Precondition{Writer: admin}); err != nil {
+		t.Fatalf("UpdateMatchResult: %v", err)
+	}
+
+	out := syncHoleOne(t, h, tour, &auth.UserClaims{Email: syncPlayer1}, "team1", time.Now())
+	if out.Status != syncSuperseded || out.Conflict == nil || out.Conflict.UserEmail != syncAdmin {
+		t.Errorf("outcome = %+v, want superseded by the admin's result", out)
+	}
+}
+
+// Entries of one sync don't conflict with each other, even on the same hole.
+func TestSyncHolesOwnEntries(t *testing.T) {
+	s := store.NewMemoryStore()
+	h := New(s, nil, "secret", "http://localhost", nil)
+	tour := newSyncTournament(t, s)
+	matchID := tour.Rounds[0].Matches[0].ID
+	then := time.Now().Add(-time.Hour)
+
+	body, _ := json.Marshal(SyncHolesRequest{Entries: []SyncHoleEntry{
+		{ID: "a", RoundNumber: 1, MatchID: matchID, Hole: 1, Result: "team1", ClientTime: then, BaseVersion: 1},
+		{ID: "b", RoundNumber: 1, MatchID: matchID, Hole: 1, Result: "team2", ClientTime: then.Add(-time.Minute), BaseVersion: 1},
+	}})
+	r := httptest.NewRequest(http.MethodPost, "/api/tournaments/"+tour.ID+"/sync", bytes.NewReader(body))
+	r.SetPathValue("id", tour.ID)
+	r = r.WithContext(context.WithValue(r.Context(), auth.UserKey, &auth.UserClaims{Email: syncPlayer1}))
+	w := httptest.NewRecorder()
+	h.SyncHoles(w, r)
+
+	var resp SyncHolesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decoding response: %v", err)
+	}
+	for _, out := range resp.Results {
+		if out.Status != syncApplied || out.Conflict != nil {
+			t.Errorf("entry %s = %+v, want applied with no conflict", out.ID, out)
+		}
+	}
+	if len(resp.Matches) != 1 || resp.Matches[0].HoleResults["1"] != "team2" {
+		t.Errorf("matches = %+v, want hole 1 at the last entry's team2", resp.Matches)
+	}
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"embed"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"scoring-backend/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed templates/tv.html
+var tvFiles embed.FS
+
+var tvTemplate = template.Must(template.New("tv.html").Funcs(template.FuncMap{
+	"points": formatPoints,
+}).ParseFS(tvFiles, "templates/tv.html"))
+
+// Defaults match the React app's theme.
+const (
+	defaultHeaderColor = "#1C4932"
+	defaultBgColor     = "#f5f5f0"
+	defaultTeam1Color  = "#1a3a6b"
+	defaultTeam2Color  = "#8b1a1a"
+)
+
+// tvDefaultInterval is how long each round stays on screen, in seconds.
+const tvDefaultInterval = 20
+
+var hexColor = regexp.MustCompile(`^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+type tvPage struct {
+	Name        string
+	HeaderColor template.CSS
+	BgColor     template.CSS
+	Team1Color  template.CSS
+	Team2Color  template.CSS
+	Updated     string
+	Scoreboard  models.Scoreboard
+	RoundScores []models.RoundScore
+	Round       tvRound
+	Interval    int
+	NextURL     string
+}
+
+type tvRound struct {
+	Number  int
+	Name    string
+	Matches []tvMatch
+}
+
+type tvMatch struct {
+	Team1      string
+	Team2      string
+	Status     string
+	Unofficial string
+	Class      string
+	Holes      []string
+}
+
+// TVLeaderboard renders a self-refreshing HTML leaderboard for a clubhouse TV,
+// reached with a spectator share token. Each refresh moves on to the next
+// round that has pairings; ?round= picks the round and ?interval= sets the
+// seconds between refreshes.
+func (h *Handler) TVLeaderboard(w http.ResponseWriter, r *http.Request) {
+	t, ok := h.sharedTournament(w, r)
+	if !ok {
+		return
+	}
+
+	interval := tvDefaultInterval
+	if n, err := strconv.Atoi(r.URL.Query().Get("interval")); err == nil {
+		interval = min(max(n, 5), 300)
+	}
+
+	// Only rounds with pairings are cycled through.
+	var shown []*models.Round
+	for i := range t.Rounds {
+		if len(t.Rounds[i].Matches) > 0 {
+			shown = append(shown, &t.Rounds[i])
+		}
+	}
+	sb := t.CalculateScoreboard()
+	// Round 3 is left out of the summary when it is played together with
+	// round 2.
+	roundScores := make([]models.RoundScore, 0, len(sb.RoundScores))
+	for _, rs := range sb.RoundScores {
+		if t.CombineRounds23 && rs.RoundNumber == 3 && rs.TotalMatches == 0 {
+			continue
+		}
+		roundScores = append(roundScores, rs)
+	}
+
+	page := tvPage{
+		Name:        t.Name,
+		HeaderColor: cssColor(t.HeaderColor, defaultHeaderColor),
+		BgColor:     cssColor(t.BgColor, defaultBgColor),
+		Team1Color:  cssColor(t.Teams[0].Color, defaultTeam1Color),
+		Team2Color:  cssColor(t.Teams[1].Color, defaultTeam2Color),
+		Updated:     time.Now().Format("15:04"),
+		Scoreboard:  sb,
+		RoundScores: roundScores,
+		Interval:    interval,
+	}
+
+	next := 0
+	if len(shown) > 0 {
+		current := 0
+		if n, err := strconv.Atoi(r.URL.Query().Get("round")); err == nil {
+			for i, round := range shown {
+				if round.Number == n {
+					current = i
+				}
+			}
+		}
+		page.Round = buildTVRound(t, shown[current])
+		next = shown[(current+1)%len(shown)].Number
+	}
+
+	q := url.Values{}
+	q.Set("interval", strconv.Itoa(interval))
+	if next != 0 {
+		q.Set("round", strconv.Itoa(next))
+	}
+	page.NextURL = "?" + q.Encode()
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := tvTemplate.Execute(w, page); err != nil {
+		log.Printf("Failed to render TV leaderboard for tournament %s: %v", t.ID, err)
+	}
+}
+
+func buildTVRound(t *models.Tournament, round *models.Round) tvRound {
+	names := make(map[string]string)
+	for _, team := range t.Teams {
+		for _, p := range team.Players {
+			names[p.ID] = p.Name
+		}
+	}
+	side := func(ids []string) string {
+		parts := make([]string, 0, len(ids))
+		for _, pid := range ids {
+			parts = append(parts, names[pid])
+		}
+		return strings.Join(parts, " & ")
+	}
+
+	out := tvRound{Number: round.Number, Name: round.Name}
+	for _, m := range round.Matches {
+		tm := tvMatch{
+			Team1:  side(m.Team1Players),
+			Team2:  side(m.Team2Players),
+			Status: m.Score,
+			Holes:  make([]string, round.HoleCount()),
+		}
+		for i := range tm.Holes {
+			tm.Holes[i] = m.HoleResults[strconv.Itoa(i+1)]
+		}
+
+		switch m.Result {
+		case models.ResultTeam1:
+			tm.Class = "won-team1"
+		case models.ResultTeam2:
+			tm.Class = "won-team2"
+		case models.ResultTie:
+			tm.Class = "tie"
+		}
+		if tm.Status == "" {
+			if m.Result == models.ResultPending || m.Result == "" {
+				tm.Status = "Not started"
+			} else {
+				tm.Status = "Final"
+			}
+		}
+		switch m.AttestationStatus {
+		case models.AttestationAwaiting:
+			tm.Unofficial = "awaiting attestation"
+		case models.AttestationDisputed:
+			tm.Unofficial = "disputed"
+		}
+		out.Matches = append(out.Matches, tm)
+	}
+	return out
+}
+
+// cssColor returns c if it is a hex color, or def otherwise, so tournament
+// settings can't inject arbitrary CSS.
+func cssColor(c, def string) template.CSS {
+	if hexColor.MatchString(c) {
+		return template.CSS(c)
+	}
+	return template.CSS(def)
+}
+
+// formatPoints drops the fraction from whole numbers of points: 3, 2.5.
+func formatPoints(p float64) string {
+	return strconv.FormatFloat(p, 'f', -1, 64)
+}
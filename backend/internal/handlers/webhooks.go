@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"scoring-backend/internal/auth"
+	"scoring-backend/internal/events"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/webhook"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var webhookEvents = map[models.WebhookEvent]bool{
+	models.WebhookMatchCompleted: true,
+	models.WebhookRoundLocked:    true,
+	models.WebhookCupClinched:    true,
+	models.WebhookUserRegistered: true,
+}
+
+type CreateWebhookRequest struct {
+	URL    string                `json:"url"`
+	Secret string                `json:"secret"`
+	Events []models.WebhookEvent `json:"events"`
+}
+
+// matchCompletedEvent is the data of a match.completed webhook.
+type matchCompletedEvent struct {
+	RoundNumber int                `json:"roundNumber"`
+	Label       string             `json:"label"`
+	Result      models.MatchResult `json:"result"`
+	Winner      string             `json:"winner,omitempty"`
+	Score       string             `json:"score"`
+	Match       *models.Match      `json:"match"`
+}
+
+// roundLockedEvent is the data of a round.locked webhook.
+type roundLockedEvent struct {
+	RoundNumber int     `json:"roundNumber"`
+	RoundName   string  `json:"roundName"`
+	Team1Points float64 `json:"team1Points"`
+	Team2Points float64 `json:"team2Points"`
+}
+
+// cupClinchedEvent is the data of a cup.clinched webhook.
+type cupClinchedEvent struct {
+	Winner     string            `json:"winner"`
+	Scoreboard models.Scoreboard `json:"scoreboard"`
+}
+
+// userRegisteredEvent is the data of a user.registered webhook.
+type userRegisteredEvent struct {
+	Email string `json:"email"`
+	Name  string `json:"name"`
+}
+
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	var req CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := webhook.CheckURL(r.Context(), req.URL); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, e := range req.Events {
+		if !webhookEvents[e] {
+			writeError(w, http.StatusBadRequest, "unknown webhook event: "+string(e))
+			return
+		}
+	}
+	if req.Secret == "" {
+		var err error
+		if req.Secret, err = auth.GenerateVerificationToken(); err != nil {
+			writeError(w, http.StatusInternalServerError, "failed to generate webhook secret")
+			return
+		}
+	}
+	if req.Events == nil {
+		req.Events = []models.WebhookEvent{}
+	}
+
+	wh := &models.Webhook{
+		ID:           uuid.New().String(),
+		TournamentID: id,
+		URL:          req.URL,
+		Secret:       req.Secret,
+		Events:       req.Events,
+		CreatedAt:    time.Now(),
+	}
+	if err := h.store.CreateWebhook(r.Context(), wh); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	// The secret is only shown when the webhook is created.
+	writeJSON(w, http.StatusCreated, wh)
+}
+
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.store.ListWebhooks(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	for _, wh := range hooks {
+		wh.Secret = ""
+	}
+	writeJSON(w, http.StatusOK, hooks)
+}
+
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := h.store.DeleteWebhook(r.Context(), r.PathValue("id"), r.PathValue("webhookId")); err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := h.store.ListWebhookDeliveries(r.Context(), r.PathValue("id"), r.PathValue("webhookId"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+// sendMatchCompleted sends the match.completed webhook for a match whose
+// result became official.
+func (h *Handler) sendMatchCompleted(ctx context.Context, e events.MatchDecided) {
+	match := findMatch(e.Tournament, e.RoundNumber, e.MatchID)
+	if match == nil || !match.IsOfficial() {
+		return
+	}
+	event := matchCompletedEvent{
+		RoundNumber: e.RoundNumber,
+		Label:       matchLabel(e.Tournament, e.RoundNumber, e.MatchID),
+		Result:      match.Result,
+		Score:       match.Score,
+		Match:       match,
+	}
+	switch match.Result {
+	case models.ResultTeam1:
+		event.Winner = e.Tournament.Teams[0].Name
+	case models.ResultTeam2:
+		event.Winner = e.Tournament.Teams[1].Name
+	}
+	h.webhooks.Send(e.Tournament.ID, models.WebhookMatchCompleted, event)
+}
+
+// sendCupClinched sends the cup.clinched webhook if a change to one match put
+// the cup out of reach of the trailing team.
+func (h *Handler) sendCupClinched(before, after *models.Tournament) {
+	if before == nil || after == nil || before.ClinchedBy() != 0 {
+		return
+	}
+	if team := after.ClinchedBy(); team != 0 {
+		h.webhooks.Send(after.ID, models.WebhookCupClinched, cupClinchedEvent{
+			Winner:     after.Teams[team-1].Name,
+			Scoreboard: after.CalculateScoreboard(),
+		})
+	}
+}
+
+func (h *Handler) sendRoundLocked(ctx context.Context, e events.RoundLocked) {
+	for _, rs := range e.Tournament.CalculateScoreboard().RoundScores {
+		if rs.RoundNumber == e.RoundNumber {
+			h.webhooks.Send(e.Tournament.ID, models.WebhookRoundLocked, roundLockedEvent{
+				RoundNumber: e.RoundNumber,
+				RoundName:   rs.RoundName,
+				Team1Points: rs.Team1Points,
+				Team2Points: rs.Team2Points,
+			})
+			return
+		}
+	}
+}
+
+func (h *Handler) sendUserRegistered(ctx context.Context, e events.UserRegistered) {
+	h.webhooks.SendAll(models.WebhookUserRegistered, userRegisteredEvent{Email: e.User.Email, Name: e.User.Name})
+}
@@ -0,0 +1,97 @@
+// Package ical writes iCalendar (RFC 5545) feeds.
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Event is a VEVENT. Times are written in UTC.
+type Event struct {
+	UID         string
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Location    string
+	Updated     time.Time
+}
+
+// Calendar is a VCALENDAR with a display name for the subscribing app.
+type Calendar struct {
+	Name   string
+	Events []Event
+}
+
+const timeFormat = "20060102T150405Z"
+
+// Write encodes c with CRLF line endings and long lines folded.
+func (c *Calendar) Write(w io.Writer) error {
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//golf-matchplay//tee times//EN",
+		"CALSCALE:GREGORIAN",
+		"METHOD:PUBLISH",
+		"X-WR-CALNAME:" + escape(c.Name),
+		// Ask subscribing apps to check for changed tee times hourly.
+		"REFRESH-INTERVAL;VALUE=DURATION:PT1H",
+		"X-PUBLISHED-TTL:PT1H",
+	}
+	now := time.Now().UTC().Format(timeFormat)
+	for _, e := range c.Events {
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+escape(e.UID),
+			"DTSTAMP:"+now,
+			"DTSTART:"+e.Start.UTC().Format(timeFormat),
+			"DTEND:"+e.End.UTC().Format(timeFormat),
+			"SUMMARY:"+escape(e.Summary),
+		)
+		if e.Description != "" {
+			lines = append(lines, "DESCRIPTION:"+escape(e.Description))
+		}
+		if e.Location != "" {
+			lines = append(lines, "LOCATION:"+escape(e.Location))
+		}
+		if !e.Updated.IsZero() {
+			lines = append(lines, "LAST-MODIFIED:"+e.Updated.UTC().Format(timeFormat))
+		}
+		lines = append(lines, "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, fold(line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+// fold splits a content line into 75-octet pieces, continuing each with a
+// leading space, without breaking a UTF-8 sequence.
+func fold(line string) string {
+	var b strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		fmt.Fprintf(&b, "%s\r\n ", line[:cut])
+		line = line[cut:]
+		limit = 74 // the leading space counts toward the next line
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+	return b.String()
+}
@@ -0,0 +1,250 @@
+// Package live fans out tournament changes to connected clients.
+package live
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historySize is how many recent events each tournament keeps for clients
+// resuming with a Last-Event-ID.
+const historySize = 256
+
+// subscriberBuffer is how many events may queue for a subscriber before it is
+// considered too slow and disconnected. A disconnected client reconnects and
+// catches up from the history.
+const subscriberBuffer = 64
+
+// streamIdle is how long a tournament's stream is kept once it has no
+// subscribers and no new events, so a client reconnecting in that time can
+// still resume from its history.
+const streamIdle = 30 * time.Minute
+
+// Event is a single change pushed to clients.
+type Event struct {
+	ID   string
+	Type string
+	Data json.RawMessage
+}
+
+// Hub keeps the subscribers and recent history for every tournament.
+//
+// Event IDs have the form "<epoch>-<seq>". The epoch changes each time the
+// server starts, so an ID from before a restart is recognised as unknown and
+// the client is sent a fresh snapshot instead. Streams are dropped when their
+// tournament is deleted or they sit idle; a new stream numbers its events on
+// from the highest sequence number any dropped stream reached, so an ID from a
+// dropped stream can't be mistaken for one of the new stream's.
+type Hub struct {
+	mu        sync.Mutex
+	epoch     string
+	streams   map[string]*stream
+	floor     uint64
+	nextSweep time.Time
+}
+
+type stream struct {
+	seq        uint64
+	history    []Event
+	subs       map[chan Event]struct{}
+	scoreboard []byte
+	active     time.Time // last event or unsubscribe
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		epoch:   strconv.FormatInt(time.Now().UnixNano(), 36),
+		streams: make(map[string]*stream),
+	}
+}
+
+func (h *Hub) stream(tournamentID string) *stream {
+	now := time.Now()
+	if now.After(h.nextSweep) {
+		h.sweepLocked(now)
+	}
+	s, ok := h.streams[tournamentID]
+	if !ok {
+		s = &stream{seq: h.floor, subs: make(map[chan Event]struct{}), active: now}
+		h.streams[tournamentID] = s
+	}
+	return s
+}
+
+// sweepLocked drops every stream that has had no subscribers and no events
+// for streamIdle.
+func (h *Hub) sweepLocked(now time.Time) {
+	h.nextSweep = now.Add(streamIdle / 2)
+	for id, s := range h.streams {
+		if len(s.subs) == 0 && now.Sub(s.active) > streamIdle {
+			h.dropLocked(id, s)
+		}
+	}
+}
+
+func (h *Hub) dropLocked(tournamentID string, s *stream) {
+	h.floor = max(h.floor, s.seq)
+	delete(h.streams, tournamentID)
+}
+
+func (h *Hub) eventID(seq uint64) string {
+	return h.epoch + "-" + strconv.FormatUint(seq, 10)
+}
+
+// Publish sends an event to every subscriber of the tournament.
+func (h *Hub) Publish(tournamentID, eventType string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.publishLocked(h.stream(tournamentID), eventType, payload)
+}
+
+// PublishScoreboard publishes the scoreboard only if it differs from the last
+// one published for the tournament.
+func (h *Hub) PublishScoreboard(tournamentID string, scoreboard any) {
+	payload, err := json.Marshal(scoreboard)
+	if err != nil {
+		log.Printf("Failed to encode scoreboard event: %v", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.stream(tournamentID)
+	if bytes.Equal(s.scoreboard, payload) {
+		return
+	}
+	s.scoreboard = payload
+	h.publishLocked(s, "scoreboard", payload)
+}
+
+// End sends a last event to the tournament's subscribers, ends their
+// subscriptions and drops the tournament's stream. It is for a tournament
+// that has been deleted.
+func (h *Hub) End(tournamentID, eventType string, data any) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Failed to encode %s event: %v", eventType, err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, ok := h.streams[tournamentID]
+	if !ok {
+		return
+	}
+	h.publishLocked(s, eventType, payload)
+	for ch := range s.subs {
+		delete(s.subs, ch)
+		close(ch)
+	}
+	h.dropLocked(tournamentID, s)
+}
+
+func (h *Hub) publishLocked(s *stream, eventType string, payload []byte) {
+	s.active = time.Now()
+	s.seq++
+	ev := Event{ID: h.eventID(s.seq), Type: eventType, Data: payload}
+
+	s.history = append(s.history, ev)
+	if len(s.history) > historySize {
+		s.history = s.history[len(s.history)-historySize:]
+	}
+
+	for ch := range s.subs {
+		select {
+		case ch <- ev:
+		default:
+			delete(s.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// Subscription is a client's view of a tournament stream.
+type Subscription struct {
+	// Events delivers new events. It is closed if the subscriber falls too far
+	// behind, or after the last event of a stream that has ended.
+	Events <-chan Event
+	// Replay holds the events missed since the client's Last-Event-ID.
+	Replay []Event
+	// Resumed is false when the Last-Event-ID was empty or could not be found
+	// in the history; the client then needs a full snapshot.
+	Resumed bool
+	// LastID is the ID of the most recent event at subscription time, to be
+	// used as the ID of a snapshot.
+	LastID string
+
+	cancel func()
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.cancel()
+}
+
+// Subscribe registers for a tournament's events, replaying anything published
+// after lastEventID.
+func (h *Hub) Subscribe(tournamentID, lastEventID string) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.stream(tournamentID)
+	ch := make(chan Event, subscriberBuffer)
+	s.subs[ch] = struct{}{}
+
+	sub := &Subscription{Events: ch, LastID: h.eventID(s.seq)}
+	var once sync.Once
+	sub.cancel = func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if _, ok := s.subs[ch]; ok {
+				delete(s.subs, ch)
+				close(ch)
+				s.active = time.Now()
+			}
+		})
+	}
+
+	seq, ok := h.parseID(lastEventID)
+	if !ok || seq > s.seq {
+		return sub
+	}
+	// Resuming is only possible if nothing after seq has left the history.
+	oldest := s.seq - uint64(len(s.history)) + 1
+	if seq+1 < oldest {
+		return sub
+	}
+	for _, ev := range s.history {
+		if evSeq, _ := h.parseID(ev.ID); evSeq > seq {
+			sub.Replay = append(sub.Replay, ev)
+		}
+	}
+	sub.Resumed = true
+	return sub
+}
+
+// parseID returns the sequence number of an event ID issued by this hub.
+func (h *Hub) parseID(id string) (uint64, bool) {
+	epoch, seq, found := strings.Cut(id, "-")
+	if !found || epoch != h.epoch {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,57 @@
+package live
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEndDropsStream(t *testing.T) {
+	h := NewHub()
+	h.Publish("t1", "hole", 1)
+	sub := h.Subscribe("t1", "")
+	before := sub.LastID
+
+	h.End("t1", "deleted", map[string]string{"id": "t1"})
+	ev, ok := <-sub.Events
+	if !ok || ev.Type != "deleted" {
+		t.Fatalf("got %+v, %v, want the deleted event", ev, ok)
+	}
+	if _, ok := <-sub.Events; ok {
+		t.Error("subscription still open after the stream ended")
+	}
+	if _, ok := h.streams["t1"]; ok {
+		t.Error("stream kept after it ended")
+	}
+	sub.Close()
+
+	// A stream started afterwards doesn't reuse the ended one's IDs, so a
+	// client can't resume across it.
+	h.Publish("t1", "hole", 2)
+	again := h.Subscribe("t1", before)
+	defer again.Close()
+	if again.Resumed {
+		t.Errorf("resumed from %s on a new stream", before)
+	}
+}
+
+func TestIdleStreamsAreDropped(t *testing.T) {
+	h := NewHub()
+	h.Publish("idle", "hole", 1)
+	h.Publish("watched", "hole", 1)
+	sub := h.Subscribe("watched", "")
+	defer sub.Close()
+
+	past := time.Now().Add(-2 * streamIdle)
+	for _, s := range h.streams {
+		s.active = past
+	}
+	h.nextSweep = time.Time{}
+	h.Publish("other", "hole", 1)
+
+	if _, ok := h.streams["idle"]; ok {
+		t.Error("idle stream with no subscribers kept")
+	}
+	if _, ok := h.streams["watched"]; !ok {
+		t.Error("stream with a subscriber dropped")
+	}
+}
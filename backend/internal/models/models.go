@@ -25,11 +25,25 @@ const (
 	ResultTie     MatchResult = "tie"
 )
 
+// AttestationStatus tracks whether a match decided by hole-by-hole scoring has
+// been confirmed by both sides. An empty status means attestation does not
+// apply: the match is undecided, was decided before attestation existed, or its
+// result was set directly by an admin.
+type AttestationStatus string
+
+const (
+	AttestationNone     AttestationStatus = ""
+	AttestationAwaiting AttestationStatus = "awaiting"
+	AttestationDisputed AttestationStatus = "disputed"
+	AttestationAttested AttestationStatus = "attested"
+)
+
 type Player struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	TeamID    string `json:"teamId"`
-	UserEmail string `json:"userEmail,omitempty"`
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	TeamID    string   `json:"teamId"`
+	UserEmail string   `json:"userEmail,omitempty"`
+	Handicap  *float64 `json:"handicap,omitempty"` // negative for a plus handicap
 }
 
 type RegisteredUser struct {
@@ -46,6 +60,7 @@ type LocalUser struct {
 	Confirmed         bool      `json:"confirmed"`
 	Disabled          bool      `json:"disabled,omitempty"`
 	VerificationToken string    `json:"verificationToken,omitempty"`
+	CalendarKey       string    `json:"calendarKey,omitempty"` // signs the calendar feed URL; replaced to revoke it
 	CreatedAt         time.Time `json:"createdAt"`
 }
 
@@ -64,55 +79,107 @@ type Team struct {
 }
 
 type Match struct {
-	ID           string      `json:"id"`
-	RoundNumber  int         `json:"roundNumber"`
-	Team1Players []string    `json:"team1Players"` // player IDs
-	Team2Players []string    `json:"team2Players"` // player IDs
-	Result       MatchResult `json:"result"`
-	Score        string      `json:"score"`        // match play score, e.g. "2 & 1", "1 UP", "A/S"
-	HoleResults  map[string]string `json:"holeResults"` // hole number "1"-"18" -> "team1", "team2", or "halved"
+	ID                string            `json:"id"`
+	RoundNumber       int               `json:"roundNumber"`
+	Team1Players      []string          `json:"team1Players"` // player IDs
+	Team2Players      []string          `json:"team2Players"` // player IDs
+	Result            MatchResult       `json:"result"`
+	Score             string            `json:"score"`       // match play score, e.g. "2 & 1", "1 UP", "A/S"
+	HoleResults       map[string]string `json:"holeResults"` // hole number "1"-"18" -> "team1", "team2", or "halved"
+	AttestationStatus AttestationStatus `json:"attestationStatus,omitempty"`
+	Attestations      []Attestation     `json:"attestations,omitempty"`
+	TeeTime           *time.Time        `json:"teeTime,omitempty"`
+	// Version starts at 1 and goes up with every change made to this match on
+	// its own (a hole, result or attestation), so scorers can tell whether the
+	// match moved on since they last saw it without tracking the whole
+	// tournament.
+	Version int64 `json:"version"`
+	// HoleWrites records the last write to each hole of HoleResults, and
+	// ResultWrite the last time the result was set directly or the match
+	// paired, so a result queued offline can be weighed against what changed
+	// since the device last saw the match.
+	HoleWrites  map[string]Write `json:"holeWrites,omitempty"`
+	ResultWrite *Write           `json:"resultWrite,omitempty"`
 }
 
-// UnmarshalJSON handles both the old array format and the new map format for HoleResults.
-func (m *Match) UnmarshalJSON(data []byte) error {
-	// Use an alias to avoid infinite recursion
-	type matchAlias Match
-	var raw struct {
-		matchAlias
-		RawHoleResults json.RawMessage `json:"holeResults"`
+// Write records a change to a match: who made it, when, and the match version
+// it produced.
+type Write struct {
+	Email   string    `json:"email"`
+	Admin   bool      `json:"admin,omitempty"`
+	At      time.Time `json:"at"`
+	Version int64     `json:"version"`
+}
+
+// Attestation is one side's confirmation or dispute of a match scorecard.
+type Attestation struct {
+	Side      string    `json:"side"`  // "team1" or "team2"
+	Email     string    `json:"email"` // linked player who attested
+	Confirmed bool      `json:"confirmed"`
+	Comment   string    `json:"comment,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// IsOfficial reports whether the match result counts toward the official
+// scoreboard: it must be decided and not awaiting or disputed attestation.
+func (m *Match) IsOfficial() bool {
+	if m.Result == ResultPending || m.Result == "" {
+		return false
+	}
+	return m.AttestationStatus == AttestationNone || m.AttestationStatus == AttestationAttested
+}
+
+// SetScoredResult records a result derived from hole-by-hole scoring. A match
+// that becomes decided waits for attestation from both sides, and any change to
+// an already decided result discards earlier attestations.
+func (m *Match) SetScoredResult(result MatchResult, score string) {
+	changed := result != m.Result || score != m.Score
+	m.Result = result
+	m.Score = score
+
+	if result == ResultPending {
+		m.AttestationStatus = AttestationNone
+		m.Attestations = nil
+		return
 	}
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return err
+	if changed || m.AttestationStatus == AttestationNone {
+		m.AttestationStatus = AttestationAwaiting
+		m.Attestations = nil
 	}
-	*m = Match(raw.matchAlias)
-	m.HoleResults = make(map[string]string)
+}
 
-	if len(raw.RawHoleResults) == 0 || string(raw.RawHoleResults) == "null" {
-		return nil
+// Attest records a side's confirmation or dispute of the scorecard, replacing
+// any earlier attestation from the same side. The match becomes attested once
+// both sides have confirmed; a dispute from either side marks it disputed.
+func (m *Match) Attest(a Attestation) error {
+	if a.Side != "team1" && a.Side != "team2" {
+		return fmt.Errorf("invalid side: %s", a.Side)
+	}
+	if m.AttestationStatus == AttestationNone {
+		return fmt.Errorf("match %s is not awaiting attestation", m.ID)
 	}
 
-	// Try map format first (new format: {"1": "team1", "2": "halved"})
-	var mapFormat map[string]string
-	if err := json.Unmarshal(raw.RawHoleResults, &mapFormat); err == nil {
-		for k, v := range mapFormat {
-			if v != "" {
-				m.HoleResults[k] = v
-			}
+	kept := make([]Attestation, 0, len(m.Attestations)+1)
+	for _, existing := range m.Attestations {
+		if existing.Side != a.Side {
+			kept = append(kept, existing)
 		}
-		return nil
 	}
+	m.Attestations = append(kept, a)
 
-	// Fall back to array format (old format: ["halved", "team1", "", ...])
-	var arrFormat []string
-	if err := json.Unmarshal(raw.RawHoleResults, &arrFormat); err == nil {
-		for i, v := range arrFormat {
-			if v != "" {
-				m.HoleResults[strconv.Itoa(i+1)] = v
-			}
+	confirmed := 0
+	for _, existing := range m.Attestations {
+		if !existing.Confirmed {
+			m.AttestationStatus = AttestationDisputed
+			return nil
 		}
-		return nil
+		confirmed++
+	}
+	if confirmed == 2 {
+		m.AttestationStatus = AttestationAttested
+	} else {
+		m.AttestationStatus = AttestationAwaiting
 	}
-
 	return nil
 }
 
@@ -134,26 +201,136 @@ func (r *Round) HoleCount() int {
 }
 
 type Tournament struct {
-	ID               string    `json:"id"`
-	Name             string    `json:"name"`
-	Teams            [2]Team   `json:"teams"`
-	Rounds           []Round   `json:"rounds"`
-	HeaderColor      string    `json:"headerColor,omitempty"`
-	BgColor          string    `json:"bgColor,omitempty"`
-	Locked           bool            `json:"locked,omitempty"`
-	CombineRounds23  bool            `json:"combineRounds23,omitempty"`
-	Rankings         []PlayerRanking `json:"rankings,omitempty"`
-	RankingsLocked   bool            `json:"rankingsLocked,omitempty"`
-	CreatedAt        time.Time `json:"createdAt"`
-	UpdatedAt        time.Time `json:"updatedAt"`
+	ID              string          `json:"id"`
+	Name            string          `json:"name"`
+	Teams           [2]Team         `json:"teams"`
+	Rounds          []Round         `json:"rounds"`
+	HeaderColor     string          `json:"headerColor,omitempty"`
+	BgColor         string          `json:"bgColor,omitempty"`
+	Locked          bool            `json:"locked,omitempty"`
+	CombineRounds23 bool            `json:"combineRounds23,omitempty"`
+	Rankings        []PlayerRanking `json:"rankings,omitempty"`
+	RankingsLocked  bool            `json:"rankingsLocked,omitempty"`
+	CreatedAt       time.Time       `json:"createdAt"`
+	UpdatedAt       time.Time       `json:"updatedAt"`
+	// Version increases with every stored change. Writes of the whole document
+	// must be made against the current version.
+	Version int64 `json:"version"`
+}
+
+// AuditAction identifies the kind of mutation an AuditEntry records.
+type AuditAction string
+
+const (
+	AuditHoleResult     AuditAction = "hole_result"
+	AuditMatchResult    AuditAction = "match_result"
+	AuditPairings       AuditAction = "pairings"
+	AuditTournamentLock AuditAction = "tournament_lock"
+	AuditRoundLock      AuditAction = "round_lock"
+	AuditRankingsLock   AuditAction = "rankings_lock"
+	AuditTournamentEdit AuditAction = "tournament_edit" // name, colors, combined rounds and team rosters
+	AuditRoundSettings  AuditAction = "round_settings"  // round name, hole count and points
+	AuditPlayerLink     AuditAction = "player_link"
+	AuditTeeTime        AuditAction = "tee_time"
+	AuditAttestation    AuditAction = "attestation" // a player confirming or disputing a scorecard
+	AuditRanking        AuditAction = "ranking"     // a player's ranking of their teammates
+	AuditImport         AuditAction = "import"      // the tournament created from a bundle
+)
+
+// AuditEntry is one record in a tournament's append-only change log. Before and
+// After hold the JSON-encoded value of whatever the action touched, e.g. a single
+// hole result, a round's pairings or a player's linked email.
+type AuditEntry struct {
+	ID           string          `json:"id"`
+	TournamentID string          `json:"tournamentId"`
+	Action       AuditAction     `json:"action"`
+	RoundNumber  int             `json:"roundNumber,omitempty"`
+	MatchID      string          `json:"matchId,omitempty"`
+	Hole         int             `json:"hole,omitempty"`
+	PlayerID     string          `json:"playerId,omitempty"`
+	UserEmail    string          `json:"userEmail"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	RevertOf     string          `json:"revertOf,omitempty"` // ID of the entry this one reverted
+	Version      int64           `json:"version,omitempty"`  // match version a change to one match produced, if known
+	CreatedAt    time.Time       `json:"createdAt"`
 }
 
+// WebhookEvent names an event that can be delivered to a webhook.
+type WebhookEvent string
+
+const (
+	WebhookMatchCompleted WebhookEvent = "match.completed"
+	WebhookRoundLocked    WebhookEvent = "round.locked"
+	WebhookCupClinched    WebhookEvent = "cup.clinched"
+	WebhookUserRegistered WebhookEvent = "user.registered"
+)
+
+// Webhook is a URL registered by an admin to receive a tournament's events.
+// Each request body is signed with Secret. An empty Events list subscribes to
+// every event.
+type Webhook struct {
+	ID           string         `json:"id"`
+	TournamentID string         `json:"tournamentId"`
+	URL          string         `json:"url"`
+	Secret       string         `json:"secret,omitempty"`
+	Events       []WebhookEvent `json:"events"`
+	CreatedAt    time.Time      `json:"createdAt"`
+}
+
+// Wants reports whether the webhook subscribes to event.
+func (w *Webhook) Wants(event WebhookEvent) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery records one event sent to a webhook and the outcome of the
+// latest attempt.
+type WebhookDelivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhookId"`
+	TournamentID  string          `json:"tournamentId"`
+	Event         WebhookEvent    `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Attempts      int             `json:"attempts"`
+	StatusCode    int             `json:"statusCode,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	Delivered     bool            `json:"delivered"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	LastAttemptAt time.Time       `json:"lastAttemptAt"`
+	// NextAttemptAt is when the delivery is due to be retried; nil once it has
+	// been delivered or has run out of retries.
+	NextAttemptAt *time.Time `json:"nextAttemptAt,omitempty"`
+}
+
+// ShareToken grants read-only access to one tournament without logging in,
+// for spectators following along from home. Deleting the token revokes it.
+type ShareToken struct {
+	Token        string    `json:"token"`
+	TournamentID string    `json:"tournamentId"`
+	Label        string    `json:"label,omitempty"`
+	CreatedBy    string    `json:"createdBy"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Scoreboard totals only count official results. Matches decided on the course
+// but still awaiting attestation (or disputed) are tallied separately as
+// provisional points.
 type Scoreboard struct {
-	Team1Name   string       `json:"team1Name"`
-	Team2Name   string       `json:"team2Name"`
-	Team1Total  float64      `json:"team1Total"`
-	Team2Total  float64      `json:"team2Total"`
-	RoundScores []RoundScore `json:"roundScores"`
+	Team1Name        string       `json:"team1Name"`
+	Team2Name        string       `json:"team2Name"`
+	Team1Total       float64      `json:"team1Total"`
+	Team2Total       float64      `json:"team2Total"`
+	Team1Provisional float64      `json:"team1Provisional"`
+	Team2Provisional float64      `json:"team2Provisional"`
+	RoundScores      []RoundScore `json:"roundScores"`
 }
 
 type RoundScore struct {
@@ -164,6 +341,10 @@ type RoundScore struct {
 	PointsPerMatch float64 `json:"pointsPerMatch"`
 	MatchesPlayed  int     `json:"matchesPlayed"`
 	TotalMatches   int     `json:"totalMatches"`
+
+	Team1Provisional float64 `json:"team1Provisional"`
+	Team2Provisional float64 `json:"team2Provisional"`
+	MatchesAwaiting  int     `json:"matchesAwaiting"`
 }
 
 func DefaultRounds() []Round {
@@ -269,6 +450,31 @@ func CalculateMatchPlayResult(holeResults map[string]string, team1Name, team2Nam
 	return ResultPending, fmt.Sprintf("A/S thru %d", played)
 }
 
+// ClinchedBy returns the team (1 or 2) whose official points are more than half
+// of all the points on offer, or 0 if neither team has clinched. The total on
+// offer isn't known until every round has its pairings, so until then nothing
+// counts as clinched. Round 3 is ignored when it is combined with round 2.
+func (t *Tournament) ClinchedBy() int {
+	sb := t.CalculateScoreboard()
+	var available float64
+	for _, rs := range sb.RoundScores {
+		if t.CombineRounds23 && rs.RoundNumber == 3 && rs.TotalMatches == 0 {
+			continue
+		}
+		if rs.TotalMatches == 0 {
+			return 0
+		}
+		available += rs.PointsPerMatch * float64(rs.TotalMatches)
+	}
+	switch {
+	case sb.Team1Total > available/2:
+		return 1
+	case sb.Team2Total > available/2:
+		return 2
+	}
+	return 0
+}
+
 func (t *Tournament) CalculateScoreboard() Scoreboard {
 	sb := Scoreboard{
 		Team1Name: t.Teams[0].Name,
@@ -284,22 +490,34 @@ func (t *Tournament) CalculateScoreboard() Scoreboard {
 		}
 
 		for _, match := range round.Matches {
+			var t1, t2 float64
 			switch match.Result {
 			case ResultTeam1:
-				rs.Team1Points += round.PointsPerMatch
-				rs.MatchesPlayed++
+				t1 = round.PointsPerMatch
 			case ResultTeam2:
-				rs.Team2Points += round.PointsPerMatch
-				rs.MatchesPlayed++
+				t2 = round.PointsPerMatch
 			case ResultTie:
-				rs.Team1Points += round.PointsPerMatch / 2
-				rs.Team2Points += round.PointsPerMatch / 2
+				t1 = round.PointsPerMatch / 2
+				t2 = round.PointsPerMatch / 2
+			default:
+				continue
+			}
+
+			if match.IsOfficial() {
+				rs.Team1Points += t1
+				rs.Team2Points += t2
 				rs.MatchesPlayed++
+			} else {
+				rs.Team1Provisional += t1
+				rs.Team2Provisional += t2
+				rs.MatchesAwaiting++
 			}
 		}
 
 		sb.Team1Total += rs.Team1Points
 		sb.Team2Total += rs.Team2Points
+		sb.Team1Provisional += rs.Team1Provisional
+		sb.Team2Provisional += rs.Team2Provisional
 		sb.RoundScores = append(sb.RoundScores, rs)
 	}
 
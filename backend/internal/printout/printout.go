@@ -0,0 +1,266 @@
+// Package printout renders PDFs for printing at the course: a scorecard for
+// each match and a draw sheet for each round.
+package printout
+
+import (
+	"fmt"
+	"io"
+	"scoring-backend/internal/models"
+	"strconv"
+	"strings"
+
+	"github.com/go-pdf/fpdf"
+)
+
+const margin = 10.0
+
+// document wraps fpdf with the translator that maps UTF-8 text onto the
+// built-in fonts' code page, so accented player names print correctly.
+type document struct {
+	*fpdf.Fpdf
+	tr func(string) string
+}
+
+func newDocument(orientation string) *document {
+	pdf := fpdf.New(orientation, "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(false, margin)
+	return &document{Fpdf: pdf, tr: pdf.UnicodeTranslatorFromDescriptor("")}
+}
+
+func (d *document) cell(w, h float64, text, border string, ln int, align string, fill bool) {
+	d.CellFormat(w, h, d.tr(text), border, ln, align, fill, 0, "")
+}
+
+func (d *document) setFill(hex string) {
+	r, g, b := parseColor(hex)
+	d.SetFillColor(r, g, b)
+}
+
+// Scorecards writes one landscape page per match in the round.
+func Scorecards(w io.Writer, t *models.Tournament, roundNumber int) error {
+	round, err := findRound(t, roundNumber)
+	if err != nil {
+		return err
+	}
+	if len(round.Matches) == 0 {
+		return fmt.Errorf("round %d has no pairings", roundNumber)
+	}
+
+	d := newDocument("L")
+	names := playerNames(t)
+	for i := range round.Matches {
+		d.AddPage()
+		scorecard(d, t, round, i, names)
+	}
+	return d.Output(w)
+}
+
+func scorecard(d *document, t *models.Tournament, round *models.Round, index int, names map[string]string) {
+	m := &round.Matches[index]
+	pageW, _ := d.GetPageSize()
+	width := pageW - 2*margin
+
+	d.SetFont("Helvetica", "B", 18)
+	d.cell(width, 9, t.Name, "", 1, "L", false)
+	d.SetFont("Helvetica", "", 12)
+	d.cell(width, 7, fmt.Sprintf("%s - Match %d", round.Name, index+1), "", 1, "L", false)
+	d.Ln(4)
+
+	holes := round.HoleCount()
+	labelW := 55.0
+	resultW := 30.0
+	holeW := (width - labelW - resultW) / float64(holes)
+	rowH := 9.0
+
+	// Header and course rows. Par and stroke index aren't stored, so they are
+	// left for the players to fill in from the course card.
+	d.SetFont("Helvetica", "B", 10)
+	d.setFill("#e8e8e8")
+	d.cell(labelW, rowH, "Hole", "1", 0, "L", true)
+	for h := 1; h <= holes; h++ {
+		d.cell(holeW, rowH, strconv.Itoa(h), "1", 0, "C", true)
+	}
+	d.cell(resultW, rowH, "Result", "1", 1, "C", true)
+
+	d.SetFont("Helvetica", "", 10)
+	for _, label := range []string{"Par", "Stroke index"} {
+		d.cell(labelW, rowH, label, "1", 0, "L", false)
+		for h := 1; h <= holes; h++ {
+			d.cell(holeW, rowH, "", "1", 0, "C", false)
+		}
+		d.cell(resultW, rowH, "", "1", 1, "C", false)
+	}
+	d.Ln(2)
+
+	sides := []struct {
+		team    models.Team
+		players []string
+		color   string
+	}{
+		{t.Teams[0], m.Team1Players, teamColor(t, 0)},
+		{t.Teams[1], m.Team2Players, teamColor(t, 1)},
+	}
+	for _, side := range sides {
+		d.SetFont("Helvetica", "B", 10)
+		d.setFill(side.color)
+		d.SetTextColor(255, 255, 255)
+		d.cell(width, rowH, side.team.Name, "1", 1, "L", true)
+		d.SetTextColor(0, 0, 0)
+		d.SetFont("Helvetica", "", 10)
+		for _, pid := range side.players {
+			d.cell(labelW, rowH, names[pid], "1", 0, "L", false)
+			for h := 1; h <= holes; h++ {
+				d.cell(holeW, rowH, "", "1", 0, "C", false)
+			}
+			d.cell(resultW, rowH, "", "1", 1, "C", false)
+		}
+	}
+
+	d.Ln(2)
+	d.SetFont("Helvetica", "B", 10)
+	d.cell(labelW, rowH, "Match status", "1", 0, "L", false)
+	for h := 1; h <= holes; h++ {
+		d.cell(holeW, rowH, "", "1", 0, "C", false)
+	}
+	d.cell(resultW, rowH, "", "1", 1, "C", false)
+
+	// Result and signature boxes, one per side.
+	d.Ln(8)
+	boxW := (width - 10) / 2
+	y := d.GetY()
+	for i, side := range sides {
+		x := margin + float64(i)*(boxW+10)
+		d.SetXY(x, y)
+		d.SetFont("Helvetica", "B", 11)
+		d.cell(boxW, 8, side.team.Name+": "+joinNames(names, side.players), "LTR", 2, "L", false)
+		d.SetFont("Helvetica", "", 10)
+		d.cell(boxW, 10, "Result:   [ ] Won   [ ] Lost   [ ] Halved      Score: ____________", "LR", 2, "L", false)
+		d.cell(boxW, 14, "Signed: ______________________________", "LBR", 2, "L", false)
+	}
+}
+
+// DrawSheet writes a one-page list of the round's pairings.
+func DrawSheet(w io.Writer, t *models.Tournament, roundNumber int) error {
+	round, err := findRound(t, roundNumber)
+	if err != nil {
+		return err
+	}
+
+	d := newDocument("P")
+	d.AddPage()
+	pageW, pageH := d.GetPageSize()
+	width := pageW - 2*margin
+	names := playerNames(t)
+
+	d.SetFont("Helvetica", "B", 18)
+	d.cell(width, 9, t.Name, "", 1, "L", false)
+	d.SetFont("Helvetica", "", 13)
+	d.cell(width, 7, round.Name, "", 1, "L", false)
+	d.SetFont("Helvetica", "", 10)
+	d.cell(width, 6, fmt.Sprintf("%s, %d holes, %s point(s) per match", formatName(round.Type), round.HoleCount(), strconv.FormatFloat(round.PointsPerMatch, 'f', -1, 64)), "", 1, "L", false)
+	d.Ln(4)
+
+	if len(round.Matches) == 0 {
+		d.cell(width, 8, "Pairings haven't been set for this round.", "", 1, "L", false)
+		return d.Output(w)
+	}
+
+	// Shrink rows to keep a full draw on one page.
+	numW, vsW, resultW := 12.0, 10.0, 30.0
+	sideW := (width - numW - vsW - resultW) / 2
+	rowH := min(12.0, (pageH-d.GetY()-margin-10)/float64(len(round.Matches)))
+
+	d.SetFont("Helvetica", "B", 11)
+	d.SetTextColor(255, 255, 255)
+	d.setFill("#555555")
+	d.cell(numW, 10, "#", "1", 0, "C", true)
+	d.setFill(teamColor(t, 0))
+	d.cell(sideW, 10, t.Teams[0].Name, "1", 0, "C", true)
+	d.setFill("#555555")
+	d.cell(vsW, 10, "", "1", 0, "C", true)
+	d.setFill(teamColor(t, 1))
+	d.cell(sideW, 10, t.Teams[1].Name, "1", 0, "C", true)
+	d.setFill("#555555")
+	d.cell(resultW, 10, "Result", "1", 1, "C", true)
+	d.SetTextColor(0, 0, 0)
+
+	d.SetFont("Helvetica", "", 11)
+	for i, m := range round.Matches {
+		d.cell(numW, rowH, strconv.Itoa(i+1), "1", 0, "C", false)
+		d.cell(sideW, rowH, joinNames(names, m.Team1Players), "1", 0, "C", false)
+		d.cell(vsW, rowH, "vs", "1", 0, "C", false)
+		d.cell(sideW, rowH, joinNames(names, m.Team2Players), "1", 0, "C", false)
+		d.cell(resultW, rowH, "", "1", 1, "C", false)
+	}
+	return d.Output(w)
+}
+
+func findRound(t *models.Tournament, roundNumber int) (*models.Round, error) {
+	for i := range t.Rounds {
+		if t.Rounds[i].Number == roundNumber {
+			return &t.Rounds[i], nil
+		}
+	}
+	return nil, fmt.Errorf("round %d not found", roundNumber)
+}
+
+func playerNames(t *models.Tournament) map[string]string {
+	names := make(map[string]string)
+	for _, team := range t.Teams {
+		for _, p := range team.Players {
+			names[p.ID] = p.Name
+		}
+	}
+	return names
+}
+
+func joinNames(names map[string]string, ids []string) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, names[id])
+	}
+	return strings.Join(parts, " & ")
+}
+
+func formatName(rt models.RoundType) string {
+	switch rt {
+	case models.RoundFourBall:
+		return "Four-ball"
+	case models.RoundFoursome:
+		return "Foursome"
+	case models.RoundLauderdale:
+		return "Lauderdale"
+	case models.RoundSingles:
+		return "Singles"
+	default:
+		return string(rt)
+	}
+}
+
+// teamColor returns the team's color, or the app's default for that side.
+func teamColor(t *models.Tournament, team int) string {
+	if c := t.Teams[team].Color; c != "" {
+		return c
+	}
+	if team == 0 {
+		return "#1a3a6b"
+	}
+	return "#8b1a1a"
+}
+
+// parseColor reads "#rgb" or "#rrggbb", falling back to mid grey.
+func parseColor(hex string) (int, int, int) {
+	hex = strings.TrimPrefix(hex, "#")
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return 128, 128, 128
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 128, 128, 128
+	}
+	return int(v >> 16 & 0xff), int(v >> 8 & 0xff), int(v & 0xff)
+}
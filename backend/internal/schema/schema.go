@@ -0,0 +1,209 @@
+// Package schema versions the stored form of tournaments and matches.
+//
+// Every tournament header and match record is written with the version
+// below. Stores pass anything older through Upgrade before using it, one
+// step at a time, so a change to the models only needs a new step here
+// instead of fallbacks scattered through decoding code. Steps work on the
+// JSON form of a document (the models' json field names), which every store
+// can produce.
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"scoring-backend/internal/models"
+	"strconv"
+)
+
+// Current is the schema version of documents written by this build.
+//
+//	0  unversioned; hole results may be a list indexed from hole 1
+//	1  hole results are a map from hole number, without empty entries
+const Current = 1
+
+// Doc is a document decoded from JSON.
+type Doc = map[string]any
+
+// step upgrades a document from one version to the next. Tournament is
+// applied to the tournament itself and Match to each match, whether stored
+// on its own or embedded in an old single-document tournament. Either may
+// be nil.
+type step struct {
+	Tournament func(Doc) error
+	Match      func(Doc) error
+}
+
+// upgrades maps a schema version to the step that brings a document of that
+// version to the next. Released steps are never changed, only added.
+var upgrades = map[int]step{
+	0: {Match: holeResultsToMap},
+}
+
+func init() {
+	for v := 0; v < Current; v++ {
+		if _, ok := upgrades[v]; !ok {
+			panic(fmt.Sprintf("schema: no upgrade from version %d", v))
+		}
+	}
+}
+
+// check rejects versions this build can't read.
+func check(from int) error {
+	if from < 0 {
+		return fmt.Errorf("invalid schema version %d", from)
+	}
+	if from > Current {
+		return fmt.Errorf("schema version %d is newer than this build supports (%d)", from, Current)
+	}
+	return nil
+}
+
+// UpgradeTournament upgrades a tournament document, and any matches embedded
+// in its rounds, from version from to Current in place.
+func UpgradeTournament(doc Doc, from int) error {
+	if err := check(from); err != nil {
+		return err
+	}
+	for v := from; v < Current; v++ {
+		s := upgrades[v]
+		if s.Tournament != nil {
+			if err := s.Tournament(doc); err != nil {
+				return fmt.Errorf("upgrading tournament from schema version %d: %w", v, err)
+			}
+		}
+		if s.Match == nil {
+			continue
+		}
+		for _, m := range embeddedMatches(doc) {
+			if err := s.Match(m); err != nil {
+				return fmt.Errorf("upgrading match from schema version %d: %w", v, err)
+			}
+		}
+	}
+	return nil
+}
+
+// UpgradeMatch upgrades a match document from version from to Current in
+// place.
+func UpgradeMatch(doc Doc, from int) error {
+	if err := check(from); err != nil {
+		return err
+	}
+	for v := from; v < Current; v++ {
+		if s := upgrades[v]; s.Match != nil {
+			if err := s.Match(doc); err != nil {
+				return fmt.Errorf("upgrading match from schema version %d: %w", v, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Upgrade brings a decoded *models.Tournament or *models.Match from version
+// from to Current, for stores that decode straight into the models. It does
+// nothing for current documents.
+func Upgrade(v any, from int) error {
+	if err := check(from); err != nil || from == Current {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var doc Doc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case *models.Tournament:
+		if err := UpgradeTournament(doc, from); err != nil {
+			return err
+		}
+		var upgraded models.Tournament
+		if err := remarshal(doc, &upgraded); err != nil {
+			return err
+		}
+		*v = upgraded
+	case *models.Match:
+		if err := UpgradeMatch(doc, from); err != nil {
+			return err
+		}
+		var upgraded models.Match
+		if err := remarshal(doc, &upgraded); err != nil {
+			return err
+		}
+		*v = upgraded
+	default:
+		return fmt.Errorf("schema: cannot upgrade %T", v)
+	}
+	return nil
+}
+
+// UpgradeJSON upgrades an encoded document with UpgradeTournament or
+// UpgradeMatch and returns it re-encoded.
+func UpgradeJSON(data []byte, from int, upgrade func(Doc, int) error) ([]byte, error) {
+	if err := check(from); err != nil || from == Current {
+		return data, err
+	}
+	var doc Doc
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if err := upgrade(doc, from); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+func remarshal(doc Doc, v any) error {
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// embeddedMatches returns the matches inside a tournament document's rounds.
+func embeddedMatches(doc Doc) []Doc {
+	var out []Doc
+	rounds, _ := doc["rounds"].([]any)
+	for _, r := range rounds {
+		round, _ := r.(Doc)
+		matches, _ := round["matches"].([]any)
+		for _, m := range matches {
+			if match, ok := m.(Doc); ok {
+				out = append(out, match)
+			}
+		}
+	}
+	return out
+}
+
+// --- Steps ---
+
+// holeResultsToMap converts hole results stored as a list, where index 0 is
+// hole 1, into a map from hole number, and drops empty entries from either
+// form.
+func holeResultsToMap(m Doc) error {
+	holes := Doc{}
+	switch raw := m["holeResults"].(type) {
+	case nil:
+	case []any:
+		for i, r := range raw {
+			if s, _ := r.(string); s != "" {
+				holes[strconv.Itoa(i+1)] = s
+			}
+		}
+	case Doc:
+		for k, r := range raw {
+			if s, _ := r.(string); s != "" {
+				holes[k] = s
+			}
+		}
+	default:
+		return fmt.Errorf("unexpected hole results %T", raw)
+	}
+	m["holeResults"] = holes
+	return nil
+}
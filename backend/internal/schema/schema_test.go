@@ -0,0 +1,35 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHoleResultsToMap(t *testing.T) {
+	tests := []struct {
+		name string
+		in   any
+		want Doc
+	}{
+		{"nil", nil, Doc{}},
+		{"list", []any{"team1", "", "halved", nil}, Doc{"1": "team1", "3": "halved"}},
+		{"empty list", []any{}, Doc{}},
+		{"map", Doc{"1": "team2", "2": "", "18": "halved"}, Doc{"1": "team2", "18": "halved"}},
+		{"empty map", Doc{}, Doc{}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m := Doc{"holeResults": tc.in}
+			if err := holeResultsToMap(m); err != nil {
+				t.Fatalf("holeResultsToMap: %v", err)
+			}
+			if got := m["holeResults"]; !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("holeResults = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	if err := holeResultsToMap(Doc{"holeResults": "team1"}); err == nil {
+		t.Error("holeResultsToMap accepted a string")
+	}
+}
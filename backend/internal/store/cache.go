@@ -0,0 +1,386 @@
+package store
+
+import (
+	"context"
+	"scoring-backend/internal/models"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CachingStore wraps another Store and keeps tournaments and user lookups in
+// memory, so repeated page views don't each read the backend. Every write
+// through it invalidates what it may have changed, so this process never
+// reads its own writes stale; writes made by other processes (replicas, the
+// migrate tool) show up once the entries they affect expire after the TTL.
+//
+// Methods that are neither cached nor invalidate anything are passed through
+// by embedding. A new Store method that changes tournaments or users must be
+// overridden here to invalidate.
+type CachingStore struct {
+	Store
+	ttl time.Duration
+
+	mu          sync.Mutex
+	tournaments map[string]cached[*models.Tournament]
+	list        *cached[[]*models.Tournament]
+	localUsers  map[string]cached[*models.LocalUser]
+	localList   *cached[[]*models.LocalUser]
+	registered  *cached[[]*models.RegisteredUser]
+	// registrations holds the last successful RegisterUser of each email.
+	registrations map[string]cached[models.RegisteredUser]
+	// A read that misses records the generation before asking the backend
+	// and only fills the cache if no write invalidated it in the meantime,
+	// so a slow read can't put back what a write just replaced.
+	tournamentGen uint64
+	userGen       uint64
+
+	hits, misses, invalidations atomic.Uint64
+}
+
+type cached[T any] struct {
+	value   T
+	expires time.Time
+}
+
+func (c *CachingStore) fresh(expires time.Time) bool {
+	return c.ttl <= 0 || time.Now().Before(expires)
+}
+
+// CacheStats reports how well a CachingStore is doing.
+type CacheStats struct {
+	Hits          uint64  `json:"hits"`
+	Misses        uint64  `json:"misses"`
+	HitRate       float64 `json:"hitRate"`
+	Invalidations uint64  `json:"invalidations"`
+	Tournaments   int     `json:"tournaments"`
+	LocalUsers    int     `json:"localUsers"`
+	TTLSeconds    float64 `json:"ttlSeconds"`
+}
+
+// NewCachingStore wraps s. Entries expire ttl after they were read; a ttl of
+// zero or less keeps them until a write invalidates them, which is only
+// correct if no other process writes to the same backend.
+func NewCachingStore(s Store, ttl time.Duration) *CachingStore {
+	return &CachingStore{
+		Store:         s,
+		ttl:           ttl,
+		tournaments:   make(map[string]cached[*models.Tournament]),
+		localUsers:    make(map[string]cached[*models.LocalUser]),
+		registrations: make(map[string]cached[models.RegisteredUser]),
+	}
+}
+
+// Stats returns the cache's counters since it was created.
+func (c *CachingStore) Stats() CacheStats {
+	c.mu.Lock()
+	tournaments, localUsers := len(c.tournaments), len(c.localUsers)
+	c.mu.Unlock()
+
+	s := CacheStats{
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Invalidations: c.invalidations.Load(),
+		Tournaments:   tournaments,
+		LocalUsers:    localUsers,
+		TTLSeconds:    c.ttl.Seconds(),
+	}
+	if total := s.Hits + s.Misses; total > 0 {
+		s.HitRate = float64(s.Hits) / float64(total)
+	}
+	return s
+}
+
+// Flush drops every cached entry.
+func (c *CachingStore) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tournaments = make(map[string]cached[*models.Tournament])
+	c.list = nil
+	c.localUsers = make(map[string]cached[*models.LocalUser])
+	c.localList = nil
+	c.registered = nil
+	c.registrations = make(map[string]cached[models.RegisteredUser])
+	c.tournamentGen++
+	c.userGen++
+	c.invalidations.Add(1)
+}
+
+// invalidateTournament drops one tournament and the tournament list.
+func (c *CachingStore) invalidateTournament(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.tournaments, id)
+	c.list = nil
+	c.tournamentGen++
+	c.invalidations.Add(1)
+}
+
+// invalidateLocalUsers drops every local user. Local user writes are rare,
+// and VerifyLocalUser doesn't say which user it changed.
+func (c *CachingStore) invalidateLocalUsers() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.localUsers = make(map[string]cached[*models.LocalUser])
+	c.localList = nil
+	c.userGen++
+	c.invalidations.Add(1)
+}
+
+// --- Tournaments ---
+
+func (c *CachingStore) GetTournament(ctx context.Context, id string) (*models.Tournament, error) {
+	c.mu.Lock()
+	if e, ok := c.tournaments[id]; ok && c.fresh(e.expires) {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return cloneTournament(e.value), nil
+	}
+	gen := c.tournamentGen
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	t, err := c.Store.GetTournament(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if gen == c.tournamentGen {
+		c.tournaments[id] = cached[*models.Tournament]{cloneTournament(t), time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return t, nil
+}
+
+func (c *CachingStore) ListTournaments(ctx context.Context) ([]*models.Tournament, error) {
+	c.mu.Lock()
+	if c.list != nil && c.fresh(c.list.expires) {
+		list := c.list.value
+		c.mu.Unlock()
+		c.hits.Add(1)
+		out := make([]*models.Tournament, len(list))
+		for i, t := range list {
+			out[i] = cloneTournament(t)
+		}
+		return out, nil
+	}
+	gen := c.tournamentGen
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	list, err := c.Store.ListTournaments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if gen == c.tournamentGen {
+		expires := time.Now().Add(c.ttl)
+		kept := make([]*models.Tournament, len(list))
+		for i, t := range list {
+			kept[i] = cloneTournament(t)
+			c.tournaments[t.ID] = cached[*models.Tournament]{kept[i], expires}
+		}
+		c.list = &cached[[]*models.Tournament]{kept, expires}
+	}
+	c.mu.Unlock()
+	return list, nil
+}
+
+func (c *CachingStore) CreateTournament(ctx context.Context, t *models.Tournament) error {
+	defer c.invalidateTournament(t.ID)
+	return c.Store.CreateTournament(ctx, t)
+}
+
+func (c *CachingStore) UpdateTournament(ctx context.Context, t *models.Tournament) error {
+	defer c.invalidateTournament(t.ID)
+	return c.Store.UpdateTournament(ctx, t)
+}
+
+func (c *CachingStore) DeleteTournament(ctx context.Context, id string) error {
+	defer c.invalidateTournament(id)
+	return c.Store.DeleteTournament(ctx, id)
+}
+
+func (c *CachingStore) ImportTournament(ctx context.Context, t *models.Tournament) error {
+	defer c.invalidateTournament(t.ID)
+	return c.Store.ImportTournament(ctx, t)
+}
+
+func (c *CachingStore) UpdateMatchResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, result models.MatchResult, score string, want Precondition) error {
+	defer c.invalidateTournament(tournamentID)
+	return c.Store.UpdateMatchResult(ctx, tournamentID, roundNumber, matchID, result, score, want)
+}
+
+func (c *CachingStore) SetRoundPairings(ctx context.Context, tournamentID string, roundNumber int, matches []models.Match, want Precondition) error {
+	defer c.invalidateTournament(tournamentID)
+	return c.Store.SetRoundPairings(ctx, tournamentID, roundNumber, matches, want)
+}
+
+func (c *CachingStore) UpdateHoleResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, hole int, result string, want Precondition) error {
+	defer c.invalidateTournament(tournamentID)
+	return c.Store.UpdateHoleResult(ctx, tournamentID, roundNumber, matchID, hole, result, want)
+}
+
+func (c *CachingStore) AttestMatch(ctx context.Context, tournamentID string, roundNumber int, matchID string, attestation models.Attestation, want Precondition) error {
+	defer c.invalidateTournament(tournamentID)
+	return c.Store.AttestMatch(ctx, tournamentID, roundNumber, matchID, attestation, want)
+}
+
+func (c *CachingStore) LinkPlayer(ctx context.Context, tournamentID string, playerID string, email string, want Precondition) error {
+	defer c.invalidateTournament(tournamentID)
+	return c.Store.LinkPlayer(ctx, tournamentID, playerID, email, want)
+}
+
+func (c *CachingStore) SaveRanking(ctx context.Context, tournamentID string, ranking models.PlayerRanking, want Precondition) error {
+	defer c.invalidateTournament(tournamentID)
+	return c.Store.SaveRanking(ctx, tournamentID, ranking, want)
+}
+
+// --- Users ---
+
+// RegisterUser skips the write when the same user was registered exactly as
+// given within the TTL, since the server re-registers users on every /api/me.
+func (c *CachingStore) RegisterUser(ctx context.Context, user *models.RegisteredUser) error {
+	key := strings.ToLower(user.Email)
+	c.mu.Lock()
+	if e, ok := c.registrations[key]; ok && c.fresh(e.expires) && e.value == *user {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return nil
+	}
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	err := c.Store.RegisterUser(ctx, user)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err == nil {
+		c.registrations[key] = cached[models.RegisteredUser]{*user, time.Now().Add(c.ttl)}
+	} else {
+		delete(c.registrations, key)
+	}
+	c.registered = nil
+	c.userGen++
+	c.invalidations.Add(1)
+	return err
+}
+
+func (c *CachingStore) ListRegisteredUsers(ctx context.Context) ([]*models.RegisteredUser, error) {
+	c.mu.Lock()
+	if c.registered != nil && c.fresh(c.registered.expires) {
+		out := cloneAll(c.registered.value)
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return out, nil
+	}
+	gen := c.userGen
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	users, err := c.Store.ListRegisteredUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if gen == c.userGen {
+		c.registered = &cached[[]*models.RegisteredUser]{cloneAll(users), time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return users, nil
+}
+
+func (c *CachingStore) GetLocalUser(ctx context.Context, email string) (*models.LocalUser, error) {
+	key := strings.ToLower(email)
+	c.mu.Lock()
+	if e, ok := c.localUsers[key]; ok && c.fresh(e.expires) {
+		u := *e.value
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return &u, nil
+	}
+	gen := c.userGen
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	user, err := c.Store.GetLocalUser(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if gen == c.userGen {
+		u := *user
+		c.localUsers[key] = cached[*models.LocalUser]{&u, time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return user, nil
+}
+
+func (c *CachingStore) ListLocalUsers(ctx context.Context) ([]*models.LocalUser, error) {
+	c.mu.Lock()
+	if c.localList != nil && c.fresh(c.localList.expires) {
+		out := cloneAll(c.localList.value)
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return out, nil
+	}
+	gen := c.userGen
+	c.mu.Unlock()
+	c.misses.Add(1)
+
+	users, err := c.Store.ListLocalUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if gen == c.userGen {
+		c.localList = &cached[[]*models.LocalUser]{cloneAll(users), time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+	return users, nil
+}
+
+func (c *CachingStore) CreateLocalUser(ctx context.Context, user *models.LocalUser) error {
+	defer c.invalidateLocalUsers()
+	return c.Store.CreateLocalUser(ctx, user)
+}
+
+func (c *CachingStore) VerifyLocalUser(ctx context.Context, token string) error {
+	defer c.invalidateLocalUsers()
+	return c.Store.VerifyLocalUser(ctx, token)
+}
+
+func (c *CachingStore) ConfirmLocalUser(ctx context.Context, email string) error {
+	defer c.invalidateLocalUsers()
+	return c.Store.ConfirmLocalUser(ctx, email)
+}
+
+func (c *CachingStore) DeleteLocalUser(ctx context.Context, email string) error {
+	defer c.invalidateLocalUsers()
+	return c.Store.DeleteLocalUser(ctx, email)
+}
+
+func (c *CachingStore) EnableLocalUser(ctx context.Context, email string) error {
+	defer c.invalidateLocalUsers()
+	return c.Store.EnableLocalUser(ctx, email)
+}
+
+func (c *CachingStore) SetCalendarKey(ctx context.Context, email, key string) error {
+	defer c.invalidateLocalUsers()
+	return c.Store.SetCalendarKey(ctx, email, key)
+}
+
+func (c *CachingStore) ImportLocalUser(ctx context.Context, user *models.LocalUser) error {
+	defer c.invalidateLocalUsers()
+	return c.Store.ImportLocalUser(ctx, user)
+}
+
+// cloneAll copies a list of flat structs.
+func cloneAll[T any](list []*T) []*T {
+	out := make([]*T, len(list))
+	for i, v := range list {
+		c := *v
+		out[i] = &c
+	}
+	return out
+}
@@ -1,81 +1,360 @@
 package store
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"scoring-backend/internal/models"
-	"strconv"
+	"scoring-backend/internal/schema"
 	"strings"
 	"sync"
 	"time"
 )
 
-// FileStore persists each tournament as a JSON file on disk.
-// Files are stored as {dir}/{tournament-id}.json.
+// FileStore persists each tournament as JSON files on disk. The tournament
+// header is stored as {dir}/{tournament-id}.json and each of its matches as
+// {dir}/{tournament-id}/matches/{match-id}.json (see split.go). Every
+// tournament write is journaled first (see journal.go).
+//
+// Several processes, such as server replicas or the migrate tool, can share a
+// data directory: every operation holds an OS advisory lock on {dir}/.lock,
+// shared for reads and exclusive for writes, for as long as it reads and
+// writes files, so read-modify-write cycles never interleave. A process that
+// dies mid-write leaves a marker behind (see journal.go), and whoever takes
+// the lock next rolls the write back before going on.
 type FileStore struct {
-	mu  sync.RWMutex
-	dir string
+	mu       sync.RWMutex
+	dir      string
+	journals map[string]*journalState
 }
 
 func NewFileStore(dir string) (*FileStore, error) {
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return nil, fmt.Errorf("creating data directory %s: %w", dir, err)
 	}
-	return &FileStore{dir: dir}, nil
+	f := &FileStore{dir: dir, journals: make(map[string]*journalState)}
+	unlock, err := f.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	if err := f.recoverJournals(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// lock takes the in-process write lock and then the data directory's
+// exclusive OS lock, and settles any write another holder didn't finish.
+func (f *FileStore) lock() (unlock func(), err error) {
+	f.mu.Lock()
+	file, err := f.lockDir(true)
+	if err != nil {
+		f.mu.Unlock()
+		return nil, err
+	}
+	unlock = func() {
+		unlockFile(file)
+		file.Close()
+		f.mu.Unlock()
+	}
+	if err := f.recoverUnfinished(); err != nil {
+		unlock()
+		return nil, err
+	}
+	return unlock, nil
+}
+
+// rlock takes the in-process read lock and then the data directory's shared
+// OS lock. If a write was left unfinished it settles it under the exclusive
+// lock first.
+func (f *FileStore) rlock() (unlock func(), err error) {
+	for {
+		f.mu.RLock()
+		file, err := f.lockDir(false)
+		if err != nil {
+			f.mu.RUnlock()
+			return nil, err
+		}
+		unlock = func() {
+			unlockFile(file)
+			file.Close()
+			f.mu.RUnlock()
+		}
+		if _, err := os.Stat(f.unfinishedPath()); os.IsNotExist(err) {
+			return unlock, nil
+		}
+		unlock()
+		wunlock, err := f.lock()
+		if err != nil {
+			return nil, err
+		}
+		wunlock()
+	}
+}
+
+// lockDir locks the data directory's lock file. Each holder opens the file
+// itself, since OS locks belong to an open file and concurrent readers in
+// this process must not release each other's locks.
+func (f *FileStore) lockDir(exclusive bool) (*os.File, error) {
+	file, err := os.OpenFile(filepath.Join(f.dir, ".lock"), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+	if err := lockFile(file, exclusive); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("locking data directory %s: %w", f.dir, err)
+	}
+	return file, nil
 }
 
 func (f *FileStore) path(id string) string {
 	return filepath.Join(f.dir, id+".json")
 }
 
+func (f *FileStore) matchDir(tournamentID string) string {
+	return filepath.Join(f.dir, tournamentID, "matches")
+}
+
+func (f *FileStore) matchPath(tournamentID, matchID string) string {
+	return filepath.Join(f.matchDir(tournamentID), matchID+".json")
+}
+
 func (f *FileStore) readTournament(id string) (*models.Tournament, error) {
+	t, _, err := f.loadTournament(id)
+	return t, err
+}
+
+// loadTournament reads a tournament and its matches. embedded reports whether
+// the tournament is still stored in the old single-file layout.
+func (f *FileStore) loadTournament(id string) (t *models.Tournament, embedded bool, err error) {
 	data, err := os.ReadFile(f.path(id))
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("tournament %s not found", id)
+			return nil, false, fmt.Errorf("tournament %s not found", id)
 		}
-		return nil, fmt.Errorf("reading tournament %s: %w", id, err)
+		return nil, false, fmt.Errorf("reading tournament %s: %w", id, err)
 	}
 
-	var t models.Tournament
-	if err := json.Unmarshal(data, &t); err != nil {
-		return nil, fmt.Errorf("decoding tournament %s: %w", id, err)
+	t = &models.Tournament{}
+	if err := decodeVersioned(data, t, schema.UpgradeTournament); err != nil {
+		return nil, false, fmt.Errorf("decoding tournament %s: %w", id, err)
 	}
-	// Normalize: ensure all matches have HoleResults initialized and migrate old array format
-	for i := range t.Rounds {
-		for j := range t.Rounds[i].Matches {
-			if t.Rounds[i].Matches[j].HoleResults == nil {
-				t.Rounds[i].Matches[j].HoleResults = make(map[string]string)
+
+	embedded = hasEmbeddedMatches(t)
+	if !embedded {
+		records, err := f.readMatchRecords(id)
+		if err != nil {
+			return nil, false, err
+		}
+		joinTournament(t, records)
+	}
+
+	normalizeTournament(t)
+	return t, embedded, nil
+}
+
+func (f *FileStore) readMatchRecords(tournamentID string) ([]matchRecord, error) {
+	entries, err := os.ReadDir(f.matchDir(tournamentID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("listing matches for tournament %s: %w", tournamentID, err)
+	}
+
+	records := make([]matchRecord, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(f.matchDir(tournamentID), entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("reading match %s: %w", entry.Name(), err)
+		}
+		var rec matchRecord
+		if err := decodeVersioned(data, &rec, upgradeMatchRecord); err != nil {
+			return nil, fmt.Errorf("decoding match %s: %w", entry.Name(), err)
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// StoredSchemaVersion returns the oldest schema version among the files of a
+// tournament.
+func (f *FileStore) StoredSchemaVersion(_ context.Context, id string) (int, error) {
+	unlock, err := f.rlock()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	paths := []string{f.path(id)}
+	entries, err := os.ReadDir(f.matchDir(id))
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("listing matches for tournament %s: %w", id, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".json" {
+			paths = append(paths, filepath.Join(f.matchDir(id), entry.Name()))
+		}
+	}
+
+	oldest := schema.Current
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) && path == f.path(id) {
+				return 0, fmt.Errorf("tournament %s not found", id)
 			}
+			return 0, fmt.Errorf("reading %s: %w", path, err)
 		}
+		var stamp struct {
+			SchemaVersion int `json:"schemaVersion"`
+		}
+		if err := json.Unmarshal(data, &stamp); err != nil {
+			return 0, fmt.Errorf("decoding %s: %w", path, err)
+		}
+		oldest = min(oldest, stamp.SchemaVersion)
 	}
-	return &t, nil
+	return oldest, nil
 }
 
-func (f *FileStore) writeTournament(t *models.Tournament) error {
-	data, err := json.MarshalIndent(t, "", "  ")
+// decodeVersioned decodes a stored document into v, first bringing it from
+// the schema version it was written at up to the current one. Old documents
+// are upgraded in their JSON form, since they may no longer decode into the
+// models as they are.
+func decodeVersioned(data []byte, v any, upgrade func(schema.Doc, int) error) error {
+	var stamp struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(data, &stamp); err != nil {
+		return err
+	}
+	data, err := schema.UpgradeJSON(data, stamp.SchemaVersion, upgrade)
 	if err != nil {
-		return fmt.Errorf("encoding tournament %s: %w", t.ID, err)
+		return err
 	}
+	return json.Unmarshal(data, v)
+}
 
-	// Write to temp file then rename for atomic writes
-	tmp := f.path(t.ID) + ".tmp"
+// upgradeMatchRecord upgrades the match inside an encoded matchRecord.
+func upgradeMatchRecord(doc schema.Doc, from int) error {
+	m, ok := doc["match"].(schema.Doc)
+	if !ok {
+		return fmt.Errorf("match record has no match")
+	}
+	return schema.UpgradeMatch(m, from)
+}
+
+// writeFileAtomic writes to a temp file then renames it into place.
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
 	if err := os.WriteFile(tmp, data, 0644); err != nil {
-		return fmt.Errorf("writing tournament %s: %w", t.ID, err)
+		return err
 	}
-	if err := os.Rename(tmp, f.path(t.ID)); err != nil {
+	if err := os.Rename(tmp, path); err != nil {
 		os.Remove(tmp)
-		return fmt.Errorf("renaming tournament file %s: %w", t.ID, err)
+		return err
+	}
+	return nil
+}
+
+// writeTournamentFiles writes the tournament header and every match record,
+// and removes records of matches that no longer exist.
+func (f *FileStore) writeTournamentFiles(t *models.Tournament) error {
+	header, records := splitTournament(t)
+
+	if err := os.MkdirAll(f.matchDir(t.ID), 0755); err != nil {
+		return fmt.Errorf("creating match directory for tournament %s: %w", t.ID, err)
+	}
+	keep := make(map[string]bool, len(records))
+	for _, rec := range records {
+		if err := f.writeMatchRecord(t.ID, rec); err != nil {
+			return err
+		}
+		keep[rec.Match.ID+".json"] = true
+	}
+
+	if err := f.writeHeader(header); err != nil {
+		return err
+	}
+
+	entries, err := os.ReadDir(f.matchDir(t.ID))
+	if err != nil {
+		return fmt.Errorf("listing matches for tournament %s: %w", t.ID, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && !keep[entry.Name()] {
+			os.Remove(filepath.Join(f.matchDir(t.ID), entry.Name()))
+		}
+	}
+	return nil
+}
+
+// writeMatchFiles persists one match of t along with the tournament header,
+// which carries the version and timestamps. The tournament must already be
+// in the split layout.
+func (f *FileStore) writeMatchFiles(t *models.Tournament, roundIdx, matchIdx int) error {
+	rec := matchRecord{
+		Round:         t.Rounds[roundIdx].Number,
+		Position:      matchIdx,
+		Match:         t.Rounds[roundIdx].Matches[matchIdx],
+		SchemaVersion: schema.Current,
+	}
+	if err := os.MkdirAll(f.matchDir(t.ID), 0755); err != nil {
+		return fmt.Errorf("creating match directory for tournament %s: %w", t.ID, err)
+	}
+	if err := f.writeMatchRecord(t.ID, rec); err != nil {
+		return err
+	}
+
+	header, _ := splitTournament(t)
+	return f.writeHeader(header)
+}
+
+// isSafeFileID reports whether id can be used as a file name without
+// escaping its directory.
+func isSafeFileID(id string) bool {
+	return id != "" && id == filepath.Base(id) && id[0] != '.'
+}
+
+func (f *FileStore) writeMatchRecord(tournamentID string, rec matchRecord) error {
+	if !isSafeFileID(rec.Match.ID) {
+		return fmt.Errorf("invalid match id %q", rec.Match.ID)
+	}
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding match %s: %w", rec.Match.ID, err)
+	}
+	if err := writeFileAtomic(f.matchPath(tournamentID, rec.Match.ID), data); err != nil {
+		return fmt.Errorf("writing match %s: %w", rec.Match.ID, err)
+	}
+	return nil
+}
+
+func (f *FileStore) writeHeader(header *tournamentRecord) error {
+	data, err := json.MarshalIndent(header, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding tournament %s: %w", header.ID, err)
+	}
+	if err := writeFileAtomic(f.path(header.ID), data); err != nil {
+		return fmt.Errorf("writing tournament %s: %w", header.ID, err)
 	}
 	return nil
 }
 
 func (f *FileStore) CreateTournament(_ context.Context, t *models.Tournament) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	if _, err := os.Stat(f.path(t.ID)); err == nil {
 		return fmt.Errorf("tournament %s already exists", t.ID)
@@ -84,32 +363,62 @@ func (f *FileStore) CreateTournament(_ context.Context, t *models.Tournament) er
 	now := time.Now()
 	t.CreatedAt = now
 	t.UpdatedAt = now
+	t.Version = 1
 
 	return f.writeTournament(t)
 }
 
+// ImportTournament writes a tournament as-is, keeping its timestamps and
+// version, and replaces any existing tournament with the same ID.
+func (f *FileStore) ImportTournament(_ context.Context, t *models.Tournament) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if !isSafeFileID(t.ID) {
+		return fmt.Errorf("invalid tournament id %q", t.ID)
+	}
+	return f.writeTournament(t)
+}
+
 func (f *FileStore) GetTournament(_ context.Context, id string) (*models.Tournament, error) {
-	f.mu.RLock()
-	defer f.mu.RUnlock()
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	return f.readTournament(id)
 }
 
 func (f *FileStore) UpdateTournament(_ context.Context, t *models.Tournament) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	if _, err := os.Stat(f.path(t.ID)); os.IsNotExist(err) {
-		return fmt.Errorf("tournament %s not found", t.ID)
+	existing, err := f.readTournament(t.ID)
+	if err != nil {
+		return err
+	}
+	if existing.Version != t.Version {
+		return &VersionConflictError{TournamentID: t.ID, Current: existing.Version}
 	}
 
 	t.UpdatedAt = time.Now()
+	t.Version++
 	return f.writeTournament(t)
 }
 
 func (f *FileStore) ListTournaments(_ context.Context) ([]*models.Tournament, error) {
-	f.mu.RLock()
-	defer f.mu.RUnlock()
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	entries, err := os.ReadDir(f.dir)
 	if err != nil {
@@ -132,28 +441,66 @@ func (f *FileStore) ListTournaments(_ context.Context) ([]*models.Tournament, er
 }
 
 func (f *FileStore) DeleteTournament(_ context.Context, id string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	p := f.path(id)
-	if _, err := os.Stat(p); os.IsNotExist(err) {
+	if _, err := os.Stat(f.path(id)); os.IsNotExist(err) {
 		return fmt.Errorf("tournament %s not found", id)
 	}
+	if err := f.deleteTournament(id); err != nil {
+		return err
+	}
+	return f.removeTournamentRecords(id)
+}
 
-	if err := os.Remove(p); err != nil {
+// removeTournamentRecords removes a deleted tournament's audit log, webhooks,
+// webhook deliveries and share tokens. Unlike the tournament itself these
+// aren't journaled, so they go for good.
+func (f *FileStore) removeTournamentRecords(id string) error {
+	for _, p := range []string{f.auditPath(id), f.webhooksPath(id), f.deliveriesPath(id)} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("deleting records of tournament %s: %w", id, err)
+		}
+	}
+	shares, err := f.readShares()
+	if err != nil {
+		return err
+	}
+	n := len(shares)
+	maps.DeleteFunc(shares, func(_ string, share *models.ShareToken) bool { return share.TournamentID == id })
+	if len(shares) == n {
+		return nil
+	}
+	return f.writeShares(shares)
+}
+
+func (f *FileStore) removeTournamentFiles(id string) error {
+	if err := os.Remove(f.path(id)); err != nil {
 		return fmt.Errorf("deleting tournament %s: %w", id, err)
 	}
+	if err := os.RemoveAll(filepath.Join(f.dir, id)); err != nil {
+		return fmt.Errorf("deleting matches for tournament %s: %w", id, err)
+	}
 	return nil
 }
 
-func (f *FileStore) UpdateMatchResult(_ context.Context, tournamentID string, roundNumber int, matchID string, result models.MatchResult, score string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+func (f *FileStore) UpdateMatchResult(_ context.Context, tournamentID string, roundNumber int, matchID string, result models.MatchResult, score string, want Precondition) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	t, err := f.readTournament(tournamentID)
+	t, embedded, err := f.loadTournament(tournamentID)
 	if err != nil {
 		return err
 	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
 
 	for i := range t.Rounds {
 		if t.Rounds[i].Number != roundNumber {
@@ -161,10 +508,14 @@ func (f *FileStore) UpdateMatchResult(_ context.Context, tournamentID string, ro
 		}
 		for j := range t.Rounds[i].Matches {
 			if t.Rounds[i].Matches[j].ID == matchID {
-				t.Rounds[i].Matches[j].Result = result
-				t.Rounds[i].Matches[j].Score = score
+				if err := want.checkMatch(tournamentID, &t.Rounds[i].Matches[j]); err != nil {
+					return err
+				}
+				writeMatchResult(&t.Rounds[i].Matches[j], result, score, want)
+				bumpMatch(&t.Rounds[i].Matches[j])
 				t.UpdatedAt = time.Now()
-				return f.writeTournament(t)
+				t.Version++
+				return f.writeMatch(t, embedded, i, j)
 			}
 		}
 		return fmt.Errorf("match %s not found in round %d", matchID, roundNumber)
@@ -173,19 +524,27 @@ func (f *FileStore) UpdateMatchResult(_ context.Context, tournamentID string, ro
 	return fmt.Errorf("round %d not found", roundNumber)
 }
 
-func (f *FileStore) SetRoundPairings(_ context.Context, tournamentID string, roundNumber int, matches []models.Match) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+func (f *FileStore) SetRoundPairings(_ context.Context, tournamentID string, roundNumber int, matches []models.Match, want Precondition) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	t, err := f.readTournament(tournamentID)
 	if err != nil {
 		return err
 	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
 
 	for i := range t.Rounds {
 		if t.Rounds[i].Number == roundNumber {
-			t.Rounds[i].Matches = matches
+			t.Rounds[i].Matches = cloneMatches(matches)
+			writePairings(t.Rounds[i].Matches, want)
 			t.UpdatedAt = time.Now()
+			t.Version++
 			return f.writeTournament(t)
 		}
 	}
@@ -198,13 +557,23 @@ func (f *FileStore) usersPath() string {
 }
 
 func (f *FileStore) RegisterUser(_ context.Context, user *models.RegisteredUser) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
+	// A users file that can't be read is an error rather than an empty
+	// registry, which writing back would wipe.
 	users := make(map[string]*models.RegisteredUser)
 	data, err := os.ReadFile(f.usersPath())
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("reading users: %w", err)
+	}
 	if err == nil {
-		json.Unmarshal(data, &users)
+		if err := json.Unmarshal(data, &users); err != nil {
+			return fmt.Errorf("decoding users: %w", err)
+		}
 	}
 	users[user.Email] = user
 	out, err := json.MarshalIndent(users, "", "  ")
@@ -223,8 +592,11 @@ func (f *FileStore) RegisterUser(_ context.Context, user *models.RegisteredUser)
 }
 
 func (f *FileStore) ListRegisteredUsers(_ context.Context) ([]*models.RegisteredUser, error) {
-	f.mu.RLock()
-	defer f.mu.RUnlock()
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	data, err := os.ReadFile(f.usersPath())
 	if err != nil {
@@ -246,20 +618,27 @@ func (f *FileStore) ListRegisteredUsers(_ context.Context) ([]*models.Registered
 	return result, nil
 }
 
-func (f *FileStore) LinkPlayer(_ context.Context, tournamentID string, playerID string, email string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+func (f *FileStore) LinkPlayer(_ context.Context, tournamentID string, playerID string, email string, want Precondition) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	t, err := f.readTournament(tournamentID)
 	if err != nil {
 		return err
 	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
 
 	for ti := range t.Teams {
 		for pi := range t.Teams[ti].Players {
 			if t.Teams[ti].Players[pi].ID == playerID {
 				t.Teams[ti].Players[pi].UserEmail = email
 				t.UpdatedAt = time.Now()
+				t.Version++
 				return f.writeTournament(t)
 			}
 		}
@@ -268,6 +647,358 @@ func (f *FileStore) LinkPlayer(_ context.Context, tournamentID string, playerID
 	return fmt.Errorf("player %s not found", playerID)
 }
 
+func (f *FileStore) SaveRanking(_ context.Context, tournamentID string, ranking models.PlayerRanking, want Precondition) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t, err := f.readTournament(tournamentID)
+	if err != nil {
+		return err
+	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
+
+	setRanking(t, ranking)
+	t.UpdatedAt = time.Now()
+	t.Version++
+	return f.writeTournament(t)
+}
+
+// auditPath returns the append-only audit log for a tournament, stored as one
+// JSON entry per line under {dir}/_audit/.
+func (f *FileStore) auditPath(tournamentID string) string {
+	return filepath.Join(f.dir, "_audit", tournamentID+".jsonl")
+}
+
+func (f *FileStore) AppendAuditEntry(_ context.Context, entry *models.AuditEntry) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("encoding audit entry: %w", err)
+	}
+
+	p := f.auditPath(entry.TournamentID)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("creating audit directory: %w", err)
+	}
+	file, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening audit log %s: %w", entry.TournamentID, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing audit entry: %w", err)
+	}
+	return nil
+}
+
+func (f *FileStore) ListAuditEntries(_ context.Context, tournamentID string) ([]*models.AuditEntry, error) {
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	file, err := os.Open(f.auditPath(tournamentID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]*models.AuditEntry, 0), nil
+		}
+		return nil, fmt.Errorf("reading audit log %s: %w", tournamentID, err)
+	}
+	defer file.Close()
+
+	entries := make([]*models.AuditEntry, 0)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e models.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue // skip a torn trailing line
+		}
+		entries = append(entries, &e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading audit log %s: %w", tournamentID, err)
+	}
+	return entries, nil
+}
+
+func (f *FileStore) webhooksPath(tournamentID string) string {
+	return filepath.Join(f.dir, "_webhooks", tournamentID+".json")
+}
+
+// deliveriesPath is an append-only log; each save of a delivery appends its
+// latest state and readers keep the last line for each ID.
+func (f *FileStore) deliveriesPath(tournamentID string) string {
+	return filepath.Join(f.dir, "_webhooks", tournamentID+".deliveries.jsonl")
+}
+
+func (f *FileStore) readWebhooks(tournamentID string) ([]*models.Webhook, error) {
+	data, err := os.ReadFile(f.webhooksPath(tournamentID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]*models.Webhook, 0), nil
+		}
+		return nil, fmt.Errorf("reading webhooks for tournament %s: %w", tournamentID, err)
+	}
+	hooks := make([]*models.Webhook, 0)
+	if err := json.Unmarshal(data, &hooks); err != nil {
+		return nil, fmt.Errorf("decoding webhooks for tournament %s: %w", tournamentID, err)
+	}
+	return hooks, nil
+}
+
+func (f *FileStore) writeWebhooks(tournamentID string, hooks []*models.Webhook) error {
+	data, err := json.MarshalIndent(hooks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding webhooks: %w", err)
+	}
+	p := f.webhooksPath(tournamentID)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("creating webhooks directory: %w", err)
+	}
+	if err := writeFileAtomic(p, data); err != nil {
+		return fmt.Errorf("writing webhooks for tournament %s: %w", tournamentID, err)
+	}
+	return nil
+}
+
+func (f *FileStore) CreateWebhook(_ context.Context, webhook *models.Webhook) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(f.path(webhook.TournamentID)); os.IsNotExist(err) {
+		return fmt.Errorf("tournament %s not found", webhook.TournamentID)
+	}
+	hooks, err := f.readWebhooks(webhook.TournamentID)
+	if err != nil {
+		return err
+	}
+	return f.writeWebhooks(webhook.TournamentID, append(hooks, webhook))
+}
+
+func (f *FileStore) ListWebhooks(_ context.Context, tournamentID string) ([]*models.Webhook, error) {
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return f.readWebhooks(tournamentID)
+}
+
+func (f *FileStore) DeleteWebhook(_ context.Context, tournamentID, webhookID string) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	hooks, err := f.readWebhooks(tournamentID)
+	if err != nil {
+		return err
+	}
+	for i, wh := range hooks {
+		if wh.ID == webhookID {
+			return f.writeWebhooks(tournamentID, append(hooks[:i], hooks[i+1:]...))
+		}
+	}
+	return fmt.Errorf("webhook %s not found", webhookID)
+}
+
+func (f *FileStore) SaveWebhookDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("encoding webhook delivery: %w", err)
+	}
+
+	p := f.deliveriesPath(delivery.TournamentID)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("creating webhooks directory: %w", err)
+	}
+	file, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening delivery log %s: %w", delivery.TournamentID, err)
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (f *FileStore) ListWebhookDeliveries(_ context.Context, tournamentID, webhookID string) ([]*models.WebhookDelivery, error) {
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	file, err := os.Open(f.deliveriesPath(tournamentID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make([]*models.WebhookDelivery, 0), nil
+		}
+		return nil, fmt.Errorf("reading delivery log %s: %w", tournamentID, err)
+	}
+	defer file.Close()
+
+	result := make([]*models.WebhookDelivery, 0)
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var d models.WebhookDelivery
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil || d.WebhookID != webhookID {
+			continue
+		}
+		if i, seen := index[d.ID]; seen {
+			result[i] = &d
+		} else {
+			index[d.ID] = len(result)
+			result = append(result, &d)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading delivery log %s: %w", tournamentID, err)
+	}
+	return result, nil
+}
+
+func (f *FileStore) sharesPath() string {
+	return filepath.Join(f.dir, "_shares.json")
+}
+
+// readShares returns every share token, keyed by token.
+func (f *FileStore) readShares() (map[string]*models.ShareToken, error) {
+	data, err := os.ReadFile(f.sharesPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return make(map[string]*models.ShareToken), nil
+		}
+		return nil, fmt.Errorf("reading share tokens: %w", err)
+	}
+	shares := make(map[string]*models.ShareToken)
+	if err := json.Unmarshal(data, &shares); err != nil {
+		return nil, fmt.Errorf("decoding share tokens: %w", err)
+	}
+	return shares, nil
+}
+
+func (f *FileStore) writeShares(shares map[string]*models.ShareToken) error {
+	data, err := json.MarshalIndent(shares, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding share tokens: %w", err)
+	}
+	if err := writeFileAtomic(f.sharesPath(), data); err != nil {
+		return fmt.Errorf("writing share tokens: %w", err)
+	}
+	return nil
+}
+
+func (f *FileStore) CreateShareToken(_ context.Context, share *models.ShareToken) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if _, err := os.Stat(f.path(share.TournamentID)); os.IsNotExist(err) {
+		return fmt.Errorf("tournament %s not found", share.TournamentID)
+	}
+	shares, err := f.readShares()
+	if err != nil {
+		return err
+	}
+	if _, exists := shares[share.Token]; exists {
+		return fmt.Errorf("share token already exists")
+	}
+	shares[share.Token] = share
+	return f.writeShares(shares)
+}
+
+func (f *FileStore) GetShareToken(_ context.Context, token string) (*models.ShareToken, error) {
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	shares, err := f.readShares()
+	if err != nil {
+		return nil, err
+	}
+	share, exists := shares[token]
+	if !exists {
+		return nil, fmt.Errorf("share token not found")
+	}
+	return share, nil
+}
+
+func (f *FileStore) ListShareTokens(_ context.Context, tournamentID string) ([]*models.ShareToken, error) {
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	shares, err := f.readShares()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*models.ShareToken, 0)
+	for _, share := range shares {
+		if share.TournamentID == tournamentID {
+			result = append(result, share)
+		}
+	}
+	sortShareTokens(result)
+	return result, nil
+}
+
+func (f *FileStore) DeleteShareToken(_ context.Context, tournamentID, token string) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	shares, err := f.readShares()
+	if err != nil {
+		return err
+	}
+	share, exists := shares[token]
+	if !exists || share.TournamentID != tournamentID {
+		return fmt.Errorf("share token not found")
+	}
+	delete(shares, token)
+	return f.writeShares(shares)
+}
+
 func (f *FileStore) localUsersPath() string {
 	return filepath.Join(f.dir, "_local_users.json")
 }
@@ -304,8 +1035,11 @@ func (f *FileStore) writeLocalUsers(users map[string]*models.LocalUser) error {
 }
 
 func (f *FileStore) CreateLocalUser(_ context.Context, user *models.LocalUser) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	users, err := f.readLocalUsers()
 	if err != nil {
@@ -321,9 +1055,28 @@ func (f *FileStore) CreateLocalUser(_ context.Context, user *models.LocalUser) e
 	return f.writeLocalUsers(users)
 }
 
+// ImportLocalUser writes a user as-is, replacing any user with the same email.
+func (f *FileStore) ImportLocalUser(_ context.Context, user *models.LocalUser) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	users, err := f.readLocalUsers()
+	if err != nil {
+		return err
+	}
+	users[strings.ToLower(user.Email)] = user
+	return f.writeLocalUsers(users)
+}
+
 func (f *FileStore) GetLocalUser(_ context.Context, email string) (*models.LocalUser, error) {
-	f.mu.RLock()
-	defer f.mu.RUnlock()
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	users, err := f.readLocalUsers()
 	if err != nil {
@@ -338,8 +1091,15 @@ func (f *FileStore) GetLocalUser(_ context.Context, email string) (*models.Local
 }
 
 func (f *FileStore) VerifyLocalUser(_ context.Context, token string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	if token == "" {
+		return fmt.Errorf("invalid verification token")
+	}
+
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	users, err := f.readLocalUsers()
 	if err != nil {
@@ -358,8 +1118,11 @@ func (f *FileStore) VerifyLocalUser(_ context.Context, token string) error {
 }
 
 func (f *FileStore) ListLocalUsers(_ context.Context) ([]*models.LocalUser, error) {
-	f.mu.RLock()
-	defer f.mu.RUnlock()
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	users, err := f.readLocalUsers()
 	if err != nil {
@@ -374,8 +1137,11 @@ func (f *FileStore) ListLocalUsers(_ context.Context) ([]*models.LocalUser, erro
 }
 
 func (f *FileStore) ConfirmLocalUser(_ context.Context, email string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	users, err := f.readLocalUsers()
 	if err != nil {
@@ -392,8 +1158,11 @@ func (f *FileStore) ConfirmLocalUser(_ context.Context, email string) error {
 }
 
 func (f *FileStore) DeleteLocalUser(_ context.Context, email string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	users, err := f.readLocalUsers()
 	if err != nil {
@@ -411,8 +1180,11 @@ func (f *FileStore) DeleteLocalUser(_ context.Context, email string) error {
 }
 
 func (f *FileStore) EnableLocalUser(_ context.Context, email string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	users, err := f.readLocalUsers()
 	if err != nil {
@@ -429,41 +1201,97 @@ func (f *FileStore) EnableLocalUser(_ context.Context, email string) error {
 	return f.writeLocalUsers(users)
 }
 
-func (f *FileStore) UpdateHoleResult(_ context.Context, tournamentID string, roundNumber int, matchID string, hole int, result string) error {
-	f.mu.Lock()
-	defer f.mu.Unlock()
+func (f *FileStore) SetCalendarKey(_ context.Context, email, key string) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
-	t, err := f.readTournament(tournamentID)
+	users, err := f.readLocalUsers()
 	if err != nil {
 		return err
 	}
 
+	user, ok := users[strings.ToLower(email)]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+
+	user.CalendarKey = key
+	return f.writeLocalUsers(users)
+}
+
+func (f *FileStore) UpdateHoleResult(_ context.Context, tournamentID string, roundNumber int, matchID string, hole int, result string, want Precondition) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t, embedded, err := f.loadTournament(tournamentID)
+	if err != nil {
+		return err
+	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
+
 	for i := range t.Rounds {
 		if t.Rounds[i].Number != roundNumber {
 			continue
 		}
 		for j := range t.Rounds[i].Matches {
 			if t.Rounds[i].Matches[j].ID == matchID {
-				match := &t.Rounds[i].Matches[j]
-				if match.HoleResults == nil {
-					match.HoleResults = make(map[string]string)
+				if err := want.checkMatch(tournamentID, &t.Rounds[i].Matches[j]); err != nil {
+					return err
+				}
+				if err := writeHoleResult(t, &t.Rounds[i], &t.Rounds[i].Matches[j], hole, result, want); err != nil {
+					return err
 				}
-				key := strconv.Itoa(hole)
-				if result == "" {
-					delete(match.HoleResults, key)
-				} else {
-					match.HoleResults[key] = result
+				bumpMatch(&t.Rounds[i].Matches[j])
+				t.UpdatedAt = time.Now()
+				t.Version++
+				return f.writeMatch(t, embedded, i, j)
+			}
+		}
+		return fmt.Errorf("match %s not found in round %d", matchID, roundNumber)
+	}
+
+	return fmt.Errorf("round %d not found", roundNumber)
+}
+
+func (f *FileStore) AttestMatch(_ context.Context, tournamentID string, roundNumber int, matchID string, attestation models.Attestation, want Precondition) error {
+	unlock, err := f.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	t, embedded, err := f.loadTournament(tournamentID)
+	if err != nil {
+		return err
+	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
+
+	for i := range t.Rounds {
+		if t.Rounds[i].Number != roundNumber {
+			continue
+		}
+		for j := range t.Rounds[i].Matches {
+			if t.Rounds[i].Matches[j].ID == matchID {
+				if err := want.checkMatch(tournamentID, &t.Rounds[i].Matches[j]); err != nil {
+					return err
 				}
-				// Backfill any earlier empty holes as halved
-				for h := 1; h < hole; h++ {
-					k := strconv.Itoa(h)
-					if match.HoleResults[k] == "" {
-						match.HoleResults[k] = "halved"
-					}
+				if err := t.Rounds[i].Matches[j].Attest(attestation); err != nil {
+					return err
 				}
-				match.Result, match.Score = models.CalculateMatchPlayResult(match.HoleResults, t.Teams[0].Name, t.Teams[1].Name, t.Rounds[i].HoleCount())
+				bumpMatch(&t.Rounds[i].Matches[j])
 				t.UpdatedAt = time.Now()
-				return f.writeTournament(t)
+				t.Version++
+				return f.writeMatch(t, embedded, i, j)
 			}
 		}
 		return fmt.Errorf("match %s not found in round %d", matchID, roundNumber)
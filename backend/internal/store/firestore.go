@@ -2,9 +2,11 @@ package store
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"scoring-backend/internal/models"
-	"strconv"
+	"scoring-backend/internal/schema"
+	"sort"
 	"strings"
 	"time"
 
@@ -46,33 +48,164 @@ func (f *FirestoreStore) localUsers() *firestore.CollectionRef {
 	return f.client.Collection("local_users")
 }
 
-// normalizeTournament ensures all nil slices and maps are initialized after
-// reading from Firestore, which does not preserve empty slices/maps.
-func normalizeTournament(t *models.Tournament) {
-	for i := range t.Teams {
-		if t.Teams[i].Players == nil {
-			t.Teams[i].Players = []models.Player{}
+func (f *FirestoreStore) shareTokens() *firestore.CollectionRef {
+	return f.client.Collection("share_tokens")
+}
+
+func (f *FirestoreStore) matches(tournamentID string) *firestore.CollectionRef {
+	return f.tournaments().Doc(tournamentID).Collection("matches")
+}
+
+func (f *FirestoreStore) webhooks(tournamentID string) *firestore.CollectionRef {
+	return f.tournaments().Doc(tournamentID).Collection("webhooks")
+}
+
+func (f *FirestoreStore) webhookDeliveries(tournamentID string) *firestore.CollectionRef {
+	return f.tournaments().Doc(tournamentID).Collection("webhook_deliveries")
+}
+
+func (f *FirestoreStore) auditEntries(tournamentID string) *firestore.CollectionRef {
+	return f.tournaments().Doc(tournamentID).Collection("audit")
+}
+
+// firestoreMatch is a stored match document. Match transactions leave the
+// tournament header alone so scorers in different matches don't contend on
+// it; they stamp the match document instead, and the match's own version
+// stands in for the bump to the tournament's.
+type firestoreMatch struct {
+	matchRecord
+	UpdatedAt time.Time
+}
+
+// decodeMatchDocs converts match documents into match records.
+func decodeMatchDocs(docs []*firestore.DocumentSnapshot) ([]firestoreMatch, error) {
+	records := make([]firestoreMatch, 0, len(docs))
+	for _, doc := range docs {
+		var rec firestoreMatch
+		if err := doc.DataTo(&rec); err != nil {
+			return nil, fmt.Errorf("decoding match %s: %w", doc.Ref.ID, err)
+		}
+		if err := rec.upgrade(); err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// decodeHeader converts a tournament header document.
+func decodeHeader(doc *firestore.DocumentSnapshot) (*models.Tournament, error) {
+	var rec tournamentRecord
+	if err := doc.DataTo(&rec); err != nil {
+		return nil, fmt.Errorf("decoding tournament %s: %w", doc.Ref.ID, err)
+	}
+	if err := rec.upgrade(); err != nil {
+		return nil, err
+	}
+	return &rec.Tournament, nil
+}
+
+// splitFirestore is splitTournament for Firestore. The header keeps only the
+// part of t's version that its matches' versions don't already account for.
+func splitFirestore(t *models.Tournament) (*tournamentRecord, []firestoreMatch) {
+	header, records := splitTournament(t)
+	header.Version -= matchWrites(t)
+	docs := make([]firestoreMatch, len(records))
+	for i, rec := range records {
+		docs[i] = firestoreMatch{matchRecord: rec}
+	}
+	return header, docs
+}
+
+// joinFirestore assembles a tournament from its header and match documents.
+// Its version is the header's plus every write made to a match on its own,
+// and it was last updated at the latest of the header's and matches' stamps.
+func joinFirestore(t *models.Tournament, docs []firestoreMatch) {
+	if !hasEmbeddedMatches(t) {
+		records := make([]matchRecord, len(docs))
+		for i, doc := range docs {
+			records[i] = doc.matchRecord
 		}
+		joinTournament(t, records)
 	}
-	if t.Rounds == nil {
-		t.Rounds = []models.Round{}
+	normalizeTournament(t)
+	t.Version += matchWrites(t)
+	for _, doc := range docs {
+		if doc.UpdatedAt.After(t.UpdatedAt) {
+			t.UpdatedAt = doc.UpdatedAt
+		}
 	}
-	for i := range t.Rounds {
-		if t.Rounds[i].Matches == nil {
-			t.Rounds[i].Matches = []models.Match{}
+}
+
+// matchWrites counts the writes made to t's matches since each was stored
+// at its first version.
+func matchWrites(t *models.Tournament) int64 {
+	var n int64
+	for _, round := range t.Rounds {
+		for i := range round.Matches {
+			n += matchVersion(&round.Matches[i]) - 1
 		}
-		for j := range t.Rounds[i].Matches {
-			if t.Rounds[i].Matches[j].HoleResults == nil {
-				t.Rounds[i].Matches[j].HoleResults = make(map[string]string)
-			}
-			if t.Rounds[i].Matches[j].Team1Players == nil {
-				t.Rounds[i].Matches[j].Team1Players = []string{}
-			}
-			if t.Rounds[i].Matches[j].Team2Players == nil {
-				t.Rounds[i].Matches[j].Team2Players = []string{}
+	}
+	return n
+}
+
+// getTournamentTx reads a tournament header and its match documents inside
+// tx. It also returns the refs of the stored match documents so a following
+// write can remove the ones that no longer belong to the tournament.
+func (f *FirestoreStore) getTournamentTx(tx *firestore.Transaction, id string) (*models.Tournament, []*firestore.DocumentRef, error) {
+	doc, err := tx.Get(f.tournaments().Doc(id))
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil, fmt.Errorf("tournament %s not found", id)
+		}
+		return nil, nil, fmt.Errorf("getting tournament %s: %w", id, err)
+	}
+	t, err := decodeHeader(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	docs, err := tx.Documents(f.matches(id)).GetAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("getting matches for tournament %s: %w", id, err)
+	}
+	refs := make([]*firestore.DocumentRef, len(docs))
+	for i, d := range docs {
+		refs[i] = d.Ref
+	}
+
+	records, err := decodeMatchDocs(docs)
+	if err != nil {
+		return nil, nil, err
+	}
+	joinFirestore(t, records)
+	return t, refs, nil
+}
+
+// setTournamentTx writes t as a header document plus one document per match,
+// and deletes the documents in existing that t no longer has a match for.
+func (f *FirestoreStore) setTournamentTx(tx *firestore.Transaction, t *models.Tournament, existing []*firestore.DocumentRef) error {
+	header, records := splitFirestore(t)
+
+	keep := make(map[string]bool, len(records))
+	for _, rec := range records {
+		if err := tx.Set(f.matches(t.ID).Doc(rec.Match.ID), rec); err != nil {
+			return fmt.Errorf("writing match %s: %w", rec.Match.ID, err)
+		}
+		keep[rec.Match.ID] = true
+	}
+	for _, ref := range existing {
+		if !keep[ref.ID] {
+			if err := tx.Delete(ref); err != nil {
+				return fmt.Errorf("deleting match %s: %w", ref.ID, err)
 			}
 		}
 	}
+
+	if err := tx.Set(f.tournaments().Doc(t.ID), header); err != nil {
+		return fmt.Errorf("writing tournament %s: %w", t.ID, err)
+	}
+	return nil
 }
 
 // --- Tournament CRUD ---
@@ -92,60 +225,93 @@ func (f *FirestoreStore) CreateTournament(ctx context.Context, t *models.Tournam
 	now := time.Now()
 	t.CreatedAt = now
 	t.UpdatedAt = now
+	t.Version = 1
 
-	if _, err := ref.Set(ctx, t); err != nil {
+	header, records := splitFirestore(t)
+	batch := f.client.Batch()
+	batch.Create(ref, header)
+	for _, rec := range records {
+		batch.Set(f.matches(t.ID).Doc(rec.Match.ID), rec)
+	}
+	if _, err := batch.Commit(ctx); err != nil {
 		return fmt.Errorf("creating tournament %s: %w", t.ID, err)
 	}
 	return nil
 }
 
-// ImportTournament writes a tournament preserving its original timestamps.
-// Used for data migration. Overwrites any existing document with the same ID.
+// ImportTournament writes a tournament preserving its original timestamps and
+// version. Overwrites any existing document with the same ID.
 func (f *FirestoreStore) ImportTournament(ctx context.Context, t *models.Tournament) error {
-	ref := f.tournaments().Doc(t.ID)
-	if _, err := ref.Set(ctx, t); err != nil {
+	err := f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		existing, err := tx.DocumentRefs(f.matches(t.ID)).GetAll()
+		if err != nil {
+			return fmt.Errorf("getting matches for tournament %s: %w", t.ID, err)
+		}
+		return f.setTournamentTx(tx, t, existing)
+	})
+	if err != nil {
 		return fmt.Errorf("importing tournament %s: %w", t.ID, err)
 	}
 	return nil
 }
 
 func (f *FirestoreStore) GetTournament(ctx context.Context, id string) (*models.Tournament, error) {
-	doc, err := f.tournaments().Doc(id).Get(ctx)
+	var t *models.Tournament
+	err := f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		var err error
+		t, _, err = f.getTournamentTx(tx, id)
+		return err
+	}, firestore.ReadOnly)
 	if err != nil {
-		if status.Code(err) == codes.NotFound {
-			return nil, fmt.Errorf("tournament %s not found", id)
-		}
-		return nil, fmt.Errorf("getting tournament %s: %w", id, err)
-	}
-
-	var t models.Tournament
-	if err := doc.DataTo(&t); err != nil {
-		return nil, fmt.Errorf("decoding tournament %s: %w", id, err)
+		return nil, err
 	}
-
-	normalizeTournament(&t)
-	return &t, nil
+	return t, nil
 }
 
 func (f *FirestoreStore) UpdateTournament(ctx context.Context, t *models.Tournament) error {
-	ref := f.tournaments().Doc(t.ID)
-
-	_, err := ref.Get(ctx)
-	if err != nil {
-		if status.Code(err) == codes.NotFound {
-			return fmt.Errorf("tournament %s not found", t.ID)
+	// Compare and write the version in a transaction so that two writers that
+	// read the same version cannot both succeed.
+	expected := t.Version
+	return f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		existing, refs, err := f.getTournamentTx(tx, t.ID)
+		if err != nil {
+			return err
+		}
+		if existing.Version != expected {
+			return &VersionConflictError{TournamentID: t.ID, Current: existing.Version}
 		}
-		return fmt.Errorf("checking tournament %s: %w", t.ID, err)
-	}
 
-	t.UpdatedAt = time.Now()
-	if _, err := ref.Set(ctx, t); err != nil {
-		return fmt.Errorf("updating tournament %s: %w", t.ID, err)
-	}
-	return nil
+		t.UpdatedAt = time.Now()
+		t.Version = expected + 1
+		return f.setTournamentTx(tx, t, refs)
+	}, firestore.MaxAttempts(transactionAttempts))
 }
 
 func (f *FirestoreStore) ListTournaments(ctx context.Context) ([]*models.Tournament, error) {
+	// Fetch every match in one collection group query rather than one query
+	// per tournament, then attach them to their headers.
+	records := make(map[string][]firestoreMatch)
+	miter := f.client.CollectionGroup("matches").Documents(ctx)
+	defer miter.Stop()
+	for {
+		doc, err := miter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing matches: %w", err)
+		}
+		parent := doc.Ref.Parent.Parent
+		if parent == nil || parent.Parent.ID != "tournaments" {
+			continue
+		}
+		decoded, err := decodeMatchDocs([]*firestore.DocumentSnapshot{doc})
+		if err != nil {
+			return nil, fmt.Errorf("tournament %s: %w", parent.ID, err)
+		}
+		records[parent.ID] = append(records[parent.ID], decoded...)
+	}
+
 	iter := f.tournaments().Documents(ctx)
 	defer iter.Stop()
 
@@ -159,16 +325,42 @@ func (f *FirestoreStore) ListTournaments(ctx context.Context) ([]*models.Tournam
 			return nil, fmt.Errorf("listing tournaments: %w", err)
 		}
 
-		var t models.Tournament
-		if err := doc.DataTo(&t); err != nil {
-			continue // skip corrupt documents
+		t, err := decodeHeader(doc)
+		if err != nil {
+			return nil, err
 		}
-		normalizeTournament(&t)
-		tournaments = append(tournaments, &t)
+		joinFirestore(t, records[t.ID])
+		tournaments = append(tournaments, t)
 	}
 	return tournaments, nil
 }
 
+// StoredSchemaVersion returns the oldest schema version among the documents
+// of a tournament.
+func (f *FirestoreStore) StoredSchemaVersion(ctx context.Context, id string) (int, error) {
+	doc, err := f.tournaments().Doc(id).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return 0, fmt.Errorf("tournament %s not found", id)
+		}
+		return 0, fmt.Errorf("getting tournament %s: %w", id, err)
+	}
+	docs, err := f.matches(id).Documents(ctx).GetAll()
+	if err != nil {
+		return 0, fmt.Errorf("getting matches for tournament %s: %w", id, err)
+	}
+
+	oldest := schema.Current
+	for _, d := range append(docs, doc) {
+		var stamp struct{ SchemaVersion int }
+		if err := d.DataTo(&stamp); err != nil {
+			return 0, fmt.Errorf("decoding %s: %w", d.Ref.Path, err)
+		}
+		oldest = min(oldest, stamp.SchemaVersion)
+	}
+	return oldest, nil
+}
+
 func (f *FirestoreStore) DeleteTournament(ctx context.Context, id string) error {
 	ref := f.tournaments().Doc(id)
 
@@ -180,122 +372,228 @@ func (f *FirestoreStore) DeleteTournament(ctx context.Context, id string) error
 		return fmt.Errorf("checking tournament %s: %w", id, err)
 	}
 
-	if _, err := ref.Delete(ctx); err != nil {
+	// The tournament's other records go first, so that if deleting them
+	// fails the tournament is still there to delete again.
+	if err := f.deleteTournamentRecords(ctx, id); err != nil {
+		return err
+	}
+
+	matchRefs, err := f.matches(id).DocumentRefs(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("listing matches for tournament %s: %w", id, err)
+	}
+	batch := f.client.Batch()
+	for _, mref := range matchRefs {
+		batch.Delete(mref)
+	}
+	batch.Delete(ref)
+	if _, err := batch.Commit(ctx); err != nil {
 		return fmt.Errorf("deleting tournament %s: %w", id, err)
 	}
 	return nil
 }
 
-// --- Match operations ---
-
-// getTournamentForUpdate reads a tournament and returns it along with its doc ref.
-func (f *FirestoreStore) getTournamentForUpdate(ctx context.Context, tournamentID string) (*models.Tournament, *firestore.DocumentRef, error) {
-	ref := f.tournaments().Doc(tournamentID)
-	doc, err := ref.Get(ctx)
-	if err != nil {
-		if status.Code(err) == codes.NotFound {
-			return nil, nil, fmt.Errorf("tournament %s not found", tournamentID)
+// deleteTournamentRecords deletes a tournament's audit log, webhooks, webhook
+// deliveries and share tokens. There can be more of them than fit in a batch,
+// so they go through a BulkWriter.
+func (f *FirestoreStore) deleteTournamentRecords(ctx context.Context, id string) error {
+	var refs []*firestore.DocumentRef
+	for _, c := range []*firestore.CollectionRef{f.auditEntries(id), f.webhooks(id), f.webhookDeliveries(id)} {
+		crefs, err := c.DocumentRefs(ctx).GetAll()
+		if err != nil {
+			return fmt.Errorf("listing %s of tournament %s: %w", c.ID, id, err)
 		}
-		return nil, nil, fmt.Errorf("getting tournament %s: %w", tournamentID, err)
+		refs = append(refs, crefs...)
 	}
-
-	var t models.Tournament
-	if err := doc.DataTo(&t); err != nil {
-		return nil, nil, fmt.Errorf("decoding tournament %s: %w", tournamentID, err)
+	shares, err := f.shareTokens().Where("TournamentID", "==", id).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("listing share tokens of tournament %s: %w", id, err)
+	}
+	for _, doc := range shares {
+		refs = append(refs, doc.Ref)
+	}
+	if len(refs) == 0 {
+		return nil
 	}
-	normalizeTournament(&t)
 
-	return &t, ref, nil
+	bw := f.client.BulkWriter(ctx)
+	jobs := make([]*firestore.BulkWriterJob, 0, len(refs))
+	for _, ref := range refs {
+		job, err := bw.Delete(ref)
+		if err != nil {
+			bw.End()
+			return fmt.Errorf("deleting records of tournament %s: %w", id, err)
+		}
+		jobs = append(jobs, job)
+	}
+	bw.End()
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			return fmt.Errorf("deleting records of tournament %s: %w", id, err)
+		}
+	}
+	return nil
 }
 
-func (f *FirestoreStore) UpdateMatchResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, result models.MatchResult, score string) error {
-	t, ref, err := f.getTournamentForUpdate(ctx, tournamentID)
-	if err != nil {
-		return err
-	}
+// --- Match operations ---
 
-	for i := range t.Rounds {
-		if t.Rounds[i].Number != roundNumber {
-			continue
+// transactionAttempts bounds how many times a read-modify-write transaction is
+// retried when another writer touches the same tournament concurrently.
+const transactionAttempts = 10
+
+// updateTournamentTx reads a tournament inside a transaction, applies mutate
+// and writes the result back. If the document changes before the commit,
+// Firestore aborts and the whole read-mutate-write is retried on fresh data, so
+// concurrent writers never silently drop each other's updates. want is checked
+// against the version read in the transaction. mutate may run more than once
+// and must only modify the tournament it is given.
+func (f *FirestoreStore) updateTournamentTx(ctx context.Context, tournamentID string, want Precondition, mutate func(t *models.Tournament) error) error {
+	return f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		t, refs, err := f.getTournamentTx(tx, tournamentID)
+		if err != nil {
+			return err
 		}
-		for j := range t.Rounds[i].Matches {
-			if t.Rounds[i].Matches[j].ID == matchID {
-				t.Rounds[i].Matches[j].Result = result
-				t.Rounds[i].Matches[j].Score = score
-				t.UpdatedAt = time.Now()
-				if _, err := ref.Set(ctx, t); err != nil {
-					return fmt.Errorf("updating tournament %s: %w", tournamentID, err)
-				}
-				return nil
-			}
+		if err := want.check(tournamentID, t.Version); err != nil {
+			return err
 		}
-		return fmt.Errorf("match %s not found in round %d", matchID, roundNumber)
-	}
 
-	return fmt.Errorf("round %d not found", roundNumber)
-}
+		if err := mutate(t); err != nil {
+			return err
+		}
 
-func (f *FirestoreStore) SetRoundPairings(ctx context.Context, tournamentID string, roundNumber int, matches []models.Match) error {
-	t, ref, err := f.getTournamentForUpdate(ctx, tournamentID)
-	if err != nil {
-		return err
-	}
+		t.UpdatedAt = time.Now()
+		t.Version++
+		return f.setTournamentTx(tx, t, refs)
+	}, firestore.MaxAttempts(transactionAttempts))
+}
 
-	for i := range t.Rounds {
-		if t.Rounds[i].Number == roundNumber {
-			t.Rounds[i].Matches = matches
-			t.UpdatedAt = time.Now()
-			if _, err := ref.Set(ctx, t); err != nil {
-				return fmt.Errorf("updating tournament %s: %w", tournamentID, err)
+// updateMatchTx applies mutate to a single match. The transaction reads the
+// header and the match document but writes only the match, so scorers
+// entering holes in different matches don't abort each other, while a
+// concurrent whole-tournament write still does. The header passed to mutate
+// is for reference only (team names, hole counts); changes to it are not
+// saved. A precondition on the tournament version reads every match, since
+// they all count towards it.
+func (f *FirestoreStore) updateMatchTx(ctx context.Context, tournamentID string, roundNumber int, matchID string, want Precondition, mutate func(t *models.Tournament, round *models.Round, m *models.Match) error) error {
+	headerRef := f.tournaments().Doc(tournamentID)
+	ref := f.matches(tournamentID).Doc(matchID)
+	var embedded bool
+	err := f.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		doc, err := tx.Get(headerRef)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return fmt.Errorf("tournament %s not found", tournamentID)
 			}
+			return fmt.Errorf("getting tournament %s: %w", tournamentID, err)
+		}
+		header, err := decodeHeader(doc)
+		if err != nil {
+			return err
+		}
+		if embedded = hasEmbeddedMatches(header); embedded {
 			return nil
 		}
-	}
 
-	return fmt.Errorf("round %d not found", roundNumber)
-}
+		if want.Version != 0 {
+			t, _, err := f.getTournamentTx(tx, tournamentID)
+			if err != nil {
+				return err
+			}
+			if err := want.check(tournamentID, t.Version); err != nil {
+				return err
+			}
+		}
+		round, _, err := locateMatch(header, roundNumber, "")
+		if err != nil {
+			return err
+		}
 
-func (f *FirestoreStore) UpdateHoleResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, hole int, result string) error {
-	t, ref, err := f.getTournamentForUpdate(ctx, tournamentID)
-	if err != nil {
+		doc, err = tx.Get(ref)
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return fmt.Errorf("match %s not found in round %d", matchID, roundNumber)
+			}
+			return fmt.Errorf("getting match %s: %w", matchID, err)
+		}
+		var rec firestoreMatch
+		if err := doc.DataTo(&rec); err != nil {
+			return fmt.Errorf("decoding match %s: %w", matchID, err)
+		}
+		if err := rec.upgrade(); err != nil {
+			return err
+		}
+		if rec.Round != roundNumber {
+			return fmt.Errorf("match %s not found in round %d", matchID, roundNumber)
+		}
+		normalizeMatch(&rec.Match)
+		if err := want.checkMatch(tournamentID, &rec.Match); err != nil {
+			return err
+		}
+
+		if err := mutate(header, round, &rec.Match); err != nil {
+			return err
+		}
+		bumpMatch(&rec.Match)
+		rec.UpdatedAt = time.Now()
+
+		if err := tx.Set(ref, rec); err != nil {
+			return fmt.Errorf("updating match %s: %w", matchID, err)
+		}
+		return nil
+	}, firestore.MaxAttempts(transactionAttempts))
+	if err != nil || !embedded {
 		return err
 	}
 
-	for i := range t.Rounds {
-		if t.Rounds[i].Number != roundNumber {
-			continue
+	// Tournaments still stored as a single document are rewritten in full,
+	// which also moves them to the per-match layout.
+	return f.updateTournamentTx(ctx, tournamentID, want, func(t *models.Tournament) error {
+		round, match, err := locateMatch(t, roundNumber, matchID)
+		if err != nil {
+			return err
 		}
-		for j := range t.Rounds[i].Matches {
-			if t.Rounds[i].Matches[j].ID == matchID {
-				match := &t.Rounds[i].Matches[j]
-				if match.HoleResults == nil {
-					match.HoleResults = make(map[string]string)
-				}
-				key := strconv.Itoa(hole)
-				if result == "" {
-					delete(match.HoleResults, key)
-				} else {
-					match.HoleResults[key] = result
-				}
-				// Backfill earlier empty holes as halved
-				for h := 1; h < hole; h++ {
-					k := strconv.Itoa(h)
-					if match.HoleResults[k] == "" {
-						match.HoleResults[k] = "halved"
-					}
-				}
-				match.Result, match.Score = models.CalculateMatchPlayResult(match.HoleResults, t.Teams[0].Name, t.Teams[1].Name, t.Rounds[i].HoleCount())
-				t.UpdatedAt = time.Now()
-				if _, err := ref.Set(ctx, t); err != nil {
-					return fmt.Errorf("updating tournament %s: %w", tournamentID, err)
-				}
+		if err := want.checkMatch(tournamentID, match); err != nil {
+			return err
+		}
+		if err := mutate(t, round, match); err != nil {
+			return err
+		}
+		bumpMatch(match)
+		return nil
+	})
+}
+
+func (f *FirestoreStore) UpdateMatchResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, result models.MatchResult, score string, want Precondition) error {
+	return f.updateMatchTx(ctx, tournamentID, roundNumber, matchID, want, func(_ *models.Tournament, _ *models.Round, m *models.Match) error {
+		writeMatchResult(m, result, score, want)
+		return nil
+	})
+}
+
+func (f *FirestoreStore) SetRoundPairings(ctx context.Context, tournamentID string, roundNumber int, matches []models.Match, want Precondition) error {
+	return f.updateTournamentTx(ctx, tournamentID, want, func(t *models.Tournament) error {
+		for i := range t.Rounds {
+			if t.Rounds[i].Number == roundNumber {
+				t.Rounds[i].Matches = cloneMatches(matches)
+				writePairings(t.Rounds[i].Matches, want)
 				return nil
 			}
 		}
-		return fmt.Errorf("match %s not found in round %d", matchID, roundNumber)
-	}
 
-	return fmt.Errorf("round %d not found", roundNumber)
+		return fmt.Errorf("round %d not found", roundNumber)
+	})
+}
+
+func (f *FirestoreStore) UpdateHoleResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, hole int, result string, want Precondition) error {
+	return f.updateMatchTx(ctx, tournamentID, roundNumber, matchID, want, func(t *models.Tournament, round *models.Round, match *models.Match) error {
+		return writeHoleResult(t, round, match, hole, result, want)
+	})
+}
+
+func (f *FirestoreStore) AttestMatch(ctx context.Context, tournamentID string, roundNumber int, matchID string, attestation models.Attestation, want Precondition) error {
+	return f.updateMatchTx(ctx, tournamentID, roundNumber, matchID, want, func(_ *models.Tournament, _ *models.Round, m *models.Match) error {
+		return m.Attest(attestation)
+	})
 }
 
 // --- User registry ---
@@ -331,28 +629,305 @@ func (f *FirestoreStore) ListRegisteredUsers(ctx context.Context) ([]*models.Reg
 	return result, nil
 }
 
-// --- Player-user linking ---
+// --- Audit log ---
+
+// auditDoc is the Firestore form of an audit entry. Before and After are kept
+// as JSON text; Firestore would otherwise store json.RawMessage as an array of
+// integers.
+type auditDoc struct {
+	ID           string
+	TournamentID string
+	Action       models.AuditAction
+	RoundNumber  int
+	MatchID      string
+	Hole         int
+	PlayerID     string
+	UserEmail    string
+	Before       string
+	After        string
+	RevertOf     string
+	Version      int64
+	CreatedAt    time.Time
+}
+
+func (f *FirestoreStore) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	ref := f.auditEntries(entry.TournamentID).Doc(entry.ID)
+	doc := auditDoc{
+		ID:           entry.ID,
+		TournamentID: entry.TournamentID,
+		Action:       entry.Action,
+		RoundNumber:  entry.RoundNumber,
+		MatchID:      entry.MatchID,
+		Hole:         entry.Hole,
+		PlayerID:     entry.PlayerID,
+		UserEmail:    entry.UserEmail,
+		Before:       string(entry.Before),
+		After:        string(entry.After),
+		RevertOf:     entry.RevertOf,
+		Version:      entry.Version,
+		CreatedAt:    entry.CreatedAt,
+	}
+	if _, err := ref.Create(ctx, doc); err != nil {
+		return fmt.Errorf("appending audit entry: %w", err)
+	}
+	return nil
+}
+
+func (f *FirestoreStore) ListAuditEntries(ctx context.Context, tournamentID string) ([]*models.AuditEntry, error) {
+	iter := f.auditEntries(tournamentID).OrderBy("CreatedAt", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+
+	result := make([]*models.AuditEntry, 0)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing audit entries: %w", err)
+		}
+
+		var d auditDoc
+		if err := doc.DataTo(&d); err != nil {
+			continue
+		}
+		result = append(result, &models.AuditEntry{
+			ID:           d.ID,
+			TournamentID: d.TournamentID,
+			Action:       d.Action,
+			RoundNumber:  d.RoundNumber,
+			MatchID:      d.MatchID,
+			Hole:         d.Hole,
+			PlayerID:     d.PlayerID,
+			UserEmail:    d.UserEmail,
+			Before:       json.RawMessage(d.Before),
+			After:        json.RawMessage(d.After),
+			RevertOf:     d.RevertOf,
+			Version:      d.Version,
+			CreatedAt:    d.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// --- Webhooks ---
+
+func (f *FirestoreStore) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	if _, err := f.tournaments().Doc(webhook.TournamentID).Get(ctx); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return fmt.Errorf("tournament %s not found", webhook.TournamentID)
+		}
+		return fmt.Errorf("checking tournament %s: %w", webhook.TournamentID, err)
+	}
+	if _, err := f.webhooks(webhook.TournamentID).Doc(webhook.ID).Create(ctx, webhook); err != nil {
+		return fmt.Errorf("creating webhook: %w", err)
+	}
+	return nil
+}
+
+func (f *FirestoreStore) ListWebhooks(ctx context.Context, tournamentID string) ([]*models.Webhook, error) {
+	iter := f.webhooks(tournamentID).OrderBy("CreatedAt", firestore.Asc).Documents(ctx)
+	defer iter.Stop()
+
+	result := make([]*models.Webhook, 0)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing webhooks: %w", err)
+		}
+
+		var wh models.Webhook
+		if err := doc.DataTo(&wh); err != nil {
+			continue
+		}
+		result = append(result, &wh)
+	}
+	return result, nil
+}
+
+func (f *FirestoreStore) DeleteWebhook(ctx context.Context, tournamentID, webhookID string) error {
+	ref := f.webhooks(tournamentID).Doc(webhookID)
+	if _, err := ref.Get(ctx); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return fmt.Errorf("webhook %s not found", webhookID)
+		}
+		return fmt.Errorf("getting webhook %s: %w", webhookID, err)
+	}
+	if _, err := ref.Delete(ctx); err != nil {
+		return fmt.Errorf("deleting webhook %s: %w", webhookID, err)
+	}
+	return nil
+}
+
+// deliveryDoc is the Firestore form of a webhook delivery, with the payload
+// kept as JSON text for the same reason as auditDoc.
+type deliveryDoc struct {
+	ID            string
+	WebhookID     string
+	TournamentID  string
+	Event         models.WebhookEvent
+	Payload       string
+	Attempts      int
+	StatusCode    int
+	Error         string
+	Delivered     bool
+	CreatedAt     time.Time
+	LastAttemptAt time.Time
+}
+
+func (f *FirestoreStore) SaveWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	doc := deliveryDoc{
+		ID:            delivery.ID,
+		WebhookID:     delivery.WebhookID,
+		TournamentID:  delivery.TournamentID,
+		Event:         delivery.Event,
+		Payload:       string(delivery.Payload),
+		Attempts:      delivery.Attempts,
+		StatusCode:    delivery.StatusCode,
+		Error:         delivery.Error,
+		Delivered:     delivery.Delivered,
+		CreatedAt:     delivery.CreatedAt,
+		LastAttemptAt: delivery.LastAttemptAt,
+	}
+	if _, err := f.webhookDeliveries(delivery.TournamentID).Doc(delivery.ID).Set(ctx, doc); err != nil {
+		return fmt.Errorf("saving webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (f *FirestoreStore) ListWebhookDeliveries(ctx context.Context, tournamentID, webhookID string) ([]*models.WebhookDelivery, error) {
+	iter := f.webhookDeliveries(tournamentID).Where("WebhookID", "==", webhookID).Documents(ctx)
+	defer iter.Stop()
+
+	result := make([]*models.WebhookDelivery, 0)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing webhook deliveries: %w", err)
+		}
+
+		var d deliveryDoc
+		if err := doc.DataTo(&d); err != nil {
+			continue
+		}
+		result = append(result, &models.WebhookDelivery{
+			ID:            d.ID,
+			WebhookID:     d.WebhookID,
+			TournamentID:  d.TournamentID,
+			Event:         d.Event,
+			Payload:       json.RawMessage(d.Payload),
+			Attempts:      d.Attempts,
+			StatusCode:    d.StatusCode,
+			Error:         d.Error,
+			Delivered:     d.Delivered,
+			CreatedAt:     d.CreatedAt,
+			LastAttemptAt: d.LastAttemptAt,
+		})
+	}
+	// Sorted here rather than in the query so no composite index is needed.
+	sort.Slice(result, func(i, j int) bool { return result[i].CreatedAt.Before(result[j].CreatedAt) })
+	return result, nil
+}
+
+// --- Spectator share tokens ---
+
+// Share tokens live in a top-level collection keyed by token so a spectator's
+// link can be resolved without knowing the tournament.
+
+func (f *FirestoreStore) CreateShareToken(ctx context.Context, share *models.ShareToken) error {
+	if _, err := f.tournaments().Doc(share.TournamentID).Get(ctx); err != nil {
+		if status.Code(err) == codes.NotFound {
+			return fmt.Errorf("tournament %s not found", share.TournamentID)
+		}
+		return fmt.Errorf("checking tournament %s: %w", share.TournamentID, err)
+	}
+	if _, err := f.shareTokens().Doc(share.Token).Create(ctx, share); err != nil {
+		return fmt.Errorf("creating share token: %w", err)
+	}
+	return nil
+}
 
-func (f *FirestoreStore) LinkPlayer(ctx context.Context, tournamentID string, playerID string, email string) error {
-	t, ref, err := f.getTournamentForUpdate(ctx, tournamentID)
+func (f *FirestoreStore) GetShareToken(ctx context.Context, token string) (*models.ShareToken, error) {
+	doc, err := f.shareTokens().Doc(token).Get(ctx)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, fmt.Errorf("share token not found")
+		}
+		return nil, fmt.Errorf("getting share token: %w", err)
+	}
+	var share models.ShareToken
+	if err := doc.DataTo(&share); err != nil {
+		return nil, fmt.Errorf("decoding share token: %w", err)
+	}
+	return &share, nil
+}
+
+func (f *FirestoreStore) ListShareTokens(ctx context.Context, tournamentID string) ([]*models.ShareToken, error) {
+	iter := f.shareTokens().Where("TournamentID", "==", tournamentID).Documents(ctx)
+	defer iter.Stop()
+
+	result := make([]*models.ShareToken, 0)
+	for {
+		doc, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing share tokens: %w", err)
+		}
+
+		var share models.ShareToken
+		if err := doc.DataTo(&share); err != nil {
+			continue
+		}
+		result = append(result, &share)
+	}
+	sortShareTokens(result)
+	return result, nil
+}
+
+func (f *FirestoreStore) DeleteShareToken(ctx context.Context, tournamentID, token string) error {
+	share, err := f.GetShareToken(ctx, token)
 	if err != nil {
 		return err
 	}
+	if share.TournamentID != tournamentID {
+		return fmt.Errorf("share token not found")
+	}
+	if _, err := f.shareTokens().Doc(token).Delete(ctx); err != nil {
+		return fmt.Errorf("deleting share token: %w", err)
+	}
+	return nil
+}
+
+// --- Player-user linking ---
 
-	for ti := range t.Teams {
-		for pi := range t.Teams[ti].Players {
-			if t.Teams[ti].Players[pi].ID == playerID {
-				t.Teams[ti].Players[pi].UserEmail = email
-				t.UpdatedAt = time.Now()
-				if _, err := ref.Set(ctx, t); err != nil {
-					return fmt.Errorf("updating tournament %s: %w", tournamentID, err)
+func (f *FirestoreStore) LinkPlayer(ctx context.Context, tournamentID string, playerID string, email string, want Precondition) error {
+	return f.updateTournamentTx(ctx, tournamentID, want, func(t *models.Tournament) error {
+		for ti := range t.Teams {
+			for pi := range t.Teams[ti].Players {
+				if t.Teams[ti].Players[pi].ID == playerID {
+					t.Teams[ti].Players[pi].UserEmail = email
+					return nil
 				}
-				return nil
 			}
 		}
-	}
 
-	return fmt.Errorf("player %s not found", playerID)
+		return fmt.Errorf("player %s not found", playerID)
+	})
+}
+
+func (f *FirestoreStore) SaveRanking(ctx context.Context, tournamentID string, ranking models.PlayerRanking, want Precondition) error {
+	return f.updateTournamentTx(ctx, tournamentID, want, func(t *models.Tournament) error {
+		setRanking(t, ranking)
+		return nil
+	})
 }
 
 // --- Local user registration ---
@@ -375,6 +950,14 @@ func (f *FirestoreStore) CreateLocalUser(ctx context.Context, user *models.Local
 	return nil
 }
 
+// ImportLocalUser writes a user as-is, replacing any user with the same email.
+func (f *FirestoreStore) ImportLocalUser(ctx context.Context, user *models.LocalUser) error {
+	if _, err := f.localUsers().Doc(strings.ToLower(user.Email)).Set(ctx, user); err != nil {
+		return fmt.Errorf("importing user %s: %w", user.Email, err)
+	}
+	return nil
+}
+
 func (f *FirestoreStore) GetLocalUser(ctx context.Context, email string) (*models.LocalUser, error) {
 	key := strings.ToLower(email)
 	doc, err := f.localUsers().Doc(key).Get(ctx)
@@ -393,6 +976,10 @@ func (f *FirestoreStore) GetLocalUser(ctx context.Context, email string) (*model
 }
 
 func (f *FirestoreStore) VerifyLocalUser(ctx context.Context, token string) error {
+	// Verified users have an empty token, which must not match.
+	if token == "" {
+		return fmt.Errorf("invalid verification token")
+	}
 	iter := f.localUsers().Where("VerificationToken", "==", token).Documents(ctx)
 	defer iter.Stop()
 
@@ -489,3 +1076,11 @@ func (f *FirestoreStore) EnableLocalUser(ctx context.Context, email string) erro
 	_, err = ref.Update(ctx, []firestore.Update{{Path: "Disabled", Value: false}})
 	return err
 }
+
+func (f *FirestoreStore) SetCalendarKey(ctx context.Context, email, key string) error {
+	_, err := f.localUsers().Doc(strings.ToLower(email)).Update(ctx, []firestore.Update{{Path: "CalendarKey", Value: key}})
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("user not found")
+	}
+	return err
+}
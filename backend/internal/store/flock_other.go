@@ -0,0 +1,12 @@
+//go:build !unix && !windows
+
+package store
+
+import "os"
+
+// Platforms without file locking only get the in-process lock, so a data
+// directory must not be shared between processes there.
+
+func lockFile(*os.File, bool) error { return nil }
+
+func unlockFile(*os.File) error { return nil }
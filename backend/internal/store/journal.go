@@ -0,0 +1,675 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/schema"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The file store journals every tournament write before making it:
+//
+//	{dir}/_journal/{tournament-id}.jsonl            one entry per write, oldest first
+//	{dir}/_journal/{tournament-id}/{seq}.json       snapshot of the tournament after entry seq
+//
+// An entry holds the state the write produced (the whole tournament, or one
+// match plus the new version) rather than the operation, so replaying entries
+// in order rebuilds the tournament at any point without the code that made
+// them. Snapshots are taken every journalSnapshotEvery entries so that a
+// restore only replays the entries after the nearest one. Tournaments that
+// existed before the journal get a snapshot of their state at entry 0 on
+// their first journaled write.
+//
+// An entry is synced to disk before the data files are touched, and a commit
+// line with the same seq is appended once they are written. An entry without
+// its commit line never took effect: recovery, replays and listings skip it,
+// and a failed write or, on opening the store, a crash mid-write is rolled
+// back by rewriting the data files from the committed entries. The last
+// committed entry is reapplied on opening if the data files don't reflect
+// it. Entries written before commit lines existed aren't marked pending and
+// count as committed. Journals are kept after a tournament is deleted, so
+// deleted tournaments can be restored too, though without the audit log,
+// webhooks and share tokens deleted along with them.
+//
+// While a write is under way {dir}/_journal/.unfinished holds its
+// tournament's ID. Taking the store's lock settles the tournament it names,
+// so a process that dies mid-write is cleaned up after by the next one to
+// take the lock rather than only by the next to open the store. The marker
+// isn't synced: after a power loss, opening the store checks every journal.
+
+// journalSnapshotEvery is the number of entries between snapshots.
+const journalSnapshotEvery = 100
+
+const (
+	journalPut    = "put"    // Tournament replaced
+	journalMatch  = "match"  // one match of the tournament replaced
+	journalDelete = "delete" // tournament deleted
+	journalCommit = "commit" // the pending entry with the same seq took effect
+)
+
+type journalEntry struct {
+	Seq int64     `json:"seq"`
+	At  time.Time `json:"at"`
+	Op  string    `json:"op"`
+	// Version and UpdatedAt are the tournament's after a match write.
+	Version       int64              `json:"version,omitempty"`
+	UpdatedAt     time.Time          `json:"updatedAt,omitzero"`
+	Tournament    *models.Tournament `json:"tournament,omitempty"`
+	Match         *matchRecord       `json:"match,omitempty"`
+	SchemaVersion int                `json:"schemaVersion,omitempty"`
+	// Pending entries only take effect once followed by their commit line.
+	Pending bool `json:"pending,omitempty"`
+}
+
+type journalSnapshot struct {
+	Seq           int64              `json:"seq"`
+	At            time.Time          `json:"at"`
+	Tournament    *models.Tournament `json:"tournament"`
+	SchemaVersion int                `json:"schemaVersion,omitempty"`
+}
+
+// journalState caches the tail of a tournament's journal. It is reread when
+// the journal's size no longer matches, e.g. after another process wrote it.
+type journalState struct {
+	size     int64
+	seq      int64
+	snapshot int64 // seq of the latest snapshot
+}
+
+// JournalEntry summarizes one journaled write, for choosing a restore point.
+type JournalEntry struct {
+	Seq     int64     `json:"seq"`
+	At      time.Time `json:"at"`
+	Op      string    `json:"op"`
+	Version int64     `json:"version,omitempty"`
+	MatchID string    `json:"matchId,omitempty"`
+}
+
+func (f *FileStore) journalPath(tournamentID string) string {
+	return filepath.Join(f.dir, "_journal", tournamentID+".jsonl")
+}
+
+func (f *FileStore) snapshotDir(tournamentID string) string {
+	return filepath.Join(f.dir, "_journal", tournamentID)
+}
+
+// writeTournament journals t and then writes it in full.
+func (f *FileStore) writeTournament(t *models.Tournament) error {
+	if !isSafeFileID(t.ID) {
+		return fmt.Errorf("invalid tournament id %q", t.ID)
+	}
+	e := &journalEntry{Op: journalPut, Tournament: t}
+	if err := f.appendJournal(t.ID, e); err != nil {
+		return err
+	}
+	if err := f.commit(t.ID, e, f.writeTournamentFiles(t)); err != nil {
+		return err
+	}
+	f.maybeSnapshot(t, e)
+	return nil
+}
+
+// writeMatch journals one match of t and then writes it along with the
+// tournament header.
+func (f *FileStore) writeMatch(t *models.Tournament, embedded bool, roundIdx, matchIdx int) error {
+	if embedded {
+		return f.writeTournament(t)
+	}
+	e := &journalEntry{
+		Op:        journalMatch,
+		Version:   t.Version,
+		UpdatedAt: t.UpdatedAt,
+		Match: &matchRecord{
+			Round:    t.Rounds[roundIdx].Number,
+			Position: matchIdx,
+			Match:    t.Rounds[roundIdx].Matches[matchIdx],
+		},
+	}
+	if err := f.appendJournal(t.ID, e); err != nil {
+		return err
+	}
+	if err := f.commit(t.ID, e, f.writeMatchFiles(t, roundIdx, matchIdx)); err != nil {
+		return err
+	}
+	f.maybeSnapshot(t, e)
+	return nil
+}
+
+func (f *FileStore) unfinishedPath() string {
+	return filepath.Join(f.dir, "_journal", ".unfinished")
+}
+
+// recoverUnfinished settles the write named by the unfinished marker, if
+// any, and removes the marker. The exclusive lock must be held.
+func (f *FileStore) recoverUnfinished() error {
+	data, err := os.ReadFile(f.unfinishedPath())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading unfinished write marker: %w", err)
+	}
+	if id := string(data); isSafeFileID(id) {
+		if err := f.recoverJournal(id); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(f.unfinishedPath()); err != nil {
+		return fmt.Errorf("removing unfinished write marker: %w", err)
+	}
+	return nil
+}
+
+// deleteTournament journals the deletion of a tournament and then removes
+// its files.
+func (f *FileStore) deleteTournament(id string) error {
+	e := &journalEntry{Op: journalDelete}
+	if err := f.appendJournal(id, e); err != nil {
+		return err
+	}
+	return f.commit(id, e, f.removeTournamentFiles(id))
+}
+
+// commit appends the commit line of the pending entry e once its data files
+// are written, or, if writing them failed with err, rolls the files back to
+// the last committed state and returns err. The unfinished marker is removed
+// once the tournament is settled either way.
+func (f *FileStore) commit(tournamentID string, e *journalEntry, err error) error {
+	if err == nil {
+		err = f.appendLine(tournamentID, &journalEntry{Seq: e.Seq, At: time.Now(), Op: journalCommit})
+	}
+	if err != nil {
+		if rerr := f.rollBack(tournamentID); rerr != nil {
+			return fmt.Errorf("%w (rolling back: %v)", err, rerr)
+		}
+	}
+	if rerr := os.Remove(f.unfinishedPath()); rerr != nil && err == nil {
+		return fmt.Errorf("removing unfinished write marker: %w", rerr)
+	}
+	return err
+}
+
+// rollBack rewrites a tournament's data files as of its last committed
+// journal entry, removing them if it didn't exist then.
+func (f *FileStore) rollBack(tournamentID string) error {
+	t, err := f.replay(tournamentID, time.Time{})
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		if _, err := os.Stat(f.path(tournamentID)); os.IsNotExist(err) {
+			return nil
+		}
+		return f.removeTournamentFiles(tournamentID)
+	}
+	return f.writeTournamentFiles(t)
+}
+
+// appendJournal assigns e the next sequence number and appends it, synced
+// and pending, to the tournament's journal.
+func (f *FileStore) appendJournal(tournamentID string, e *journalEntry) error {
+	st, err := f.journalState(tournamentID)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(f.unfinishedPath(), []byte(tournamentID), 0644); err != nil {
+		return fmt.Errorf("writing unfinished write marker: %w", err)
+	}
+	e.Seq = st.seq + 1
+	e.At = time.Now()
+	e.SchemaVersion = schema.Current
+	e.Pending = true
+	return f.appendLine(tournamentID, e)
+}
+
+// appendLine appends e, synced, to the tournament's journal.
+func (f *FileStore) appendLine(tournamentID string, e *journalEntry) error {
+	st, err := f.journalState(tournamentID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding journal entry: %w", err)
+	}
+
+	p := f.journalPath(tournamentID)
+	file, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("opening journal %s: %w", tournamentID, err)
+	}
+	defer file.Close()
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing journal %s: %w", tournamentID, err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("syncing journal %s: %w", tournamentID, err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("writing journal %s: %w", tournamentID, err)
+	}
+	st.seq = e.Seq
+	st.size = info.Size()
+	return nil
+}
+
+// journalState returns the cached tail of a journal, reading it if needed.
+// Starting the journal of a tournament that already exists snapshots its
+// current state first.
+func (f *FileStore) journalState(tournamentID string) (*journalState, error) {
+	info, err := os.Stat(f.journalPath(tournamentID))
+	if os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(f.journalPath(tournamentID)), 0755); err != nil {
+			return nil, fmt.Errorf("creating journal directory: %w", err)
+		}
+		st := &journalState{}
+		if t, err := f.readTournament(tournamentID); err == nil {
+			if err := f.writeSnapshot(&journalSnapshot{Seq: 0, At: t.UpdatedAt, Tournament: t}); err != nil {
+				return nil, err
+			}
+		}
+		f.journals[tournamentID] = st
+		return st, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading journal %s: %w", tournamentID, err)
+	}
+	if st, ok := f.journals[tournamentID]; ok && st.size == info.Size() {
+		return st, nil
+	}
+
+	entries, err := f.readJournal(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	st := &journalState{size: info.Size()}
+	if len(entries) > 0 {
+		st.seq = entries[len(entries)-1].Seq
+	}
+	if seqs, err := f.snapshotSeqs(tournamentID); err == nil && len(seqs) > 0 {
+		st.snapshot = seqs[len(seqs)-1]
+	}
+	f.journals[tournamentID] = st
+	return st, nil
+}
+
+// maybeSnapshot snapshots t if enough entries have been written since the
+// last snapshot. A failed snapshot only means a longer replay on restore, so
+// it is retried on the next write rather than failing this one.
+func (f *FileStore) maybeSnapshot(t *models.Tournament, e *journalEntry) {
+	st := f.journals[t.ID]
+	if st == nil || e.Seq-st.snapshot < journalSnapshotEvery {
+		return
+	}
+	if f.writeSnapshot(&journalSnapshot{Seq: e.Seq, At: e.At, Tournament: t}) == nil {
+		st.snapshot = e.Seq
+	}
+}
+
+func (f *FileStore) writeSnapshot(s *journalSnapshot) error {
+	s.SchemaVersion = schema.Current
+	dir := f.snapshotDir(s.Tournament.ID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("creating snapshot directory: %w", err)
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("encoding snapshot: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, fmt.Sprintf("%09d.json", s.Seq)), data); err != nil {
+		return fmt.Errorf("writing snapshot of tournament %s: %w", s.Tournament.ID, err)
+	}
+	return nil
+}
+
+// snapshotSeqs lists the sequence numbers of a tournament's snapshots in
+// ascending order.
+func (f *FileStore) snapshotSeqs(tournamentID string) ([]int64, error) {
+	entries, err := os.ReadDir(f.snapshotDir(tournamentID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("listing snapshots of tournament %s: %w", tournamentID, err)
+	}
+	var seqs []int64
+	for _, entry := range entries {
+		var seq int64
+		if _, err := fmt.Sscanf(entry.Name(), "%d.json", &seq); err == nil && filepath.Ext(entry.Name()) == ".json" {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Slice(seqs, func(i, j int) bool { return seqs[i] < seqs[j] })
+	return seqs, nil
+}
+
+func (f *FileStore) readSnapshot(tournamentID string, seq int64) (*journalSnapshot, error) {
+	data, err := os.ReadFile(filepath.Join(f.snapshotDir(tournamentID), fmt.Sprintf("%09d.json", seq)))
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot %d of tournament %s: %w", seq, tournamentID, err)
+	}
+	var s journalSnapshot
+	if err := decodeVersioned(data, &s, upgradeJournalDoc); err != nil {
+		return nil, fmt.Errorf("decoding snapshot %d of tournament %s: %w", seq, tournamentID, err)
+	}
+	return &s, nil
+}
+
+// readJournal reads every entry of a tournament's journal. A torn trailing
+// line, left by a crash while appending, is skipped.
+func (f *FileStore) readJournal(tournamentID string) ([]*journalEntry, error) {
+	file, err := os.Open(f.journalPath(tournamentID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading journal %s: %w", tournamentID, err)
+	}
+	defer file.Close()
+
+	var entries []*journalEntry
+	var bad error
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if bad != nil {
+			return nil, bad
+		}
+		var e journalEntry
+		if err := decodeVersioned(scanner.Bytes(), &e, upgradeJournalDoc); err != nil {
+			bad = fmt.Errorf("decoding journal %s after entry %d: %w", tournamentID, len(entries), err)
+			continue
+		}
+		entries = append(entries, &e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading journal %s: %w", tournamentID, err)
+	}
+	return entries, nil
+}
+
+// committedEntries drops commit lines and pending entries that weren't
+// committed from a journal.
+func committedEntries(journal []*journalEntry) []*journalEntry {
+	done := make(map[int64]bool)
+	for _, e := range journal {
+		if e.Op == journalCommit {
+			done[e.Seq] = true
+		}
+	}
+	entries := make([]*journalEntry, 0, len(journal))
+	for _, e := range journal {
+		if e.Op != journalCommit && (!e.Pending || done[e.Seq]) {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// upgradeJournalDoc upgrades the tournament or match inside an encoded
+// journal entry or snapshot.
+func upgradeJournalDoc(doc schema.Doc, from int) error {
+	if t, ok := doc["tournament"].(schema.Doc); ok {
+		if err := schema.UpgradeTournament(t, from); err != nil {
+			return err
+		}
+	}
+	if _, ok := doc["match"].(schema.Doc); ok {
+		return upgradeMatchRecord(doc, from)
+	}
+	return nil
+}
+
+// applyJournalEntry returns the tournament as it is after e, given t as it
+// was before (nil if it didn't exist). t may be modified.
+func applyJournalEntry(t *models.Tournament, e *journalEntry) *models.Tournament {
+	switch e.Op {
+	case journalPut:
+		return e.Tournament
+	case journalDelete:
+		return nil
+	case journalMatch:
+		if t == nil || e.Match == nil {
+			return t
+		}
+		placeMatch(t, *e.Match)
+		t.Version = e.Version
+		t.UpdatedAt = e.UpdatedAt
+	}
+	return t
+}
+
+// placeMatch replaces the match in t with rec's, or inserts it at rec's
+// position if the round doesn't have it.
+func placeMatch(t *models.Tournament, rec matchRecord) {
+	for i := range t.Rounds {
+		round := &t.Rounds[i]
+		if round.Number != rec.Round {
+			continue
+		}
+		for j := range round.Matches {
+			if round.Matches[j].ID == rec.Match.ID {
+				round.Matches[j] = rec.Match
+				return
+			}
+		}
+		pos := min(max(rec.Position, 0), len(round.Matches))
+		round.Matches = append(round.Matches[:pos], append([]models.Match{rec.Match}, round.Matches[pos:]...)...)
+		return
+	}
+}
+
+// recoverJournals settles writes a crash interrupted, in every journal.
+func (f *FileStore) recoverJournals() error {
+	entries, err := os.ReadDir(filepath.Join(f.dir, "_journal"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("listing journals: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".jsonl" {
+			continue
+		}
+		if err := f.recoverJournal(strings.TrimSuffix(entry.Name(), ".jsonl")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recoverJournal settles a write to one tournament that was interrupted. A
+// torn trailing line is cut off so later entries start on a line of their
+// own. A journal ending in an uncommitted entry has its tournament rolled
+// back to the last committed state; otherwise the last entry is reapplied if
+// the data files don't reflect it.
+func (f *FileStore) recoverJournal(id string) error {
+	if err := f.trimJournal(id); err != nil {
+		return err
+	}
+	journal, err := f.readJournal(id)
+	if err != nil {
+		return err
+	}
+	if len(journal) == 0 {
+		return nil
+	}
+	if last := journal[len(journal)-1]; last.Pending {
+		if err := f.rollBack(id); err != nil {
+			return fmt.Errorf("rolling back tournament %s to its journal: %w", id, err)
+		}
+		return nil
+	}
+	journal = committedEntries(journal)
+	if len(journal) == 0 {
+		return nil
+	}
+	last := journal[len(journal)-1]
+	current, readErr := f.readTournament(id)
+	exists := readErr == nil
+
+	switch {
+	case last.Op == journalDelete && exists:
+		err = f.removeTournamentFiles(id)
+	case last.Op == journalPut && (!exists || current.Version != last.Tournament.Version):
+		err = f.writeTournamentFiles(last.Tournament)
+	case last.Op == journalMatch && exists && current.Version != last.Version:
+		err = f.writeTournamentFiles(applyJournalEntry(current, last))
+	}
+	if err != nil {
+		return fmt.Errorf("recovering tournament %s from its journal: %w", id, err)
+	}
+	return nil
+}
+
+// trimJournal truncates a journal after its last complete line.
+func (f *FileStore) trimJournal(id string) error {
+	data, err := os.ReadFile(f.journalPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading journal %s: %w", id, err)
+	}
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return nil
+	}
+	size := bytes.LastIndexByte(data, '\n') + 1
+	if err := os.Truncate(f.journalPath(id), int64(size)); err != nil {
+		return fmt.Errorf("trimming torn entry from journal %s: %w", id, err)
+	}
+	return nil
+}
+
+// Journal lists the journaled writes of a tournament, oldest first.
+func (f *FileStore) Journal(_ context.Context, tournamentID string) ([]JournalEntry, error) {
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	entries, err := f.readJournal(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil {
+		return nil, fmt.Errorf("no journal for tournament %s", tournamentID)
+	}
+	entries = committedEntries(entries)
+	out := make([]JournalEntry, len(entries))
+	for i, e := range entries {
+		out[i] = JournalEntry{Seq: e.Seq, At: e.At, Op: e.Op, Version: e.Version}
+		switch {
+		case e.Tournament != nil:
+			out[i].Version = e.Tournament.Version
+		case e.Match != nil:
+			out[i].MatchID = e.Match.Match.ID
+		}
+	}
+	return out, nil
+}
+
+// TournamentAt rebuilds a tournament as it was at the given time from its
+// journal, starting from the latest snapshot taken by then.
+func (f *FileStore) TournamentAt(_ context.Context, tournamentID string, at time.Time) (*models.Tournament, error) {
+	unlock, err := f.rlock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return f.tournamentAt(tournamentID, at)
+}
+
+func (f *FileStore) tournamentAt(tournamentID string, at time.Time) (*models.Tournament, error) {
+	t, err := f.replay(tournamentID, at)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("tournament %s did not exist at %s", tournamentID, at.Format(time.RFC3339))
+	}
+	normalizeTournament(t)
+	return t, nil
+}
+
+// replay rebuilds a tournament from its committed journal entries up to the
+// given time, or all of them if at is zero. It returns nil if the tournament
+// didn't exist then.
+func (f *FileStore) replay(tournamentID string, at time.Time) (*models.Tournament, error) {
+	seqs, err := f.snapshotSeqs(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := f.readJournal(tournamentID)
+	if err != nil {
+		return nil, err
+	}
+	if entries == nil && seqs == nil {
+		return nil, fmt.Errorf("no journal for tournament %s", tournamentID)
+	}
+	latest := at.IsZero()
+
+	var t *models.Tournament
+	var from int64 = -1
+	for i := len(seqs) - 1; i >= 0; i-- {
+		s, err := f.readSnapshot(tournamentID, seqs[i])
+		if err != nil {
+			return nil, err
+		}
+		if latest || !s.At.After(at) {
+			t, from = s.Tournament, s.Seq
+			break
+		}
+	}
+	for _, e := range committedEntries(entries) {
+		if e.Seq <= from {
+			continue
+		}
+		if !latest && e.At.After(at) {
+			break
+		}
+		t = applyJournalEntry(t, e)
+	}
+	return t, nil
+}
+
+// RestoreTournament writes a tournament back as it was at the given time.
+// The restore is itself a journaled write with a new version, so clients
+// holding the current version see a conflict and it can be undone the same
+// way.
+func (f *FileStore) RestoreTournament(_ context.Context, tournamentID string, at time.Time) (*models.Tournament, error) {
+	unlock, err := f.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	t, err := f.tournamentAt(tournamentID, at)
+	if err != nil {
+		return nil, err
+	}
+	if current, err := f.readTournament(tournamentID); err == nil && current.Version > t.Version {
+		t.Version = current.Version
+	}
+	t.Version++
+	t.UpdatedAt = time.Now()
+	if err := f.writeTournament(t); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
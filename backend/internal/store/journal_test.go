@@ -0,0 +1,400 @@
+package store
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"scoring-backend/internal/models"
+)
+
+// newJournalTournament returns an unsaved tournament with one singles match.
+func newJournalTournament() *models.Tournament {
+	t := &models.Tournament{ID: "t-journal", Name: "v1"}
+	t.Teams[0] = models.Team{ID: "team-1", Name: "Europe"}
+	t.Teams[1] = models.Team{ID: "team-2", Name: "USA"}
+	t.Rounds = []models.Round{{Number: 1, Name: "Singles", Type: models.RoundSingles, PointsPerMatch: 1, Matches: []models.Match{{
+		ID:          "m-1",
+		RoundNumber: 1,
+		Result:      models.ResultPending,
+		HoleResults: map[string]string{},
+		Version:     1,
+	}}}}
+	return t
+}
+
+func openFileStore(t *testing.T, dir string) *FileStore {
+	t.Helper()
+	fs, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	return fs
+}
+
+// rename writes the tournament back under a new name, as an edit would.
+func rename(t *testing.T, fs *FileStore, id, name string) *models.Tournament {
+	t.Helper()
+	ctx := context.Background()
+	tour, err := fs.GetTournament(ctx, id)
+	if err != nil {
+		t.Fatalf("GetTournament: %v", err)
+	}
+	tour.Name = name
+	if err := fs.UpdateTournament(ctx, tour); err != nil {
+		t.Fatalf("UpdateTournament: %v", err)
+	}
+	return tour
+}
+
+// mark returns a time after every write made so far and before any made
+// later.
+func mark() time.Time {
+	at := time.Now()
+	time.Sleep(time.Millisecond)
+	return at
+}
+
+func assertState(t *testing.T, got *models.Tournament, err error, name string, version int64) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("got error %v, want %s at version %d", err, name, version)
+	}
+	if got.Name != name || got.Version != version {
+		t.Errorf("got %s at version %d, want %s at version %d", got.Name, got.Version, name, version)
+	}
+}
+
+func TestJournalRollsBackUncommittedWrite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fs := openFileStore(t, dir)
+	tour := newJournalTournament()
+	if err := fs.CreateTournament(ctx, tour); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.UpdateHoleResult(ctx, tour.ID, 1, "m-1", 1, "team1", Precondition{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A crash after the entry was journaled and the header written, but
+	// before the match file and the commit line.
+	torn, _ := fs.GetTournament(ctx, tour.ID)
+	torn.Name = "torn"
+	torn.Version = 99
+	torn.Rounds[0].Matches[0].HoleResults["2"] = "team2"
+	if err := fs.appendJournal(tour.ID, &journalEntry{Op: journalPut, Tournament: torn}); err != nil {
+		t.Fatal(err)
+	}
+	header, _ := splitTournament(torn)
+	if err := fs.writeHeader(header); err != nil {
+		t.Fatal(err)
+	}
+
+	fs = openFileStore(t, dir)
+	got, err := fs.GetTournament(ctx, tour.ID)
+	assertState(t, got, err, "v1", 2)
+	if holes := got.Rounds[0].Matches[0].HoleResults; len(holes) != 1 || holes["1"] != "team1" {
+		t.Errorf("hole results = %v, want only hole 1", holes)
+	}
+	at, err := fs.TournamentAt(ctx, tour.ID, time.Now())
+	assertState(t, at, err, "v1", 2)
+
+	entries, err := fs.Journal(ctx, tour.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Op != journalPut || entries[1].Op != journalMatch {
+		t.Errorf("journal = %+v, want the create and the hole result", entries)
+	}
+
+	// The uncommitted seq is not reused, so a later commit line can't be
+	// taken for it.
+	rename(t, fs, tour.ID, "v3")
+	got, err = fs.TournamentAt(ctx, tour.ID, time.Now())
+	assertState(t, got, err, "v3", 3)
+}
+
+func TestJournalRollsBackUncommittedCreate(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fs := openFileStore(t, dir)
+	tour := newJournalTournament()
+	if err := fs.appendJournal(tour.ID, &journalEntry{Op: journalPut, Tournament: tour}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.writeTournamentFiles(tour); err != nil {
+		t.Fatal(err)
+	}
+
+	fs = openFileStore(t, dir)
+	if _, err := fs.GetTournament(ctx, tour.ID); err == nil {
+		t.Error("uncommitted tournament survived recovery")
+	}
+	if _, err := fs.TournamentAt(ctx, tour.ID, time.Now()); err == nil {
+		t.Error("TournamentAt rebuilt an uncommitted tournament")
+	}
+}
+
+func TestJournalReappliesCommittedWrite(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fs := openFileStore(t, dir)
+	tour := newJournalTournament()
+	if err := fs.CreateTournament(ctx, tour); err != nil {
+		t.Fatal(err)
+	}
+
+	// The entry and its commit line reached the disk but the data files
+	// didn't.
+	next := newJournalTournament()
+	next.Name, next.Version = "v2", 2
+	e := &journalEntry{Op: journalPut, Tournament: next}
+	if err := fs.appendJournal(tour.ID, e); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.appendLine(tour.ID, &journalEntry{Seq: e.Seq, At: time.Now(), Op: journalCommit}); err != nil {
+		t.Fatal(err)
+	}
+
+	fs = openFileStore(t, dir)
+	got, err := fs.GetTournament(ctx, tour.ID)
+	assertState(t, got, err, "v2", 2)
+}
+
+func TestJournalPreCommitEntriesCount(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	fs := openFileStore(t, dir)
+	tour := newJournalTournament()
+	if err := fs.CreateTournament(ctx, tour); err != nil {
+		t.Fatal(err)
+	}
+	before := mark()
+	rename(t, fs, tour.ID, "v2")
+
+	// Strip the pending flags and commit lines, as a journal written before
+	// them would be.
+	data, err := os.ReadFile(fs.journalPath(tour.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		if !strings.Contains(line, `"op":"commit"`) {
+			lines = append(lines, strings.Replace(line, `,"pending":true`, "", 1))
+		}
+	}
+	if err := os.WriteFile(fs.journalPath(tour.ID), []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fs = openFileStore(t, dir)
+	got, err := fs.GetTournament(ctx, tour.ID)
+	assertState(t, got, err, "v2", 2)
+	got, err = fs.TournamentAt(ctx, tour.ID, before)
+	assertState(t, got, err, "v1", 1)
+}
+
+func TestTournamentAtAndRestore(t *testing.T) {
+	ctx := context.Background()
+	fs := openFileStore(t, t.TempDir())
+	tour := newJournalTournament()
+	if err := fs.CreateTournament(ctx, tour); err != nil {
+		t.Fatal(err)
+	}
+
+	// Entry 1 is the create; renaming to v2..v150 writes entries 2..150, so
+	// the snapshot after entry 100 holds v100.
+	var marks []time.Time // marks[v] is a time at which the tournament was at version v
+	marks = append(marks, time.Time{}, mark())
+	for v := 2; v <= 150; v++ {
+		rename(t, fs, tour.ID, "v"+strconv.Itoa(v))
+		marks = append(marks, mark())
+	}
+	seqs, err := fs.snapshotSeqs(tour.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seqs) != 1 || seqs[0] != journalSnapshotEvery {
+		t.Fatalf("snapshots = %v, want one after entry %d", seqs, journalSnapshotEvery)
+	}
+
+	for _, v := range []int{1, 50, 99, 100, 101, 150} {
+		got, err := fs.TournamentAt(ctx, tour.ID, marks[v])
+		assertState(t, got, err, "v"+strconv.Itoa(v), int64(v))
+	}
+	if _, err := fs.TournamentAt(ctx, tour.ID, marks[1].Add(-time.Hour)); err == nil {
+		t.Error("TournamentAt before the create succeeded")
+	}
+
+	// Restores from before the snapshot and from after it.
+	got, err := fs.RestoreTournament(ctx, tour.ID, marks[40])
+	assertState(t, got, err, "v40", 151)
+	got, err = fs.RestoreTournament(ctx, tour.ID, marks[120])
+	assertState(t, got, err, "v120", 152)
+	got, err = fs.GetTournament(ctx, tour.ID)
+	assertState(t, got, err, "v120", 152)
+
+	// The snapshot is used: with the entries it covers gone, states after
+	// it still rebuild and states before it no longer do.
+	data, err := os.ReadFile(fs.journalPath(tour.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var kept []string
+	for _, line := range lines {
+		var e journalEntry
+		if err := decodeVersioned([]byte(line), &e, upgradeJournalDoc); err != nil {
+			t.Fatal(err)
+		}
+		if e.Seq > journalSnapshotEvery {
+			kept = append(kept, line)
+		}
+	}
+	if err := os.WriteFile(fs.journalPath(tour.ID), []byte(strings.Join(kept, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	got, err = fs.TournamentAt(ctx, tour.ID, marks[130])
+	assertState(t, got, err, "v130", 130)
+	if _, err := fs.TournamentAt(ctx, tour.ID, marks[50]); err == nil {
+		t.Error("TournamentAt before the snapshot succeeded without the entries")
+	}
+}
+
+func TestRestoreDeletedTournament(t *testing.T) {
+	ctx := context.Background()
+	fs := openFileStore(t, t.TempDir())
+	tour := newJournalTournament()
+	if err := fs.CreateTournament(ctx, tour); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.UpdateHoleResult(ctx, tour.ID, 1, "m-1", 1, "team2", Precondition{}); err != nil {
+		t.Fatal(err)
+	}
+	before := mark()
+	if err := fs.DeleteTournament(ctx, tour.ID); err != nil {
+		t.Fatal(err)
+	}
+	after := mark()
+
+	if _, err := fs.TournamentAt(ctx, tour.ID, after); err == nil {
+		t.Error("TournamentAt after the delete succeeded")
+	}
+	got, err := fs.RestoreTournament(ctx, tour.ID, before)
+	assertState(t, got, err, "v1", 3)
+	got, err = fs.GetTournament(ctx, tour.ID)
+	assertState(t, got, err, "v1", 3)
+	if m := got.Rounds[0].Matches[0]; m.HoleResults["1"] != "team2" || m.Version != 2 {
+		t.Errorf("restored match = %+v, want hole 1 to team2 at version 2", m)
+	}
+}
+
+func TestSharedDirConcurrentWrites(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	stores := []*FileStore{openFileStore(t, dir), openFileStore(t, dir)}
+	tour := newJournalTournament()
+	if err := stores[0].CreateTournament(ctx, tour); err != nil {
+		t.Fatal(err)
+	}
+
+	const writes = 25
+	var wg sync.WaitGroup
+	errs := make(chan error, len(stores)*writes)
+	for i, fs := range stores {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for n := range writes {
+				errs <- fs.UpdateHoleResult(ctx, tour.ID, 1, "m-1", i*9+n%9+1, "team"+strconv.Itoa(i+1), Precondition{})
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("UpdateHoleResult: %v", err)
+		}
+	}
+
+	total := int64(1 + len(stores)*writes)
+	for i, fs := range stores {
+		got, err := fs.GetTournament(ctx, tour.ID)
+		assertState(t, got, err, "v1", total)
+		if v := got.Rounds[0].Matches[0].Version; v != total {
+			t.Errorf("store %d: match version = %d, want %d", i, v, total)
+		}
+		entries, err := fs.Journal(ctx, tour.ID)
+		if err != nil {
+			t.Fatalf("store %d: Journal: %v", i, err)
+		}
+		if int64(len(entries)) != total {
+			t.Errorf("store %d: %d journal entries, want %d", i, len(entries), total)
+		}
+		for j, e := range entries {
+			if e.Seq != int64(j+1) {
+				t.Errorf("store %d: entry %d has seq %d", i, j, e.Seq)
+				break
+			}
+		}
+		at, err := fs.TournamentAt(ctx, tour.ID, time.Now())
+		assertState(t, at, err, "v1", total)
+	}
+}
+
+func TestSharedDirSettlesOtherWritersCrash(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	crashed, other := openFileStore(t, dir), openFileStore(t, dir)
+	tour := newJournalTournament()
+	if err := crashed.CreateTournament(ctx, tour); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first store dies after journaling a rename and writing its
+	// header, without committing it.
+	torn := newJournalTournament()
+	torn.Name, torn.Version = "torn", 2
+	if err := crashed.appendJournal(tour.ID, &journalEntry{Op: journalPut, Tournament: torn}); err != nil {
+		t.Fatal(err)
+	}
+	header, _ := splitTournament(torn)
+	if err := crashed.writeHeader(header); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := other.GetTournament(ctx, tour.ID)
+	assertState(t, got, err, "v1", 1)
+	if _, err := os.Stat(other.unfinishedPath()); !os.IsNotExist(err) {
+		t.Errorf("unfinished marker left after recovery: %v", err)
+	}
+
+	// It dies again partway through appending an entry.
+	if err := os.WriteFile(other.unfinishedPath(), []byte(tour.ID), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.OpenFile(other.journalPath(tour.ID), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString(`{"seq":3,"op":"put","tourn`)
+	file.Close()
+
+	rename(t, other, tour.ID, "v2")
+	entries, err := crashed.Journal(ctx, tour.ID)
+	if err != nil {
+		t.Fatalf("Journal: %v", err)
+	}
+	if len(entries) != 2 || entries[1].Version != 2 {
+		t.Errorf("journal = %+v, want the create and the rename", entries)
+	}
+	got, err = crashed.TournamentAt(ctx, tour.ID, time.Now())
+	assertState(t, got, err, "v2", 2)
+}
@@ -3,8 +3,9 @@ package store
 import (
 	"context"
 	"fmt"
+	"maps"
 	"scoring-backend/internal/models"
-	"strconv"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -15,6 +16,45 @@ type MemoryStore struct {
 	tournaments map[string]*models.Tournament
 	users       map[string]*models.RegisteredUser
 	localUsers  map[string]*models.LocalUser
+	audit       map[string][]*models.AuditEntry
+	webhooks    map[string][]*models.Webhook
+	deliveries  map[string][]*models.WebhookDelivery
+	shares      map[string]*models.ShareToken
+}
+
+// cloneTournament deep-copies a tournament so callers can't change stored
+// state through the slices and maps they get back.
+func cloneTournament(t *models.Tournament) *models.Tournament {
+	c := *t
+	for i := range c.Teams {
+		c.Teams[i].Players = slices.Clone(t.Teams[i].Players)
+	}
+	c.Rounds = slices.Clone(t.Rounds)
+	for i := range c.Rounds {
+		c.Rounds[i].Matches = cloneMatches(t.Rounds[i].Matches)
+	}
+	c.Rankings = slices.Clone(t.Rankings)
+	for i := range c.Rankings {
+		c.Rankings[i].PlayerIDs = slices.Clone(t.Rankings[i].PlayerIDs)
+	}
+	normalizeTournament(&c)
+	return &c
+}
+
+func cloneMatches(matches []models.Match) []models.Match {
+	out := slices.Clone(matches)
+	for i := range out {
+		out[i].Team1Players = slices.Clone(matches[i].Team1Players)
+		out[i].Team2Players = slices.Clone(matches[i].Team2Players)
+		out[i].HoleResults = maps.Clone(matches[i].HoleResults)
+		out[i].Attestations = slices.Clone(matches[i].Attestations)
+		out[i].HoleWrites = maps.Clone(matches[i].HoleWrites)
+		if w := matches[i].ResultWrite; w != nil {
+			copied := *w
+			out[i].ResultWrite = &copied
+		}
+	}
+	return out
 }
 
 func NewMemoryStore() *MemoryStore {
@@ -22,6 +62,10 @@ func NewMemoryStore() *MemoryStore {
 		tournaments: make(map[string]*models.Tournament),
 		users:       make(map[string]*models.RegisteredUser),
 		localUsers:  make(map[string]*models.LocalUser),
+		audit:       make(map[string][]*models.AuditEntry),
+		webhooks:    make(map[string][]*models.Webhook),
+		deliveries:  make(map[string][]*models.WebhookDelivery),
+		shares:      make(map[string]*models.ShareToken),
 	}
 }
 
@@ -36,10 +80,20 @@ func (m *MemoryStore) CreateTournament(_ context.Context, t *models.Tournament)
 	now := time.Now()
 	t.CreatedAt = now
 	t.UpdatedAt = now
+	t.Version = 1
 
 	// Deep copy to avoid external mutation
-	copied := *t
-	m.tournaments[t.ID] = &copied
+	m.tournaments[t.ID] = cloneTournament(t)
+	return nil
+}
+
+// ImportTournament stores a tournament as-is, keeping its timestamps and
+// version, and replaces any existing tournament with the same ID.
+func (m *MemoryStore) ImportTournament(_ context.Context, t *models.Tournament) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.tournaments[t.ID] = cloneTournament(t)
 	return nil
 }
 
@@ -52,21 +106,24 @@ func (m *MemoryStore) GetTournament(_ context.Context, id string) (*models.Tourn
 		return nil, fmt.Errorf("tournament %s not found", id)
 	}
 
-	copied := *t
-	return &copied, nil
+	return cloneTournament(t), nil
 }
 
 func (m *MemoryStore) UpdateTournament(_ context.Context, t *models.Tournament) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if _, ok := m.tournaments[t.ID]; !ok {
+	existing, ok := m.tournaments[t.ID]
+	if !ok {
 		return fmt.Errorf("tournament %s not found", t.ID)
 	}
+	if existing.Version != t.Version {
+		return &VersionConflictError{TournamentID: t.ID, Current: existing.Version}
+	}
 
 	t.UpdatedAt = time.Now()
-	copied := *t
-	m.tournaments[t.ID] = &copied
+	t.Version++
+	m.tournaments[t.ID] = cloneTournament(t)
 	return nil
 }
 
@@ -76,8 +133,7 @@ func (m *MemoryStore) ListTournaments(_ context.Context) ([]*models.Tournament,
 
 	result := make([]*models.Tournament, 0, len(m.tournaments))
 	for _, t := range m.tournaments {
-		copied := *t
-		result = append(result, &copied)
+		result = append(result, cloneTournament(t))
 	}
 	return result, nil
 }
@@ -91,10 +147,18 @@ func (m *MemoryStore) DeleteTournament(_ context.Context, id string) error {
 	}
 
 	delete(m.tournaments, id)
+	delete(m.audit, id)
+	delete(m.webhooks, id)
+	delete(m.deliveries, id)
+	for token, share := range m.shares {
+		if share.TournamentID == id {
+			delete(m.shares, token)
+		}
+	}
 	return nil
 }
 
-func (m *MemoryStore) UpdateMatchResult(_ context.Context, tournamentID string, roundNumber int, matchID string, result models.MatchResult, score string) error {
+func (m *MemoryStore) UpdateMatchResult(_ context.Context, tournamentID string, roundNumber int, matchID string, result models.MatchResult, score string, want Precondition) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -102,6 +166,9 @@ func (m *MemoryStore) UpdateMatchResult(_ context.Context, tournamentID string,
 	if !ok {
 		return fmt.Errorf("tournament %s not found", tournamentID)
 	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
 
 	for i := range t.Rounds {
 		if t.Rounds[i].Number != roundNumber {
@@ -109,9 +176,13 @@ func (m *MemoryStore) UpdateMatchResult(_ context.Context, tournamentID string,
 		}
 		for j := range t.Rounds[i].Matches {
 			if t.Rounds[i].Matches[j].ID == matchID {
-				t.Rounds[i].Matches[j].Result = result
-				t.Rounds[i].Matches[j].Score = score
+				if err := want.checkMatch(tournamentID, &t.Rounds[i].Matches[j]); err != nil {
+					return err
+				}
+				writeMatchResult(&t.Rounds[i].Matches[j], result, score, want)
+				bumpMatch(&t.Rounds[i].Matches[j])
 				t.UpdatedAt = time.Now()
+				t.Version++
 				return nil
 			}
 		}
@@ -121,7 +192,7 @@ func (m *MemoryStore) UpdateMatchResult(_ context.Context, tournamentID string,
 	return fmt.Errorf("round %d not found", roundNumber)
 }
 
-func (m *MemoryStore) SetRoundPairings(_ context.Context, tournamentID string, roundNumber int, matches []models.Match) error {
+func (m *MemoryStore) SetRoundPairings(_ context.Context, tournamentID string, roundNumber int, matches []models.Match, want Precondition) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -129,11 +200,16 @@ func (m *MemoryStore) SetRoundPairings(_ context.Context, tournamentID string, r
 	if !ok {
 		return fmt.Errorf("tournament %s not found", tournamentID)
 	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
 
 	for i := range t.Rounds {
 		if t.Rounds[i].Number == roundNumber {
-			t.Rounds[i].Matches = matches
+			t.Rounds[i].Matches = cloneMatches(matches)
+			writePairings(t.Rounds[i].Matches, want)
 			t.UpdatedAt = time.Now()
+			t.Version++
 			return nil
 		}
 	}
@@ -141,7 +217,7 @@ func (m *MemoryStore) SetRoundPairings(_ context.Context, tournamentID string, r
 	return fmt.Errorf("round %d not found", roundNumber)
 }
 
-func (m *MemoryStore) UpdateHoleResult(_ context.Context, tournamentID string, roundNumber int, matchID string, hole int, result string) error {
+func (m *MemoryStore) UpdateHoleResult(_ context.Context, tournamentID string, roundNumber int, matchID string, hole int, result string, want Precondition) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -149,6 +225,9 @@ func (m *MemoryStore) UpdateHoleResult(_ context.Context, tournamentID string, r
 	if !ok {
 		return fmt.Errorf("tournament %s not found", tournamentID)
 	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
 
 	for i := range t.Rounds {
 		if t.Rounds[i].Number != roundNumber {
@@ -156,25 +235,51 @@ func (m *MemoryStore) UpdateHoleResult(_ context.Context, tournamentID string, r
 		}
 		for j := range t.Rounds[i].Matches {
 			if t.Rounds[i].Matches[j].ID == matchID {
-				match := &t.Rounds[i].Matches[j]
-				if match.HoleResults == nil {
-					match.HoleResults = make(map[string]string)
+				if err := want.checkMatch(tournamentID, &t.Rounds[i].Matches[j]); err != nil {
+					return err
 				}
-				key := strconv.Itoa(hole)
-				if result == "" {
-					delete(match.HoleResults, key)
-				} else {
-					match.HoleResults[key] = result
+				if err := writeHoleResult(t, &t.Rounds[i], &t.Rounds[i].Matches[j], hole, result, want); err != nil {
+					return err
 				}
-				// Backfill any earlier empty holes as halved
-				for h := 1; h < hole; h++ {
-					k := strconv.Itoa(h)
-					if match.HoleResults[k] == "" {
-						match.HoleResults[k] = "halved"
-					}
+				bumpMatch(&t.Rounds[i].Matches[j])
+				t.UpdatedAt = time.Now()
+				t.Version++
+				return nil
+			}
+		}
+		return fmt.Errorf("match %s not found in round %d", matchID, roundNumber)
+	}
+
+	return fmt.Errorf("round %d not found", roundNumber)
+}
+
+func (m *MemoryStore) AttestMatch(_ context.Context, tournamentID string, roundNumber int, matchID string, attestation models.Attestation, want Precondition) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tournaments[tournamentID]
+	if !ok {
+		return fmt.Errorf("tournament %s not found", tournamentID)
+	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
+
+	for i := range t.Rounds {
+		if t.Rounds[i].Number != roundNumber {
+			continue
+		}
+		for j := range t.Rounds[i].Matches {
+			if t.Rounds[i].Matches[j].ID == matchID {
+				if err := want.checkMatch(tournamentID, &t.Rounds[i].Matches[j]); err != nil {
+					return err
+				}
+				if err := t.Rounds[i].Matches[j].Attest(attestation); err != nil {
+					return err
 				}
-				match.Result, match.Score = models.CalculateMatchPlayResult(match.HoleResults, t.Teams[0].Name, t.Teams[1].Name, t.Rounds[i].HoleCount())
+				bumpMatch(&t.Rounds[i].Matches[j])
 				t.UpdatedAt = time.Now()
+				t.Version++
 				return nil
 			}
 		}
@@ -202,6 +307,151 @@ func (m *MemoryStore) ListRegisteredUsers(_ context.Context) ([]*models.Register
 	return result, nil
 }
 
+func (m *MemoryStore) AppendAuditEntry(_ context.Context, entry *models.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	copied := *entry
+	m.audit[entry.TournamentID] = append(m.audit[entry.TournamentID], &copied)
+	return nil
+}
+
+func (m *MemoryStore) ListAuditEntries(_ context.Context, tournamentID string) ([]*models.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entries := m.audit[tournamentID]
+	result := make([]*models.AuditEntry, 0, len(entries))
+	for _, e := range entries {
+		copied := *e
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) CreateWebhook(_ context.Context, webhook *models.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.tournaments[webhook.TournamentID]; !exists {
+		return fmt.Errorf("tournament %s not found", webhook.TournamentID)
+	}
+	copied := *webhook
+	m.webhooks[webhook.TournamentID] = append(m.webhooks[webhook.TournamentID], &copied)
+	return nil
+}
+
+func (m *MemoryStore) ListWebhooks(_ context.Context, tournamentID string) ([]*models.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	hooks := m.webhooks[tournamentID]
+	result := make([]*models.Webhook, 0, len(hooks))
+	for _, wh := range hooks {
+		copied := *wh
+		result = append(result, &copied)
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) DeleteWebhook(_ context.Context, tournamentID, webhookID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hooks := m.webhooks[tournamentID]
+	for i, wh := range hooks {
+		if wh.ID == webhookID {
+			m.webhooks[tournamentID] = append(hooks[:i:i], hooks[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("webhook %s not found", webhookID)
+}
+
+func (m *MemoryStore) SaveWebhookDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	copied := *delivery
+	deliveries := m.deliveries[delivery.TournamentID]
+	for i, d := range deliveries {
+		if d.ID == delivery.ID {
+			deliveries[i] = &copied
+			return nil
+		}
+	}
+	m.deliveries[delivery.TournamentID] = append(deliveries, &copied)
+	return nil
+}
+
+func (m *MemoryStore) ListWebhookDeliveries(_ context.Context, tournamentID, webhookID string) ([]*models.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*models.WebhookDelivery, 0)
+	for _, d := range m.deliveries[tournamentID] {
+		if d.WebhookID == webhookID {
+			copied := *d
+			result = append(result, &copied)
+		}
+	}
+	return result, nil
+}
+
+func (m *MemoryStore) CreateShareToken(_ context.Context, share *models.ShareToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.tournaments[share.TournamentID]; !exists {
+		return fmt.Errorf("tournament %s not found", share.TournamentID)
+	}
+	if _, exists := m.shares[share.Token]; exists {
+		return fmt.Errorf("share token already exists")
+	}
+	copied := *share
+	m.shares[share.Token] = &copied
+	return nil
+}
+
+func (m *MemoryStore) GetShareToken(_ context.Context, token string) (*models.ShareToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	share, exists := m.shares[token]
+	if !exists {
+		return nil, fmt.Errorf("share token not found")
+	}
+	copied := *share
+	return &copied, nil
+}
+
+func (m *MemoryStore) ListShareTokens(_ context.Context, tournamentID string) ([]*models.ShareToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*models.ShareToken, 0)
+	for _, share := range m.shares {
+		if share.TournamentID == tournamentID {
+			copied := *share
+			result = append(result, &copied)
+		}
+	}
+	sortShareTokens(result)
+	return result, nil
+}
+
+func (m *MemoryStore) DeleteShareToken(_ context.Context, tournamentID, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	share, exists := m.shares[token]
+	if !exists || share.TournamentID != tournamentID {
+		return fmt.Errorf("share token not found")
+	}
+	delete(m.shares, token)
+	return nil
+}
+
 func (m *MemoryStore) CreateLocalUser(_ context.Context, user *models.LocalUser) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -216,6 +466,16 @@ func (m *MemoryStore) CreateLocalUser(_ context.Context, user *models.LocalUser)
 	return nil
 }
 
+// ImportLocalUser stores a user as-is, replacing any user with the same email.
+func (m *MemoryStore) ImportLocalUser(_ context.Context, user *models.LocalUser) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	copied := *user
+	m.localUsers[strings.ToLower(user.Email)] = &copied
+	return nil
+}
+
 func (m *MemoryStore) GetLocalUser(_ context.Context, email string) (*models.LocalUser, error) {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
@@ -230,6 +490,10 @@ func (m *MemoryStore) GetLocalUser(_ context.Context, email string) (*models.Loc
 }
 
 func (m *MemoryStore) VerifyLocalUser(_ context.Context, token string) error {
+	if token == "" {
+		return fmt.Errorf("invalid verification token")
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -297,7 +561,20 @@ func (m *MemoryStore) EnableLocalUser(_ context.Context, email string) error {
 	return nil
 }
 
-func (m *MemoryStore) LinkPlayer(_ context.Context, tournamentID string, playerID string, email string) error {
+func (m *MemoryStore) SetCalendarKey(_ context.Context, email, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.localUsers[strings.ToLower(email)]
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+
+	user.CalendarKey = key
+	return nil
+}
+
+func (m *MemoryStore) LinkPlayer(_ context.Context, tournamentID string, playerID string, email string, want Precondition) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -305,12 +582,16 @@ func (m *MemoryStore) LinkPlayer(_ context.Context, tournamentID string, playerI
 	if !ok {
 		return fmt.Errorf("tournament %s not found", tournamentID)
 	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
 
 	for ti := range t.Teams {
 		for pi := range t.Teams[ti].Players {
 			if t.Teams[ti].Players[pi].ID == playerID {
 				t.Teams[ti].Players[pi].UserEmail = email
 				t.UpdatedAt = time.Now()
+				t.Version++
 				return nil
 			}
 		}
@@ -318,3 +599,22 @@ func (m *MemoryStore) LinkPlayer(_ context.Context, tournamentID string, playerI
 
 	return fmt.Errorf("player %s not found", playerID)
 }
+
+func (m *MemoryStore) SaveRanking(_ context.Context, tournamentID string, ranking models.PlayerRanking, want Precondition) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tournaments[tournamentID]
+	if !ok {
+		return fmt.Errorf("tournament %s not found", tournamentID)
+	}
+	if err := want.check(tournamentID, t.Version); err != nil {
+		return err
+	}
+
+	ranking.PlayerIDs = slices.Clone(ranking.PlayerIDs)
+	setRanking(t, ranking)
+	t.UpdatedAt = time.Now()
+	t.Version++
+	return nil
+}
@@ -0,0 +1,955 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/schema"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps tournaments in relational tables, with each match's
+// hole results in a JSONB column.
+//
+// Locking works at two levels. Writes of a whole tournament take the
+// tournament row FOR UPDATE. Writes of one match take the tournament row FOR
+// KEY SHARE, which many match writers can hold at once but which excludes
+// whole-tournament writers, and then lock only their own match row FOR
+// UPDATE. Scorers on different matches therefore only queue briefly for the
+// version bump at the end of their transaction.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+// NewPostgresStore connects to the database at url (a postgres:// URL or
+// key=value connection string) and applies any pending schema migrations.
+func NewPostgresStore(ctx context.Context, url string) (*PostgresStore, error) {
+	pool, err := pgxpool.New(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to postgres: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("connecting to postgres: %w", err)
+	}
+	if err := migratePostgres(ctx, pool); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return &PostgresStore{pool: pool}, nil
+}
+
+func (p *PostgresStore) Close() error {
+	p.pool.Close()
+	return nil
+}
+
+// pgQuerier is satisfied by both *pgxpool.Pool and pgx.Tx.
+type pgQuerier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// lockTournament locks a tournament row in the given mode ("UPDATE" or "KEY
+// SHARE") and returns its version.
+func lockTournament(ctx context.Context, tx pgx.Tx, id, mode string) (int64, error) {
+	var version int64
+	err := tx.QueryRow(ctx, `SELECT version FROM tournaments WHERE id = $1 FOR `+mode, id).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("tournament %s not found", id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting tournament %s: %w", id, err)
+	}
+	return version, nil
+}
+
+// --- Tournament rows ---
+
+func loadPostgresTournament(ctx context.Context, q pgQuerier, id string) (*models.Tournament, error) {
+	t := &models.Tournament{}
+	var rankings []byte
+	var version int
+	err := q.QueryRow(ctx, `
+		SELECT id, name, header_color, bg_color, locked, combine_rounds23, rankings, rankings_locked, created_at, updated_at, version, schema_version
+		FROM tournaments WHERE id = $1`, id).
+		Scan(&t.ID, &t.Name, &t.HeaderColor, &t.BgColor, &t.Locked, &t.CombineRounds23, &rankings, &t.RankingsLocked, &t.CreatedAt, &t.UpdatedAt, &t.Version, &version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("tournament %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting tournament %s: %w", id, err)
+	}
+	if rankings != nil {
+		if err := json.Unmarshal(rankings, &t.Rankings); err != nil {
+			return nil, fmt.Errorf("decoding rankings of tournament %s: %w", id, err)
+		}
+	}
+
+	if err := loadPostgresTeams(ctx, q, t); err != nil {
+		return nil, err
+	}
+	if err := loadPostgresRounds(ctx, q, t, version); err != nil {
+		return nil, err
+	}
+	normalizeTournament(t)
+	return t, nil
+}
+
+func loadPostgresTeams(ctx context.Context, q pgQuerier, t *models.Tournament) error {
+	rows, err := q.Query(ctx, `SELECT position, id, name, color, logo FROM teams WHERE tournament_id = $1`, t.ID)
+	if err != nil {
+		return fmt.Errorf("getting teams of tournament %s: %w", t.ID, err)
+	}
+	for rows.Next() {
+		var pos int
+		var team models.Team
+		if err := rows.Scan(&pos, &team.ID, &team.Name, &team.Color, &team.Logo); err != nil {
+			rows.Close()
+			return fmt.Errorf("decoding team of tournament %s: %w", t.ID, err)
+		}
+		if pos == 0 || pos == 1 {
+			t.Teams[pos] = team
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("getting teams of tournament %s: %w", t.ID, err)
+	}
+
+	rows, err = q.Query(ctx, `
+		SELECT team_position, id, name, team_id, user_email, handicap
+		FROM players WHERE tournament_id = $1 ORDER BY team_position, position`, t.ID)
+	if err != nil {
+		return fmt.Errorf("getting players of tournament %s: %w", t.ID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pos int
+		var p models.Player
+		if err := rows.Scan(&pos, &p.ID, &p.Name, &p.TeamID, &p.UserEmail, &p.Handicap); err != nil {
+			return fmt.Errorf("decoding player of tournament %s: %w", t.ID, err)
+		}
+		if pos == 0 || pos == 1 {
+			t.Teams[pos].Players = append(t.Teams[pos].Players, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("getting players of tournament %s: %w", t.ID, err)
+	}
+	return nil
+}
+
+const postgresMatchColumns = `id, round_number, position, team1_players, team2_players, result, score, hole_results, attestation_status, attestations, tee_time, version, hole_writes, result_write, schema_version`
+
+func scanPostgresMatch(row pgx.Row) (matchRecord, error) {
+	var rec matchRecord
+	var attestations, holeWrites, resultWrite []byte
+	m := &rec.Match
+	if err := row.Scan(&m.ID, &rec.Round, &rec.Position, &m.Team1Players, &m.Team2Players, &m.Result, &m.Score,
+		&m.HoleResults, &m.AttestationStatus, &attestations, &m.TeeTime, &m.Version, &holeWrites, &resultWrite, &rec.SchemaVersion); err != nil {
+		return rec, err
+	}
+	m.RoundNumber = rec.Round
+	if attestations != nil {
+		if err := json.Unmarshal(attestations, &m.Attestations); err != nil {
+			return rec, fmt.Errorf("decoding attestations of match %s: %w", m.ID, err)
+		}
+	}
+	if holeWrites != nil {
+		if err := json.Unmarshal(holeWrites, &m.HoleWrites); err != nil {
+			return rec, fmt.Errorf("decoding hole writes of match %s: %w", m.ID, err)
+		}
+	}
+	if resultWrite != nil {
+		if err := json.Unmarshal(resultWrite, &m.ResultWrite); err != nil {
+			return rec, fmt.Errorf("decoding result write of match %s: %w", m.ID, err)
+		}
+	}
+	return rec, rec.upgrade()
+}
+
+func loadPostgresRounds(ctx context.Context, q pgQuerier, t *models.Tournament, version int) error {
+	rows, err := q.Query(ctx, `
+		SELECT number, name, type, points_per_match, holes, locked
+		FROM rounds WHERE tournament_id = $1 ORDER BY position`, t.ID)
+	if err != nil {
+		return fmt.Errorf("getting rounds of tournament %s: %w", t.ID, err)
+	}
+	for rows.Next() {
+		var r models.Round
+		if err := rows.Scan(&r.Number, &r.Name, &r.Type, &r.PointsPerMatch, &r.Holes, &r.Locked); err != nil {
+			rows.Close()
+			return fmt.Errorf("decoding round of tournament %s: %w", t.ID, err)
+		}
+		t.Rounds = append(t.Rounds, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("getting rounds of tournament %s: %w", t.ID, err)
+	}
+	// The header is upgraded before the matches, which carry their own
+	// version, are attached.
+	header := tournamentRecord{Tournament: *t, SchemaVersion: version}
+	if err := header.upgrade(); err != nil {
+		return err
+	}
+	*t = header.Tournament
+
+	rows, err = q.Query(ctx, `SELECT `+postgresMatchColumns+` FROM matches WHERE tournament_id = $1`, t.ID)
+	if err != nil {
+		return fmt.Errorf("getting matches of tournament %s: %w", t.ID, err)
+	}
+	defer rows.Close()
+	records := make([]matchRecord, 0)
+	for rows.Next() {
+		rec, err := scanPostgresMatch(rows)
+		if err != nil {
+			return fmt.Errorf("decoding match of tournament %s: %w", t.ID, err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("getting matches of tournament %s: %w", t.ID, err)
+	}
+	joinTournament(t, records)
+	return nil
+}
+
+// writePostgresTournament replaces every row of t. The caller must hold the
+// tournament row FOR UPDATE if it exists.
+func writePostgresTournament(ctx context.Context, tx pgx.Tx, t *models.Tournament) error {
+	rankings, err := nullJSON(t.Rankings)
+	if err != nil {
+		return fmt.Errorf("encoding rankings: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO tournaments (id, name, header_color, bg_color, locked, combine_rounds23, rankings, rankings_locked, created_at, updated_at, version, schema_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (id) DO UPDATE SET
+			name = excluded.name, header_color = excluded.header_color, bg_color = excluded.bg_color,
+			locked = excluded.locked, combine_rounds23 = excluded.combine_rounds23, rankings = excluded.rankings,
+			rankings_locked = excluded.rankings_locked, created_at = excluded.created_at,
+			updated_at = excluded.updated_at, version = excluded.version, schema_version = excluded.schema_version`,
+		t.ID, t.Name, t.HeaderColor, t.BgColor, t.Locked, t.CombineRounds23, rankings, t.RankingsLocked,
+		t.CreatedAt, t.UpdatedAt, t.Version, schema.Current); err != nil {
+		return fmt.Errorf("writing tournament %s: %w", t.ID, err)
+	}
+
+	// Children are replaced wholesale; the tournament row is kept so that its
+	// lock is held throughout.
+	for _, table := range []string{"teams", "players", "rounds", "matches"} {
+		if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE tournament_id = $1`, t.ID); err != nil {
+			return fmt.Errorf("replacing %s of tournament %s: %w", table, t.ID, err)
+		}
+	}
+
+	batch := &pgx.Batch{}
+	for i, team := range t.Teams {
+		batch.Queue(`INSERT INTO teams (tournament_id, position, id, name, color, logo) VALUES ($1, $2, $3, $4, $5, $6)`,
+			t.ID, i, team.ID, team.Name, team.Color, team.Logo)
+		for j, p := range team.Players {
+			batch.Queue(`
+				INSERT INTO players (tournament_id, team_position, position, id, name, team_id, user_email, handicap)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+				t.ID, i, j, p.ID, p.Name, p.TeamID, p.UserEmail, p.Handicap)
+		}
+	}
+	for i, r := range t.Rounds {
+		batch.Queue(`
+			INSERT INTO rounds (tournament_id, number, position, name, type, points_per_match, holes, locked)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			t.ID, r.Number, i, r.Name, r.Type, r.PointsPerMatch, r.Holes, r.Locked)
+		for j := range r.Matches {
+			if err := queuePostgresMatch(batch, t.ID, r.Number, j, &r.Matches[j]); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("writing tournament %s: %w", t.ID, err)
+	}
+	return nil
+}
+
+// queuePostgresMatch queues an insert of m, replacing any stored copy.
+func queuePostgresMatch(batch *pgx.Batch, tournamentID string, roundNumber, position int, m *models.Match) error {
+	holes := make(map[string]string, len(m.HoleResults))
+	for k, v := range m.HoleResults {
+		if v != "" {
+			holes[k] = v
+		}
+	}
+	holeJSON, err := json.Marshal(holes)
+	if err != nil {
+		return fmt.Errorf("encoding hole results of match %s: %w", m.ID, err)
+	}
+	attestations, err := nullJSON(m.Attestations)
+	if err != nil {
+		return fmt.Errorf("encoding attestations of match %s: %w", m.ID, err)
+	}
+	holeWrites, resultWrite, err := matchWritesJSON(m)
+	if err != nil {
+		return err
+	}
+
+	batch.Queue(`
+		INSERT INTO matches (tournament_id, id, round_number, position, team1_players, team2_players, result, score, hole_results, attestation_status, attestations, tee_time, version, hole_writes, result_write, schema_version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT (tournament_id, id) DO UPDATE SET
+			round_number = excluded.round_number, position = excluded.position,
+			team1_players = excluded.team1_players, team2_players = excluded.team2_players,
+			result = excluded.result, score = excluded.score, hole_results = excluded.hole_results,
+			attestation_status = excluded.attestation_status, attestations = excluded.attestations,
+			tee_time = excluded.tee_time, version = excluded.version,
+			hole_writes = excluded.hole_writes, result_write = excluded.result_write, schema_version = excluded.schema_version`,
+		tournamentID, m.ID, roundNumber, position, nonNilStrings(m.Team1Players), nonNilStrings(m.Team2Players),
+		m.Result, m.Score, string(holeJSON), m.AttestationStatus, attestations, m.TeeTime, matchVersion(m), holeWrites, resultWrite, schema.Current)
+	return nil
+}
+
+// updatePostgresTournament loads a tournament under an exclusive row lock,
+// checks want, applies mutate and writes it back with a new version.
+func (p *PostgresStore) updatePostgresTournament(ctx context.Context, tournamentID string, want Precondition, mutate func(t *models.Tournament) error) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		version, err := lockTournament(ctx, tx, tournamentID, "UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := want.check(tournamentID, version); err != nil {
+			return err
+		}
+		t, err := loadPostgresTournament(ctx, tx, tournamentID)
+		if err != nil {
+			return err
+		}
+		if err := mutate(t); err != nil {
+			return err
+		}
+		t.UpdatedAt = pgNow()
+		t.Version++
+		return writePostgresTournament(ctx, tx, t)
+	})
+}
+
+// updatePostgresMatch applies mutate to one match while holding only that
+// match row exclusively. The tournament passed to mutate has its teams and
+// rounds but no matches, and is for reference only. A precondition on the
+// tournament version takes the tournament row exclusively instead, since the
+// version has to stay put until the commit.
+func (p *PostgresStore) updatePostgresMatch(ctx context.Context, tournamentID string, roundNumber int, matchID string, want Precondition, mutate func(t *models.Tournament, round *models.Round, m *models.Match) error) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		mode := "KEY SHARE"
+		if want.Version != 0 {
+			mode = "UPDATE"
+		}
+		version, err := lockTournament(ctx, tx, tournamentID, mode)
+		if err != nil {
+			return err
+		}
+		if err := want.check(tournamentID, version); err != nil {
+			return err
+		}
+
+		header := &models.Tournament{ID: tournamentID}
+		if err := loadPostgresTeams(ctx, tx, header); err != nil {
+			return err
+		}
+		var round models.Round
+		err = tx.QueryRow(ctx, `
+			SELECT number, name, type, points_per_match, holes, locked
+			FROM rounds WHERE tournament_id = $1 AND number = $2`, tournamentID, roundNumber).
+			Scan(&round.Number, &round.Name, &round.Type, &round.PointsPerMatch, &round.Holes, &round.Locked)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("round %d not found", roundNumber)
+		}
+		if err != nil {
+			return fmt.Errorf("getting round %d: %w", roundNumber, err)
+		}
+
+		rec, err := scanPostgresMatch(tx.QueryRow(ctx, `
+			SELECT `+postgresMatchColumns+` FROM matches
+			WHERE tournament_id = $1 AND id = $2 FOR UPDATE`, tournamentID, matchID))
+		if errors.Is(err, pgx.ErrNoRows) || (err == nil && rec.Round != roundNumber) {
+			return fmt.Errorf("match %s not found in round %d", matchID, roundNumber)
+		}
+		if err != nil {
+			return fmt.Errorf("getting match %s: %w", matchID, err)
+		}
+		normalizeMatch(&rec.Match)
+		if err := want.checkMatch(tournamentID, &rec.Match); err != nil {
+			return err
+		}
+
+		if err := mutate(header, &round, &rec.Match); err != nil {
+			return err
+		}
+		bumpMatch(&rec.Match)
+
+		batch := &pgx.Batch{}
+		if err := queuePostgresMatch(batch, tournamentID, rec.Round, rec.Position, &rec.Match); err != nil {
+			return err
+		}
+		batch.Queue(`UPDATE tournaments SET version = version + 1, updated_at = $2 WHERE id = $1`, tournamentID, pgNow())
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return fmt.Errorf("updating match %s: %w", matchID, err)
+		}
+		return nil
+	})
+}
+
+// --- Tournaments ---
+
+func (p *PostgresStore) CreateTournament(ctx context.Context, t *models.Tournament) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		now := pgNow()
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO tournaments (id, name, created_at, updated_at, version) VALUES ($1, $2, $3, $3, 1)
+			ON CONFLICT (id) DO NOTHING`, t.ID, t.Name, now)
+		if err != nil {
+			return fmt.Errorf("creating tournament %s: %w", t.ID, err)
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("tournament %s already exists", t.ID)
+		}
+		t.CreatedAt = now
+		t.UpdatedAt = now
+		t.Version = 1
+		return writePostgresTournament(ctx, tx, t)
+	})
+}
+
+// ImportTournament writes a tournament as-is, keeping its timestamps and
+// version, and replaces any existing tournament with the same ID.
+func (p *PostgresStore) ImportTournament(ctx context.Context, t *models.Tournament) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		// Lock the row if it exists so no match writer is midway through it.
+		var version int64
+		err := tx.QueryRow(ctx, `SELECT version FROM tournaments WHERE id = $1 FOR UPDATE`, t.ID).Scan(&version)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("getting tournament %s: %w", t.ID, err)
+		}
+		return writePostgresTournament(ctx, tx, t)
+	})
+}
+
+func (p *PostgresStore) GetTournament(ctx context.Context, id string) (*models.Tournament, error) {
+	var t *models.Tournament
+	err := pgx.BeginTxFunc(ctx, p.pool, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
+		var err error
+		t, err = loadPostgresTournament(ctx, tx, id)
+		return err
+	})
+	return t, err
+}
+
+// StoredSchemaVersion returns the oldest schema version among the rows of a
+// tournament.
+func (p *PostgresStore) StoredSchemaVersion(ctx context.Context, id string) (int, error) {
+	var version int
+	err := p.pool.QueryRow(ctx, `
+		SELECT LEAST(t.schema_version, (SELECT MIN(schema_version) FROM matches WHERE tournament_id = t.id))
+		FROM tournaments t WHERE t.id = $1`, id).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, fmt.Errorf("tournament %s not found", id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting schema version of tournament %s: %w", id, err)
+	}
+	return version, nil
+}
+
+func (p *PostgresStore) UpdateTournament(ctx context.Context, t *models.Tournament) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		current, err := lockTournament(ctx, tx, t.ID, "UPDATE")
+		if err != nil {
+			return err
+		}
+		if current != t.Version {
+			return &VersionConflictError{TournamentID: t.ID, Current: current}
+		}
+
+		t.UpdatedAt = pgNow()
+		t.Version++
+		return writePostgresTournament(ctx, tx, t)
+	})
+}
+
+func (p *PostgresStore) ListTournaments(ctx context.Context) ([]*models.Tournament, error) {
+	tournaments := make([]*models.Tournament, 0)
+	err := pgx.BeginTxFunc(ctx, p.pool, pgx.TxOptions{IsoLevel: pgx.RepeatableRead, AccessMode: pgx.ReadOnly}, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `SELECT id FROM tournaments ORDER BY created_at, id`)
+		if err != nil {
+			return fmt.Errorf("listing tournaments: %w", err)
+		}
+		ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return fmt.Errorf("listing tournaments: %w", err)
+		}
+		for _, id := range ids {
+			t, err := loadPostgresTournament(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			tournaments = append(tournaments, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tournaments, nil
+}
+
+func (p *PostgresStore) DeleteTournament(ctx context.Context, id string) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, `DELETE FROM tournaments WHERE id = $1`, id)
+		if err != nil {
+			return fmt.Errorf("deleting tournament %s: %w", id, err)
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("tournament %s not found", id)
+		}
+		// tournamentRecords (see sqlite.go) have no foreign key to cascade
+		// the delete through.
+		for _, table := range tournamentRecords {
+			if _, err := tx.Exec(ctx, `DELETE FROM `+table+` WHERE tournament_id = $1`, id); err != nil {
+				return fmt.Errorf("deleting %s of tournament %s: %w", table, id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// --- Match operations ---
+
+func (p *PostgresStore) UpdateMatchResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, result models.MatchResult, score string, want Precondition) error {
+	return p.updatePostgresMatch(ctx, tournamentID, roundNumber, matchID, want, func(_ *models.Tournament, _ *models.Round, m *models.Match) error {
+		writeMatchResult(m, result, score, want)
+		return nil
+	})
+}
+
+func (p *PostgresStore) SetRoundPairings(ctx context.Context, tournamentID string, roundNumber int, matches []models.Match, want Precondition) error {
+	return p.updatePostgresTournament(ctx, tournamentID, want, func(t *models.Tournament) error {
+		round, _, err := locateMatch(t, roundNumber, "")
+		if err != nil {
+			return err
+		}
+		round.Matches = cloneMatches(matches)
+		writePairings(round.Matches, want)
+		return nil
+	})
+}
+
+func (p *PostgresStore) UpdateHoleResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, hole int, result string, want Precondition) error {
+	return p.updatePostgresMatch(ctx, tournamentID, roundNumber, matchID, want, func(t *models.Tournament, round *models.Round, match *models.Match) error {
+		return writeHoleResult(t, round, match, hole, result, want)
+	})
+}
+
+func (p *PostgresStore) AttestMatch(ctx context.Context, tournamentID string, roundNumber int, matchID string, attestation models.Attestation, want Precondition) error {
+	return p.updatePostgresMatch(ctx, tournamentID, roundNumber, matchID, want, func(_ *models.Tournament, _ *models.Round, m *models.Match) error {
+		return m.Attest(attestation)
+	})
+}
+
+func (p *PostgresStore) LinkPlayer(ctx context.Context, tournamentID string, playerID string, email string, want Precondition) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		version, err := lockTournament(ctx, tx, tournamentID, "UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := want.check(tournamentID, version); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `UPDATE players SET user_email = $3 WHERE tournament_id = $1 AND id = $2`, tournamentID, playerID, email)
+		if err != nil {
+			return fmt.Errorf("linking player %s: %w", playerID, err)
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("player %s not found", playerID)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE tournaments SET version = version + 1, updated_at = $2 WHERE id = $1`, tournamentID, pgNow()); err != nil {
+			return fmt.Errorf("updating tournament %s: %w", tournamentID, err)
+		}
+		return nil
+	})
+}
+
+func (p *PostgresStore) SaveRanking(ctx context.Context, tournamentID string, ranking models.PlayerRanking, want Precondition) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		version, err := lockTournament(ctx, tx, tournamentID, "UPDATE")
+		if err != nil {
+			return err
+		}
+		if err := want.check(tournamentID, version); err != nil {
+			return err
+		}
+		t := &models.Tournament{ID: tournamentID}
+		var rankings []byte
+		if err := tx.QueryRow(ctx, `SELECT rankings FROM tournaments WHERE id = $1`, tournamentID).Scan(&rankings); err != nil {
+			return fmt.Errorf("getting rankings of tournament %s: %w", tournamentID, err)
+		}
+		if rankings != nil {
+			if err := json.Unmarshal(rankings, &t.Rankings); err != nil {
+				return fmt.Errorf("decoding rankings of tournament %s: %w", tournamentID, err)
+			}
+		}
+		setRanking(t, ranking)
+		encoded, err := nullJSON(t.Rankings)
+		if err != nil {
+			return fmt.Errorf("encoding rankings: %w", err)
+		}
+		if _, err := tx.Exec(ctx, `UPDATE tournaments SET rankings = $2, version = version + 1, updated_at = $3 WHERE id = $1`, tournamentID, encoded, pgNow()); err != nil {
+			return fmt.Errorf("updating tournament %s: %w", tournamentID, err)
+		}
+		return nil
+	})
+}
+
+// --- User registry ---
+
+func (p *PostgresStore) RegisterUser(ctx context.Context, user *models.RegisteredUser) error {
+	if _, err := p.pool.Exec(ctx, `
+		INSERT INTO registered_users (email, name, picture) VALUES ($1, $2, $3)
+		ON CONFLICT (email) DO UPDATE SET name = excluded.name, picture = excluded.picture`,
+		user.Email, user.Name, user.Picture); err != nil {
+		return fmt.Errorf("registering user %s: %w", user.Email, err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) ListRegisteredUsers(ctx context.Context) ([]*models.RegisteredUser, error) {
+	rows, err := p.pool.Query(ctx, `SELECT email, name, picture FROM registered_users ORDER BY email`)
+	if err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.RegisteredUser, error) {
+		var u models.RegisteredUser
+		err := row.Scan(&u.Email, &u.Name, &u.Picture)
+		return &u, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	return users, nil
+}
+
+// --- Audit log ---
+
+func (p *PostgresStore) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	if _, err := p.pool.Exec(ctx, `
+		INSERT INTO audit_entries (id, tournament_id, action, round_number, match_id, hole, player_id, user_email, before, after, revert_of, version, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+		entry.ID, entry.TournamentID, entry.Action, entry.RoundNumber, entry.MatchID, entry.Hole, entry.PlayerID,
+		entry.UserEmail, nullRaw(entry.Before), nullRaw(entry.After), entry.RevertOf, entry.Version, entry.CreatedAt); err != nil {
+		return fmt.Errorf("writing audit entry: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) ListAuditEntries(ctx context.Context, tournamentID string) ([]*models.AuditEntry, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, tournament_id, action, round_number, match_id, hole, player_id, user_email, before, after, revert_of, version, created_at
+		FROM audit_entries WHERE tournament_id = $1 ORDER BY seq`, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log %s: %w", tournamentID, err)
+	}
+	entries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.AuditEntry, error) {
+		var e models.AuditEntry
+		var before, after []byte
+		err := row.Scan(&e.ID, &e.TournamentID, &e.Action, &e.RoundNumber, &e.MatchID, &e.Hole, &e.PlayerID,
+			&e.UserEmail, &before, &after, &e.RevertOf, &e.Version, &e.CreatedAt)
+		if before != nil {
+			e.Before = json.RawMessage(before)
+		}
+		if after != nil {
+			e.After = json.RawMessage(after)
+		}
+		return &e, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log %s: %w", tournamentID, err)
+	}
+	return entries, nil
+}
+
+// --- Webhooks ---
+
+func (p *PostgresStore) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	events := make([]string, len(webhook.Events))
+	for i, e := range webhook.Events {
+		events[i] = string(e)
+	}
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if _, err := lockTournament(ctx, tx, webhook.TournamentID, "KEY SHARE"); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO webhooks (id, tournament_id, url, secret, events, created_at) VALUES ($1, $2, $3, $4, $5, $6)`,
+			webhook.ID, webhook.TournamentID, webhook.URL, webhook.Secret, events, webhook.CreatedAt); err != nil {
+			return fmt.Errorf("creating webhook: %w", err)
+		}
+		return nil
+	})
+}
+
+func (p *PostgresStore) ListWebhooks(ctx context.Context, tournamentID string) ([]*models.Webhook, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, tournament_id, url, secret, events, created_at
+		FROM webhooks WHERE tournament_id = $1 ORDER BY seq`, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("listing webhooks for tournament %s: %w", tournamentID, err)
+	}
+	hooks, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.Webhook, error) {
+		var wh models.Webhook
+		var events []string
+		err := row.Scan(&wh.ID, &wh.TournamentID, &wh.URL, &wh.Secret, &events, &wh.CreatedAt)
+		wh.Events = make([]models.WebhookEvent, len(events))
+		for i, e := range events {
+			wh.Events[i] = models.WebhookEvent(e)
+		}
+		return &wh, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing webhooks for tournament %s: %w", tournamentID, err)
+	}
+	return hooks, nil
+}
+
+func (p *PostgresStore) DeleteWebhook(ctx context.Context, tournamentID, webhookID string) error {
+	tag, err := p.pool.Exec(ctx, `DELETE FROM webhooks WHERE tournament_id = $1 AND id = $2`, tournamentID, webhookID)
+	if err != nil {
+		return fmt.Errorf("deleting webhook %s: %w", webhookID, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("webhook %s not found", webhookID)
+	}
+	return nil
+}
+
+func (p *PostgresStore) SaveWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	if _, err := p.pool.Exec(ctx, `
+		INSERT INTO webhook_deliveries (id, webhook_id, tournament_id, event, payload, attempts, status_code, error, delivered, created_at, last_attempt_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (id) DO UPDATE SET
+			payload = excluded.payload, attempts = excluded.attempts, status_code = excluded.status_code,
+			error = excluded.error, delivered = excluded.delivered, last_attempt_at = excluded.last_attempt_at,
+			next_attempt_at = excluded.next_attempt_at`,
+		d.ID, d.WebhookID, d.TournamentID, d.Event, nullRaw(d.Payload), d.Attempts, d.StatusCode, d.Error, d.Delivered,
+		d.CreatedAt, d.LastAttemptAt, d.NextAttemptAt); err != nil {
+		return fmt.Errorf("writing webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) ListWebhookDeliveries(ctx context.Context, tournamentID, webhookID string) ([]*models.WebhookDelivery, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT id, webhook_id, tournament_id, event, payload, attempts, status_code, error, delivered, created_at, last_attempt_at, next_attempt_at
+		FROM webhook_deliveries WHERE tournament_id = $1 AND webhook_id = $2 ORDER BY seq`, tournamentID, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("reading delivery log %s: %w", tournamentID, err)
+	}
+	deliveries, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.WebhookDelivery, error) {
+		var d models.WebhookDelivery
+		var payload []byte
+		err := row.Scan(&d.ID, &d.WebhookID, &d.TournamentID, &d.Event, &payload, &d.Attempts, &d.StatusCode, &d.Error,
+			&d.Delivered, &d.CreatedAt, &d.LastAttemptAt, &d.NextAttemptAt)
+		if payload != nil {
+			d.Payload = json.RawMessage(payload)
+		}
+		return &d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading delivery log %s: %w", tournamentID, err)
+	}
+	return deliveries, nil
+}
+
+// --- Share tokens ---
+
+func (p *PostgresStore) CreateShareToken(ctx context.Context, share *models.ShareToken) error {
+	return pgx.BeginFunc(ctx, p.pool, func(tx pgx.Tx) error {
+		if _, err := lockTournament(ctx, tx, share.TournamentID, "KEY SHARE"); err != nil {
+			return err
+		}
+		tag, err := tx.Exec(ctx, `
+			INSERT INTO share_tokens (token, tournament_id, label, created_by, created_at) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (token) DO NOTHING`,
+			share.Token, share.TournamentID, share.Label, share.CreatedBy, share.CreatedAt)
+		if err != nil {
+			return fmt.Errorf("creating share token: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return fmt.Errorf("share token already exists")
+		}
+		return nil
+	})
+}
+
+func scanShareToken(row pgx.Row) (*models.ShareToken, error) {
+	var share models.ShareToken
+	err := row.Scan(&share.Token, &share.TournamentID, &share.Label, &share.CreatedBy, &share.CreatedAt)
+	return &share, err
+}
+
+func (p *PostgresStore) GetShareToken(ctx context.Context, token string) (*models.ShareToken, error) {
+	share, err := scanShareToken(p.pool.QueryRow(ctx, `
+		SELECT token, tournament_id, label, created_by, created_at FROM share_tokens WHERE token = $1`, token))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("share token not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting share token: %w", err)
+	}
+	return share, nil
+}
+
+func (p *PostgresStore) ListShareTokens(ctx context.Context, tournamentID string) ([]*models.ShareToken, error) {
+	rows, err := p.pool.Query(ctx, `
+		SELECT token, tournament_id, label, created_by, created_at FROM share_tokens WHERE tournament_id = $1`, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("listing share tokens: %w", err)
+	}
+	shares, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.ShareToken, error) {
+		return scanShareToken(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing share tokens: %w", err)
+	}
+	sortShareTokens(shares)
+	return shares, nil
+}
+
+func (p *PostgresStore) DeleteShareToken(ctx context.Context, tournamentID, token string) error {
+	tag, err := p.pool.Exec(ctx, `DELETE FROM share_tokens WHERE token = $1 AND tournament_id = $2`, token, tournamentID)
+	if err != nil {
+		return fmt.Errorf("deleting share token: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("share token not found")
+	}
+	return nil
+}
+
+// --- Local users ---
+
+func scanPostgresLocalUser(row pgx.Row) (*models.LocalUser, error) {
+	var u models.LocalUser
+	err := row.Scan(&u.Email, &u.Name, &u.PasswordHash, &u.EmailVerified, &u.Confirmed, &u.Disabled, &u.VerificationToken, &u.CalendarKey, &u.CreatedAt)
+	return &u, err
+}
+
+func (p *PostgresStore) CreateLocalUser(ctx context.Context, user *models.LocalUser) error {
+	tag, err := p.pool.Exec(ctx, `
+		INSERT INTO local_users (email_key, `+localUserColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (email_key) DO NOTHING`,
+		strings.ToLower(user.Email), user.Email, user.Name, user.PasswordHash, user.EmailVerified, user.Confirmed,
+		user.Disabled, user.VerificationToken, user.CalendarKey, user.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("creating user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("a user with email %s already exists", user.Email)
+	}
+	return nil
+}
+
+// ImportLocalUser writes a user as-is, replacing any user with the same email.
+func (p *PostgresStore) ImportLocalUser(ctx context.Context, user *models.LocalUser) error {
+	if _, err := p.pool.Exec(ctx, `
+		INSERT INTO local_users (email_key, `+localUserColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (email_key) DO UPDATE SET
+			email = excluded.email, name = excluded.name, password_hash = excluded.password_hash,
+			email_verified = excluded.email_verified, confirmed = excluded.confirmed, disabled = excluded.disabled,
+			verification_token = excluded.verification_token, calendar_key = excluded.calendar_key,
+			created_at = excluded.created_at`,
+		strings.ToLower(user.Email), user.Email, user.Name, user.PasswordHash, user.EmailVerified, user.Confirmed,
+		user.Disabled, user.VerificationToken, user.CalendarKey, user.CreatedAt); err != nil {
+		return fmt.Errorf("importing user %s: %w", user.Email, err)
+	}
+	return nil
+}
+
+func (p *PostgresStore) GetLocalUser(ctx context.Context, email string) (*models.LocalUser, error) {
+	u, err := scanPostgresLocalUser(p.pool.QueryRow(ctx, `SELECT `+localUserColumns+` FROM local_users WHERE email_key = $1`, strings.ToLower(email)))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+	return u, nil
+}
+
+func (p *PostgresStore) VerifyLocalUser(ctx context.Context, token string) error {
+	if token == "" {
+		return fmt.Errorf("invalid verification token")
+	}
+	tag, err := p.pool.Exec(ctx, `
+		UPDATE local_users SET email_verified = true, verification_token = '' WHERE verification_token = $1`, token)
+	if err != nil {
+		return fmt.Errorf("verifying user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("invalid verification token")
+	}
+	return nil
+}
+
+func (p *PostgresStore) ListLocalUsers(ctx context.Context) ([]*models.LocalUser, error) {
+	rows, err := p.pool.Query(ctx, `SELECT `+localUserColumns+` FROM local_users ORDER BY created_at, email_key`)
+	if err != nil {
+		return nil, fmt.Errorf("listing local users: %w", err)
+	}
+	users, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (*models.LocalUser, error) {
+		return scanPostgresLocalUser(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing local users: %w", err)
+	}
+	return users, nil
+}
+
+// setLocalUserColumn updates one column of a user, identified by email.
+func (p *PostgresStore) setLocalUserColumn(ctx context.Context, email, column string, value any) error {
+	tag, err := p.pool.Exec(ctx, `UPDATE local_users SET `+column+` = $2 WHERE email_key = $1`, strings.ToLower(email), value)
+	if err != nil {
+		return fmt.Errorf("updating user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+func (p *PostgresStore) ConfirmLocalUser(ctx context.Context, email string) error {
+	return p.setLocalUserColumn(ctx, email, "confirmed", true)
+}
+
+func (p *PostgresStore) DeleteLocalUser(ctx context.Context, email string) error {
+	return p.setLocalUserColumn(ctx, email, "disabled", true)
+}
+
+func (p *PostgresStore) EnableLocalUser(ctx context.Context, email string) error {
+	return p.setLocalUserColumn(ctx, email, "disabled", false)
+}
+
+func (p *PostgresStore) SetCalendarKey(ctx context.Context, email, key string) error {
+	return p.setLocalUserColumn(ctx, email, "calendar_key", key)
+}
+
+// pgNow is the current time at the precision Postgres stores, so that a
+// tournament handed back to the caller matches what a later read returns.
+func pgNow() time.Time {
+	return time.Now().Truncate(time.Microsecond)
+}
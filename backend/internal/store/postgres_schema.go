@@ -0,0 +1,221 @@
+package store
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// postgresMigrations are applied in order, each in its own transaction. As
+// with SQLite, released entries are never edited, only appended to.
+//
+// Match hole results are a JSONB object keyed by hole number, like the
+// models.Match field. Audit snapshots and webhook payloads use json rather
+// than jsonb so they read back byte for byte as written.
+var postgresMigrations = []string{
+	// 1: initial schema
+	`
+	CREATE TABLE tournaments (
+		id               TEXT PRIMARY KEY,
+		name             TEXT NOT NULL,
+		header_color     TEXT NOT NULL DEFAULT '',
+		bg_color         TEXT NOT NULL DEFAULT '',
+		locked           BOOLEAN NOT NULL DEFAULT false,
+		combine_rounds23 BOOLEAN NOT NULL DEFAULT false,
+		rankings         JSONB,
+		rankings_locked  BOOLEAN NOT NULL DEFAULT false,
+		created_at       TIMESTAMPTZ NOT NULL,
+		updated_at       TIMESTAMPTZ NOT NULL,
+		version          BIGINT NOT NULL
+	);
+
+	CREATE TABLE teams (
+		tournament_id TEXT NOT NULL REFERENCES tournaments(id) ON DELETE CASCADE,
+		position      SMALLINT NOT NULL,
+		id            TEXT NOT NULL,
+		name          TEXT NOT NULL,
+		color         TEXT NOT NULL DEFAULT '',
+		logo          TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (tournament_id, position)
+	);
+
+	CREATE TABLE players (
+		tournament_id TEXT NOT NULL REFERENCES tournaments(id) ON DELETE CASCADE,
+		team_position SMALLINT NOT NULL,
+		position      INTEGER NOT NULL,
+		id            TEXT NOT NULL,
+		name          TEXT NOT NULL,
+		team_id       TEXT NOT NULL DEFAULT '',
+		user_email    TEXT NOT NULL DEFAULT '',
+		handicap      DOUBLE PRECISION,
+		PRIMARY KEY (tournament_id, team_position, position)
+	);
+	CREATE INDEX players_user_email ON players (user_email) WHERE user_email <> '';
+
+	CREATE TABLE rounds (
+		tournament_id    TEXT NOT NULL REFERENCES tournaments(id) ON DELETE CASCADE,
+		number           INTEGER NOT NULL,
+		position         INTEGER NOT NULL,
+		name             TEXT NOT NULL,
+		type             TEXT NOT NULL,
+		points_per_match DOUBLE PRECISION NOT NULL,
+		holes            INTEGER NOT NULL DEFAULT 0,
+		locked           BOOLEAN NOT NULL DEFAULT false,
+		PRIMARY KEY (tournament_id, number)
+	);
+
+	CREATE TABLE matches (
+		tournament_id      TEXT NOT NULL REFERENCES tournaments(id) ON DELETE CASCADE,
+		id                 TEXT NOT NULL,
+		round_number       INTEGER NOT NULL,
+		position           INTEGER NOT NULL,
+		team1_players      TEXT[] NOT NULL,
+		team2_players      TEXT[] NOT NULL,
+		result             TEXT NOT NULL,
+		score              TEXT NOT NULL DEFAULT '',
+		hole_results       JSONB NOT NULL DEFAULT '{}',
+		attestation_status TEXT NOT NULL DEFAULT '',
+		attestations       JSONB,
+		tee_time           TIMESTAMPTZ,
+		PRIMARY KEY (tournament_id, id)
+	);
+
+	CREATE TABLE registered_users (
+		email   TEXT PRIMARY KEY,
+		name    TEXT NOT NULL,
+		picture TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE local_users (
+		email_key          TEXT PRIMARY KEY,
+		email              TEXT NOT NULL,
+		name               TEXT NOT NULL,
+		password_hash      TEXT NOT NULL,
+		email_verified     BOOLEAN NOT NULL DEFAULT false,
+		confirmed          BOOLEAN NOT NULL DEFAULT false,
+		disabled           BOOLEAN NOT NULL DEFAULT false,
+		verification_token TEXT NOT NULL DEFAULT '',
+		created_at         TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX local_users_verification_token ON local_users (verification_token) WHERE verification_token <> '';
+
+	CREATE TABLE audit_entries (
+		seq           BIGSERIAL PRIMARY KEY,
+		id            TEXT NOT NULL,
+		tournament_id TEXT NOT NULL,
+		action        TEXT NOT NULL,
+		round_number  INTEGER NOT NULL DEFAULT 0,
+		match_id      TEXT NOT NULL DEFAULT '',
+		hole          INTEGER NOT NULL DEFAULT 0,
+		player_id     TEXT NOT NULL DEFAULT '',
+		user_email    TEXT NOT NULL,
+		before        JSON,
+		after         JSON,
+		revert_of     TEXT NOT NULL DEFAULT '',
+		created_at    TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX audit_entries_tournament ON audit_entries (tournament_id, seq);
+
+	CREATE TABLE webhooks (
+		seq           BIGSERIAL PRIMARY KEY,
+		id            TEXT NOT NULL UNIQUE,
+		tournament_id TEXT NOT NULL,
+		url           TEXT NOT NULL,
+		secret        TEXT NOT NULL DEFAULT '',
+		events        TEXT[] NOT NULL,
+		created_at    TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX webhooks_tournament ON webhooks (tournament_id, seq);
+
+	CREATE TABLE webhook_deliveries (
+		seq             BIGSERIAL PRIMARY KEY,
+		id              TEXT NOT NULL UNIQUE,
+		webhook_id      TEXT NOT NULL,
+		tournament_id   TEXT NOT NULL,
+		event           TEXT NOT NULL,
+		payload         JSON,
+		attempts        INTEGER NOT NULL DEFAULT 0,
+		status_code     INTEGER NOT NULL DEFAULT 0,
+		error           TEXT NOT NULL DEFAULT '',
+		delivered       BOOLEAN NOT NULL DEFAULT false,
+		created_at      TIMESTAMPTZ NOT NULL,
+		last_attempt_at TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX webhook_deliveries_webhook ON webhook_deliveries (tournament_id, webhook_id, seq);
+
+	CREATE TABLE share_tokens (
+		token         TEXT PRIMARY KEY,
+		tournament_id TEXT NOT NULL,
+		label         TEXT NOT NULL DEFAULT '',
+		created_by    TEXT NOT NULL,
+		created_at    TIMESTAMPTZ NOT NULL
+	);
+	CREATE INDEX share_tokens_tournament ON share_tokens (tournament_id);
+	`,
+	// 2: document schema versions (see package schema). Rows written before
+	// this were already in the version 1 form.
+	`
+	ALTER TABLE tournaments ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE matches ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 1;
+	`,
+	// 3: the match version each audited change to a match produced. Older
+	// entries stay at 0, meaning unknown.
+	`
+	ALTER TABLE audit_entries ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
+	`,
+	// 4: per-match versions. Existing matches start at 1.
+	`
+	ALTER TABLE matches ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+	`,
+	// 5: the key each user's calendar feed URL is signed with.
+	`
+	ALTER TABLE local_users ADD COLUMN calendar_key TEXT NOT NULL DEFAULT '';
+	`,
+	// 6: the last write to each hole of a match, and to its result.
+	`
+	ALTER TABLE matches ADD COLUMN hole_writes JSONB;
+	ALTER TABLE matches ADD COLUMN result_write JSONB;
+	`,
+	// 7: when each undelivered webhook delivery is due to be retried.
+	`
+	ALTER TABLE webhook_deliveries ADD COLUMN next_attempt_at TIMESTAMPTZ;
+	`,
+}
+
+// postgresMigrationLock is the advisory lock key held while migrating, so
+// that several servers starting at once apply each migration only once.
+const postgresMigrationLock = 0x73636f72696e67 // "scoring"
+
+// migratePostgres brings the database schema up to date.
+func migratePostgres(ctx context.Context, pool *pgxpool.Pool) error {
+	if _, err := pool.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	return pgx.BeginFunc(ctx, pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1)`, postgresMigrationLock); err != nil {
+			return fmt.Errorf("locking schema: %w", err)
+		}
+		var current int
+		if err := tx.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+			return fmt.Errorf("reading schema version: %w", err)
+		}
+		if current > len(postgresMigrations) {
+			return fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, len(postgresMigrations))
+		}
+		for v := current + 1; v <= len(postgresMigrations); v++ {
+			if _, err := tx.Exec(ctx, postgresMigrations[v-1]); err != nil {
+				return fmt.Errorf("applying schema migration %d: %w", v, err)
+			}
+			if _, err := tx.Exec(ctx, `INSERT INTO schema_migrations (version) VALUES ($1)`, v); err != nil {
+				return fmt.Errorf("recording schema migration %d: %w", v, err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"fmt"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/schema"
+	"sort"
+)
+
+// Persistent stores keep each tournament as a header record (the tournament
+// with every round's match list emptied) plus one record per match. Entering a
+// hole result then rewrites a single small match record instead of the whole
+// tournament, and writers on different matches don't contend with each other.
+//
+// Tournaments saved before this layout have their matches embedded in the
+// header. They are read as-is and split on their next write.
+
+// tournamentRecord is the stored form of a tournament header.
+type tournamentRecord struct {
+	models.Tournament
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+}
+
+// matchRecord is the stored form of a single match. Round and Position locate
+// the match within the tournament so the original ordering can be rebuilt.
+type matchRecord struct {
+	Round         int          `json:"round"`
+	Position      int          `json:"position"`
+	Match         models.Match `json:"match"`
+	SchemaVersion int          `json:"schemaVersion,omitempty"`
+}
+
+// splitTournament returns the header record and match records for t, both
+// at the current schema version.
+func splitTournament(t *models.Tournament) (*tournamentRecord, []matchRecord) {
+	header := &tournamentRecord{Tournament: *t, SchemaVersion: schema.Current}
+	header.Rounds = make([]models.Round, len(t.Rounds))
+	records := make([]matchRecord, 0)
+	for i, round := range t.Rounds {
+		header.Rounds[i] = round
+		header.Rounds[i].Matches = []models.Match{}
+		for j, m := range round.Matches {
+			records = append(records, matchRecord{Round: round.Number, Position: j, Match: m, SchemaVersion: schema.Current})
+		}
+	}
+	return header, records
+}
+
+// upgrade brings a header read from storage to the current schema version,
+// along with any matches still embedded in it.
+func (r *tournamentRecord) upgrade() error {
+	if err := schema.Upgrade(&r.Tournament, r.SchemaVersion); err != nil {
+		return fmt.Errorf("tournament %s: %w", r.ID, err)
+	}
+	r.SchemaVersion = schema.Current
+	return nil
+}
+
+// upgrade brings a match record read from storage to the current schema
+// version.
+func (r *matchRecord) upgrade() error {
+	if err := schema.Upgrade(&r.Match, r.SchemaVersion); err != nil {
+		return fmt.Errorf("match %s: %w", r.Match.ID, err)
+	}
+	r.SchemaVersion = schema.Current
+	return nil
+}
+
+// joinTournament attaches match records to their rounds in header, in their
+// stored order. Records for rounds that no longer exist are dropped.
+func joinTournament(header *models.Tournament, records []matchRecord) {
+	sort.Slice(records, func(a, b int) bool {
+		if records[a].Round != records[b].Round {
+			return records[a].Round < records[b].Round
+		}
+		return records[a].Position < records[b].Position
+	})
+
+	for i := range header.Rounds {
+		header.Rounds[i].Matches = []models.Match{}
+	}
+	for _, rec := range records {
+		for i := range header.Rounds {
+			if header.Rounds[i].Number == rec.Round {
+				header.Rounds[i].Matches = append(header.Rounds[i].Matches, rec.Match)
+				break
+			}
+		}
+	}
+}
+
+// hasEmbeddedMatches reports whether a stored header still uses the old
+// layout with matches embedded in the tournament.
+func hasEmbeddedMatches(t *models.Tournament) bool {
+	for _, round := range t.Rounds {
+		if len(round.Matches) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// locateMatch finds a round and one of its matches in t. With an empty matchID
+// only the round is looked up.
+func locateMatch(t *models.Tournament, roundNumber int, matchID string) (*models.Round, *models.Match, error) {
+	for i := range t.Rounds {
+		if t.Rounds[i].Number != roundNumber {
+			continue
+		}
+		round := &t.Rounds[i]
+		if matchID == "" {
+			return round, nil, nil
+		}
+		for j := range round.Matches {
+			if round.Matches[j].ID == matchID {
+				return round, &round.Matches[j], nil
+			}
+		}
+		return round, nil, fmt.Errorf("match %s not found in round %d", matchID, roundNumber)
+	}
+	return nil, nil, fmt.Errorf("round %d not found", roundNumber)
+}
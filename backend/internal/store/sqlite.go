@@ -0,0 +1,1118 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/schema"
+	"strconv"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteStore keeps everything in a single SQLite database file, with
+// tournaments broken out into tables for teams, players, rounds, matches and
+// hole results. Write transactions take SQLite's write lock up front
+// (BEGIN IMMEDIATE), so concurrent writers queue instead of failing midway,
+// while readers see a consistent snapshot through WAL mode.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens or creates the database at path and applies any
+// pending schema migrations.
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+	dsn := path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(10000)&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite database %s: %w", path, err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening sqlite database %s: %w", path, err)
+	}
+	if err := migrateSQLite(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// sqlQuerier is satisfied by both *sql.DB and *sql.Tx.
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// withTx runs fn in a transaction, committing if it returns nil.
+func (s *SQLiteStore) withTx(ctx context.Context, readOnly bool, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: readOnly})
+	if err != nil {
+		return fmt.Errorf("starting transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// Times are stored as RFC 3339 text with their original offset so they read
+// back exactly as they were written.
+func formatTime(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseTime(s string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid stored time %q: %w", s, err)
+	}
+	return t, nil
+}
+
+// nullJSON encodes v for a nullable JSON column, storing NULL for nil.
+func nullJSON(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if string(data) == "null" {
+		return nil, nil
+	}
+	return string(data), nil
+}
+
+// matchWritesJSON encodes m's write records for the hole_writes and
+// result_write columns.
+func matchWritesJSON(m *models.Match) (holeWrites, resultWrite any, err error) {
+	if holeWrites, err = nullJSON(m.HoleWrites); err != nil {
+		return nil, nil, fmt.Errorf("encoding hole writes of match %s: %w", m.ID, err)
+	}
+	if resultWrite, err = nullJSON(m.ResultWrite); err != nil {
+		return nil, nil, fmt.Errorf("encoding result write of match %s: %w", m.ID, err)
+	}
+	return holeWrites, resultWrite, nil
+}
+
+func nullRaw(raw json.RawMessage) any {
+	if len(raw) == 0 {
+		return nil
+	}
+	return string(raw)
+}
+
+// affected reports whether a statement changed any rows.
+func affected(res sql.Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// sqliteVersion returns a tournament's stored version.
+func sqliteVersion(ctx context.Context, q sqlQuerier, id string) (int64, error) {
+	var version int64
+	err := q.QueryRowContext(ctx, `SELECT version FROM tournaments WHERE id = ?`, id).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("tournament %s not found", id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting tournament %s: %w", id, err)
+	}
+	return version, nil
+}
+
+func tournamentExists(ctx context.Context, q sqlQuerier, id string) error {
+	var one int
+	err := q.QueryRowContext(ctx, `SELECT 1 FROM tournaments WHERE id = ?`, id).Scan(&one)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("tournament %s not found", id)
+	}
+	if err != nil {
+		return fmt.Errorf("getting tournament %s: %w", id, err)
+	}
+	return nil
+}
+
+// --- Tournament rows ---
+
+func loadSQLiteTournament(ctx context.Context, q sqlQuerier, id string) (*models.Tournament, error) {
+	t := &models.Tournament{}
+	var rankings sql.NullString
+	var created, updated string
+	var version int
+	err := q.QueryRowContext(ctx, `
+		SELECT id, name, header_color, bg_color, locked, combine_rounds23, rankings, rankings_locked, created_at, updated_at, version, schema_version
+		FROM tournaments WHERE id = ?`, id).
+		Scan(&t.ID, &t.Name, &t.HeaderColor, &t.BgColor, &t.Locked, &t.CombineRounds23, &rankings, &t.RankingsLocked, &created, &updated, &t.Version, &version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("tournament %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting tournament %s: %w", id, err)
+	}
+	if t.CreatedAt, err = parseTime(created); err != nil {
+		return nil, err
+	}
+	if t.UpdatedAt, err = parseTime(updated); err != nil {
+		return nil, err
+	}
+	if rankings.Valid {
+		if err := json.Unmarshal([]byte(rankings.String), &t.Rankings); err != nil {
+			return nil, fmt.Errorf("decoding rankings of tournament %s: %w", id, err)
+		}
+	}
+
+	if err := loadSQLiteTeams(ctx, q, t); err != nil {
+		return nil, err
+	}
+	if err := loadSQLiteRounds(ctx, q, t, version); err != nil {
+		return nil, err
+	}
+	normalizeTournament(t)
+	return t, nil
+}
+
+func loadSQLiteTeams(ctx context.Context, q sqlQuerier, t *models.Tournament) error {
+	rows, err := q.QueryContext(ctx, `SELECT position, id, name, color, logo FROM teams WHERE tournament_id = ?`, t.ID)
+	if err != nil {
+		return fmt.Errorf("getting teams of tournament %s: %w", t.ID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pos int
+		var team models.Team
+		if err := rows.Scan(&pos, &team.ID, &team.Name, &team.Color, &team.Logo); err != nil {
+			return fmt.Errorf("decoding team of tournament %s: %w", t.ID, err)
+		}
+		if pos == 0 || pos == 1 {
+			t.Teams[pos] = team
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("getting teams of tournament %s: %w", t.ID, err)
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT team_position, id, name, team_id, user_email, handicap
+		FROM players WHERE tournament_id = ? ORDER BY team_position, position`, t.ID)
+	if err != nil {
+		return fmt.Errorf("getting players of tournament %s: %w", t.ID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var pos int
+		var p models.Player
+		var handicap sql.NullFloat64
+		if err := rows.Scan(&pos, &p.ID, &p.Name, &p.TeamID, &p.UserEmail, &handicap); err != nil {
+			return fmt.Errorf("decoding player of tournament %s: %w", t.ID, err)
+		}
+		if handicap.Valid {
+			p.Handicap = &handicap.Float64
+		}
+		if pos == 0 || pos == 1 {
+			t.Teams[pos].Players = append(t.Teams[pos].Players, p)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("getting players of tournament %s: %w", t.ID, err)
+	}
+	return nil
+}
+
+func loadSQLiteRounds(ctx context.Context, q sqlQuerier, t *models.Tournament, version int) error {
+	rows, err := q.QueryContext(ctx, `
+		SELECT number, name, type, points_per_match, holes, locked
+		FROM rounds WHERE tournament_id = ? ORDER BY position`, t.ID)
+	if err != nil {
+		return fmt.Errorf("getting rounds of tournament %s: %w", t.ID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r models.Round
+		if err := rows.Scan(&r.Number, &r.Name, &r.Type, &r.PointsPerMatch, &r.Holes, &r.Locked); err != nil {
+			return fmt.Errorf("decoding round of tournament %s: %w", t.ID, err)
+		}
+		t.Rounds = append(t.Rounds, r)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("getting rounds of tournament %s: %w", t.ID, err)
+	}
+	// The header is upgraded before the matches, which carry their own
+	// version, are attached.
+	header := tournamentRecord{Tournament: *t, SchemaVersion: version}
+	if err := header.upgrade(); err != nil {
+		return err
+	}
+	*t = header.Tournament
+
+	holes := make(map[string]map[string]string)
+	rows, err = q.QueryContext(ctx, `SELECT match_id, hole, result FROM hole_results WHERE tournament_id = ?`, t.ID)
+	if err != nil {
+		return fmt.Errorf("getting hole results of tournament %s: %w", t.ID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var matchID, result string
+		var hole int
+		if err := rows.Scan(&matchID, &hole, &result); err != nil {
+			return fmt.Errorf("decoding hole result of tournament %s: %w", t.ID, err)
+		}
+		if holes[matchID] == nil {
+			holes[matchID] = make(map[string]string)
+		}
+		holes[matchID][strconv.Itoa(hole)] = result
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("getting hole results of tournament %s: %w", t.ID, err)
+	}
+
+	rows, err = q.QueryContext(ctx, `
+		SELECT id, round_number, position, team1_players, team2_players, result, score, attestation_status, attestations, tee_time, version, hole_writes, result_write, schema_version
+		FROM matches WHERE tournament_id = ?`, t.ID)
+	if err != nil {
+		return fmt.Errorf("getting matches of tournament %s: %w", t.ID, err)
+	}
+	defer rows.Close()
+	records := make([]matchRecord, 0)
+	for rows.Next() {
+		var rec matchRecord
+		var team1, team2 string
+		var attestations, teeTime, holeWrites, resultWrite sql.NullString
+		m := &rec.Match
+		if err := rows.Scan(&m.ID, &rec.Round, &rec.Position, &team1, &team2, &m.Result, &m.Score, &m.AttestationStatus, &attestations, &teeTime, &m.Version, &holeWrites, &resultWrite, &rec.SchemaVersion); err != nil {
+			return fmt.Errorf("decoding match of tournament %s: %w", t.ID, err)
+		}
+		m.RoundNumber = rec.Round
+		if err := json.Unmarshal([]byte(team1), &m.Team1Players); err != nil {
+			return fmt.Errorf("decoding match %s: %w", m.ID, err)
+		}
+		if err := json.Unmarshal([]byte(team2), &m.Team2Players); err != nil {
+			return fmt.Errorf("decoding match %s: %w", m.ID, err)
+		}
+		if attestations.Valid {
+			if err := json.Unmarshal([]byte(attestations.String), &m.Attestations); err != nil {
+				return fmt.Errorf("decoding attestations of match %s: %w", m.ID, err)
+			}
+		}
+		if holeWrites.Valid {
+			if err := json.Unmarshal([]byte(holeWrites.String), &m.HoleWrites); err != nil {
+				return fmt.Errorf("decoding hole writes of match %s: %w", m.ID, err)
+			}
+		}
+		if resultWrite.Valid {
+			if err := json.Unmarshal([]byte(resultWrite.String), &m.ResultWrite); err != nil {
+				return fmt.Errorf("decoding result write of match %s: %w", m.ID, err)
+			}
+		}
+		if teeTime.Valid {
+			tt, err := parseTime(teeTime.String)
+			if err != nil {
+				return err
+			}
+			m.TeeTime = &tt
+		}
+		m.HoleResults = holes[m.ID]
+		if err := rec.upgrade(); err != nil {
+			return err
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("getting matches of tournament %s: %w", t.ID, err)
+	}
+	joinTournament(t, records)
+	return nil
+}
+
+// writeSQLiteTournament replaces every row of t. Deleting the tournament row
+// cascades to its teams, players, rounds, matches and hole results.
+func writeSQLiteTournament(ctx context.Context, tx *sql.Tx, t *models.Tournament) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tournaments WHERE id = ?`, t.ID); err != nil {
+		return fmt.Errorf("replacing tournament %s: %w", t.ID, err)
+	}
+
+	rankings, err := nullJSON(t.Rankings)
+	if err != nil {
+		return fmt.Errorf("encoding rankings: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO tournaments (id, name, header_color, bg_color, locked, combine_rounds23, rankings, rankings_locked, created_at, updated_at, version, schema_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.Name, t.HeaderColor, t.BgColor, t.Locked, t.CombineRounds23, rankings, t.RankingsLocked,
+		formatTime(t.CreatedAt), formatTime(t.UpdatedAt), t.Version, schema.Current); err != nil {
+		return fmt.Errorf("writing tournament %s: %w", t.ID, err)
+	}
+
+	for i, team := range t.Teams {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO teams (tournament_id, position, id, name, color, logo) VALUES (?, ?, ?, ?, ?, ?)`,
+			t.ID, i, team.ID, team.Name, team.Color, team.Logo); err != nil {
+			return fmt.Errorf("writing team %s: %w", team.ID, err)
+		}
+		for j, p := range team.Players {
+			var handicap any
+			if p.Handicap != nil {
+				handicap = *p.Handicap
+			}
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO players (tournament_id, team_position, position, id, name, team_id, user_email, handicap)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				t.ID, i, j, p.ID, p.Name, p.TeamID, p.UserEmail, handicap); err != nil {
+				return fmt.Errorf("writing player %s: %w", p.ID, err)
+			}
+		}
+	}
+
+	for i, r := range t.Rounds {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO rounds (tournament_id, number, position, name, type, points_per_match, holes, locked)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			t.ID, r.Number, i, r.Name, r.Type, r.PointsPerMatch, r.Holes, r.Locked); err != nil {
+			return fmt.Errorf("writing round %d: %w", r.Number, err)
+		}
+		for j := range r.Matches {
+			if err := insertSQLiteMatch(ctx, tx, t.ID, r.Number, j, &r.Matches[j]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func insertSQLiteMatch(ctx context.Context, tx *sql.Tx, tournamentID string, roundNumber, position int, m *models.Match) error {
+	team1, err := json.Marshal(nonNilStrings(m.Team1Players))
+	if err != nil {
+		return fmt.Errorf("encoding match %s: %w", m.ID, err)
+	}
+	team2, err := json.Marshal(nonNilStrings(m.Team2Players))
+	if err != nil {
+		return fmt.Errorf("encoding match %s: %w", m.ID, err)
+	}
+	attestations, err := nullJSON(m.Attestations)
+	if err != nil {
+		return fmt.Errorf("encoding attestations of match %s: %w", m.ID, err)
+	}
+	holeWrites, resultWrite, err := matchWritesJSON(m)
+	if err != nil {
+		return err
+	}
+	var teeTime any
+	if m.TeeTime != nil {
+		teeTime = formatTime(*m.TeeTime)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO matches (tournament_id, id, round_number, position, team1_players, team2_players, result, score, attestation_status, attestations, tee_time, version, hole_writes, result_write, schema_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		tournamentID, m.ID, roundNumber, position, string(team1), string(team2), m.Result, m.Score, m.AttestationStatus, attestations, teeTime, matchVersion(m), holeWrites, resultWrite, schema.Current); err != nil {
+		return fmt.Errorf("writing match %s: %w", m.ID, err)
+	}
+	for key, result := range m.HoleResults {
+		hole, err := strconv.Atoi(key)
+		if err != nil || result == "" {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO hole_results (tournament_id, match_id, hole, result) VALUES (?, ?, ?, ?)`,
+			tournamentID, m.ID, hole, result); err != nil {
+			return fmt.Errorf("writing hole %d of match %s: %w", hole, m.ID, err)
+		}
+	}
+	return nil
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// updateSQLiteTournament loads a tournament, checks want, applies mutate and
+// writes it back with a new version.
+func (s *SQLiteStore) updateSQLiteTournament(ctx context.Context, tournamentID string, want Precondition, mutate func(t *models.Tournament) error) error {
+	return s.withTx(ctx, false, func(tx *sql.Tx) error {
+		t, err := loadSQLiteTournament(ctx, tx, tournamentID)
+		if err != nil {
+			return err
+		}
+		if err := want.check(tournamentID, t.Version); err != nil {
+			return err
+		}
+		if err := mutate(t); err != nil {
+			return err
+		}
+		t.UpdatedAt = time.Now()
+		t.Version++
+		return writeSQLiteTournament(ctx, tx, t)
+	})
+}
+
+// updateSQLiteMatch checks want, applies mutate to one match and rewrites
+// only that match and its hole results, plus the version on the tournament
+// row.
+func (s *SQLiteStore) updateSQLiteMatch(ctx context.Context, tournamentID string, roundNumber int, matchID string, want Precondition, mutate func(t *models.Tournament, round *models.Round, m *models.Match) error) error {
+	return s.withTx(ctx, false, func(tx *sql.Tx) error {
+		t, err := loadSQLiteTournament(ctx, tx, tournamentID)
+		if err != nil {
+			return err
+		}
+		if err := want.check(tournamentID, t.Version); err != nil {
+			return err
+		}
+		round, match, err := locateMatch(t, roundNumber, matchID)
+		if err != nil {
+			return err
+		}
+		if err := want.checkMatch(tournamentID, match); err != nil {
+			return err
+		}
+		if err := mutate(t, round, match); err != nil {
+			return err
+		}
+		bumpMatch(match)
+
+		position := 0
+		for i := range round.Matches {
+			if round.Matches[i].ID == matchID {
+				position = i
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM matches WHERE tournament_id = ? AND id = ?`, tournamentID, matchID); err != nil {
+			return fmt.Errorf("updating match %s: %w", matchID, err)
+		}
+		if err := insertSQLiteMatch(ctx, tx, tournamentID, roundNumber, position, match); err != nil {
+			return err
+		}
+		return bumpSQLiteVersion(ctx, tx, tournamentID)
+	})
+}
+
+func bumpSQLiteVersion(ctx context.Context, tx *sql.Tx, tournamentID string) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE tournaments SET version = version + 1, updated_at = ? WHERE id = ?`,
+		formatTime(time.Now()), tournamentID); err != nil {
+		return fmt.Errorf("updating tournament %s: %w", tournamentID, err)
+	}
+	return nil
+}
+
+// --- Tournaments ---
+
+func (s *SQLiteStore) CreateTournament(ctx context.Context, t *models.Tournament) error {
+	return s.withTx(ctx, false, func(tx *sql.Tx) error {
+		if err := tournamentExists(ctx, tx, t.ID); err == nil {
+			return fmt.Errorf("tournament %s already exists", t.ID)
+		}
+		now := time.Now()
+		t.CreatedAt = now
+		t.UpdatedAt = now
+		t.Version = 1
+		return writeSQLiteTournament(ctx, tx, t)
+	})
+}
+
+// ImportTournament writes a tournament as-is, keeping its timestamps and
+// version, and replaces any existing tournament with the same ID.
+func (s *SQLiteStore) ImportTournament(ctx context.Context, t *models.Tournament) error {
+	return s.withTx(ctx, false, func(tx *sql.Tx) error {
+		return writeSQLiteTournament(ctx, tx, t)
+	})
+}
+
+func (s *SQLiteStore) GetTournament(ctx context.Context, id string) (*models.Tournament, error) {
+	var t *models.Tournament
+	err := s.withTx(ctx, true, func(tx *sql.Tx) error {
+		var err error
+		t, err = loadSQLiteTournament(ctx, tx, id)
+		return err
+	})
+	return t, err
+}
+
+// StoredSchemaVersion returns the oldest schema version among the rows of a
+// tournament.
+func (s *SQLiteStore) StoredSchemaVersion(ctx context.Context, id string) (int, error) {
+	var version int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT MIN(t.schema_version, COALESCE((SELECT MIN(schema_version) FROM matches WHERE tournament_id = t.id), t.schema_version))
+		FROM tournaments t WHERE t.id = ?`, id).Scan(&version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("tournament %s not found", id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getting schema version of tournament %s: %w", id, err)
+	}
+	return version, nil
+}
+
+func (s *SQLiteStore) UpdateTournament(ctx context.Context, t *models.Tournament) error {
+	return s.withTx(ctx, false, func(tx *sql.Tx) error {
+		current, err := sqliteVersion(ctx, tx, t.ID)
+		if err != nil {
+			return err
+		}
+		if current != t.Version {
+			return &VersionConflictError{TournamentID: t.ID, Current: current}
+		}
+
+		t.UpdatedAt = time.Now()
+		t.Version++
+		return writeSQLiteTournament(ctx, tx, t)
+	})
+}
+
+func (s *SQLiteStore) ListTournaments(ctx context.Context) ([]*models.Tournament, error) {
+	tournaments := make([]*models.Tournament, 0)
+	err := s.withTx(ctx, true, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT id FROM tournaments ORDER BY created_at, id`)
+		if err != nil {
+			return fmt.Errorf("listing tournaments: %w", err)
+		}
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return fmt.Errorf("listing tournaments: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("listing tournaments: %w", err)
+		}
+
+		for _, id := range ids {
+			t, err := loadSQLiteTournament(ctx, tx, id)
+			if err != nil {
+				return err
+			}
+			tournaments = append(tournaments, t)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tournaments, nil
+}
+
+// tournamentRecords are the tables keyed by tournament that aren't part of
+// the tournament itself, and so aren't removed by its cascading deletes.
+var tournamentRecords = []string{"audit_entries", "webhooks", "webhook_deliveries", "share_tokens"}
+
+func (s *SQLiteStore) DeleteTournament(ctx context.Context, id string) error {
+	return s.withTx(ctx, false, func(tx *sql.Tx) error {
+		ok, err := affected(tx.ExecContext(ctx, `DELETE FROM tournaments WHERE id = ?`, id))
+		if err != nil {
+			return fmt.Errorf("deleting tournament %s: %w", id, err)
+		}
+		if !ok {
+			return fmt.Errorf("tournament %s not found", id)
+		}
+		for _, table := range tournamentRecords {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE tournament_id = ?`, id); err != nil {
+				return fmt.Errorf("deleting %s of tournament %s: %w", table, id, err)
+			}
+		}
+		return nil
+	})
+}
+
+// --- Match operations ---
+
+func (s *SQLiteStore) UpdateMatchResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, result models.MatchResult, score string, want Precondition) error {
+	return s.updateSQLiteMatch(ctx, tournamentID, roundNumber, matchID, want, func(_ *models.Tournament, _ *models.Round, m *models.Match) error {
+		writeMatchResult(m, result, score, want)
+		return nil
+	})
+}
+
+func (s *SQLiteStore) SetRoundPairings(ctx context.Context, tournamentID string, roundNumber int, matches []models.Match, want Precondition) error {
+	return s.updateSQLiteTournament(ctx, tournamentID, want, func(t *models.Tournament) error {
+		round, _, err := locateMatch(t, roundNumber, "")
+		if err != nil {
+			return err
+		}
+		round.Matches = cloneMatches(matches)
+		writePairings(round.Matches, want)
+		return nil
+	})
+}
+
+func (s *SQLiteStore) UpdateHoleResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, hole int, result string, want Precondition) error {
+	return s.updateSQLiteMatch(ctx, tournamentID, roundNumber, matchID, want, func(t *models.Tournament, round *models.Round, match *models.Match) error {
+		return writeHoleResult(t, round, match, hole, result, want)
+	})
+}
+
+func (s *SQLiteStore) AttestMatch(ctx context.Context, tournamentID string, roundNumber int, matchID string, attestation models.Attestation, want Precondition) error {
+	return s.updateSQLiteMatch(ctx, tournamentID, roundNumber, matchID, want, func(_ *models.Tournament, _ *models.Round, m *models.Match) error {
+		return m.Attest(attestation)
+	})
+}
+
+func (s *SQLiteStore) LinkPlayer(ctx context.Context, tournamentID string, playerID string, email string, want Precondition) error {
+	return s.withTx(ctx, false, func(tx *sql.Tx) error {
+		version, err := sqliteVersion(ctx, tx, tournamentID)
+		if err != nil {
+			return err
+		}
+		if err := want.check(tournamentID, version); err != nil {
+			return err
+		}
+		ok, err := affected(tx.ExecContext(ctx, `UPDATE players SET user_email = ? WHERE tournament_id = ? AND id = ?`, email, tournamentID, playerID))
+		if err != nil {
+			return fmt.Errorf("linking player %s: %w", playerID, err)
+		}
+		if !ok {
+			return fmt.Errorf("player %s not found", playerID)
+		}
+		return bumpSQLiteVersion(ctx, tx, tournamentID)
+	})
+}
+
+func (s *SQLiteStore) SaveRanking(ctx context.Context, tournamentID string, ranking models.PlayerRanking, want Precondition) error {
+	return s.withTx(ctx, false, func(tx *sql.Tx) error {
+		version, err := sqliteVersion(ctx, tx, tournamentID)
+		if err != nil {
+			return err
+		}
+		if err := want.check(tournamentID, version); err != nil {
+			return err
+		}
+		t := &models.Tournament{ID: tournamentID}
+		var rankings sql.NullString
+		if err := tx.QueryRowContext(ctx, `SELECT rankings FROM tournaments WHERE id = ?`, tournamentID).Scan(&rankings); err != nil {
+			return fmt.Errorf("getting rankings of tournament %s: %w", tournamentID, err)
+		}
+		if rankings.Valid {
+			if err := json.Unmarshal([]byte(rankings.String), &t.Rankings); err != nil {
+				return fmt.Errorf("decoding rankings of tournament %s: %w", tournamentID, err)
+			}
+		}
+		setRanking(t, ranking)
+		encoded, err := nullJSON(t.Rankings)
+		if err != nil {
+			return fmt.Errorf("encoding rankings: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE tournaments SET rankings = ? WHERE id = ?`, encoded, tournamentID); err != nil {
+			return fmt.Errorf("saving ranking: %w", err)
+		}
+		return bumpSQLiteVersion(ctx, tx, tournamentID)
+	})
+}
+
+// --- User registry ---
+
+func (s *SQLiteStore) RegisterUser(ctx context.Context, user *models.RegisteredUser) error {
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO registered_users (email, name, picture) VALUES (?, ?, ?)
+		ON CONFLICT (email) DO UPDATE SET name = excluded.name, picture = excluded.picture`,
+		user.Email, user.Name, user.Picture); err != nil {
+		return fmt.Errorf("registering user %s: %w", user.Email, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListRegisteredUsers(ctx context.Context) ([]*models.RegisteredUser, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT email, name, picture FROM registered_users ORDER BY email`)
+	if err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.RegisteredUser, 0)
+	for rows.Next() {
+		var u models.RegisteredUser
+		if err := rows.Scan(&u.Email, &u.Name, &u.Picture); err != nil {
+			return nil, fmt.Errorf("decoding user: %w", err)
+		}
+		users = append(users, &u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing users: %w", err)
+	}
+	return users, nil
+}
+
+// --- Audit log ---
+
+func (s *SQLiteStore) AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error {
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_entries (id, tournament_id, action, round_number, match_id, hole, player_id, user_email, before, after, revert_of, version, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.ID, entry.TournamentID, entry.Action, entry.RoundNumber, entry.MatchID, entry.Hole, entry.PlayerID,
+		entry.UserEmail, nullRaw(entry.Before), nullRaw(entry.After), entry.RevertOf, entry.Version, formatTime(entry.CreatedAt)); err != nil {
+		return fmt.Errorf("writing audit entry: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListAuditEntries(ctx context.Context, tournamentID string) ([]*models.AuditEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, tournament_id, action, round_number, match_id, hole, player_id, user_email, before, after, revert_of, version, created_at
+		FROM audit_entries WHERE tournament_id = ? ORDER BY seq`, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("reading audit log %s: %w", tournamentID, err)
+	}
+	defer rows.Close()
+
+	entries := make([]*models.AuditEntry, 0)
+	for rows.Next() {
+		var e models.AuditEntry
+		var before, after sql.NullString
+		var created string
+		if err := rows.Scan(&e.ID, &e.TournamentID, &e.Action, &e.RoundNumber, &e.MatchID, &e.Hole, &e.PlayerID,
+			&e.UserEmail, &before, &after, &e.RevertOf, &e.Version, &created); err != nil {
+			return nil, fmt.Errorf("decoding audit entry: %w", err)
+		}
+		if before.Valid {
+			e.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			e.After = json.RawMessage(after.String)
+		}
+		if e.CreatedAt, err = parseTime(created); err != nil {
+			return nil, err
+		}
+		entries = append(entries, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading audit log %s: %w", tournamentID, err)
+	}
+	return entries, nil
+}
+
+// --- Webhooks ---
+
+func (s *SQLiteStore) CreateWebhook(ctx context.Context, webhook *models.Webhook) error {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return fmt.Errorf("encoding webhook events: %w", err)
+	}
+	return s.withTx(ctx, false, func(tx *sql.Tx) error {
+		if err := tournamentExists(ctx, tx, webhook.TournamentID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO webhooks (id, tournament_id, url, secret, events, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			webhook.ID, webhook.TournamentID, webhook.URL, webhook.Secret, string(events), formatTime(webhook.CreatedAt)); err != nil {
+			return fmt.Errorf("creating webhook: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) ListWebhooks(ctx context.Context, tournamentID string) ([]*models.Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, tournament_id, url, secret, events, created_at
+		FROM webhooks WHERE tournament_id = ? ORDER BY seq`, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("listing webhooks for tournament %s: %w", tournamentID, err)
+	}
+	defer rows.Close()
+
+	hooks := make([]*models.Webhook, 0)
+	for rows.Next() {
+		var wh models.Webhook
+		var events, created string
+		if err := rows.Scan(&wh.ID, &wh.TournamentID, &wh.URL, &wh.Secret, &events, &created); err != nil {
+			return nil, fmt.Errorf("decoding webhook: %w", err)
+		}
+		if err := json.Unmarshal([]byte(events), &wh.Events); err != nil {
+			return nil, fmt.Errorf("decoding events of webhook %s: %w", wh.ID, err)
+		}
+		if wh.CreatedAt, err = parseTime(created); err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, &wh)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing webhooks for tournament %s: %w", tournamentID, err)
+	}
+	return hooks, nil
+}
+
+func (s *SQLiteStore) DeleteWebhook(ctx context.Context, tournamentID, webhookID string) error {
+	ok, err := affected(s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE tournament_id = ? AND id = ?`, tournamentID, webhookID))
+	if err != nil {
+		return fmt.Errorf("deleting webhook %s: %w", webhookID, err)
+	}
+	if !ok {
+		return fmt.Errorf("webhook %s not found", webhookID)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) SaveWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	var nextAttempt any
+	if d.NextAttemptAt != nil {
+		nextAttempt = formatTime(*d.NextAttemptAt)
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (id, webhook_id, tournament_id, event, payload, attempts, status_code, error, delivered, created_at, last_attempt_at, next_attempt_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			payload = excluded.payload, attempts = excluded.attempts, status_code = excluded.status_code,
+			error = excluded.error, delivered = excluded.delivered, last_attempt_at = excluded.last_attempt_at,
+			next_attempt_at = excluded.next_attempt_at`,
+		d.ID, d.WebhookID, d.TournamentID, d.Event, nullRaw(d.Payload), d.Attempts, d.StatusCode, d.Error, d.Delivered,
+		formatTime(d.CreatedAt), formatTime(d.LastAttemptAt), nextAttempt); err != nil {
+		return fmt.Errorf("writing webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListWebhookDeliveries(ctx context.Context, tournamentID, webhookID string) ([]*models.WebhookDelivery, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, webhook_id, tournament_id, event, payload, attempts, status_code, error, delivered, created_at, last_attempt_at, next_attempt_at
+		FROM webhook_deliveries WHERE tournament_id = ? AND webhook_id = ? ORDER BY seq`, tournamentID, webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("reading delivery log %s: %w", tournamentID, err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload, nextAttempt sql.NullString
+		var created, lastAttempt string
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.TournamentID, &d.Event, &payload, &d.Attempts, &d.StatusCode, &d.Error,
+			&d.Delivered, &created, &lastAttempt, &nextAttempt); err != nil {
+			return nil, fmt.Errorf("decoding webhook delivery: %w", err)
+		}
+		if payload.Valid {
+			d.Payload = json.RawMessage(payload.String)
+		}
+		if d.CreatedAt, err = parseTime(created); err != nil {
+			return nil, err
+		}
+		if d.LastAttemptAt, err = parseTime(lastAttempt); err != nil {
+			return nil, err
+		}
+		if nextAttempt.Valid {
+			next, err := parseTime(nextAttempt.String)
+			if err != nil {
+				return nil, err
+			}
+			d.NextAttemptAt = &next
+		}
+		deliveries = append(deliveries, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading delivery log %s: %w", tournamentID, err)
+	}
+	return deliveries, nil
+}
+
+// --- Share tokens ---
+
+func (s *SQLiteStore) CreateShareToken(ctx context.Context, share *models.ShareToken) error {
+	return s.withTx(ctx, false, func(tx *sql.Tx) error {
+		if err := tournamentExists(ctx, tx, share.TournamentID); err != nil {
+			return err
+		}
+		ok, err := affected(tx.ExecContext(ctx, `
+			INSERT INTO share_tokens (token, tournament_id, label, created_by, created_at) VALUES (?, ?, ?, ?, ?)
+			ON CONFLICT (token) DO NOTHING`,
+			share.Token, share.TournamentID, share.Label, share.CreatedBy, formatTime(share.CreatedAt)))
+		if err != nil {
+			return fmt.Errorf("creating share token: %w", err)
+		}
+		if !ok {
+			return fmt.Errorf("share token already exists")
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteStore) GetShareToken(ctx context.Context, token string) (*models.ShareToken, error) {
+	var share models.ShareToken
+	var created string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT token, tournament_id, label, created_by, created_at FROM share_tokens WHERE token = ?`, token).
+		Scan(&share.Token, &share.TournamentID, &share.Label, &share.CreatedBy, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("share token not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting share token: %w", err)
+	}
+	if share.CreatedAt, err = parseTime(created); err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+func (s *SQLiteStore) ListShareTokens(ctx context.Context, tournamentID string) ([]*models.ShareToken, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT token, tournament_id, label, created_by, created_at FROM share_tokens WHERE tournament_id = ?`, tournamentID)
+	if err != nil {
+		return nil, fmt.Errorf("listing share tokens: %w", err)
+	}
+	defer rows.Close()
+
+	shares := make([]*models.ShareToken, 0)
+	for rows.Next() {
+		var share models.ShareToken
+		var created string
+		if err := rows.Scan(&share.Token, &share.TournamentID, &share.Label, &share.CreatedBy, &created); err != nil {
+			return nil, fmt.Errorf("decoding share token: %w", err)
+		}
+		if share.CreatedAt, err = parseTime(created); err != nil {
+			return nil, err
+		}
+		shares = append(shares, &share)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing share tokens: %w", err)
+	}
+	sortShareTokens(shares)
+	return shares, nil
+}
+
+func (s *SQLiteStore) DeleteShareToken(ctx context.Context, tournamentID, token string) error {
+	ok, err := affected(s.db.ExecContext(ctx, `DELETE FROM share_tokens WHERE token = ? AND tournament_id = ?`, token, tournamentID))
+	if err != nil {
+		return fmt.Errorf("deleting share token: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("share token not found")
+	}
+	return nil
+}
+
+// --- Local users ---
+
+const localUserColumns = `email, name, password_hash, email_verified, confirmed, disabled, verification_token, calendar_key, created_at`
+
+func scanLocalUser(row interface{ Scan(...any) error }) (*models.LocalUser, error) {
+	var u models.LocalUser
+	var created string
+	if err := row.Scan(&u.Email, &u.Name, &u.PasswordHash, &u.EmailVerified, &u.Confirmed, &u.Disabled, &u.VerificationToken, &u.CalendarKey, &created); err != nil {
+		return nil, err
+	}
+	var err error
+	if u.CreatedAt, err = parseTime(created); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *SQLiteStore) CreateLocalUser(ctx context.Context, user *models.LocalUser) error {
+	ok, err := affected(s.db.ExecContext(ctx, `
+		INSERT INTO local_users (email_key, `+localUserColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (email_key) DO NOTHING`,
+		strings.ToLower(user.Email), user.Email, user.Name, user.PasswordHash, user.EmailVerified, user.Confirmed,
+		user.Disabled, user.VerificationToken, user.CalendarKey, formatTime(user.CreatedAt)))
+	if err != nil {
+		return fmt.Errorf("creating user: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("a user with email %s already exists", user.Email)
+	}
+	return nil
+}
+
+// ImportLocalUser writes a user as-is, replacing any user with the same email.
+func (s *SQLiteStore) ImportLocalUser(ctx context.Context, user *models.LocalUser) error {
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT OR REPLACE INTO local_users (email_key, `+localUserColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		strings.ToLower(user.Email), user.Email, user.Name, user.PasswordHash, user.EmailVerified, user.Confirmed,
+		user.Disabled, user.VerificationToken, user.CalendarKey, formatTime(user.CreatedAt)); err != nil {
+		return fmt.Errorf("importing user %s: %w", user.Email, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) GetLocalUser(ctx context.Context, email string) (*models.LocalUser, error) {
+	u, err := scanLocalUser(s.db.QueryRowContext(ctx, `SELECT `+localUserColumns+` FROM local_users WHERE email_key = ?`, strings.ToLower(email)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting user: %w", err)
+	}
+	return u, nil
+}
+
+func (s *SQLiteStore) VerifyLocalUser(ctx context.Context, token string) error {
+	if token == "" {
+		return fmt.Errorf("invalid verification token")
+	}
+	ok, err := affected(s.db.ExecContext(ctx, `
+		UPDATE local_users SET email_verified = 1, verification_token = '' WHERE verification_token = ?`, token))
+	if err != nil {
+		return fmt.Errorf("verifying user: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("invalid verification token")
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ListLocalUsers(ctx context.Context) ([]*models.LocalUser, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+localUserColumns+` FROM local_users ORDER BY created_at, email_key`)
+	if err != nil {
+		return nil, fmt.Errorf("listing local users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]*models.LocalUser, 0)
+	for rows.Next() {
+		u, err := scanLocalUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("decoding local user: %w", err)
+		}
+		users = append(users, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listing local users: %w", err)
+	}
+	return users, nil
+}
+
+// setLocalUserColumn updates one column of a user, identified by email.
+func (s *SQLiteStore) setLocalUserColumn(ctx context.Context, email, column string, value any) error {
+	ok, err := affected(s.db.ExecContext(ctx, `UPDATE local_users SET `+column+` = ? WHERE email_key = ?`, value, strings.ToLower(email)))
+	if err != nil {
+		return fmt.Errorf("updating user: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("user not found")
+	}
+	return nil
+}
+
+func (s *SQLiteStore) ConfirmLocalUser(ctx context.Context, email string) error {
+	return s.setLocalUserColumn(ctx, email, "confirmed", true)
+}
+
+func (s *SQLiteStore) DeleteLocalUser(ctx context.Context, email string) error {
+	return s.setLocalUserColumn(ctx, email, "disabled", true)
+}
+
+func (s *SQLiteStore) EnableLocalUser(ctx context.Context, email string) error {
+	return s.setLocalUserColumn(ctx, email, "disabled", false)
+}
+
+func (s *SQLiteStore) SetCalendarKey(ctx context.Context, email, key string) error {
+	return s.setLocalUserColumn(ctx, email, "calendar_key", key)
+}
@@ -0,0 +1,237 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// sqliteMigrations are applied in order, each in its own transaction. The
+// schema version is the number of migrations applied; never edit or reorder
+// an entry once released, only append.
+var sqliteMigrations = []string{
+	// 1: initial schema
+	`
+	CREATE TABLE tournaments (
+		id               TEXT PRIMARY KEY,
+		name             TEXT NOT NULL,
+		header_color     TEXT NOT NULL DEFAULT '',
+		bg_color         TEXT NOT NULL DEFAULT '',
+		locked           INTEGER NOT NULL DEFAULT 0,
+		combine_rounds23 INTEGER NOT NULL DEFAULT 0,
+		rankings         TEXT,
+		rankings_locked  INTEGER NOT NULL DEFAULT 0,
+		created_at       TEXT NOT NULL,
+		updated_at       TEXT NOT NULL,
+		version          INTEGER NOT NULL
+	);
+
+	CREATE TABLE teams (
+		tournament_id TEXT NOT NULL REFERENCES tournaments(id) ON DELETE CASCADE,
+		position      INTEGER NOT NULL,
+		id            TEXT NOT NULL,
+		name          TEXT NOT NULL,
+		color         TEXT NOT NULL DEFAULT '',
+		logo          TEXT NOT NULL DEFAULT '',
+		PRIMARY KEY (tournament_id, position)
+	);
+
+	CREATE TABLE players (
+		tournament_id TEXT NOT NULL REFERENCES tournaments(id) ON DELETE CASCADE,
+		team_position INTEGER NOT NULL,
+		position      INTEGER NOT NULL,
+		id            TEXT NOT NULL,
+		name          TEXT NOT NULL,
+		team_id       TEXT NOT NULL DEFAULT '',
+		user_email    TEXT NOT NULL DEFAULT '',
+		handicap      REAL,
+		PRIMARY KEY (tournament_id, team_position, position)
+	);
+	CREATE INDEX players_user_email ON players (user_email) WHERE user_email <> '';
+
+	CREATE TABLE rounds (
+		tournament_id    TEXT NOT NULL REFERENCES tournaments(id) ON DELETE CASCADE,
+		number           INTEGER NOT NULL,
+		position         INTEGER NOT NULL,
+		name             TEXT NOT NULL,
+		type             TEXT NOT NULL,
+		points_per_match REAL NOT NULL,
+		holes            INTEGER NOT NULL DEFAULT 0,
+		locked           INTEGER NOT NULL DEFAULT 0,
+		PRIMARY KEY (tournament_id, number)
+	);
+
+	CREATE TABLE matches (
+		tournament_id      TEXT NOT NULL REFERENCES tournaments(id) ON DELETE CASCADE,
+		id                 TEXT NOT NULL,
+		round_number       INTEGER NOT NULL,
+		position           INTEGER NOT NULL,
+		team1_players      TEXT NOT NULL,
+		team2_players      TEXT NOT NULL,
+		result             TEXT NOT NULL,
+		score              TEXT NOT NULL DEFAULT '',
+		attestation_status TEXT NOT NULL DEFAULT '',
+		attestations       TEXT,
+		tee_time           TEXT,
+		PRIMARY KEY (tournament_id, id)
+	);
+
+	CREATE TABLE hole_results (
+		tournament_id TEXT NOT NULL,
+		match_id      TEXT NOT NULL,
+		hole          INTEGER NOT NULL,
+		result        TEXT NOT NULL,
+		PRIMARY KEY (tournament_id, match_id, hole),
+		FOREIGN KEY (tournament_id, match_id) REFERENCES matches(tournament_id, id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE registered_users (
+		email   TEXT PRIMARY KEY,
+		name    TEXT NOT NULL,
+		picture TEXT NOT NULL DEFAULT ''
+	);
+
+	CREATE TABLE local_users (
+		email_key          TEXT PRIMARY KEY,
+		email              TEXT NOT NULL,
+		name               TEXT NOT NULL,
+		password_hash      TEXT NOT NULL,
+		email_verified     INTEGER NOT NULL DEFAULT 0,
+		confirmed          INTEGER NOT NULL DEFAULT 0,
+		disabled           INTEGER NOT NULL DEFAULT 0,
+		verification_token TEXT NOT NULL DEFAULT '',
+		created_at         TEXT NOT NULL
+	);
+	CREATE INDEX local_users_verification_token ON local_users (verification_token) WHERE verification_token <> '';
+
+	CREATE TABLE audit_entries (
+		seq           INTEGER PRIMARY KEY AUTOINCREMENT,
+		id            TEXT NOT NULL,
+		tournament_id TEXT NOT NULL,
+		action        TEXT NOT NULL,
+		round_number  INTEGER NOT NULL DEFAULT 0,
+		match_id      TEXT NOT NULL DEFAULT '',
+		hole          INTEGER NOT NULL DEFAULT 0,
+		player_id     TEXT NOT NULL DEFAULT '',
+		user_email    TEXT NOT NULL,
+		before        TEXT,
+		after         TEXT,
+		revert_of     TEXT NOT NULL DEFAULT '',
+		created_at    TEXT NOT NULL
+	);
+	CREATE INDEX audit_entries_tournament ON audit_entries (tournament_id, seq);
+
+	CREATE TABLE webhooks (
+		seq           INTEGER PRIMARY KEY AUTOINCREMENT,
+		id            TEXT NOT NULL UNIQUE,
+		tournament_id TEXT NOT NULL,
+		url           TEXT NOT NULL,
+		secret        TEXT NOT NULL DEFAULT '',
+		events        TEXT NOT NULL,
+		created_at    TEXT NOT NULL
+	);
+	CREATE INDEX webhooks_tournament ON webhooks (tournament_id, seq);
+
+	CREATE TABLE webhook_deliveries (
+		seq             INTEGER PRIMARY KEY AUTOINCREMENT,
+		id              TEXT NOT NULL UNIQUE,
+		webhook_id      TEXT NOT NULL,
+		tournament_id   TEXT NOT NULL,
+		event           TEXT NOT NULL,
+		payload         TEXT,
+		attempts        INTEGER NOT NULL DEFAULT 0,
+		status_code     INTEGER NOT NULL DEFAULT 0,
+		error           TEXT NOT NULL DEFAULT '',
+		delivered       INTEGER NOT NULL DEFAULT 0,
+		created_at      TEXT NOT NULL,
+		last_attempt_at TEXT NOT NULL
+	);
+	CREATE INDEX webhook_deliveries_webhook ON webhook_deliveries (tournament_id, webhook_id, seq);
+
+	CREATE TABLE share_tokens (
+		token         TEXT PRIMARY KEY,
+		tournament_id TEXT NOT NULL,
+		label         TEXT NOT NULL DEFAULT '',
+		created_by    TEXT NOT NULL,
+		created_at    TEXT NOT NULL
+	);
+	CREATE INDEX share_tokens_tournament ON share_tokens (tournament_id);
+	`,
+	// 2: document schema versions (see package schema). Rows written before
+	// this were already in the version 1 form.
+	`
+	ALTER TABLE tournaments ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 1;
+	ALTER TABLE matches ADD COLUMN schema_version INTEGER NOT NULL DEFAULT 1;
+	`,
+	// 3: the match version each audited change to a match produced. Older
+	// entries stay at 0, meaning unknown.
+	`
+	ALTER TABLE audit_entries ADD COLUMN version INTEGER NOT NULL DEFAULT 0;
+	`,
+	// 4: per-match versions. Existing matches start at 1.
+	`
+	ALTER TABLE matches ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+	`,
+	// 5: the key each user's calendar feed URL is signed with.
+	`
+	ALTER TABLE local_users ADD COLUMN calendar_key TEXT NOT NULL DEFAULT '';
+	`,
+	// 6: the last write to each hole of a match, and to its result.
+	`
+	ALTER TABLE matches ADD COLUMN hole_writes TEXT;
+	ALTER TABLE matches ADD COLUMN result_write TEXT;
+	`,
+	// 7: when each undelivered webhook delivery is due to be retried.
+	`
+	ALTER TABLE webhook_deliveries ADD COLUMN next_attempt_at TEXT;
+	`,
+}
+
+// migrateSQLite brings the database schema up to date.
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return fmt.Errorf("creating schema_migrations: %w", err)
+	}
+
+	var current int
+	if err := db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	if current > len(sqliteMigrations) {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d)", current, len(sqliteMigrations))
+	}
+
+	for v := current + 1; v <= len(sqliteMigrations); v++ {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		// Another process may have applied it while this one waited for the
+		// write lock.
+		var applied int
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, v).Scan(&applied); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("reading schema version: %w", err)
+		}
+		if applied > 0 {
+			tx.Rollback()
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, sqliteMigrations[v-1]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("applying schema migration %d: %w", v, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, v, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("recording schema migration %d: %w", v, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("committing schema migration %d: %w", v, err)
+		}
+	}
+	return nil
+}
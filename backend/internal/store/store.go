@@ -2,30 +2,196 @@ package store
 
 import (
 	"context"
+	"fmt"
 	"scoring-backend/internal/models"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// SchemaVersioner is implemented by stores that keep the schema version each
+// document was written at, so tools can tell which tournaments still hold
+// documents from an older one.
+type SchemaVersioner interface {
+	// StoredSchemaVersion returns the oldest schema version among a
+	// tournament's stored header and matches.
+	StoredSchemaVersion(ctx context.Context, tournamentID string) (int, error)
+}
+
+// VersionConflictError is returned by UpdateTournament, and by the
+// fine-grained writes given a Precondition, when the tournament has been
+// changed since the caller read it.
+type VersionConflictError struct {
+	TournamentID string
+	MatchID      string // set when it was the match's version that didn't match
+	Current      int64
+}
+
+func (e *VersionConflictError) Error() string {
+	if e.MatchID != "" {
+		return fmt.Sprintf("match %s was modified by someone else (current version %d)", e.MatchID, e.Current)
+	}
+	return fmt.Sprintf("tournament %s was modified by someone else (current version %d)", e.TournamentID, e.Current)
+}
+
+// Precondition is checked by a fine-grained write (a match, pairings or
+// player link change) under the same lock or transaction as the write itself,
+// so a caller that read the tournament first can make the write conditional
+// on nothing having changed since. Zero fields are not checked.
+type Precondition struct {
+	Version      int64 // the tournament version the caller read
+	MatchVersion int64 // the version of the match a match write changes
+	// Writer is who is making a hole, result or pairings write. The store
+	// records it, with the resulting match version and the time (now, unless
+	// Writer.At is set), as the last write of what changed (see
+	// models.Match.HoleWrites).
+	Writer models.Write
+	// Sync, for a hole write, makes it conditional on the hole's last write
+	// instead of on the match version alone.
+	Sync *HoleSync
+}
+
+// HoleSync decides a hole result queued on a device against the last change
+// made to the hole, or to the match's result directly, since BaseVersion, the
+// match version the device last saw. Changes in Own, match versions written
+// by earlier entries of the same sync, don't count; a change by the same user
+// from another device does. The entry goes ahead over a change by these
+// rules, in order:
+//   - an admin's entry beats a non-admin's change, and the reverse;
+//   - otherwise the later of the two wins, taking At, when the entry was made
+//     (never later than now), against the time of the change.
+//
+// A losing entry fails with a *SupersededError.
+type HoleSync struct {
+	BaseVersion int64
+	At          time.Time
+	Own         []int64
+	// Overrode is set to the change an entry went ahead over, if any.
+	Overrode *models.Write
+}
+
+// SupersededError is returned by a hole write with a HoleSync that lost to a
+// change made since the device last saw the match.
+type SupersededError struct {
+	MatchID string
+	Hole    int
+	Result  string       // the hole's result, which stands
+	Change  models.Write // the change the entry lost to
+}
+
+func (e *SupersededError) Error() string {
+	return fmt.Sprintf("hole %d of match %s was changed by %s since", e.Hole, e.MatchID, e.Change.Email)
+}
+
+// decide weighs the entry's writer against the latest change to m's hole
+// since the base version, returning a *SupersededError if the entry loses.
+func (s *HoleSync) decide(m *models.Match, hole int, writer models.Write) error {
+	key := strconv.Itoa(hole)
+	counts := func(w models.Write) bool {
+		return w.Version > s.BaseVersion && !slices.Contains(s.Own, w.Version)
+	}
+	var last *models.Write
+	if w, ok := m.HoleWrites[key]; ok && counts(w) {
+		last = &w
+	}
+	if w := m.ResultWrite; w != nil && counts(*w) && (last == nil || w.Version > last.Version) {
+		last = w
+	}
+	if last == nil {
+		return nil
+	}
+
+	lost := false
+	switch {
+	case writer.Admin && !last.Admin:
+	case !writer.Admin && last.Admin:
+		lost = true
+	default:
+		lost = !s.At.After(last.At)
+	}
+	if lost {
+		return &SupersededError{MatchID: m.ID, Hole: hole, Result: m.HoleResults[key], Change: *last}
+	}
+	change := *last
+	s.Overrode = &change
+	return nil
+}
+
+// check returns a *VersionConflictError if the tournament's stored version
+// doesn't meet the precondition.
+func (p Precondition) check(tournamentID string, version int64) error {
+	if p.Version != 0 && p.Version != version {
+		return &VersionConflictError{TournamentID: tournamentID, Current: version}
+	}
+	return nil
+}
+
+// checkMatch returns a *VersionConflictError naming the match if m's version
+// doesn't meet the precondition.
+func (p Precondition) checkMatch(tournamentID string, m *models.Match) error {
+	if current := matchVersion(m); p.MatchVersion != 0 && p.MatchVersion != current {
+		return &VersionConflictError{TournamentID: tournamentID, MatchID: m.ID, Current: current}
+	}
+	return nil
+}
+
 // Store defines the interface for tournament data persistence.
 // Implementations can back this with in-memory storage, Firestore, or any other provider.
 type Store interface {
-	// Tournament CRUD
+	// Tournament CRUD. UpdateTournament returns a *VersionConflictError unless
+	// t.Version matches the stored version; every write bumps the version.
+	// DeleteTournament also deletes the tournament's audit log, webhooks,
+	// webhook deliveries and share tokens.
 	CreateTournament(ctx context.Context, t *models.Tournament) error
 	GetTournament(ctx context.Context, id string) (*models.Tournament, error)
 	UpdateTournament(ctx context.Context, t *models.Tournament) error
 	ListTournaments(ctx context.Context) ([]*models.Tournament, error)
 	DeleteTournament(ctx context.Context, id string) error
+	// ImportTournament writes t as-is, keeping its timestamps and version and
+	// replacing any tournament with the same ID. It is for restoring and
+	// migrating data, not for edits.
+	ImportTournament(ctx context.Context, t *models.Tournament) error
 
-	// Match operations
-	UpdateMatchResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, result models.MatchResult, score string) error
-	SetRoundPairings(ctx context.Context, tournamentID string, roundNumber int, matches []models.Match) error
-	UpdateHoleResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, hole int, result string) error
+	// Match operations. Each bumps the tournament version, and the match
+	// version of the match it changes, and fails with a *VersionConflictError
+	// if want isn't met.
+	UpdateMatchResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, result models.MatchResult, score string, want Precondition) error
+	SetRoundPairings(ctx context.Context, tournamentID string, roundNumber int, matches []models.Match, want Precondition) error
+	UpdateHoleResult(ctx context.Context, tournamentID string, roundNumber int, matchID string, hole int, result string, want Precondition) error
+	AttestMatch(ctx context.Context, tournamentID string, roundNumber int, matchID string, attestation models.Attestation, want Precondition) error
 
 	// User registry
 	RegisterUser(ctx context.Context, user *models.RegisteredUser) error
 	ListRegisteredUsers(ctx context.Context) ([]*models.RegisteredUser, error)
 
+	// Audit log (append-only, oldest entry first)
+	AppendAuditEntry(ctx context.Context, entry *models.AuditEntry) error
+	ListAuditEntries(ctx context.Context, tournamentID string) ([]*models.AuditEntry, error)
+
+	// Webhooks. SaveWebhookDelivery inserts or replaces a delivery by ID;
+	// deliveries are listed oldest first.
+	CreateWebhook(ctx context.Context, webhook *models.Webhook) error
+	ListWebhooks(ctx context.Context, tournamentID string) ([]*models.Webhook, error)
+	DeleteWebhook(ctx context.Context, tournamentID, webhookID string) error
+	SaveWebhookDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	ListWebhookDeliveries(ctx context.Context, tournamentID, webhookID string) ([]*models.WebhookDelivery, error)
+
+	// Spectator share tokens. GetShareToken looks a token up across all
+	// tournaments.
+	CreateShareToken(ctx context.Context, share *models.ShareToken) error
+	GetShareToken(ctx context.Context, token string) (*models.ShareToken, error)
+	ListShareTokens(ctx context.Context, tournamentID string) ([]*models.ShareToken, error)
+	DeleteShareToken(ctx context.Context, tournamentID, token string) error
+
 	// Player-user linking
-	LinkPlayer(ctx context.Context, tournamentID string, playerID string, email string) error
+	LinkPlayer(ctx context.Context, tournamentID string, playerID string, email string, want Precondition) error
+
+	// SaveRanking replaces the player ranking submitted by the same user
+	// (emails compared case-insensitively), or adds it, and bumps the
+	// tournament version.
+	SaveRanking(ctx context.Context, tournamentID string, ranking models.PlayerRanking, want Precondition) error
 
 	// Local user registration
 	CreateLocalUser(ctx context.Context, user *models.LocalUser) error
@@ -35,4 +201,157 @@ type Store interface {
 	ConfirmLocalUser(ctx context.Context, email string) error
 	DeleteLocalUser(ctx context.Context, email string) error
 	EnableLocalUser(ctx context.Context, email string) error
+	// SetCalendarKey replaces the key a user's calendar feed URL is signed
+	// with, so any earlier URL stops working.
+	SetCalendarKey(ctx context.Context, email, key string) error
+	// ImportLocalUser writes user as-is, replacing any user with the same
+	// email. Like ImportTournament it is only for restoring and migrating.
+	ImportLocalUser(ctx context.Context, user *models.LocalUser) error
+}
+
+// setRanking replaces the ranking submitted by ranking.SubmittedBy, or adds it.
+func setRanking(t *models.Tournament, ranking models.PlayerRanking) {
+	for i, rk := range t.Rankings {
+		if strings.EqualFold(rk.SubmittedBy, ranking.SubmittedBy) {
+			t.Rankings[i] = ranking
+			return
+		}
+	}
+	t.Rankings = append(t.Rankings, ranking)
+}
+
+// sortShareTokens orders share tokens oldest first.
+func sortShareTokens(shares []*models.ShareToken) {
+	sort.Slice(shares, func(i, j int) bool { return shares[i].CreatedAt.Before(shares[j].CreatedAt) })
+}
+
+// normalizeTournament initializes nil slices and maps, so that every store
+// hands back the same shape whether or not its encoding keeps empty values.
+func normalizeTournament(t *models.Tournament) {
+	for i := range t.Teams {
+		if t.Teams[i].Players == nil {
+			t.Teams[i].Players = []models.Player{}
+		}
+	}
+	if t.Rounds == nil {
+		t.Rounds = []models.Round{}
+	}
+	for i := range t.Rounds {
+		if t.Rounds[i].Matches == nil {
+			t.Rounds[i].Matches = []models.Match{}
+		}
+		for j := range t.Rounds[i].Matches {
+			normalizeMatch(&t.Rounds[i].Matches[j])
+		}
+	}
+}
+
+func normalizeMatch(m *models.Match) {
+	m.Version = matchVersion(m)
+	if m.HoleResults == nil {
+		m.HoleResults = make(map[string]string)
+	}
+	for hole, result := range m.HoleResults {
+		if result == "" {
+			delete(m.HoleResults, hole)
+		}
+	}
+	if m.Team1Players == nil {
+		m.Team1Players = []string{}
+	}
+	if m.Team2Players == nil {
+		m.Team2Players = []string{}
+	}
+}
+
+// matchVersion is m's version, counting a match saved before matches had
+// versions, or not yet saved, as version 1.
+func matchVersion(m *models.Match) int64 {
+	if m.Version == 0 {
+		return 1
+	}
+	return m.Version
+}
+
+// bumpMatch moves a match to its next version after a change to it alone.
+func bumpMatch(m *models.Match) {
+	m.Version = matchVersion(m) + 1
+}
+
+// written is want.Writer as the write that moves m to its next version.
+func written(m *models.Match, want Precondition) models.Write {
+	w := want.Writer
+	if w.At.IsZero() {
+		w.At = time.Now()
+	}
+	w.Version = matchVersion(m) + 1
+	return w
+}
+
+// writeHoleResult is setHoleResult for a write to a single hole: it decides a
+// synced entry against the hole's last write first, and records the write.
+// Like setHoleResult it leaves the match version to the caller.
+func writeHoleResult(t *models.Tournament, round *models.Round, m *models.Match, hole int, result string, want Precondition) error {
+	if want.Sync != nil {
+		if err := want.Sync.decide(m, hole, want.Writer); err != nil {
+			return err
+		}
+	}
+	w := written(m, want)
+	setHoleResult(t, round, m, hole, result)
+	if m.HoleWrites == nil {
+		m.HoleWrites = make(map[string]models.Write)
+	}
+	m.HoleWrites[strconv.Itoa(hole)] = w
+	return nil
+}
+
+// writeMatchResult is setMatchResult, recording the write.
+func writeMatchResult(m *models.Match, result models.MatchResult, score string, want Precondition) {
+	w := written(m, want)
+	setMatchResult(m, result, score)
+	m.ResultWrite = &w
+}
+
+// writePairings records a pairings write on each of a round's new matches,
+// at the version each is saved at.
+func writePairings(matches []models.Match, want Precondition) {
+	for i := range matches {
+		w := want.Writer
+		if w.At.IsZero() {
+			w.At = time.Now()
+		}
+		w.Version = matchVersion(&matches[i])
+		matches[i].ResultWrite = &w
+	}
+}
+
+// setMatchResult records a result entered directly by an admin.
+func setMatchResult(m *models.Match, result models.MatchResult, score string) {
+	m.Result = result
+	m.Score = score
+	// A result set directly by an admin is official as-is.
+	m.AttestationStatus = models.AttestationNone
+	m.Attestations = nil
+}
+
+// setHoleResult records one hole of a match (an empty result clears it),
+// halves any earlier hole left empty and rescores the match.
+func setHoleResult(t *models.Tournament, round *models.Round, match *models.Match, hole int, result string) {
+	if match.HoleResults == nil {
+		match.HoleResults = make(map[string]string)
+	}
+	key := strconv.Itoa(hole)
+	if result == "" {
+		delete(match.HoleResults, key)
+	} else {
+		match.HoleResults[key] = result
+	}
+	for h := 1; h < hole; h++ {
+		k := strconv.Itoa(h)
+		if match.HoleResults[k] == "" {
+			match.HoleResults[k] = "halved"
+		}
+	}
+	match.SetScoredResult(models.CalculateMatchPlayResult(match.HoleResults, t.Teams[0].Name, t.Teams[1].Name, round.HoleCount()))
 }
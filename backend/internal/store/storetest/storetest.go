@@ -0,0 +1,1259 @@
+// Package storetest holds the behavioral contract every store.Store must
+// meet. Each backend's tests hand Run a constructor:
+//
+//	func TestMemoryStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) store.Store { return store.NewMemoryStore() })
+//	}
+//
+// Every test makes its own IDs and emails, so a constructor may return the
+// same database each time; nothing checks that a list holds only what the
+// test wrote.
+package storetest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/schema"
+	"scoring-backend/internal/store"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Factory returns the store a single test runs against. It may register
+// cleanup with t.
+type Factory func(t *testing.T) store.Store
+
+// Run runs the whole suite against stores made by newStore.
+func Run(t *testing.T, newStore Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"EmptyCollections", testEmptyCollections},
+		{"ReturnedCopies", testReturnedCopies},
+		{"UpdateVersioning", testUpdateVersioning},
+		{"ListAndDelete", testListAndDelete},
+		{"DeleteRecords", testDeleteRecords},
+		{"Import", testImport},
+		{"SchemaVersion", testSchemaVersion},
+		{"MatchResult", testMatchResult},
+		{"HoleResults", testHoleResults},
+		{"Attestation", testAttestation},
+		{"RoundPairings", testRoundPairings},
+		{"LinkPlayer", testLinkPlayer},
+		{"SaveRanking", testSaveRanking},
+		{"Preconditions", testPreconditions},
+		{"HoleSync", testHoleSync},
+		{"RegisteredUsers", testRegisteredUsers},
+		{"LocalUserLifecycle", testLocalUserLifecycle},
+		{"AuditLog", testAuditLog},
+		{"Webhooks", testWebhooks},
+		{"ShareTokens", testShareTokens},
+		{"ConcurrentHoleUpdates", testConcurrentHoleUpdates},
+		{"ConcurrentMatchWrites", testConcurrentMatchWrites},
+		{"ConcurrentTournamentUpdates", testConcurrentTournamentUpdates},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+// --- Fixtures ---
+
+func newID(prefix string) string {
+	return prefix + "-" + uuid.NewString()
+}
+
+// newTournament builds an unsaved tournament with two players a side, a
+// four-match 18-hole round 1 and a one-match 9-hole round 2.
+func newTournament() *models.Tournament {
+	t := &models.Tournament{ID: newID("t"), Name: "Ryder Cup"}
+	for i, name := range []string{"Europe", "USA"} {
+		team := models.Team{ID: newID("team"), Name: name}
+		for j := 0; j < 2; j++ {
+			team.Players = append(team.Players, models.Player{ID: newID("p"), Name: fmt.Sprintf("%s %d", name, j+1), TeamID: team.ID})
+		}
+		t.Teams[i] = team
+	}
+	p := func(team, i int) string { return t.Teams[team].Players[i].ID }
+
+	round1 := models.Round{Number: 1, Name: "Fourballs", Type: models.RoundFourBall, PointsPerMatch: 1}
+	for i := 0; i < 4; i++ {
+		round1.Matches = append(round1.Matches, models.Match{
+			ID:           newID("m"),
+			RoundNumber:  1,
+			Team1Players: []string{p(0, 0), p(0, 1)},
+			Team2Players: []string{p(1, 0), p(1, 1)},
+			Result:       models.ResultPending,
+			HoleResults:  map[string]string{},
+			Version:      1,
+		})
+	}
+	round2 := models.Round{Number: 2, Name: "Singles", Type: models.RoundSingles, PointsPerMatch: 0.5, Holes: 9}
+	round2.Matches = []models.Match{{
+		ID:           newID("m"),
+		RoundNumber:  2,
+		Team1Players: []string{p(0, 0)},
+		Team2Players: []string{p(1, 0)},
+		Result:       models.ResultPending,
+		HoleResults:  map[string]string{},
+		Version:      1,
+	}}
+	t.Rounds = []models.Round{round1, round2}
+	return t
+}
+
+func create(t *testing.T, s store.Store) *models.Tournament {
+	t.Helper()
+	tour := newTournament()
+	if err := s.CreateTournament(context.Background(), tour); err != nil {
+		t.Fatalf("CreateTournament: %v", err)
+	}
+	return tour
+}
+
+func get(t *testing.T, s store.Store, id string) *models.Tournament {
+	t.Helper()
+	tour, err := s.GetTournament(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetTournament(%s): %v", id, err)
+	}
+	return tour
+}
+
+func findMatch(t *testing.T, tour *models.Tournament, round int, matchID string) *models.Match {
+	t.Helper()
+	for i := range tour.Rounds {
+		if tour.Rounds[i].Number != round {
+			continue
+		}
+		for j := range tour.Rounds[i].Matches {
+			if tour.Rounds[i].Matches[j].ID == matchID {
+				return &tour.Rounds[i].Matches[j]
+			}
+		}
+	}
+	t.Fatalf("match %s not in round %d", matchID, round)
+	return nil
+}
+
+// --- Assertions ---
+
+// canonical renders v as JSON with every timestamp in UTC at microsecond
+// precision, the finest that all backends keep.
+func canonical(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return fmt.Sprintf("<%v>", err)
+	}
+	out, _ := json.Marshal(canonicalTimes(tree))
+	return string(out)
+}
+
+func canonicalTimes(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = canonicalTimes(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = canonicalTimes(e)
+		}
+	case string:
+		if ts, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return ts.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+		}
+	}
+	return v
+}
+
+func assertSame(t *testing.T, what string, want, got any) {
+	t.Helper()
+	if w, g := canonical(want), canonical(got); w != g {
+		t.Errorf("%s differs\nwant %s\n got %s", what, w, g)
+	}
+}
+
+// assertErr checks that err is exactly the message the handlers pass on to
+// clients.
+func assertErr(t *testing.T, op string, err error, want string) {
+	t.Helper()
+	if err == nil {
+		t.Errorf("%s: got no error, want %q", op, want)
+	} else if err.Error() != want {
+		t.Errorf("%s: got error %q, want %q", op, err, want)
+	}
+}
+
+func assertNoErr(t *testing.T, op string, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", op, err)
+	}
+}
+
+func assertVersion(t *testing.T, s store.Store, id string, want int64) {
+	t.Helper()
+	if got := get(t, s, id).Version; got != want {
+		t.Errorf("version = %d, want %d", got, want)
+	}
+}
+
+// --- Tournaments ---
+
+func testCreateAndGet(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	before := time.Now().Add(-time.Second)
+	tour := create(t, s)
+
+	if tour.Version != 1 {
+		t.Errorf("CreateTournament set version %d, want 1", tour.Version)
+	}
+	if tour.CreatedAt.Before(before) || !tour.UpdatedAt.Equal(tour.CreatedAt) {
+		t.Errorf("CreateTournament set createdAt %v, updatedAt %v", tour.CreatedAt, tour.UpdatedAt)
+	}
+	assertSame(t, "stored tournament", tour, get(t, s, tour.ID))
+
+	dup := newTournament()
+	dup.ID = tour.ID
+	assertErr(t, "CreateTournament duplicate", s.CreateTournament(ctx, dup), fmt.Sprintf("tournament %s already exists", tour.ID))
+
+	missing := newID("t")
+	_, err := s.GetTournament(ctx, missing)
+	assertErr(t, "GetTournament missing", err, fmt.Sprintf("tournament %s not found", missing))
+}
+
+// testEmptyCollections checks that nil slices and maps come back empty
+// rather than nil, whatever the backend's encoding does with them.
+func testEmptyCollections(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	bare := &models.Tournament{ID: newID("t"), Name: "Bare"}
+	assertNoErr(t, "CreateTournament", s.CreateTournament(ctx, bare))
+
+	sparse := newTournament()
+	sparse.Teams[1].Players = nil
+	sparse.Rounds[0].Matches[0].HoleResults = nil
+	sparse.Rounds[0].Matches[1].Team1Players = nil
+	sparse.Rounds[1].Matches = nil
+	assertNoErr(t, "CreateTournament", s.CreateTournament(ctx, sparse))
+
+	check := func(tour *models.Tournament) {
+		t.Helper()
+		if tour.Rounds == nil {
+			t.Errorf("%s: rounds are nil", tour.Name)
+		}
+		for _, team := range tour.Teams {
+			if team.Players == nil {
+				t.Errorf("%s: players of team %q are nil", tour.Name, team.Name)
+			}
+		}
+		for _, r := range tour.Rounds {
+			if r.Matches == nil {
+				t.Errorf("%s: matches of round %d are nil", tour.Name, r.Number)
+			}
+			for _, m := range r.Matches {
+				if m.HoleResults == nil || m.Team1Players == nil || m.Team2Players == nil {
+					t.Errorf("%s: match %s has nil hole results or players", tour.Name, m.ID)
+				}
+			}
+		}
+	}
+	check(get(t, s, bare.ID))
+	check(get(t, s, sparse.ID))
+
+	all, err := s.ListTournaments(ctx)
+	assertNoErr(t, "ListTournaments", err)
+	for _, tour := range all {
+		if tour.ID == bare.ID || tour.ID == sparse.ID {
+			check(tour)
+		}
+	}
+}
+
+func testReturnedCopies(t *testing.T, s store.Store) {
+	tour := create(t, s)
+	want := get(t, s, tour.ID)
+
+	got := get(t, s, tour.ID)
+	got.Name = "Changed"
+	got.Teams[0].Players[0].Name = "Changed"
+	got.Rounds[0].Matches[0].HoleResults["1"] = "team1"
+	got.Rounds[0].Matches[0].Team1Players[0] = "changed"
+
+	assertSame(t, "tournament after changing a returned copy", want, get(t, s, tour.ID))
+}
+
+func testUpdateVersioning(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+
+	edit := get(t, s, tour.ID)
+	edit.Name = "Solheim Cup"
+	edit.HeaderColor = "#123456"
+	edit.Teams[0].Players = append(edit.Teams[0].Players, models.Player{ID: newID("p"), Name: "Late entry", TeamID: edit.Teams[0].ID})
+	assertNoErr(t, "UpdateTournament", s.UpdateTournament(ctx, edit))
+	if edit.Version != 2 {
+		t.Errorf("UpdateTournament set version %d, want 2", edit.Version)
+	}
+	assertSame(t, "updated tournament", edit, get(t, s, tour.ID))
+
+	stale := get(t, s, tour.ID)
+	stale.Version = 1
+	err := s.UpdateTournament(ctx, stale)
+	var conflict *store.VersionConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("UpdateTournament with a stale version: got %v, want a *VersionConflictError", err)
+	}
+	if conflict.TournamentID != tour.ID || conflict.Current != 2 {
+		t.Errorf("conflict = %+v, want tournament %s at version 2", conflict, tour.ID)
+	}
+	assertVersion(t, s, tour.ID, 2)
+
+	missing := newTournament()
+	missing.Version = 1
+	assertErr(t, "UpdateTournament missing", s.UpdateTournament(ctx, missing), fmt.Sprintf("tournament %s not found", missing.ID))
+}
+
+func testListAndDelete(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	a, b := create(t, s), create(t, s)
+
+	listed := func() map[string]*models.Tournament {
+		t.Helper()
+		all, err := s.ListTournaments(ctx)
+		assertNoErr(t, "ListTournaments", err)
+		byID := make(map[string]*models.Tournament)
+		for _, tour := range all {
+			byID[tour.ID] = tour
+		}
+		return byID
+	}
+	byID := listed()
+	if byID[a.ID] == nil || byID[b.ID] == nil {
+		t.Fatalf("ListTournaments is missing a created tournament")
+	}
+	assertSame(t, "listed tournament", a, byID[a.ID])
+
+	assertNoErr(t, "DeleteTournament", s.DeleteTournament(ctx, a.ID))
+	_, err := s.GetTournament(ctx, a.ID)
+	assertErr(t, "GetTournament deleted", err, fmt.Sprintf("tournament %s not found", a.ID))
+	assertErr(t, "DeleteTournament twice", s.DeleteTournament(ctx, a.ID), fmt.Sprintf("tournament %s not found", a.ID))
+
+	byID = listed()
+	if byID[a.ID] != nil || byID[b.ID] == nil {
+		t.Errorf("ListTournaments after delete: has deleted %v, has kept %v", byID[a.ID] != nil, byID[b.ID] != nil)
+	}
+}
+
+// testDeleteRecords checks that deleting a tournament deletes its audit log,
+// webhooks, webhook deliveries and share tokens, and leaves another's alone.
+func testDeleteRecords(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	doomed, kept := create(t, s), create(t, s)
+	at := time.Date(2024, 9, 27, 12, 0, 0, 0, time.UTC)
+
+	type records struct {
+		entry    *models.AuditEntry
+		hook     *models.Webhook
+		delivery *models.WebhookDelivery
+		share    *models.ShareToken
+	}
+	add := func(tour *models.Tournament) records {
+		t.Helper()
+		r := records{
+			entry: &models.AuditEntry{ID: newID("a"), TournamentID: tour.ID, Action: models.AuditTournamentLock, UserEmail: "admin@example.com", Before: json.RawMessage(`false`), After: json.RawMessage(`true`), Version: 2, CreatedAt: at},
+			hook:  &models.Webhook{ID: newID("wh"), TournamentID: tour.ID, URL: "https://example.com", Events: []models.WebhookEvent{}, CreatedAt: at},
+			share: &models.ShareToken{Token: newID("share"), TournamentID: tour.ID, CreatedBy: "admin@example.com", CreatedAt: at},
+		}
+		r.delivery = &models.WebhookDelivery{ID: newID("d"), WebhookID: r.hook.ID, TournamentID: tour.ID, Event: models.WebhookRoundLocked, Payload: json.RawMessage(`{}`), Attempts: 1, CreatedAt: at, LastAttemptAt: at}
+		assertNoErr(t, "AppendAuditEntry", s.AppendAuditEntry(ctx, r.entry))
+		assertNoErr(t, "CreateWebhook", s.CreateWebhook(ctx, r.hook))
+		assertNoErr(t, "SaveWebhookDelivery", s.SaveWebhookDelivery(ctx, r.delivery))
+		assertNoErr(t, "CreateShareToken", s.CreateShareToken(ctx, r.share))
+		return r
+	}
+	gone, stays := add(doomed), add(kept)
+
+	assertNoErr(t, "DeleteTournament", s.DeleteTournament(ctx, doomed.ID))
+
+	entries, err := s.ListAuditEntries(ctx, doomed.ID)
+	assertNoErr(t, "ListAuditEntries deleted", err)
+	hooks, err := s.ListWebhooks(ctx, doomed.ID)
+	assertNoErr(t, "ListWebhooks deleted", err)
+	deliveries, err := s.ListWebhookDeliveries(ctx, doomed.ID, gone.hook.ID)
+	assertNoErr(t, "ListWebhookDeliveries deleted", err)
+	shares, err := s.ListShareTokens(ctx, doomed.ID)
+	assertNoErr(t, "ListShareTokens deleted", err)
+	if len(entries)+len(hooks)+len(deliveries)+len(shares) != 0 {
+		t.Errorf("deleted tournament kept %d audit entries, %d webhooks, %d deliveries and %d share tokens", len(entries), len(hooks), len(deliveries), len(shares))
+	}
+	_, err = s.GetShareToken(ctx, gone.share.Token)
+	assertErr(t, "GetShareToken deleted", err, "share token not found")
+
+	entries, err = s.ListAuditEntries(ctx, kept.ID)
+	assertNoErr(t, "ListAuditEntries", err)
+	assertSame(t, "kept audit log", []*models.AuditEntry{stays.entry}, entries)
+	hooks, err = s.ListWebhooks(ctx, kept.ID)
+	assertNoErr(t, "ListWebhooks", err)
+	assertSame(t, "kept webhooks", []*models.Webhook{stays.hook}, hooks)
+	deliveries, err = s.ListWebhookDeliveries(ctx, kept.ID, stays.hook.ID)
+	assertNoErr(t, "ListWebhookDeliveries", err)
+	assertSame(t, "kept deliveries", []*models.WebhookDelivery{stays.delivery}, deliveries)
+	share, err := s.GetShareToken(ctx, stays.share.Token)
+	assertNoErr(t, "GetShareToken", err)
+	assertSame(t, "kept share token", stays.share, share)
+}
+
+func testImport(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	stamp := time.Date(2024, 9, 27, 8, 30, 0, 123456000, time.UTC)
+	tee := stamp.Add(time.Hour)
+
+	tour := newTournament()
+	tour.CreatedAt = stamp
+	tour.UpdatedAt = stamp.Add(time.Minute)
+	tour.Version = 7
+	tour.Rankings = []models.PlayerRanking{{SubmittedBy: "captain@example.com", PlayerIDs: []string{tour.Teams[0].Players[1].ID, tour.Teams[0].Players[0].ID}, UpdatedAt: stamp}}
+	tour.Rounds[0].Matches[0].TeeTime = &tee
+	tour.Rounds[0].Matches[0].HoleResults = map[string]string{"1": "team1", "2": "halved"}
+	tour.Rounds[0].Matches[0].Result = models.ResultTeam1
+	tour.Rounds[0].Matches[0].Score = "3 & 2"
+	tour.Rounds[0].Matches[0].AttestationStatus = models.AttestationAwaiting
+	tour.Rounds[0].Matches[0].Attestations = []models.Attestation{{Side: "team1", Email: "a@example.com", Confirmed: true, CreatedAt: stamp}}
+	handicap := -1.4
+	tour.Teams[1].Players[0].Handicap = &handicap
+	tour.Teams[1].Players[0].UserEmail = "linked@example.com"
+	assertNoErr(t, "ImportTournament", s.ImportTournament(ctx, tour))
+	assertSame(t, "imported tournament", tour, get(t, s, tour.ID))
+
+	// A second import replaces the first outright.
+	replaced := newTournament()
+	replaced.ID = tour.ID
+	replaced.Name = "Replaced"
+	replaced.Rounds = replaced.Rounds[1:]
+	replaced.CreatedAt, replaced.UpdatedAt, replaced.Version = stamp, stamp, 3
+	assertNoErr(t, "ImportTournament again", s.ImportTournament(ctx, replaced))
+	assertSame(t, "re-imported tournament", replaced, get(t, s, tour.ID))
+}
+
+// --- Matches ---
+
+// testSchemaVersion checks that stores recording schema versions report what
+// they write as current, including after a match-only write.
+func testSchemaVersion(t *testing.T, s store.Store) {
+	versioner, ok := s.(store.SchemaVersioner)
+	if !ok {
+		t.Skip("store does not record schema versions")
+	}
+	ctx := context.Background()
+	tour := create(t, s)
+	m := tour.Rounds[0].Matches[0]
+	if err := s.UpdateHoleResult(ctx, tour.ID, 1, m.ID, 1, "team1", store.Precondition{}); err != nil {
+		t.Fatalf("UpdateHoleResult: %v", err)
+	}
+	if v, err := versioner.StoredSchemaVersion(ctx, tour.ID); err != nil || v != schema.Current {
+		t.Errorf("StoredSchemaVersion = %d, %v, want %d", v, err, schema.Current)
+	}
+	if _, err := versioner.StoredSchemaVersion(ctx, newID("missing")); err == nil {
+		t.Error("StoredSchemaVersion of a missing tournament succeeded")
+	}
+}
+
+func testMatchResult(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	m := tour.Rounds[1].Matches[0]
+
+	for hole := 1; hole <= 5; hole++ {
+		assertNoErr(t, "UpdateHoleResult", s.UpdateHoleResult(ctx, tour.ID, 2, m.ID, hole, "team1", store.Precondition{}))
+	}
+	assertNoErr(t, "AttestMatch", s.AttestMatch(ctx, tour.ID, 2, m.ID, models.Attestation{Side: "team1", Email: "a@example.com", Confirmed: true, CreatedAt: time.Now()}, store.Precondition{}))
+
+	// An admin's result overrides scoring and needs no attestation.
+	assertNoErr(t, "UpdateMatchResult", s.UpdateMatchResult(ctx, tour.ID, 2, m.ID, models.ResultTie, "A/S", store.Precondition{}))
+	got := findMatch(t, get(t, s, tour.ID), 2, m.ID)
+	if got.Result != models.ResultTie || got.Score != "A/S" {
+		t.Errorf("result = %s %q, want tie \"A/S\"", got.Result, got.Score)
+	}
+	if got.AttestationStatus != models.AttestationNone || len(got.Attestations) != 0 {
+		t.Errorf("attestation = %q with %d entries, want none", got.AttestationStatus, len(got.Attestations))
+	}
+	if len(got.HoleResults) != 5 {
+		t.Errorf("UpdateMatchResult changed hole results: %v", got.HoleResults)
+	}
+	assertVersion(t, s, tour.ID, 8)
+
+	missing := newID("t")
+	assertErr(t, "UpdateMatchResult missing tournament", s.UpdateMatchResult(ctx, missing, 1, m.ID, models.ResultTie, "", store.Precondition{}), fmt.Sprintf("tournament %s not found", missing))
+	assertErr(t, "UpdateMatchResult missing round", s.UpdateMatchResult(ctx, tour.ID, 9, m.ID, models.ResultTie, "", store.Precondition{}), "round 9 not found")
+	assertErr(t, "UpdateMatchResult wrong round", s.UpdateMatchResult(ctx, tour.ID, 1, m.ID, models.ResultTie, "", store.Precondition{}), fmt.Sprintf("match %s not found in round 1", m.ID))
+	assertErr(t, "UpdateHoleResult missing match", s.UpdateHoleResult(ctx, tour.ID, 2, "nope", 1, "team1", store.Precondition{}), "match nope not found in round 2")
+	assertVersion(t, s, tour.ID, 8)
+}
+
+func testHoleResults(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	m := tour.Rounds[0].Matches[1]
+	other := tour.Rounds[0].Matches[2]
+
+	// Scoring hole 4 first halves the holes before it.
+	assertNoErr(t, "UpdateHoleResult", s.UpdateHoleResult(ctx, tour.ID, 1, m.ID, 4, "team2", store.Precondition{}))
+	want := map[string]string{"1": "halved", "2": "halved", "3": "halved", "4": "team2"}
+	got := findMatch(t, get(t, s, tour.ID), 1, m.ID)
+	assertSame(t, "hole results after backfill", want, got.HoleResults)
+	result, score := models.CalculateMatchPlayResult(want, tour.Teams[0].Name, tour.Teams[1].Name, 18)
+	if got.Result != result || got.Score != score {
+		t.Errorf("result = %s %q, want %s %q", got.Result, got.Score, result, score)
+	}
+
+	// Later holes never overwrite earlier ones.
+	assertNoErr(t, "UpdateHoleResult", s.UpdateHoleResult(ctx, tour.ID, 1, m.ID, 2, "team1", store.Precondition{}))
+	assertNoErr(t, "UpdateHoleResult", s.UpdateHoleResult(ctx, tour.ID, 1, m.ID, 6, "team1", store.Precondition{}))
+	want = map[string]string{"1": "halved", "2": "team1", "3": "halved", "4": "team2", "5": "halved", "6": "team1"}
+	assertSame(t, "hole results", want, findMatch(t, get(t, s, tour.ID), 1, m.ID).HoleResults)
+
+	// An empty result clears just that hole.
+	assertNoErr(t, "UpdateHoleResult clear", s.UpdateHoleResult(ctx, tour.ID, 1, m.ID, 6, "", store.Precondition{}))
+	delete(want, "6")
+	assertSame(t, "hole results after clearing", want, findMatch(t, get(t, s, tour.ID), 1, m.ID).HoleResults)
+
+	if holes := findMatch(t, get(t, s, tour.ID), 1, other.ID).HoleResults; len(holes) != 0 {
+		t.Errorf("another match changed: %v", holes)
+	}
+	assertVersion(t, s, tour.ID, 5)
+
+	// A 9-hole match closes out on the round's own hole count.
+	single := tour.Rounds[1].Matches[0]
+	for hole := 1; hole <= 5; hole++ {
+		assertNoErr(t, "UpdateHoleResult", s.UpdateHoleResult(ctx, tour.ID, 2, single.ID, hole, "team1", store.Precondition{}))
+	}
+	got = findMatch(t, get(t, s, tour.ID), 2, single.ID)
+	if got.Result != models.ResultTeam1 || got.Score != "5 & 4" {
+		t.Errorf("9-hole result = %s %q, want team1 \"5 & 4\"", got.Result, got.Score)
+	}
+	if got.AttestationStatus != models.AttestationAwaiting {
+		t.Errorf("decided match attestation = %q, want awaiting", got.AttestationStatus)
+	}
+}
+
+func testAttestation(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	m := tour.Rounds[1].Matches[0]
+	at := time.Date(2024, 9, 29, 16, 0, 0, 0, time.UTC)
+
+	err := s.AttestMatch(ctx, tour.ID, 2, m.ID, models.Attestation{Side: "team1", Confirmed: true, CreatedAt: at}, store.Precondition{})
+	assertErr(t, "AttestMatch undecided", err, fmt.Sprintf("match %s is not awaiting attestation", m.ID))
+
+	for hole := 1; hole <= 5; hole++ {
+		assertNoErr(t, "UpdateHoleResult", s.UpdateHoleResult(ctx, tour.ID, 2, m.ID, hole, "team2", store.Precondition{}))
+	}
+	first := models.Attestation{Side: "team1", Email: "eu@example.com", Confirmed: true, Comment: "Well played", CreatedAt: at}
+	assertNoErr(t, "AttestMatch", s.AttestMatch(ctx, tour.ID, 2, m.ID, first, store.Precondition{}))
+	got := findMatch(t, get(t, s, tour.ID), 2, m.ID)
+	if got.AttestationStatus != models.AttestationAwaiting {
+		t.Errorf("after one side: status %q, want awaiting", got.AttestationStatus)
+	}
+	assertSame(t, "attestations", []models.Attestation{first}, got.Attestations)
+
+	second := models.Attestation{Side: "team2", Email: "us@example.com", Confirmed: true, CreatedAt: at.Add(time.Minute)}
+	assertNoErr(t, "AttestMatch", s.AttestMatch(ctx, tour.ID, 2, m.ID, second, store.Precondition{}))
+	got = findMatch(t, get(t, s, tour.ID), 2, m.ID)
+	if got.AttestationStatus != models.AttestationAttested || len(got.Attestations) != 2 {
+		t.Errorf("after both sides: status %q with %d attestations, want attested with 2", got.AttestationStatus, len(got.Attestations))
+	}
+
+	assertErr(t, "AttestMatch bad side", s.AttestMatch(ctx, tour.ID, 2, m.ID, models.Attestation{Side: "caddie"}, store.Precondition{}), "invalid side: caddie")
+	assertVersion(t, s, tour.ID, 8)
+}
+
+func testRoundPairings(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	tee := time.Date(2024, 9, 28, 7, 35, 0, 0, time.UTC)
+
+	pairings := []models.Match{
+		{ID: newID("m"), RoundNumber: 1, Team1Players: []string{tour.Teams[0].Players[1].ID}, Team2Players: []string{tour.Teams[1].Players[1].ID}, Result: models.ResultPending, HoleResults: map[string]string{}, TeeTime: &tee, Version: 1},
+		{ID: newID("m"), RoundNumber: 1, Team1Players: []string{tour.Teams[0].Players[0].ID}, Team2Players: []string{tour.Teams[1].Players[0].ID}, Result: models.ResultPending, HoleResults: map[string]string{}, Version: 1},
+	}
+	writer := models.Write{Email: "admin@example.com", Admin: true}
+	assertNoErr(t, "SetRoundPairings", s.SetRoundPairings(ctx, tour.ID, 1, pairings, store.Precondition{Writer: writer}))
+
+	got := get(t, s, tour.ID)
+	// Each new match records the pairing as its last result write.
+	for i := range got.Rounds[0].Matches {
+		m := &got.Rounds[0].Matches[i]
+		if w := m.ResultWrite; w == nil || w.Email != writer.Email || !w.Admin || w.Version != 1 || w.At.IsZero() {
+			t.Errorf("match %d result write = %+v, want the pairing by %s at version 1", i, w, writer.Email)
+		}
+		m.ResultWrite = nil
+	}
+	if pairings[0].ResultWrite != nil {
+		t.Error("SetRoundPairings changed the caller's matches")
+	}
+	assertSame(t, "round 1 matches", pairings, got.Rounds[0].Matches)
+	assertSame(t, "round 2 matches", tour.Rounds[1].Matches, got.Rounds[1].Matches)
+	if got.Version != 2 {
+		t.Errorf("version = %d, want 2", got.Version)
+	}
+
+	// The new matches are individually addressable.
+	assertNoErr(t, "UpdateHoleResult", s.UpdateHoleResult(ctx, tour.ID, 1, pairings[1].ID, 1, "team1", store.Precondition{}))
+	assertErr(t, "UpdateHoleResult removed match", s.UpdateHoleResult(ctx, tour.ID, 1, tour.Rounds[0].Matches[0].ID, 1, "team1", store.Precondition{}),
+		fmt.Sprintf("match %s not found in round 1", tour.Rounds[0].Matches[0].ID))
+
+	assertNoErr(t, "SetRoundPairings empty", s.SetRoundPairings(ctx, tour.ID, 2, nil, store.Precondition{}))
+	if matches := get(t, s, tour.ID).Rounds[1].Matches; matches == nil || len(matches) != 0 {
+		t.Errorf("round 2 after clearing = %#v, want an empty list", matches)
+	}
+
+	assertErr(t, "SetRoundPairings missing round", s.SetRoundPairings(ctx, tour.ID, 7, pairings, store.Precondition{}), "round 7 not found")
+}
+
+func testLinkPlayer(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	player := tour.Teams[1].Players[1]
+
+	assertNoErr(t, "LinkPlayer", s.LinkPlayer(ctx, tour.ID, player.ID, "golfer@example.com", store.Precondition{}))
+	got := get(t, s, tour.ID)
+	if email := got.Teams[1].Players[1].UserEmail; email != "golfer@example.com" {
+		t.Errorf("linked email = %q", email)
+	}
+	if got.Version != 2 {
+		t.Errorf("version = %d, want 2", got.Version)
+	}
+
+	assertNoErr(t, "LinkPlayer unlink", s.LinkPlayer(ctx, tour.ID, player.ID, "", store.Precondition{}))
+	if email := get(t, s, tour.ID).Teams[1].Players[1].UserEmail; email != "" {
+		t.Errorf("unlinked email = %q", email)
+	}
+
+	assertErr(t, "LinkPlayer missing player", s.LinkPlayer(ctx, tour.ID, "nobody", "x@example.com", store.Precondition{}), "player nobody not found")
+	missing := newID("t")
+	assertErr(t, "LinkPlayer missing tournament", s.LinkPlayer(ctx, missing, player.ID, "x@example.com", store.Precondition{}), fmt.Sprintf("tournament %s not found", missing))
+}
+
+func testSaveRanking(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	europe, usa := tour.Teams[0].Players, tour.Teams[1].Players
+	at := time.Date(2024, 9, 27, 18, 0, 0, 0, time.UTC)
+
+	first := models.PlayerRanking{SubmittedBy: "captain@example.com", PlayerIDs: []string{europe[0].ID, europe[1].ID}, UpdatedAt: at}
+	other := models.PlayerRanking{SubmittedBy: "rival@example.com", PlayerIDs: []string{usa[1].ID, usa[0].ID}, UpdatedAt: at}
+	assertNoErr(t, "SaveRanking", s.SaveRanking(ctx, tour.ID, first, store.Precondition{}))
+	assertNoErr(t, "SaveRanking", s.SaveRanking(ctx, tour.ID, other, store.Precondition{}))
+
+	// A resubmission replaces the same user's ranking, whatever the case of
+	// their email, and keeps the others.
+	again := models.PlayerRanking{SubmittedBy: "Captain@Example.com", PlayerIDs: []string{europe[1].ID, europe[0].ID}, UpdatedAt: at.Add(time.Hour)}
+	assertNoErr(t, "SaveRanking again", s.SaveRanking(ctx, tour.ID, again, store.Precondition{}))
+
+	got := get(t, s, tour.ID)
+	assertSame(t, "rankings", []models.PlayerRanking{again, other}, got.Rankings)
+	if got.Version != 4 {
+		t.Errorf("version = %d, want 4", got.Version)
+	}
+	// Nothing else in the tournament changed.
+	assertSame(t, "rounds", tour.Rounds, got.Rounds)
+
+	missing := newID("t")
+	assertErr(t, "SaveRanking missing tournament", s.SaveRanking(ctx, missing, first, store.Precondition{}), fmt.Sprintf("tournament %s not found", missing))
+}
+
+// testPreconditions checks that every fine-grained write refuses a stale
+// version without changing anything, and goes through on the current one.
+func testHoleSync(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	m := tour.Rounds[0].Matches[0]
+	player := models.Write{Email: "player@example.com"}
+	other := models.Write{Email: "other@example.com"}
+	admin := models.Write{Email: "admin@example.com", Admin: true}
+	base := m.Version
+
+	// Writes record who made them and the match version they produced.
+	start := time.Now()
+	assertNoErr(t, "UpdateHoleResult", s.UpdateHoleResult(ctx, tour.ID, 1, m.ID, 1, "team1", store.Precondition{Writer: player}))
+	w := findMatch(t, get(t, s, tour.ID), 1, m.ID).HoleWrites["1"]
+	if w.Email != player.Email || w.Version != base+1 || w.At.Before(start) {
+		t.Errorf("hole 1 write = %+v, want %s at version %d", w, player.Email, base+1)
+	}
+
+	sync := func(writer models.Write, hole int, result string, at time.Time, own ...int64) (*store.HoleSync, error) {
+		hs := &store.HoleSync{BaseVersion: base, At: at, Own: own}
+		return hs, s.UpdateHoleResult(ctx, tour.ID, 1, m.ID, hole, result, store.Precondition{Writer: writer, Sync: hs})
+	}
+	superseded := func(op string, err error, by string, result string) {
+		t.Helper()
+		var lost *store.SupersededError
+		if !errors.As(err, &lost) {
+			t.Fatalf("%s: got %v, want a *SupersededError", op, err)
+		}
+		if lost.Change.Email != by || lost.Result != result {
+			t.Errorf("%s: lost to %s (result %q), want %s (%q)", op, lost.Change.Email, lost.Result, by, result)
+		}
+	}
+
+	// An entry made before the change since its base version loses to it.
+	_, err := sync(other, 1, "team2", start.Add(-time.Minute))
+	superseded("earlier entry", err, player.Email, "team1")
+
+	// A later one wins, reporting what it overrode.
+	hs, err := sync(other, 1, "team2", time.Now())
+	assertNoErr(t, "later entry", err)
+	if hs.Overrode == nil || hs.Overrode.Email != player.Email {
+		t.Errorf("later entry overrode %+v, want the change by %s", hs.Overrode, player.Email)
+	}
+
+	// An admin's entry beats a player's change however old it is, and a
+	// player's entry never beats an admin's.
+	hs, err = sync(admin, 1, "halved", start.Add(-time.Hour))
+	assertNoErr(t, "admin entry", err)
+	_, err = sync(player, 1, "team1", time.Now())
+	superseded("entry after an admin's change", err, admin.Email, "halved")
+
+	// A result set directly counts as a change to every hole.
+	assertNoErr(t, "UpdateMatchResult", s.UpdateMatchResult(ctx, tour.ID, 1, m.ID, models.ResultTie, "A/S", store.Precondition{Writer: admin}))
+	_, err = sync(player, 5, "team1", time.Now())
+	superseded("entry after a direct result", err, admin.Email, "")
+
+	// Changes the sync made itself don't count.
+	current := findMatch(t, get(t, s, tour.ID), 1, m.ID)
+	var own []int64
+	for v := base + 1; v <= current.Version; v++ {
+		own = append(own, v)
+	}
+	hs, err = sync(player, 1, "team1", start.Add(-time.Hour), own...)
+	assertNoErr(t, "entry after its own sync's changes", err)
+	if hs.Overrode != nil {
+		t.Errorf("entry after its own sync's changes overrode %+v", hs.Overrode)
+	}
+
+	// Entries on a hole nobody else changed go ahead.
+	base = findMatch(t, get(t, s, tour.ID), 1, m.ID).Version
+	_, err = sync(player, 2, "team2", start.Add(-time.Hour))
+	assertNoErr(t, "entry on an unchanged hole", err)
+}
+
+func testPreconditions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	m := tour.Rounds[0].Matches[0]
+	decided := tour.Rounds[0].Matches[1]
+	player := tour.Teams[0].Players[0]
+	for hole := 1; hole <= 10; hole++ {
+		assertNoErr(t, "UpdateHoleResult", s.UpdateHoleResult(ctx, tour.ID, 1, decided.ID, hole, "team1", store.Precondition{}))
+	}
+
+	writes := []struct {
+		name  string
+		write func(want store.Precondition) error
+	}{
+		{"UpdateHoleResult", func(want store.Precondition) error {
+			return s.UpdateHoleResult(ctx, tour.ID, 1, m.ID, 1, "team1", want)
+		}},
+		{"UpdateMatchResult", func(want store.Precondition) error {
+			return s.UpdateMatchResult(ctx, tour.ID, 1, m.ID, models.ResultTie, "A/S", want)
+		}},
+		{"AttestMatch", func(want store.Precondition) error {
+			return s.AttestMatch(ctx, tour.ID, 1, decided.ID, models.Attestation{Side: "team1", Email: "a@example.com", Confirmed: true, CreatedAt: time.Now()}, want)
+		}},
+		{"LinkPlayer", func(want store.Precondition) error {
+			return s.LinkPlayer(ctx, tour.ID, player.ID, "golfer@example.com", want)
+		}},
+		{"SetRoundPairings", func(want store.Precondition) error {
+			return s.SetRoundPairings(ctx, tour.ID, 2, nil, want)
+		}},
+		{"SaveRanking", func(want store.Precondition) error {
+			return s.SaveRanking(ctx, tour.ID, models.PlayerRanking{SubmittedBy: "a@example.com", PlayerIDs: []string{player.ID}, UpdatedAt: time.Now()}, want)
+		}},
+	}
+
+	for _, w := range writes {
+		before := get(t, s, tour.ID)
+		err := w.write(store.Precondition{Version: before.Version - 1})
+		var conflict *store.VersionConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("%s with a stale version: got %v, want a *VersionConflictError", w.name, err)
+		}
+		if conflict.TournamentID != tour.ID || conflict.Current != before.Version {
+			t.Errorf("%s conflict = %+v, want tournament %s at version %d", w.name, conflict, tour.ID, before.Version)
+		}
+		assertSame(t, w.name+" refused", before, get(t, s, tour.ID))
+
+		assertNoErr(t, w.name, w.write(store.Precondition{Version: before.Version}))
+		assertVersion(t, s, tour.ID, before.Version+1)
+	}
+
+	// Match writes can instead be conditional on the match alone, and each
+	// one moves that match to its next version.
+	matchWrites := writes[:3]
+	for _, w := range matchWrites {
+		before := get(t, s, tour.ID)
+		id := m.ID
+		if w.name == "AttestMatch" {
+			id = decided.ID
+		}
+		current := findMatch(t, before, 1, id).Version
+		err := w.write(store.Precondition{MatchVersion: current - 1})
+		var conflict *store.VersionConflictError
+		if !errors.As(err, &conflict) {
+			t.Fatalf("%s with a stale match version: got %v, want a *VersionConflictError", w.name, err)
+		}
+		if conflict.TournamentID != tour.ID || conflict.MatchID != id || conflict.Current != current {
+			t.Errorf("%s conflict = %+v, want match %s at version %d", w.name, conflict, id, current)
+		}
+		assertSame(t, w.name+" refused", before, get(t, s, tour.ID))
+
+		assertNoErr(t, w.name, w.write(store.Precondition{MatchVersion: current}))
+		if got := findMatch(t, get(t, s, tour.ID), 1, id).Version; got != current+1 {
+			t.Errorf("%s: match version = %d, want %d", w.name, got, current+1)
+		}
+	}
+}
+
+// --- Users ---
+
+func testRegisteredUsers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	email := newID("u") + "@example.com"
+
+	assertNoErr(t, "RegisterUser", s.RegisterUser(ctx, &models.RegisteredUser{Email: email, Name: "First", Picture: "a.png"}))
+	assertNoErr(t, "RegisterUser again", s.RegisterUser(ctx, &models.RegisteredUser{Email: email, Name: "Second", Picture: "b.png"}))
+
+	users, err := s.ListRegisteredUsers(ctx)
+	assertNoErr(t, "ListRegisteredUsers", err)
+	var found []*models.RegisteredUser
+	for _, u := range users {
+		if u.Email == email {
+			found = append(found, u)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("found %d registrations for %s, want 1", len(found), email)
+	}
+	assertSame(t, "registered user", &models.RegisteredUser{Email: email, Name: "Second", Picture: "b.png"}, found[0])
+}
+
+func testLocalUserLifecycle(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	email := newID("Local") + "@Example.com"
+	token := newID("verify")
+	user := &models.LocalUser{
+		Email:             email,
+		Name:              "Local User",
+		PasswordHash:      "$2a$10$hash",
+		VerificationToken: token,
+		CreatedAt:         time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	assertNoErr(t, "CreateLocalUser", s.CreateLocalUser(ctx, user))
+
+	dup := *user
+	dup.Email = strings.ToUpper(email)
+	assertErr(t, "CreateLocalUser duplicate", s.CreateLocalUser(ctx, &dup), fmt.Sprintf("a user with email %s already exists", dup.Email))
+
+	// Lookups ignore case.
+	got, err := s.GetLocalUser(ctx, strings.ToLower(email))
+	assertNoErr(t, "GetLocalUser", err)
+	assertSame(t, "local user", user, got)
+
+	lookup := func() *models.LocalUser {
+		t.Helper()
+		u, err := s.GetLocalUser(ctx, email)
+		assertNoErr(t, "GetLocalUser", err)
+		return u
+	}
+
+	// An empty token must not match users who have already verified.
+	assertErr(t, "VerifyLocalUser empty", s.VerifyLocalUser(ctx, ""), "invalid verification token")
+	assertNoErr(t, "VerifyLocalUser", s.VerifyLocalUser(ctx, token))
+	if u := lookup(); !u.EmailVerified || u.VerificationToken != "" {
+		t.Errorf("after verifying: verified %v, token %q", u.EmailVerified, u.VerificationToken)
+	}
+	assertErr(t, "VerifyLocalUser reused", s.VerifyLocalUser(ctx, token), "invalid verification token")
+	assertErr(t, "VerifyLocalUser empty after verifying", s.VerifyLocalUser(ctx, ""), "invalid verification token")
+
+	assertNoErr(t, "ConfirmLocalUser", s.ConfirmLocalUser(ctx, strings.ToUpper(email)))
+	if !lookup().Confirmed {
+		t.Errorf("user not confirmed")
+	}
+
+	// Deleting only disables, so the email stays taken.
+	assertNoErr(t, "DeleteLocalUser", s.DeleteLocalUser(ctx, email))
+	if !lookup().Disabled {
+		t.Errorf("user not disabled")
+	}
+	assertNoErr(t, "EnableLocalUser", s.EnableLocalUser(ctx, email))
+	if u := lookup(); u.Disabled || !u.Confirmed || !u.EmailVerified {
+		t.Errorf("after enabling: disabled %v, confirmed %v, verified %v", u.Disabled, u.Confirmed, u.EmailVerified)
+	}
+
+	assertNoErr(t, "SetCalendarKey", s.SetCalendarKey(ctx, strings.ToUpper(email), "key-1"))
+	assertNoErr(t, "SetCalendarKey again", s.SetCalendarKey(ctx, email, "key-2"))
+	if u := lookup(); u.CalendarKey != "key-2" || u.Disabled || !u.Confirmed {
+		t.Errorf("after setting the calendar key: key %q, disabled %v, confirmed %v", u.CalendarKey, u.Disabled, u.Confirmed)
+	}
+
+	users, err := s.ListLocalUsers(ctx)
+	assertNoErr(t, "ListLocalUsers", err)
+	listed := 0
+	for _, u := range users {
+		if strings.EqualFold(u.Email, email) {
+			listed++
+		}
+	}
+	if listed != 1 {
+		t.Errorf("ListLocalUsers has %d entries for %s, want 1", listed, email)
+	}
+
+	replacement := *user
+	replacement.Name = "Imported"
+	replacement.Disabled = true
+	replacement.CalendarKey = "imported-key"
+	assertNoErr(t, "ImportLocalUser", s.ImportLocalUser(ctx, &replacement))
+	assertSame(t, "imported local user", &replacement, lookup())
+
+	nobody := newID("nobody") + "@example.com"
+	_, err = s.GetLocalUser(ctx, nobody)
+	assertErr(t, "GetLocalUser missing", err, "user not found")
+	assertErr(t, "ConfirmLocalUser missing", s.ConfirmLocalUser(ctx, nobody), "user not found")
+	assertErr(t, "DeleteLocalUser missing", s.DeleteLocalUser(ctx, nobody), "user not found")
+	assertErr(t, "EnableLocalUser missing", s.EnableLocalUser(ctx, nobody), "user not found")
+	assertErr(t, "SetCalendarKey missing", s.SetCalendarKey(ctx, nobody, "key"), "user not found")
+}
+
+// --- Audit log, webhooks and share tokens ---
+
+func testAuditLog(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	at := time.Date(2024, 9, 27, 9, 0, 0, 0, time.UTC)
+
+	var entries []*models.AuditEntry
+	for i, after := range []string{`"team1"`, `"halved"`, `{"email":"a@example.com"}`} {
+		e := &models.AuditEntry{
+			ID:           newID("a"),
+			TournamentID: tour.ID,
+			Action:       models.AuditHoleResult,
+			RoundNumber:  1,
+			MatchID:      tour.Rounds[0].Matches[0].ID,
+			Hole:         i + 1,
+			UserEmail:    "scorer@example.com",
+			Before:       json.RawMessage(`null`),
+			After:        json.RawMessage(after),
+			Version:      int64(i + 2),
+			CreatedAt:    at.Add(time.Duration(i) * time.Minute),
+		}
+		if i == 2 {
+			e.Action, e.Hole, e.MatchID, e.PlayerID = models.AuditPlayerLink, 0, "", tour.Teams[0].Players[0].ID
+			e.RevertOf = entries[0].ID
+		}
+		assertNoErr(t, "AppendAuditEntry", s.AppendAuditEntry(ctx, e))
+		entries = append(entries, e)
+	}
+
+	got, err := s.ListAuditEntries(ctx, tour.ID)
+	assertNoErr(t, "ListAuditEntries", err)
+	assertSame(t, "audit log", entries, got)
+
+	none, err := s.ListAuditEntries(ctx, newID("t"))
+	assertNoErr(t, "ListAuditEntries empty", err)
+	if none == nil || len(none) != 0 {
+		t.Errorf("audit log of an unknown tournament = %#v, want an empty list", none)
+	}
+}
+
+func testWebhooks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	at := time.Date(2024, 9, 27, 10, 0, 0, 0, time.UTC)
+
+	missing := newID("t")
+	err := s.CreateWebhook(ctx, &models.Webhook{ID: newID("wh"), TournamentID: missing, URL: "https://example.com", CreatedAt: at})
+	assertErr(t, "CreateWebhook missing tournament", err, fmt.Sprintf("tournament %s not found", missing))
+
+	hooks := []*models.Webhook{
+		{ID: newID("wh"), TournamentID: tour.ID, URL: "https://example.com/a", Secret: "s1", Events: []models.WebhookEvent{models.WebhookMatchCompleted, models.WebhookCupClinched}, CreatedAt: at},
+		{ID: newID("wh"), TournamentID: tour.ID, URL: "https://example.com/b", Events: []models.WebhookEvent{}, CreatedAt: at.Add(time.Minute)},
+	}
+	for _, wh := range hooks {
+		assertNoErr(t, "CreateWebhook", s.CreateWebhook(ctx, wh))
+	}
+	got, err := s.ListWebhooks(ctx, tour.ID)
+	assertNoErr(t, "ListWebhooks", err)
+	assertSame(t, "webhooks", hooks, got)
+
+	retryAt := at.Add(10 * time.Second)
+	deliveries := []*models.WebhookDelivery{
+		{ID: newID("d"), WebhookID: hooks[0].ID, TournamentID: tour.ID, Event: models.WebhookMatchCompleted, Payload: json.RawMessage(`{"match":"m1"}`), Attempts: 1, StatusCode: 500, Error: "server error", CreatedAt: at, LastAttemptAt: at, NextAttemptAt: &retryAt},
+		{ID: newID("d"), WebhookID: hooks[0].ID, TournamentID: tour.ID, Event: models.WebhookCupClinched, Payload: json.RawMessage(`{"team":"Europe"}`), Attempts: 1, StatusCode: 200, Delivered: true, CreatedAt: at.Add(time.Second), LastAttemptAt: at.Add(time.Second)},
+		{ID: newID("d"), WebhookID: hooks[1].ID, TournamentID: tour.ID, Event: models.WebhookRoundLocked, Payload: json.RawMessage(`{}`), Attempts: 1, Delivered: true, CreatedAt: at, LastAttemptAt: at},
+	}
+	for _, d := range deliveries {
+		assertNoErr(t, "SaveWebhookDelivery", s.SaveWebhookDelivery(ctx, d))
+	}
+	pending, err := s.ListWebhookDeliveries(ctx, tour.ID, hooks[0].ID)
+	assertNoErr(t, "ListWebhookDeliveries", err)
+	assertSame(t, "deliveries before the retry", deliveries[:2], pending)
+	// Saving a delivery again replaces it in place.
+	retried := *deliveries[0]
+	retried.Attempts, retried.StatusCode, retried.Error, retried.Delivered = 2, 204, "", true
+	retried.LastAttemptAt, retried.NextAttemptAt = at.Add(time.Minute), nil
+	assertNoErr(t, "SaveWebhookDelivery retry", s.SaveWebhookDelivery(ctx, &retried))
+
+	gotDeliveries, err := s.ListWebhookDeliveries(ctx, tour.ID, hooks[0].ID)
+	assertNoErr(t, "ListWebhookDeliveries", err)
+	assertSame(t, "deliveries", []*models.WebhookDelivery{&retried, deliveries[1]}, gotDeliveries)
+
+	assertNoErr(t, "DeleteWebhook", s.DeleteWebhook(ctx, tour.ID, hooks[0].ID))
+	got, err = s.ListWebhooks(ctx, tour.ID)
+	assertNoErr(t, "ListWebhooks", err)
+	assertSame(t, "webhooks after delete", hooks[1:], got)
+	assertErr(t, "DeleteWebhook twice", s.DeleteWebhook(ctx, tour.ID, hooks[0].ID), fmt.Sprintf("webhook %s not found", hooks[0].ID))
+
+	none, err := s.ListWebhooks(ctx, newID("t"))
+	assertNoErr(t, "ListWebhooks empty", err)
+	if none == nil || len(none) != 0 {
+		t.Errorf("webhooks of an unknown tournament = %#v, want an empty list", none)
+	}
+}
+
+func testShareTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour, other := create(t, s), create(t, s)
+	at := time.Date(2024, 9, 27, 11, 0, 0, 0, time.UTC)
+
+	shares := []*models.ShareToken{
+		{Token: newID("share"), TournamentID: tour.ID, Label: "Clubhouse TV", CreatedBy: "admin@example.com", CreatedAt: at.Add(time.Minute)},
+		{Token: newID("share"), TournamentID: tour.ID, CreatedBy: "admin@example.com", CreatedAt: at},
+		{Token: newID("share"), TournamentID: other.ID, CreatedBy: "admin@example.com", CreatedAt: at},
+	}
+	for _, sh := range shares {
+		assertNoErr(t, "CreateShareToken", s.CreateShareToken(ctx, sh))
+	}
+
+	dup := *shares[0]
+	dup.TournamentID = other.ID
+	assertErr(t, "CreateShareToken duplicate", s.CreateShareToken(ctx, &dup), "share token already exists")
+	missing := newID("t")
+	err := s.CreateShareToken(ctx, &models.ShareToken{Token: newID("share"), TournamentID: missing, CreatedAt: at})
+	assertErr(t, "CreateShareToken missing tournament", err, fmt.Sprintf("tournament %s not found", missing))
+
+	got, err := s.GetShareToken(ctx, shares[2].Token)
+	assertNoErr(t, "GetShareToken", err)
+	assertSame(t, "share token", shares[2], got)
+
+	// Listed oldest first.
+	list, err := s.ListShareTokens(ctx, tour.ID)
+	assertNoErr(t, "ListShareTokens", err)
+	assertSame(t, "share tokens", []*models.ShareToken{shares[1], shares[0]}, list)
+
+	assertErr(t, "DeleteShareToken other tournament", s.DeleteShareToken(ctx, other.ID, shares[0].Token), "share token not found")
+	assertNoErr(t, "DeleteShareToken", s.DeleteShareToken(ctx, tour.ID, shares[0].Token))
+	_, err = s.GetShareToken(ctx, shares[0].Token)
+	assertErr(t, "GetShareToken deleted", err, "share token not found")
+	assertErr(t, "DeleteShareToken twice", s.DeleteShareToken(ctx, tour.ID, shares[0].Token), "share token not found")
+}
+
+// --- Concurrency ---
+
+// testConcurrentHoleUpdates scores every hole of two matches at once, as
+// scorers in different groups would, and checks no write is lost.
+func testConcurrentHoleUpdates(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	matches := []string{tour.Rounds[0].Matches[0].ID, tour.Rounds[0].Matches[3].ID}
+	const holes = 18
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(matches)*holes)
+	for _, id := range matches {
+		for hole := 1; hole <= holes; hole++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result := "team1"
+				if hole%3 == 0 {
+					result = "team2"
+				}
+				if err := s.UpdateHoleResult(ctx, tour.ID, 1, id, hole, result, store.Precondition{}); err != nil {
+					errs <- fmt.Errorf("hole %d of %s: %w", hole, id, err)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	got := get(t, s, tour.ID)
+	for _, id := range matches {
+		m := findMatch(t, got, 1, id)
+		for hole := 1; hole <= holes; hole++ {
+			want := "team1"
+			if hole%3 == 0 {
+				want = "team2"
+			}
+			// A hole may have been backfilled as halved before its own
+			// write landed, but that write must then win.
+			if r := m.HoleResults[fmt.Sprint(hole)]; r != want {
+				t.Errorf("match %s hole %d = %q, want %q", id, hole, r, want)
+			}
+		}
+	}
+	if got.Version != 1+int64(len(matches)*holes) {
+		t.Errorf("version = %d, want %d", got.Version, 1+len(matches)*holes)
+	}
+}
+
+// testConcurrentMatchWrites races writes to four matches in two rounds: holes
+// scored by different players, a match result, and several hole writes made
+// against the same match version. Each match's version and per-hole writes
+// must only reflect the writes made to that match.
+func testConcurrentMatchWrites(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	scored, result, raced := tour.Rounds[0].Matches[0], tour.Rounds[0].Matches[1], tour.Rounds[0].Matches[2]
+	singles, singlesHoles := tour.Rounds[1].Matches[0], tour.Rounds[1].HoleCount()
+	const racers = 6
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	var mu sync.Mutex
+	won, conflicts := 0, 0
+	write := func(f func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	for hole := 1; hole <= 18; hole++ {
+		write(func() error {
+			want := store.Precondition{Writer: models.Write{Email: "europe@example.com"}}
+			return s.UpdateHoleResult(ctx, tour.ID, 1, scored.ID, hole, "team1", want)
+		})
+	}
+	for hole := 1; hole <= singlesHoles; hole++ {
+		write(func() error {
+			want := store.Precondition{Writer: models.Write{Email: "usa@example.com"}}
+			return s.UpdateHoleResult(ctx, tour.ID, 2, singles.ID, hole, "team2", want)
+		})
+	}
+	write(func() error {
+		return s.UpdateMatchResult(ctx, tour.ID, 1, result.ID, models.ResultTie, "AS", store.Precondition{MatchVersion: 1})
+	})
+	for hole := 1; hole <= racers; hole++ {
+		write(func() error {
+			err := s.UpdateHoleResult(ctx, tour.ID, 1, raced.ID, hole, "team1", store.Precondition{MatchVersion: 1})
+			var conflict *store.VersionConflictError
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				won++
+			case errors.As(err, &conflict) && conflict.MatchID == raced.ID:
+				conflicts++
+			default:
+				return fmt.Errorf("hole %d of the raced match: %w", hole, err)
+			}
+			return nil
+		})
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if won != 1 || conflicts != racers-1 {
+		t.Errorf("%d raced writes won and %d conflicted, want 1 and %d", won, conflicts, racers-1)
+	}
+
+	got := get(t, s, tour.ID)
+	check := func(round int, id string, version int64, email string, holes int) {
+		t.Helper()
+		m := findMatch(t, got, round, id)
+		if m.Version != version {
+			t.Errorf("match %s version = %d, want %d", id, m.Version, version)
+		}
+		for hole := 1; hole <= holes; hole++ {
+			if w := m.HoleWrites[fmt.Sprint(hole)]; w.Email != email {
+				t.Errorf("match %s hole %d written by %q, want %q", id, hole, w.Email, email)
+			}
+		}
+	}
+	check(1, scored.ID, 19, "europe@example.com", 18)
+	check(2, singles.ID, 1+int64(singlesHoles), "usa@example.com", singlesHoles)
+	check(1, result.ID, 2, "", 0)
+	check(1, raced.ID, 2, "", 0)
+	if m := findMatch(t, got, 1, result.ID); m.Result != models.ResultTie || m.ResultWrite == nil {
+		t.Errorf("match result = %q with write %v, want tie and recorded", m.Result, m.ResultWrite)
+	}
+	if want := 1 + 18 + int64(singlesHoles) + 1 + 1; got.Version != want {
+		t.Errorf("version = %d, want %d", got.Version, want)
+	}
+}
+
+// testConcurrentTournamentUpdates races several whole-tournament writes
+// made against the same version; exactly one may win.
+func testConcurrentTournamentUpdates(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	tour := create(t, s)
+	const writers = 8
+
+	edits := make([]*models.Tournament, writers)
+	for i := range edits {
+		edits[i] = get(t, s, tour.ID)
+		edits[i].Name = fmt.Sprintf("Edit %d", i)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	won, conflicts := 0, 0
+	for _, edit := range edits {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.UpdateTournament(ctx, edit)
+			var conflict *store.VersionConflictError
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				won++
+			case errors.As(err, &conflict):
+				conflicts++
+			default:
+				t.Errorf("UpdateTournament: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if won != 1 || conflicts != writers-1 {
+		t.Errorf("%d writes won and %d conflicted, want 1 and %d", won, conflicts, writers-1)
+	}
+	assertVersion(t, s, tour.ID, 2)
+}
@@ -0,0 +1,283 @@
+// Package webhook delivers tournament events to admin-registered URLs.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/store"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SignatureHeader carries "sha256=<hex HMAC-SHA256 of the body>", keyed with
+// the webhook's secret.
+const SignatureHeader = "X-Webhook-Signature"
+
+// retryDelays are the waits before each retry after a failed attempt.
+var retryDelays = []time.Duration{10 * time.Second, time.Minute, 5 * time.Minute, 30 * time.Minute}
+
+// Payload is the JSON body POSTed to a webhook.
+type Payload struct {
+	DeliveryID   string              `json:"deliveryId"`
+	Event        models.WebhookEvent `json:"event"`
+	TournamentID string              `json:"tournamentId"`
+	CreatedAt    time.Time           `json:"createdAt"`
+	Data         any                 `json:"data"`
+}
+
+// Dispatcher sends events to the webhooks that subscribe to them and records
+// every attempt in the store's delivery log. Deliveries run in the
+// background; a failing endpoint is retried with increasing delays.
+type Dispatcher struct {
+	store  store.Store
+	client *http.Client
+	delays []time.Duration
+}
+
+func NewDispatcher(s store.Store) *Dispatcher {
+	return &Dispatcher{
+		store:  s,
+		client: newClient(),
+		delays: retryDelays,
+	}
+}
+
+// newClient returns the client deliveries are made with. It refuses to
+// connect to internal addresses, checking the address actually dialed so a
+// hostname that resolves somewhere else at delivery than it did at
+// registration is still caught, and it doesn't follow redirects, which could
+// point anywhere.
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+				return fmt.Errorf("webhook address %s is not allowed", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// CheckURL returns an error unless raw is an absolute http or https URL whose
+// host is public: not a loopback, private or link-local address, such as a
+// cloud metadata endpoint, nor a name that resolves to one.
+func CheckURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if blockedIP(ip) {
+			return fmt.Errorf("url host %s is not a public address", host)
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("url host %s could not be resolved", host)
+	}
+	for _, addr := range addrs {
+		if blockedIP(addr.IP) {
+			return fmt.Errorf("url host %s resolves to %s, which is not a public address", host, addr.IP)
+		}
+	}
+	return nil
+}
+
+// blockedIP reports whether ip is an address webhooks may not reach.
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// Sign returns the signature header value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send delivers event to every webhook of the tournament subscribed to it.
+func (d *Dispatcher) Send(tournamentID string, event models.WebhookEvent, data any) {
+	go func() {
+		ctx := context.Background()
+		hooks, err := d.store.ListWebhooks(ctx, tournamentID)
+		if err != nil {
+			log.Printf("Failed to list webhooks for tournament %s: %v", tournamentID, err)
+			return
+		}
+		d.deliverAll(ctx, hooks, tournamentID, event, data)
+	}()
+}
+
+// SendAll delivers an event that isn't tied to one tournament, such as a new
+// user registering, to the subscribed webhooks of every tournament.
+func (d *Dispatcher) SendAll(event models.WebhookEvent, data any) {
+	go func() {
+		ctx := context.Background()
+		tournaments, err := d.store.ListTournaments(ctx)
+		if err != nil {
+			log.Printf("Failed to list tournaments for webhooks: %v", err)
+			return
+		}
+		for _, t := range tournaments {
+			hooks, err := d.store.ListWebhooks(ctx, t.ID)
+			if err != nil {
+				log.Printf("Failed to list webhooks for tournament %s: %v", t.ID, err)
+				continue
+			}
+			d.deliverAll(ctx, hooks, t.ID, event, data)
+		}
+	}()
+}
+
+func (d *Dispatcher) deliverAll(ctx context.Context, hooks []*models.Webhook, tournamentID string, event models.WebhookEvent, data any) {
+	for _, wh := range hooks {
+		if !wh.Wants(event) {
+			continue
+		}
+
+		now := time.Now()
+		delivery := &models.WebhookDelivery{
+			ID:           uuid.New().String(),
+			WebhookID:    wh.ID,
+			TournamentID: tournamentID,
+			Event:        event,
+			CreatedAt:    now,
+		}
+		body, err := json.Marshal(Payload{
+			DeliveryID:   delivery.ID,
+			Event:        event,
+			TournamentID: tournamentID,
+			CreatedAt:    now,
+			Data:         data,
+		})
+		if err != nil {
+			log.Printf("Failed to encode %s webhook payload: %v", event, err)
+			continue
+		}
+		delivery.Payload = body
+
+		go d.deliver(ctx, wh, delivery)
+	}
+}
+
+// Resume picks up the deliveries that were waiting to be retried when the
+// server last stopped, each at the time it was due or at once if that has
+// passed. It returns straight away; the retries run in the background.
+func (d *Dispatcher) Resume() {
+	go func() {
+		ctx := context.Background()
+		tournaments, err := d.store.ListTournaments(ctx)
+		if err != nil {
+			log.Printf("Failed to list tournaments to resume webhook deliveries: %v", err)
+			return
+		}
+		for _, t := range tournaments {
+			hooks, err := d.store.ListWebhooks(ctx, t.ID)
+			if err != nil {
+				log.Printf("Failed to list webhooks for tournament %s: %v", t.ID, err)
+				continue
+			}
+			for _, wh := range hooks {
+				deliveries, err := d.store.ListWebhookDeliveries(ctx, t.ID, wh.ID)
+				if err != nil {
+					log.Printf("Failed to list deliveries for webhook %s: %v", wh.ID, err)
+					continue
+				}
+				for _, delivery := range deliveries {
+					if !delivery.Delivered && delivery.NextAttemptAt != nil {
+						go d.deliver(ctx, wh, delivery)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// deliver makes a delivery's remaining attempts: the first, then a retry after
+// each failure until the delays run out. The time each retry is due is saved
+// with the attempt before it, so that Resume can carry on after a restart.
+func (d *Dispatcher) deliver(ctx context.Context, wh *models.Webhook, delivery *models.WebhookDelivery) {
+	for {
+		if delivery.NextAttemptAt != nil {
+			time.Sleep(time.Until(*delivery.NextAttemptAt))
+		}
+		d.attempt(ctx, wh, delivery)
+		delivery.NextAttemptAt = nil
+		if !delivery.Delivered && delivery.Attempts <= len(d.delays) {
+			next := delivery.LastAttemptAt.Add(d.delays[delivery.Attempts-1])
+			delivery.NextAttemptAt = &next
+		}
+		if err := d.store.SaveWebhookDelivery(ctx, delivery); err != nil {
+			log.Printf("Failed to record webhook delivery %s: %v", delivery.ID, err)
+		}
+		if delivery.NextAttemptAt == nil {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, wh *models.Webhook, delivery *models.WebhookDelivery) {
+	delivery.Attempts++
+	delivery.LastAttemptAt = time.Now()
+	delivery.StatusCode = 0
+	delivery.Error = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, wh.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		delivery.Error = err.Error()
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "golf-matchplay-webhooks/1")
+	req.Header.Set("X-Webhook-Event", string(delivery.Event))
+	req.Header.Set("X-Webhook-Delivery", delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(wh.Secret, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		delivery.Error = err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	delivery.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		delivery.Delivered = true
+		return
+	}
+	delivery.Error = fmt.Sprintf("endpoint returned %s", resp.Status)
+}
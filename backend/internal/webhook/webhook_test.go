@@ -0,0 +1,228 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"scoring-backend/internal/models"
+	"scoring-backend/internal/store"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recordingStore passes every saved delivery attempt on to saved.
+type recordingStore struct {
+	store.Store
+	saved chan models.WebhookDelivery
+}
+
+func (s *recordingStore) SaveWebhookDelivery(ctx context.Context, d *models.WebhookDelivery) error {
+	s.saved <- *d
+	return s.Store.SaveWebhookDelivery(ctx, d)
+}
+
+// newTestDispatcher returns a dispatcher that may reach srv and retries
+// almost at once.
+func newTestDispatcher(srv *httptest.Server) (*Dispatcher, *recordingStore) {
+	s := &recordingStore{Store: store.NewMemoryStore(), saved: make(chan models.WebhookDelivery, 16)}
+	return &Dispatcher{store: s, client: srv.Client(), delays: []time.Duration{time.Millisecond, time.Millisecond}}, s
+}
+
+func nextSaved(t *testing.T, s *recordingStore) models.WebhookDelivery {
+	t.Helper()
+	select {
+	case d := <-s.saved:
+		return d
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a delivery attempt")
+		return models.WebhookDelivery{}
+	}
+}
+
+func TestDeliverySigned(t *testing.T) {
+	const secret = "s3cret"
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{r.Header, body}
+	}))
+	defer srv.Close()
+
+	d, s := newTestDispatcher(srv)
+	hook := &models.Webhook{ID: "wh-1", URL: srv.URL, Secret: secret}
+	d.deliverAll(context.Background(), []*models.Webhook{hook}, "t-1", models.WebhookRoundLocked, map[string]int{"roundNumber": 2})
+
+	req := <-requests
+	if got, want := req.header.Get(SignatureHeader), Sign(secret, req.body); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if got := req.header.Get("X-Webhook-Event"); got != string(models.WebhookRoundLocked) {
+		t.Errorf("event header = %q", got)
+	}
+	var payload Payload
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("decoding payload: %v", err)
+	}
+	if payload.Event != models.WebhookRoundLocked || payload.TournamentID != "t-1" {
+		t.Errorf("payload = %+v", payload)
+	}
+
+	if saved := nextSaved(t, s); !saved.Delivered || saved.Attempts != 1 || saved.StatusCode != http.StatusOK {
+		t.Errorf("saved delivery = %+v, want delivered on the first attempt", saved)
+	}
+}
+
+func TestDeliveryRetriesServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	d, s := newTestDispatcher(srv)
+	hook := &models.Webhook{ID: "wh-1", URL: srv.URL, Secret: "x"}
+	d.deliverAll(context.Background(), []*models.Webhook{hook}, "t-1", models.WebhookMatchCompleted, nil)
+
+	first := nextSaved(t, s)
+	if first.Delivered || first.Attempts != 1 || first.StatusCode != http.StatusServiceUnavailable || first.Error == "" {
+		t.Errorf("first attempt = %+v, want a logged 503", first)
+	}
+	if first.NextAttemptAt == nil || !first.NextAttemptAt.After(first.LastAttemptAt) {
+		t.Errorf("first attempt due again at %v, want after %v", first.NextAttemptAt, first.LastAttemptAt)
+	}
+	second := nextSaved(t, s)
+	if !second.Delivered || second.Attempts != 2 || second.ID != first.ID || second.NextAttemptAt != nil {
+		t.Errorf("second attempt = %+v, want the same delivery delivered on attempt 2", second)
+	}
+
+	logged, err := s.ListWebhookDeliveries(context.Background(), "t-1", hook.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) != 1 || !logged[0].Delivered {
+		t.Errorf("delivery log = %+v, want one delivered entry", logged)
+	}
+}
+
+func TestResumeRetriesUndelivered(t *testing.T) {
+	delivered := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered <- r.Header.Get("X-Webhook-Delivery")
+	}))
+	defer srv.Close()
+
+	d, s := newTestDispatcher(srv)
+	ctx := context.Background()
+	tour := &models.Tournament{ID: "t-1", Name: "Cup"}
+	if err := s.CreateTournament(ctx, tour); err != nil {
+		t.Fatal(err)
+	}
+	hook := &models.Webhook{ID: "wh-1", TournamentID: tour.ID, URL: srv.URL, Secret: "x", CreatedAt: time.Now()}
+	if err := s.CreateWebhook(ctx, hook); err != nil {
+		t.Fatal(err)
+	}
+	due := time.Now().Add(-time.Minute)
+	for _, delivery := range []*models.WebhookDelivery{
+		{ID: "d-pending", WebhookID: hook.ID, TournamentID: tour.ID, Event: models.WebhookMatchCompleted, Payload: json.RawMessage(`{}`), Attempts: 1, StatusCode: 503, NextAttemptAt: &due},
+		{ID: "d-done", WebhookID: hook.ID, TournamentID: tour.ID, Event: models.WebhookMatchCompleted, Payload: json.RawMessage(`{}`), Attempts: 1, Delivered: true},
+		{ID: "d-given-up", WebhookID: hook.ID, TournamentID: tour.ID, Event: models.WebhookMatchCompleted, Payload: json.RawMessage(`{}`), Attempts: 3, StatusCode: 503},
+	} {
+		if err := s.Store.SaveWebhookDelivery(ctx, delivery); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d.Resume()
+	if saved := nextSaved(t, s); saved.ID != "d-pending" || !saved.Delivered || saved.Attempts != 2 {
+		t.Errorf("resumed delivery = %+v, want d-pending delivered on attempt 2", saved)
+	}
+	if id := <-delivered; id != "d-pending" {
+		t.Errorf("delivered %s, want d-pending", id)
+	}
+	select {
+	case id := <-delivered:
+		t.Errorf("unexpected delivery of %s", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestUnsubscribedEventsNotSent(t *testing.T) {
+	hits := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits <- r.URL.Path
+	}))
+	defer srv.Close()
+
+	d, s := newTestDispatcher(srv)
+	hooks := []*models.Webhook{
+		{ID: "wh-locked", URL: srv.URL + "/locked", Events: []models.WebhookEvent{models.WebhookRoundLocked}},
+		{ID: "wh-completed", URL: srv.URL + "/completed", Events: []models.WebhookEvent{models.WebhookMatchCompleted}},
+	}
+	d.deliverAll(context.Background(), hooks, "t-1", models.WebhookMatchCompleted, nil)
+
+	if saved := nextSaved(t, s); saved.WebhookID != "wh-completed" {
+		t.Errorf("saved delivery for %s, want wh-completed", saved.WebhookID)
+	}
+	if path := <-hits; path != "/completed" {
+		t.Errorf("request to %s, want /completed", path)
+	}
+	select {
+	case path := <-hits:
+		t.Errorf("unexpected request to %s", path)
+	case d := <-s.saved:
+		t.Errorf("unexpected delivery %+v", d)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestClientRefusesInternalAddresses(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+
+	_, err := newClient().Post(srv.URL, "application/json", strings.NewReader("{}"))
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("posting to %s: got %v, want the address refused", srv.URL, err)
+	}
+	if calls.Load() != 0 {
+		t.Error("the loopback server was reached")
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{"https://93.184.216.34/hook", true},
+		{"http://[2606:2800:220:1::1]:8080/hook", true},
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://10.1.2.3/hook", false},
+		{"http://172.16.0.1/hook", false},
+		{"http://192.168.1.10/hook", false},
+		{"http://169.254.169.254/latest/meta-data/", false},
+		{"http://[fe80::1]/hook", false},
+		{"http://0.0.0.0/hook", false},
+		{"http://localhost/hook", false},
+		{"ftp://93.184.216.34/hook", false},
+		{"/relative/hook", false},
+	}
+	for _, tc := range tests {
+		if err := CheckURL(context.Background(), tc.url); (err == nil) != tc.ok {
+			t.Errorf("CheckURL(%q) = %v, want ok=%v", tc.url, err, tc.ok)
+		}
+	}
+}
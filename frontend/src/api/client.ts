@@ -1,4 +1,4 @@
-import { Tournament, Scoreboard, MatchResult, HoleResult, User, RegisteredUser, LocalUserInfo, PlayerRanking } from '../types';
+import { Tournament, Scoreboard, Match, MatchResult, HoleResult, User, RegisteredUser, LocalUserInfo, PlayerRanking, AuditEntry, Webhook, WebhookEvent, WebhookDelivery, ShareToken, RosterImportReport, BundleImportResult, HoleSyncEntry, HoleSyncResponse } from '../types';
 
 const API_BASE = (import.meta.env.VITE_API_URL || '') + '/api';
 
@@ -6,6 +6,13 @@ function getToken(): string | null {
   return localStorage.getItem('access_token');
 }
 
+// If-Match header for an admin edit. version is the tournament version the
+// edit was made against, read together with the data the form or view was
+// showing, so the server rejects the change if the tournament moved on since.
+function ifMatch(version: number): Record<string, string> {
+  return { 'If-Match': `"${version}"` };
+}
+
 async function apiFetch<T>(path: string, options: RequestInit = {}): Promise<T> {
   const token = getToken();
   if (!token) {
@@ -23,11 +30,21 @@ async function apiFetch<T>(path: string, options: RequestInit = {}): Promise<T>
 
   if (!res.ok) {
     const body = await res.json().catch(() => ({ error: res.statusText }));
+    if (res.status === 409) {
+      throw new Error(body.matchId
+        ? 'This match was changed by someone else. Reload and try again.'
+        : 'This tournament was changed by someone else. Reload and try again.');
+    }
     throw new Error(body.error || `Request failed: ${res.status}`);
   }
 
   if (res.status === 204) return undefined as T;
-  return res.json();
+  const data = await res.json();
+  if (data && typeof data.warning === 'string') {
+    // Saved, but the server couldn't record it fully (e.g. in the audit log).
+    console.warn(data.warning);
+  }
+  return data;
 }
 
 // Public fetch for unauthenticated endpoints (register, login, verify)
@@ -75,6 +92,17 @@ export async function getMe(): Promise<User> {
   return apiFetch<User>('/me');
 }
 
+// Personal iCalendar feed of the user's tee times, for calendar subscriptions.
+export async function getCalendarLink(): Promise<{ url: string }> {
+  return apiFetch<{ url: string }>('/me/calendar');
+}
+
+// Replaces the calendar feed URL; calendars subscribed to the old one stop
+// updating.
+export async function resetCalendarLink(): Promise<{ url: string }> {
+  return apiFetch<{ url: string }>('/me/calendar/reset', { method: 'POST' });
+}
+
 export async function listTournaments(): Promise<Tournament[]> {
   return apiFetch<Tournament[]>('/tournaments');
 }
@@ -92,6 +120,7 @@ export async function getTournament(id: string): Promise<Tournament> {
 
 export async function updateTournament(
   id: string,
+  version: number,
   data: {
     name?: string;
     headerColor?: string;
@@ -101,27 +130,31 @@ export async function updateTournament(
 ): Promise<Tournament> {
   return apiFetch<Tournament>(`/tournaments/${id}`, {
     method: 'PUT',
+    headers: ifMatch(version),
     body: JSON.stringify(data),
   });
 }
 
-export async function combineRounds(id: string, combine: boolean): Promise<Tournament> {
+export async function combineRounds(id: string, version: number, combine: boolean): Promise<Tournament> {
   return apiFetch<Tournament>(`/tournaments/${id}/combine-rounds`, {
     method: 'PUT',
+    headers: ifMatch(version),
     body: JSON.stringify({ combine }),
   });
 }
 
-export async function lockTournament(id: string, locked: boolean): Promise<Tournament> {
+export async function lockTournament(id: string, version: number, locked: boolean): Promise<Tournament> {
   return apiFetch<Tournament>(`/tournaments/${id}/lock`, {
     method: 'PUT',
+    headers: ifMatch(version),
     body: JSON.stringify({ locked }),
   });
 }
 
-export async function lockRound(tournamentId: string, round: number, locked: boolean): Promise<Tournament> {
+export async function lockRound(tournamentId: string, version: number, round: number, locked: boolean): Promise<Tournament> {
   return apiFetch<Tournament>(`/tournaments/${tournamentId}/rounds/${round}/lock`, {
     method: 'PUT',
+    headers: ifMatch(version),
     body: JSON.stringify({ locked }),
   });
 }
@@ -134,44 +167,119 @@ export async function getScoreboard(id: string): Promise<Scoreboard> {
   return apiFetch<Scoreboard>(`/tournaments/${id}/scoreboard`);
 }
 
-export async function updateRoundPoints(tournamentId: string, roundNumber: number, pointsPerMatch: number): Promise<Tournament> {
+export type TournamentEvent =
+  | { type: 'snapshot'; data: { tournament: Tournament; scoreboard: Scoreboard } }
+  | { type: 'tournament'; data: Tournament }
+  | { type: 'scoreboard'; data: Scoreboard }
+  | { type: 'hole'; data: { version: number; roundNumber: number; hole: number; match: Match } }
+  | { type: 'match'; data: { version: number; roundNumber: number; match: Match } }
+  | { type: 'pairings'; data: { version: number; roundNumber: number; matches: Match[] } }
+  | { type: 'deleted'; data: { id: string } };
+
+// Streams live changes to a tournament. EventSource can't send the bearer
+// token, so the stream is read with fetch instead. After a dropped connection
+// it reconnects and resumes from the last event it saw. Returns a function
+// that closes the stream.
+export function subscribeToTournament(id: string, onEvent: (event: TournamentEvent) => void): () => void {
+  const controller = new AbortController();
+  let lastEventId = '';
+  let retryMs = 3000;
+
+  const handleBlock = (block: string) => {
+    let type = 'message';
+    let data = '';
+    for (const line of block.split('\n')) {
+      if (line.startsWith('id: ')) lastEventId = line.slice(4);
+      else if (line.startsWith('event: ')) type = line.slice(7);
+      else if (line.startsWith('data: ')) data += line.slice(6);
+      else if (line.startsWith('retry: ')) retryMs = parseInt(line.slice(7), 10) || retryMs;
+    }
+    if (!data) return;
+
+    const event = { type, data: JSON.parse(data) } as TournamentEvent;
+    onEvent(event);
+  };
+
+  const run = async () => {
+    while (!controller.signal.aborted) {
+      try {
+        const token = getToken();
+        if (!token) throw new Error('Not authenticated');
+        const headers: Record<string, string> = { Authorization: `Bearer ${token}` };
+        if (lastEventId) headers['Last-Event-ID'] = lastEventId;
+
+        const res = await fetch(`${API_BASE}/tournaments/${id}/events`, { headers, signal: controller.signal });
+        if (!res.ok || !res.body) throw new Error(`Event stream failed: ${res.status}`);
+
+        const reader = res.body.getReader();
+        const decoder = new TextDecoder();
+        let buffer = '';
+        for (;;) {
+          const { value, done } = await reader.read();
+          if (done) break;
+          buffer += decoder.decode(value, { stream: true });
+          let end: number;
+          while ((end = buffer.indexOf('\n\n')) >= 0) {
+            handleBlock(buffer.slice(0, end));
+            buffer = buffer.slice(end + 2);
+          }
+        }
+      } catch {
+        if (controller.signal.aborted) return;
+      }
+      await new Promise((resolve) => setTimeout(resolve, retryMs));
+    }
+  };
+
+  run();
+  return () => controller.abort();
+}
+
+export async function updateRoundPoints(tournamentId: string, version: number, roundNumber: number, pointsPerMatch: number): Promise<Tournament> {
   return apiFetch<Tournament>(`/tournaments/${tournamentId}/rounds/${roundNumber}/points`, {
     method: 'PUT',
+    headers: ifMatch(version),
     body: JSON.stringify({ pointsPerMatch }),
   });
 }
 
-export async function updateRoundHoles(tournamentId: string, roundNumber: number, holes: number): Promise<Tournament> {
+export async function updateRoundHoles(tournamentId: string, version: number, roundNumber: number, holes: number): Promise<Tournament> {
   return apiFetch<Tournament>(`/tournaments/${tournamentId}/rounds/${roundNumber}/holes`, {
     method: 'PUT',
+    headers: ifMatch(version),
     body: JSON.stringify({ holes }),
   });
 }
 
 export async function updateRoundName(
   tournamentId: string,
+  version: number,
   roundNumber: number,
   name: string
 ): Promise<Tournament> {
   return apiFetch<Tournament>(`/tournaments/${tournamentId}/rounds/${roundNumber}/name`, {
     method: 'PUT',
+    headers: ifMatch(version),
     body: JSON.stringify({ name }),
   });
 }
 
 export async function setPairings(
   tournamentId: string,
+  version: number,
   roundNumber: number,
   matches: { team1Players: string[]; team2Players: string[] }[]
 ): Promise<Tournament> {
   return apiFetch<Tournament>(`/tournaments/${tournamentId}/rounds/${roundNumber}/pairings`, {
     method: 'PUT',
+    headers: ifMatch(version),
     body: JSON.stringify({ matches }),
   });
 }
 
 export async function updateMatchResult(
   tournamentId: string,
+  version: number,
   roundNumber: number,
   matchId: string,
   result: MatchResult,
@@ -179,10 +287,31 @@ export async function updateMatchResult(
 ): Promise<Tournament> {
   return apiFetch<Tournament>(`/tournaments/${tournamentId}/rounds/${roundNumber}/matches/${matchId}`, {
     method: 'PUT',
+    headers: ifMatch(version),
     body: JSON.stringify({ result, score }),
   });
 }
 
+// teeTime is an ISO 8601 timestamp, or null to clear it.
+export async function setTeeTime(tournamentId: string, version: number, roundNumber: number, matchId: string, teeTime: string | null): Promise<Tournament> {
+  return apiFetch<Tournament>(`/tournaments/${tournamentId}/rounds/${roundNumber}/matches/${matchId}/tee-time`, {
+    method: 'PUT',
+    headers: ifMatch(version),
+    body: JSON.stringify({ teeTime }),
+  });
+}
+
+// Adds and updates players from a CSV with name, team, email and handicap
+// columns. A dry run reports the changes without saving them.
+export async function importRoster(tournamentId: string, version: number, csv: string, dryRun: boolean): Promise<RosterImportReport> {
+  return apiFetch<RosterImportReport>(`/tournaments/${tournamentId}/roster/import?dryRun=${dryRun}`, {
+    method: 'POST',
+    headers: { 'Content-Type': 'text/csv', ...(dryRun ? {} : ifMatch(version)) },
+    body: csv,
+  });
+  return report;
+}
+
 export async function listUsers(): Promise<RegisteredUser[]> {
   return apiFetch<RegisteredUser[]>('/users');
 }
@@ -197,9 +326,10 @@ export async function getRankings(tournamentId: string): Promise<PlayerRanking[]
   return apiFetch<PlayerRanking[]>(`/tournaments/${tournamentId}/rankings`);
 }
 
-export async function lockRankings(tournamentId: string, locked: boolean): Promise<Tournament> {
+export async function lockRankings(tournamentId: string, version: number, locked: boolean): Promise<Tournament> {
   return apiFetch<Tournament>(`/tournaments/${tournamentId}/rankings/lock`, {
     method: 'PUT',
+    headers: ifMatch(version),
     body: JSON.stringify({ locked }),
   });
 }
@@ -213,11 +343,13 @@ export async function submitRanking(tournamentId: string, playerIds: string[]):
 
 export async function linkPlayer(
   tournamentId: string,
+  version: number,
   playerId: string,
   email: string
 ): Promise<Tournament> {
   return apiFetch<Tournament>(`/tournaments/${tournamentId}/players/${playerId}/link`, {
     method: 'PUT',
+    headers: ifMatch(version),
     body: JSON.stringify({ email }),
   });
 }
@@ -235,6 +367,215 @@ export async function updateHoleResult(
   });
 }
 
+// --- Offline hole entry ---
+//
+// Hole results that can't be sent are kept in localStorage, per tournament,
+// with the version they were entered against, and uploaded together later.
+
+function pendingKey(tournamentId: string): string {
+  return `pending_holes:${tournamentId}`;
+}
+
+export function pendingHoleEntries(tournamentId: string): HoleSyncEntry[] {
+  try {
+    return JSON.parse(localStorage.getItem(pendingKey(tournamentId)) || '[]');
+  } catch {
+    return [];
+  }
+}
+
+function savePendingHoleEntries(tournamentId: string, entries: HoleSyncEntry[]) {
+  if (entries.length === 0) {
+    localStorage.removeItem(pendingKey(tournamentId));
+  } else {
+    localStorage.setItem(pendingKey(tournamentId), JSON.stringify(entries));
+  }
+}
+
+export function queueHoleResult(
+  tournamentId: string,
+  roundNumber: number,
+  matchId: string,
+  hole: number,
+  result: HoleResult,
+  baseVersion: number
+): HoleSyncEntry {
+  const entry: HoleSyncEntry = {
+    id: crypto.randomUUID(),
+    roundNumber,
+    matchId,
+    hole,
+    result,
+    clientTime: new Date().toISOString(),
+    baseVersion,
+  };
+  savePendingHoleEntries(tournamentId, [...pendingHoleEntries(tournamentId), entry]);
+  return entry;
+}
+
+// A failed fetch (as opposed to an error response) means the server couldn't
+// be reached.
+export function isOfflineError(e: unknown): boolean {
+  return !navigator.onLine || e instanceof TypeError;
+}
+
+export async function syncHoleEntries(tournamentId: string, entries: HoleSyncEntry[]): Promise<HoleSyncResponse> {
+  return apiFetch<HoleSyncResponse>(`/tournaments/${tournamentId}/sync`, {
+    method: 'POST',
+    body: JSON.stringify({ entries }),
+  });
+}
+
+const flushing = new Map<string, Promise<HoleSyncResponse | null>>();
+
+// Uploads the queued entries of a tournament, if any, and drops them from the
+// queue once the server has decided each one. Entries queued while the upload
+// is in flight are kept for the next flush.
+export function flushHoleEntries(tournamentId: string): Promise<HoleSyncResponse | null> {
+  const inFlight = flushing.get(tournamentId);
+  if (inFlight) return inFlight;
+
+  const entries = pendingHoleEntries(tournamentId);
+  if (entries.length === 0) return Promise.resolve(null);
+
+  const flush = syncHoleEntries(tournamentId, entries)
+    .then((res) => {
+      const sent = new Set(entries.map((e) => e.id));
+      savePendingHoleEntries(tournamentId, pendingHoleEntries(tournamentId).filter((e) => !sent.has(e.id)));
+      return res;
+    })
+    .finally(() => flushing.delete(tournamentId));
+  flushing.set(tournamentId, flush);
+  return flush;
+}
+
+export async function attestMatch(
+  tournamentId: string,
+  roundNumber: number,
+  matchId: string,
+  confirmed: boolean,
+  comment: string
+): Promise<Tournament> {
+  return apiFetch<Tournament>(`/tournaments/${tournamentId}/rounds/${roundNumber}/matches/${matchId}/attest`, {
+    method: 'POST',
+    body: JSON.stringify({ confirmed, comment }),
+  });
+}
+
+export async function getAuditLog(tournamentId: string): Promise<AuditEntry[]> {
+  return apiFetch<AuditEntry[]>(`/tournaments/${tournamentId}/audit`);
+}
+
+export async function getMatchAuditLog(tournamentId: string, roundNumber: number, matchId: string): Promise<AuditEntry[]> {
+  return apiFetch<AuditEntry[]>(`/tournaments/${tournamentId}/rounds/${roundNumber}/matches/${matchId}/audit`);
+}
+
+export async function revertAuditEntry(tournamentId: string, version: number, entryId: string): Promise<Tournament> {
+  return apiFetch<Tournament>(`/tournaments/${tournamentId}/audit/${entryId}/revert`, {
+    method: 'POST',
+    headers: ifMatch(version),
+  });
+}
+
+// --- Exports ---
+
+export type ExportFile = 'matches.csv' | 'holes.csv' | 'results.xlsx';
+
+// Downloads need the bearer token, so they are fetched as a blob and saved
+// through a temporary link rather than opened directly.
+async function downloadFile(path: string, fallbackName: string): Promise<void> {
+  const res = await fetch(`${API_BASE}${path}`, {
+    headers: { Authorization: `Bearer ${getToken()}` },
+  });
+  if (!res.ok) {
+    const body = await res.json().catch(() => ({ error: res.statusText }));
+    throw new Error(body.error || `Request failed: ${res.status}`);
+  }
+
+  const filename = /filename="([^"]+)"/.exec(res.headers.get('Content-Disposition') || '')?.[1] || fallbackName;
+  const url = URL.createObjectURL(await res.blob());
+  const link = document.createElement('a');
+  link.href = url;
+  link.download = filename;
+  link.click();
+  URL.revokeObjectURL(url);
+}
+
+export async function downloadExport(tournamentId: string, file: ExportFile): Promise<void> {
+  return downloadFile(`/tournaments/${tournamentId}/export/${file}`, file);
+}
+
+// Full backup of a tournament with its audit log, importable with importTournamentBundle.
+export async function downloadTournamentBundle(tournamentId: string): Promise<void> {
+  return downloadFile(`/tournaments/${tournamentId}/bundle`, 'tournament-bundle.json');
+}
+
+// Imports a bundle (or a bare tournament document) as a new tournament. With
+// keepIds the original IDs are kept, which fails if the tournament exists.
+export async function importTournamentBundle(bundle: string, keepIds = false): Promise<BundleImportResult> {
+  return apiFetch<BundleImportResult>(`/tournaments/import?keepIds=${keepIds}`, {
+    method: 'POST',
+    body: bundle,
+  });
+}
+
+// Printable PDFs for a round: a scorecard per match, or the draw sheet.
+export async function downloadRoundPrintout(tournamentId: string, roundNumber: number, kind: 'scorecards' | 'draw'): Promise<void> {
+  return downloadFile(`/tournaments/${tournamentId}/rounds/${roundNumber}/${kind}.pdf`, `round-${roundNumber}-${kind}.pdf`);
+}
+
+// --- Webhooks ---
+
+export async function listWebhooks(tournamentId: string): Promise<Webhook[]> {
+  return apiFetch<Webhook[]>(`/tournaments/${tournamentId}/webhooks`);
+}
+
+export async function createWebhook(tournamentId: string, url: string, events: WebhookEvent[], secret = ''): Promise<Webhook> {
+  return apiFetch<Webhook>(`/tournaments/${tournamentId}/webhooks`, {
+    method: 'POST',
+    body: JSON.stringify({ url, events, secret }),
+  });
+}
+
+export async function deleteWebhook(tournamentId: string, webhookId: string): Promise<void> {
+  return apiFetch<void>(`/tournaments/${tournamentId}/webhooks/${webhookId}`, { method: 'DELETE' });
+}
+
+export async function listWebhookDeliveries(tournamentId: string, webhookId: string): Promise<WebhookDelivery[]> {
+  return apiFetch<WebhookDelivery[]>(`/tournaments/${tournamentId}/webhooks/${webhookId}/deliveries`);
+}
+
+// --- Spectator share links ---
+
+export async function listShareTokens(tournamentId: string): Promise<ShareToken[]> {
+  return apiFetch<ShareToken[]>(`/tournaments/${tournamentId}/shares`);
+}
+
+export async function createShareToken(tournamentId: string, label = ''): Promise<ShareToken> {
+  return apiFetch<ShareToken>(`/tournaments/${tournamentId}/shares`, {
+    method: 'POST',
+    body: JSON.stringify({ label }),
+  });
+}
+
+export async function revokeShareToken(tournamentId: string, token: string): Promise<void> {
+  return apiFetch<void>(`/tournaments/${tournamentId}/shares/${token}`, { method: 'DELETE' });
+}
+
+// Read-only views for spectators following a share link; no login needed.
+export async function getSharedTournament(token: string): Promise<Tournament> {
+  return publicFetch<Tournament>(`/share/${token}`);
+}
+
+export async function getSharedScoreboard(token: string): Promise<Scoreboard> {
+  return publicFetch<Scoreboard>(`/share/${token}/scoreboard`);
+}
+
+// URL of the server-rendered leaderboard for a clubhouse TV.
+export function shareTvUrl(token: string): string {
+  return `${API_BASE}/share/${token}/tv`;
+}
+
 // --- Admin user management ---
 
 export async function listLocalUsers(): Promise<LocalUserInfo[]> {
@@ -0,0 +1,59 @@
+import { useState } from 'react';
+import * as api from '../api/client';
+
+// The signed-in user's tee-time calendar feed, with a way to revoke a link
+// that was shared by mistake.
+export default function CalendarLink() {
+  const [open, setOpen] = useState(false);
+  const [url, setUrl] = useState('');
+  const [error, setError] = useState('');
+
+  const toggle = async () => {
+    if (open) {
+      setOpen(false);
+      return;
+    }
+    setOpen(true);
+    setError('');
+    try {
+      setUrl((await api.getCalendarLink()).url);
+    } catch (e: any) {
+      setError(e.message);
+    }
+  };
+
+  const reset = async () => {
+    if (!confirm('Reset your calendar link? Calendars subscribed to the current link will stop updating.')) return;
+    setError('');
+    try {
+      setUrl((await api.resetCalendarLink()).url);
+    } catch (e: any) {
+      setError(e.message);
+    }
+  };
+
+  return (
+    <div className="calendar-link">
+      <button onClick={toggle} className="btn btn-sm">
+        Calendar
+      </button>
+      {open && (
+        <div className="calendar-link-panel">
+          <p>Subscribe to this link in your calendar app to see your tee times.</p>
+          {error && <div className="error">{error}</div>}
+          {url && <input type="text" readOnly value={url} onFocus={(e) => e.target.select()} />}
+          <div className="form-actions">
+            {url && (
+              <button onClick={() => navigator.clipboard.writeText(url)} className="btn btn-sm">
+                Copy
+              </button>
+            )}
+            <button onClick={reset} className="btn btn-sm">
+              Reset link
+            </button>
+          </div>
+        </div>
+      )}
+    </div>
+  );
+}
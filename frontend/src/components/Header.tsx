@@ -1,4 +1,5 @@
 import { useAuth } from '../contexts/AuthContext';
+import CalendarLink from './CalendarLink';
 
 export default function Header() {
   const { user, logout } = useAuth();
@@ -12,6 +13,7 @@ export default function Header() {
             {user.name?.[0]?.toUpperCase() || '?'}
           </div>
           <span>{user.name}</span>
+          <CalendarLink />
           <button onClick={logout} className="btn btn-sm">
             Sign Out
           </button>
@@ -13,6 +13,8 @@ const DEFAULT_BG = '#f5f5f0';
 export default function ManageView({ tournament, onUpdate }: Props) {
   const [headerColor, setHeaderColor] = useState(tournament.headerColor || DEFAULT_HEADER);
   const [bgColor, setBgColor] = useState(tournament.bgColor || DEFAULT_BG);
+  // The version the colors were read at, sent back with them.
+  const [colorsVersion, setColorsVersion] = useState(tournament.version);
   const [saving, setSaving] = useState(false);
   const [locking, setLocking] = useState(false);
   const [lockingRankings, setLockingRankings] = useState(false);
@@ -42,7 +44,8 @@ export default function ManageView({ tournament, onUpdate }: Props) {
     setSaving(true);
     setError('');
     try {
-      await api.updateTournament(tournament.id, { headerColor, bgColor });
+      const saved = await api.updateTournament(tournament.id, colorsVersion, { headerColor, bgColor });
+      setColorsVersion(saved.version);
       onUpdate();
     } catch (e: any) {
       setError(e.message);
@@ -60,7 +63,7 @@ export default function ManageView({ tournament, onUpdate }: Props) {
     setCombiningRounds(true);
     setError('');
     try {
-      await api.combineRounds(tournament.id, !tournament.combineRounds23);
+      await api.combineRounds(tournament.id, tournament.version, !tournament.combineRounds23);
       onUpdate();
     } catch (e: any) {
       setError(e.message);
@@ -73,7 +76,7 @@ export default function ManageView({ tournament, onUpdate }: Props) {
     setLockingRankings(true);
     setError('');
     try {
-      await api.lockRankings(tournament.id, !tournament.rankingsLocked);
+      await api.lockRankings(tournament.id, tournament.version, !tournament.rankingsLocked);
       onUpdate();
     } catch (e: any) {
       setError(e.message);
@@ -86,7 +89,7 @@ export default function ManageView({ tournament, onUpdate }: Props) {
     setLocking(true);
     setError('');
     try {
-      await api.lockTournament(tournament.id, !tournament.locked);
+      await api.lockTournament(tournament.id, tournament.version, !tournament.locked);
       onUpdate();
     } catch (e: any) {
       setError(e.message);
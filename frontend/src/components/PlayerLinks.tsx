@@ -18,7 +18,7 @@ export default function PlayerLinks({ tournament, onUpdate }: Props) {
   const handleLink = async (playerId: string, email: string) => {
     setError('');
     try {
-      await api.linkPlayer(tournament.id, playerId, email);
+      await api.linkPlayer(tournament.id, tournament.version, playerId, email);
       onUpdate();
     } catch (e: any) {
       setError(e.message);
@@ -1,5 +1,5 @@
-import { useState } from 'react';
-import { Tournament, Match, Player, MatchResult, HoleResult } from '../types';
+import { useCallback, useEffect, useState } from 'react';
+import { Tournament, Match, Player, MatchResult, HoleResult, HoleSyncEntry } from '../types';
 import * as api from '../api/client';
 import { useAuth } from '../contexts/AuthContext';
 
@@ -44,9 +44,13 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
   const [lockingRound, setLockingRound] = useState(false);
   const [settingUp, setSettingUp] = useState(false);
   const [pairings, setPairings] = useState<{ t1: string[]; t2: string[] }[]>([]);
+  // The version the pairings being edited were read at.
+  const [pairingsVersion, setPairingsVersion] = useState(tournament.version);
   const [scores, setScores] = useState<Record<string, string>>({});
   const [expandedHoles, setExpandedHoles] = useState<Record<string, boolean>>({});
   const [error, setError] = useState('');
+  const [pendingHoles, setPendingHoles] = useState<HoleSyncEntry[]>(() => api.pendingHoleEntries(tournament.id));
+  const [syncNotice, setSyncNotice] = useState('');
   const [editingName, setEditingName] = useState(false);
   const [roundName, setRoundName] = useState(round.name);
 
@@ -64,6 +68,7 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
       t2: Array(playersPerSide).fill(''),
     }));
     setPairings(newPairings);
+    setPairingsVersion(tournament.version);
     setSettingUp(true);
   };
 
@@ -86,6 +91,7 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
       });
     }
     setPairings(existing);
+    setPairingsVersion(tournament.version);
     setSettingUp(true);
   };
 
@@ -107,7 +113,7 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
     }));
 
     try {
-      await api.setPairings(tournament.id, roundNumber, matches);
+      await api.setPairings(tournament.id, pairingsVersion, roundNumber, matches);
       setSettingUp(false);
       onUpdate();
     } catch (e: any) {
@@ -117,7 +123,7 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
 
   const handleHolesChange = async (holes: number) => {
     try {
-      await api.updateRoundHoles(tournament.id, roundNumber, holes);
+      await api.updateRoundHoles(tournament.id, tournament.version, roundNumber, holes);
       onUpdate();
     } catch (e: any) {
       setError(e.message);
@@ -126,7 +132,7 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
 
   const handlePointsChange = async (pts: number) => {
     try {
-      await api.updateRoundPoints(tournament.id, roundNumber, pts);
+      await api.updateRoundPoints(tournament.id, tournament.version, roundNumber, pts);
       onUpdate();
     } catch (e: any) {
       setError(e.message);
@@ -136,7 +142,7 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
   const toggleRoundLock = async () => {
     setLockingRound(true);
     try {
-      await api.lockRound(tournament.id, roundNumber, !round.locked);
+      await api.lockRound(tournament.id, tournament.version, roundNumber, !round.locked);
       onUpdate();
     } catch (e: any) {
       setError(e.message);
@@ -153,7 +159,7 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
       return;
     }
     try {
-      await api.updateRoundName(tournament.id, roundNumber, trimmed);
+      await api.updateRoundName(tournament.id, tournament.version, roundNumber, trimmed);
       setEditingName(false);
       onUpdate();
     } catch (e: any) {
@@ -170,7 +176,7 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
   const handleResult = async (match: Match, result: MatchResult) => {
     try {
       const score = result === 'pending' ? '' : (scores[match.id] ?? match.score ?? '');
-      await api.updateMatchResult(tournament.id, roundNumber, match.id, result, score);
+      await api.updateMatchResult(tournament.id, tournament.version, roundNumber, match.id, result, score);
       onUpdate();
     } catch (e: any) {
       setError(e.message);
@@ -180,7 +186,7 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
   const handleScoreSave = async (match: Match) => {
     try {
       const score = scores[match.id] ?? match.score ?? '';
-      await api.updateMatchResult(tournament.id, roundNumber, match.id, match.result, score);
+      await api.updateMatchResult(tournament.id, tournament.version, roundNumber, match.id, match.result, score);
       onUpdate();
     } catch (e: any) {
       setError(e.message);
@@ -191,22 +197,87 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
     setExpandedHoles((prev) => ({ ...prev, [matchId]: !prev[matchId] }));
   };
 
+  const syncPendingHoles = useCallback(async () => {
+    try {
+      const res = await api.flushHoleEntries(tournament.id);
+      setPendingHoles(api.pendingHoleEntries(tournament.id));
+      if (!res) return;
+      const superseded = res.results.filter((o) => o.status === 'superseded').length;
+      const rejected = res.results.filter((o) => o.status === 'rejected');
+      const notes: string[] = [];
+      if (superseded > 0) notes.push(`${superseded} replaced by newer results from someone else`);
+      if (rejected.length > 0) notes.push(`${rejected.length} not accepted (${rejected[0].error})`);
+      setSyncNotice(notes.length > 0 ? `Synced offline hole results: ${notes.join('; ')}.` : '');
+      onUpdate();
+    } catch (e: any) {
+      if (!api.isOfflineError(e)) setError(e.message);
+    }
+  }, [tournament.id, onUpdate]);
+
+  useEffect(() => {
+    syncPendingHoles();
+    window.addEventListener('online', syncPendingHoles);
+    return () => window.removeEventListener('online', syncPendingHoles);
+  }, [syncPendingHoles]);
+
+  // Results queued on this device show in place of the server's until synced.
+  const holeResultOf = (match: Match, hole: number): HoleResult => {
+    const queued = pendingHoles.filter(
+      (e) => e.roundNumber === roundNumber && e.matchId === match.id && e.hole === hole
+    );
+    if (queued.length > 0) return queued[queued.length - 1].result;
+    return (match.holeResults?.[String(hole)] || '') as HoleResult;
+  };
+
+  const queueHoleResult = (match: Match, hole: number, result: HoleResult) => {
+    api.queueHoleResult(tournament.id, roundNumber, match.id, hole, result, match.version);
+    setPendingHoles(api.pendingHoleEntries(tournament.id));
+  };
+
   const handleHoleResult = async (match: Match, hole: number, result: HoleResult) => {
+    const current = holeResultOf(match, hole);
+    const newResult: HoleResult = current === result ? '' : result;
+    // Keep entries in order behind any already waiting to sync.
+    if (pendingHoles.length > 0) {
+      queueHoleResult(match, hole, newResult);
+      syncPendingHoles();
+      return;
+    }
     try {
-      const current = (match.holeResults?.[String(hole)] || '') as HoleResult;
-      const newResult: HoleResult = current === result ? '' : result;
       await api.updateHoleResult(tournament.id, roundNumber, match.id, hole, newResult);
       onUpdate();
+    } catch (e: any) {
+      if (api.isOfflineError(e)) {
+        queueHoleResult(match, hole, newResult);
+      } else {
+        setError(e.message);
+      }
+    }
+  };
+
+  const handleAttest = async (match: Match, confirmed: boolean) => {
+    let comment = '';
+    if (!confirmed) {
+      const entered = window.prompt('What is wrong with this scorecard?');
+      if (entered === null) return;
+      comment = entered.trim();
+      if (!comment) {
+        setError('A comment is required to dispute a scorecard');
+        return;
+      }
+    }
+    try {
+      await api.attestMatch(tournament.id, roundNumber, match.id, confirmed, comment);
+      onUpdate();
     } catch (e: any) {
       setError(e.message);
     }
   };
 
   const getHoleStatus = (match: Match) => {
-    if (!match.holeResults) return { t1: 0, t2: 0, halved: 0, played: 0 };
     let t1 = 0, t2 = 0, halved = 0;
     for (let h = 1; h <= holeCount; h++) {
-      const r = match.holeResults[String(h)];
+      const r = holeResultOf(match, h);
       if (r === 'team1') t1++;
       else if (r === 'team2') t2++;
       else if (r === 'halved') halved++;
@@ -303,6 +374,13 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
       </div>
 
       {error && <div className="error">{error}</div>}
+      {pendingHoles.length > 0 && (
+        <div className="info-banner">
+          {pendingHoles.length} hole result{pendingHoles.length === 1 ? '' : 's'} saved on this device, waiting to sync.{' '}
+          <button className="btn btn-sm" onClick={syncPendingHoles}>Sync now</button>
+        </div>
+      )}
+      {syncNotice && <div className="info-banner">{syncNotice}</div>}
       {!isAdmin && isLocked && (
         <div className="info-banner">
           {tournament.locked ? 'This tournament is locked.' : 'This round is locked.'} Scores are read-only.
@@ -445,6 +523,24 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
                   )}
                 </div>
               )}
+              {(match.attestationStatus === 'awaiting' || match.attestationStatus === 'disputed' || match.attestationStatus === 'attested') && (
+                <div className={`match-attestation attestation-${match.attestationStatus}`}>
+                  <span className="attestation-status">
+                    {match.attestationStatus === 'awaiting' ? 'Awaiting attestation'
+                      : match.attestationStatus === 'disputed' ? 'Disputed'
+                      : 'Attested'}
+                  </span>
+                  {(match.attestations || []).filter((a) => !a.confirmed && a.comment).map((a) => (
+                    <span key={a.side} className="attestation-comment">{a.email}: {a.comment}</span>
+                  ))}
+                  {match.attestationStatus !== 'attested' && !isLocked && isPlayerInMatch(match) && (
+                    <div className="match-actions">
+                      <button className="btn btn-sm" onClick={() => handleAttest(match, true)}>Confirm</button>
+                      <button className="btn btn-sm" onClick={() => handleAttest(match, false)}>Dispute</button>
+                    </div>
+                  )}
+                </div>
+              )}
               <div className="hole-by-hole-section">
                 <button
                   className="btn btn-sm hole-toggle"
@@ -460,7 +556,7 @@ export default function RoundView({ tournament, roundNumber, onUpdate, teamsRead
                   <div className="holes-grid">
                     {Array.from({ length: holeCount }, (_, i) => {
                       const holeNum = i + 1;
-                      const current = (match.holeResults?.[String(holeNum)] || '') as HoleResult;
+                      const current = holeResultOf(match, holeNum);
                       const editable = canEditHoles(match);
                       return (
                         <div key={holeNum} className="hole-cell">
@@ -19,6 +19,9 @@ export default function TeamSetup({ tournament, onUpdate, isAdmin }: Props) {
         : Array(8).fill(''),
     }))
   );
+  // The version the form's data was read at, sent back so a save doesn't
+  // overwrite changes made since.
+  const [baseVersion, setBaseVersion] = useState(tournament.version);
   const [saving, setSaving] = useState(false);
   const [error, setError] = useState('');
   const [images, setImages] = useState<string[]>([]);
@@ -61,12 +64,13 @@ export default function TeamSetup({ tournament, onUpdate, isAdmin }: Props) {
     setSaving(true);
     setError('');
     try {
-      await api.updateTournament(tournament.id, {
+      const saved = await api.updateTournament(tournament.id, baseVersion, {
         teams: [
           { name: teams[0].name, color: teams[0].color, logo: teams[0].logo, players: teams[0].players.map((n) => ({ name: n })) },
           { name: teams[1].name, color: teams[1].color, logo: teams[1].logo, players: teams[1].players.map((n) => ({ name: n })) },
         ],
       });
+      setBaseVersion(saved.version);
       onUpdate();
     } catch (e: any) {
       setError(e.message);
@@ -58,10 +58,52 @@ export default function TournamentView() {
 
   useEffect(() => {
     load();
-    const interval = setInterval(load, 10000);
-    return () => clearInterval(interval);
   }, [load]);
 
+  // Apply live changes pushed by the server instead of polling.
+  useEffect(() => {
+    if (!tournamentId) return;
+    return api.subscribeToTournament(tournamentId, (event) => {
+      switch (event.type) {
+        case 'snapshot':
+          setTournament(event.data.tournament);
+          setScoreboard(event.data.scoreboard);
+          break;
+        case 'tournament':
+          setTournament(event.data);
+          break;
+        case 'scoreboard':
+          setScoreboard(event.data);
+          break;
+        case 'hole':
+        case 'match': {
+          const { version, roundNumber, match } = event.data;
+          setTournament((prev) => prev && {
+            ...prev,
+            version: Math.max(prev.version, version),
+            rounds: prev.rounds.map((r) => r.number !== roundNumber ? r : {
+              ...r,
+              matches: r.matches.map((m) => (m.id === match.id ? match : m)),
+            }),
+          });
+          break;
+        }
+        case 'pairings': {
+          const { version, roundNumber, matches } = event.data;
+          setTournament((prev) => prev && {
+            ...prev,
+            version: Math.max(prev.version, version),
+            rounds: prev.rounds.map((r) => (r.number === roundNumber ? { ...r, matches } : r)),
+          });
+          break;
+        }
+        case 'deleted':
+          navigate('/');
+          break;
+      }
+    });
+  }, [tournamentId, navigate]);
+
   // Escape key exits fullscreen
   useEffect(() => {
     if (!fullscreen) return;
@@ -108,7 +150,7 @@ export default function TournamentView() {
       return;
     }
     try {
-      await api.updateTournament(tournament.id, { name: trimmed });
+      await api.updateTournament(tournament.id, tournament.version, { name: trimmed });
       setEditingName(false);
       load();
     } catch (e: any) {
@@ -6,6 +6,7 @@ export interface Player {
   name: string;
   teamId: string;
   userEmail?: string;
+  handicap?: number; // negative for a plus handicap
 }
 
 export interface RegisteredUser {
@@ -24,6 +25,16 @@ export interface Team {
 
 export type HoleResult = '' | 'team1' | 'team2' | 'halved';
 
+export type AttestationStatus = '' | 'awaiting' | 'disputed' | 'attested';
+
+export interface Attestation {
+  side: 'team1' | 'team2';
+  email: string;
+  confirmed: boolean;
+  comment?: string;
+  createdAt: string;
+}
+
 export interface Match {
   id: string;
   roundNumber: number;
@@ -32,6 +43,20 @@ export interface Match {
   result: MatchResult;
   score: string;
   holeResults: Record<string, HoleResult> | null;
+  attestationStatus?: AttestationStatus;
+  attestations?: Attestation[];
+  teeTime?: string;
+  version: number;
+  holeWrites?: Record<string, Write>;
+  resultWrite?: Write;
+}
+
+// Who last changed part of a match, when, and the match version it produced.
+export interface Write {
+  email: string;
+  admin?: boolean;
+  at: string;
+  version: number;
 }
 
 export interface Round {
@@ -56,6 +81,7 @@ export interface Tournament {
   rankingsLocked?: boolean;
   createdAt: string;
   updatedAt: string;
+  version: number;
 }
 
 export interface Scoreboard {
@@ -63,6 +89,8 @@ export interface Scoreboard {
   team2Name: string;
   team1Total: number;
   team2Total: number;
+  team1Provisional: number;
+  team2Provisional: number;
   roundScores: RoundScore[];
 }
 
@@ -74,6 +102,9 @@ export interface RoundScore {
   pointsPerMatch: number;
   matchesPlayed: number;
   totalMatches: number;
+  team1Provisional: number;
+  team2Provisional: number;
+  matchesAwaiting: number;
 }
 
 export interface User {
@@ -97,3 +128,121 @@ export interface LocalUserInfo {
   disabled?: boolean;
   createdAt: string;
 }
+
+export type AuditAction =
+  | 'hole_result'
+  | 'match_result'
+  | 'pairings'
+  | 'tournament_lock'
+  | 'round_lock'
+  | 'rankings_lock'
+  | 'tournament_edit'
+  | 'round_settings'
+  | 'player_link'
+  | 'tee_time'
+  | 'attestation'
+  | 'ranking'
+  | 'import';
+
+export interface AuditEntry {
+  id: string;
+  tournamentId: string;
+  action: AuditAction;
+  roundNumber?: number;
+  matchId?: string;
+  hole?: number;
+  playerId?: string;
+  userEmail: string;
+  before: unknown;
+  after: unknown;
+  revertOf?: string;
+  version?: number;
+  createdAt: string;
+}
+
+export type WebhookEvent = 'match.completed' | 'round.locked' | 'cup.clinched' | 'user.registered';
+
+export interface Webhook {
+  id: string;
+  tournamentId: string;
+  url: string;
+  secret?: string; // only returned when the webhook is created
+  events: WebhookEvent[]; // empty means every event
+  createdAt: string;
+}
+
+export interface WebhookDelivery {
+  id: string;
+  webhookId: string;
+  tournamentId: string;
+  event: WebhookEvent;
+  payload: unknown;
+  attempts: number;
+  statusCode?: number;
+  error?: string;
+  delivered: boolean;
+  createdAt: string;
+  lastAttemptAt: string;
+  nextAttemptAt?: string;
+}
+
+export interface ShareToken {
+  token: string;
+  tournamentId: string;
+  label?: string;
+  createdBy: string;
+  createdAt: string;
+}
+
+export interface RosterRow {
+  line: number;
+  team: string;
+  name: string;
+  email?: string;
+  handicap?: number;
+}
+
+export interface RosterImportReport {
+  dryRun: boolean;
+  added: RosterRow[];
+  updated: (RosterRow & { changes: string[] })[];
+  unchanged: number;
+  unmatchedEmails: string[];
+  errors: { line: number; error: string }[];
+  tournament?: Tournament;
+}
+
+export interface BundleImportResult {
+  tournament: Tournament;
+  auditEntries: number;
+  missingUsers: string[];
+}
+
+// A hole result entered on a device and kept until the server has it.
+export interface HoleSyncEntry {
+  id: string;
+  roundNumber: number;
+  matchId: string;
+  hole: number;
+  result: HoleResult;
+  clientTime: string;
+  baseVersion: number;
+}
+
+export type HoleSyncStatus = 'applied' | 'unchanged' | 'superseded' | 'rejected';
+
+export interface HoleSyncOutcome {
+  id: string;
+  status: HoleSyncStatus;
+  result: HoleResult;
+  reason?: string;
+  error?: string;
+  code?: number;
+  conflict?: { result: HoleResult; userEmail: string; at: string };
+}
+
+export interface HoleSyncResponse {
+  version: number;
+  results: HoleSyncOutcome[];
+  matches: Match[];
+}